@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"chat_agent/internal/ai"
+	"chat_agent/internal/config"
+)
+
+// fakeLLMOptions 内置模拟模型服务的命令行参数
+type fakeLLMOptions struct {
+	Enabled   bool
+	Addr      string
+	Script    string
+	Latency   time.Duration
+	FailEvery int
+}
+
+// parseFakeLLMFlags 解析服务启动参数，例如 go run ./cmd/server -fake-llm
+func parseFakeLLMFlags(args []string) (fakeLLMOptions, error) {
+	var options fakeLLMOptions
+	flags := flag.NewFlagSet("server", flag.ContinueOnError)
+	flags.BoolVar(&options.Enabled, "fake-llm", false, "使用内置的模拟模型服务，不需要模型密钥和网络")
+	flags.StringVar(&options.Addr, "fake-llm-addr", "127.0.0.1:8090", "模拟模型服务的监听地址")
+	flags.StringVar(&options.Script, "fake-llm-script", "", "模拟模型的JSON脚本，默认使用内置脚本")
+	flags.DurationVar(&options.Latency, "fake-llm-latency", 0, "模拟模型每个请求额外的延迟，例如500ms")
+	flags.IntVar(&options.FailEvery, "fake-llm-fail-every", 0, "每N个请求注入一次503错误，0表示不注入")
+	err := flags.Parse(args)
+	return options, err
+}
+
+// startFakeLLM 启动内置的模拟模型服务，并把所有模型都路由到该服务。
+// 模拟服务提供OpenAI兼容的接口，调用仍经过真实的客户端、重试和熔断
+func startFakeLLM(cfg *config.Config, options fakeLLMOptions) error {
+	script := ai.DefaultFakeScript()
+	if options.Script != "" {
+		var err error
+		if script, err = ai.LoadFakeScript(options.Script); err != nil {
+			return err
+		}
+	}
+
+	listener, err := net.Listen("tcp", options.Addr)
+	if err != nil {
+		return err
+	}
+	server := ai.NewFakeOpenAIServer(script, ai.FakeServerOptions{Latency: options.Latency, FailEvery: options.FailEvery})
+	go func() {
+		if err := http.Serve(listener, server); err != nil {
+			log.Printf("Warning: fake LLM server stopped: %v", err)
+		}
+	}()
+
+	cfg.LLMProviders = []config.LLMProviderConfig{{
+		Name:    "fake",
+		Type:    "openai_compatible",
+		APIKey:  "fake",
+		BaseURL: "http://" + listener.Addr().String() + "/v1",
+		Models:  []string{"*"},
+	}}
+	log.Printf("Fake LLM server listening on %s, all models are served by it", listener.Addr())
+	return nil
+}
@@ -1,20 +1,39 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
+	"os"
+	"time"
 
 	"chat_agent/internal/ai"
 	"chat_agent/internal/api"
+	"chat_agent/internal/auth"
 	"chat_agent/internal/config"
+	"chat_agent/internal/middleware"
+	"chat_agent/internal/notify"
 	"chat_agent/internal/repository"
 	"chat_agent/internal/service"
 
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 )
 
-func Main() {
+func Main(fakeLLM fakeLLMOptions) {
 	// 加载配置
 	cfg := config.LoadConfig()
+	if err := cfg.EnsureJWTSecret(); err != nil {
+		log.Fatalf("Invalid auth config: %v", err)
+	}
+
+	// 离线开发时用内置的模拟模型服务代替真实的模型提供方
+	if fakeLLM.Enabled {
+		if err := startFakeLLM(cfg, fakeLLM); err != nil {
+			log.Fatalf("Failed to start fake LLM server: %v", err)
+		}
+	}
 
 	// 设置Gin模式
 	if cfg.Environment == "production" {
@@ -37,26 +56,124 @@ func Main() {
 		log.Printf("Warning: Failed to seed data: %v", err)
 	}
 
+	// 限流或记忆使用Redis时初始化Redis连接
+	var redisClient *redis.Client
+	if cfg.RateLimitBackend == "redis" || cfg.MemoryBackend == "redis" {
+		redisClient, err = config.InitRedis(cfg)
+		if err != nil {
+			log.Fatalf("Failed to connect to redis: %v", err)
+		}
+	}
+
+	// 初始化限流器
+	var rateLimiter middleware.RateLimiter = middleware.NewMemoryRateLimiter()
+	if cfg.RateLimitBackend == "redis" {
+		rateLimiter = middleware.NewRedisRateLimiter(redisClient)
+	}
+	rateLimits := api.RateLimits{
+		SendMessage:   middleware.RateLimitMiddleware(rateLimiter, "send_message", middleware.RateLimit{PerMinute: cfg.RateLimitMessageRate, Burst: cfg.RateLimitMessageBurst}),
+		StreamMessage: middleware.RateLimitMiddleware(rateLimiter, "stream_message", middleware.RateLimit{PerMinute: cfg.RateLimitStreamRate, Burst: cfg.RateLimitStreamBurst}),
+		SearchStars:   middleware.RateLimitMiddleware(rateLimiter, "search_stars", middleware.RateLimit{PerMinute: cfg.RateLimitSearchRate, Burst: cfg.RateLimitSearchBurst}),
+		EnhanceStar:   middleware.RateLimitMiddleware(rateLimiter, "enhance_star", middleware.RateLimit{PerMinute: cfg.RateLimitEnhanceRate, Burst: cfg.RateLimitEnhanceBurst}),
+		CreateGuest:   middleware.RateLimitMiddleware(rateLimiter, "create_guest", middleware.RateLimit{PerMinute: cfg.RateLimitGuestRate, Burst: cfg.RateLimitGuestBurst}),
+	}
+
 	// 初始化仓库
 	starRepo := repository.NewStarRepository(db)
 	chatRepo := repository.NewChatRepository(db)
 	messageRepo := repository.NewMessageRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db)
+	resetTokenRepo := repository.NewPasswordResetTokenRepository(db)
+	apiKeyRepo := repository.NewAPIKeyRepository(db)
+	usageRepo := repository.NewUsageRepository(db)
+	identityRepo := repository.NewUserIdentityRepository(db)
+	memoryRepo := repository.NewMemoryRepository(db)
+	profileRepo := repository.NewProfileRepository(db)
+
+	// 初始化认证组件
+	tokenManager := auth.NewTokenManager(cfg.JWTSecret, time.Duration(cfg.AccessTokenTTL)*time.Minute)
+	apiKeyService := service.NewAPIKeyService(apiKeyRepo, userRepo)
 
 	// 初始化AI组件
-	promptBuilder := ai.NewPromptTemplate()
-	llmClient := ai.NewOpenAIClient(cfg.OpenAIAPIKey, cfg.LLMBaseURL, cfg.LLMModel)
-	memoryManager := ai.NewInMemoryManager() // 创建内存记忆管理器
+	promptBuilder := newPromptTemplate(cfg)
+	llmClient := newLLMClient(cfg)
+	memoryManager := newMemoryManager(cfg, redisClient, memoryRepo)
+
+	// 从用户消息中提取事实写入长期记忆
+	var factExtractor ai.FactExtractor
+	switch cfg.FactExtraction {
+	case "llm":
+		factExtractor = ai.NewKeywordFactExtractor(promptBuilder, llmClient, cfg.FactExtractionModel)
+	case "rules":
+		factExtractor = ai.NewKeywordFactExtractor(promptBuilder, nil, "")
+	}
+
+	// 长对话的较早消息压缩为滚动摘要
+	var summarizer *ai.Summarizer
+	if cfg.SummaryEveryMessages > 0 {
+		summarizer = ai.NewSummarizer(llmClient, cfg.SummaryModel, cfg.SummaryEveryMessages)
+	}
 
 	// 初始化服务
+	authService := service.NewAuthService(userRepo, refreshTokenRepo, resetTokenRepo, tokenManager, notify.NewLogNotifier(), service.AuthOptions{
+		RefreshTTL:       time.Duration(cfg.RefreshTokenTTL) * time.Hour,
+		PasswordResetTTL: time.Duration(cfg.PasswordResetTTL) * time.Minute,
+		PasswordResetURL: cfg.PasswordResetURL,
+		MaxLoginAttempts: cfg.LoginMaxAttempts,
+		LockoutDuration:  time.Duration(cfg.LoginLockoutMinutes) * time.Minute,
+	})
 	starService := service.NewStarService(starRepo)
-	chatService := service.NewChatService(chatRepo, messageRepo, starRepo, llmClient, memoryManager, promptBuilder)
+	userService := service.NewUserService(userRepo, refreshTokenRepo)
+
+	// 登录会话和API密钥共用同一个认证中间件，两者都按用户的当前角色授权
+	authMiddleware := middleware.AuthMiddleware(tokenManager, apiKeyService, authService)
+	usageService := service.NewUsageService(usageRepo, service.UsageOptions{
+		DailyTokenQuota:   int64(cfg.DailyTokenQuota),
+		MonthlyTokenQuota: int64(cfg.MonthlyTokenQuota),
+		Prices:            cfg.LLMPrices,
+		Currency:          cfg.LLMPriceCurrency,
+	})
+	guestService := service.NewGuestService(userRepo, messageRepo, tokenManager, memoryManager, service.GuestOptions{
+		TTL:          time.Duration(cfg.GuestTTLHours) * time.Hour,
+		MessageLimit: cfg.GuestMessageLimit,
+	})
+	profileService := service.NewProfileService(profileRepo, starRepo)
+	memoryService := service.NewMemoryService(chatRepo, starRepo, profileRepo, memoryManager)
+	chatService := service.NewChatService(chatRepo, messageRepo, starRepo, llmClient, memoryManager, promptBuilder, usageService, profileService, factExtractor, summarizer, cfg.LLMFallbacks, newGenerationLimits(cfg))
 
 	// 初始化API处理器
-	chatHandler := api.NewChatHandler(chatService)
+	authHandler := api.NewAuthHandler(authService, guestService)
+	chatHandler := api.NewChatHandler(chatService, guestService)
 	starHandler := api.NewStarHandler(starService)
+	userHandler := api.NewUserHandler(userService)
+	apiKeyHandler := api.NewAPIKeyHandler(apiKeyService)
+	usageHandler := api.NewUsageHandler(usageService)
+	profileHandler := api.NewProfileHandler(profileService)
+	memoryHandler := api.NewMemoryHandler(memoryService)
+
+	// 配置了身份提供方时启用单点登录
+	var ssoHandler *api.SSOHandler
+	if cfg.OIDCEnabled() {
+		oidcProvider := auth.NewOIDCProvider(auth.OIDCConfig{
+			IssuerURL:    cfg.OIDCIssuerURL,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  cfg.OIDCRedirectURL,
+			Scopes:       cfg.OIDCScopes,
+		}, nil)
+		ssoService := service.NewSSOService(oidcProvider, tokenManager, userRepo, identityRepo, authService)
+		ssoHandler = api.NewSSOHandler(ssoService, cfg.OIDCPostLoginURL)
+	}
+
+	// 后台清理过期的游客账号
+	go guestService.RunSweeper(context.Background(), time.Duration(cfg.GuestSweepIntervalMinutes)*time.Minute)
+
+	// 后台清理过期的记忆和有效权重过低的长期记忆
+	go ai.RunMemoryGC(context.Background(), memoryManager, time.Duration(cfg.MemoryPruneIntervalMinutes)*time.Minute)
 
 	// 设置路由
-	router := api.SetupRouter(chatHandler, starHandler)
+	router := api.SetupRouter(authHandler, chatHandler, starHandler, userHandler, apiKeyHandler, usageHandler, profileHandler, memoryHandler, api.NewHealthHandler(llmClient), ssoHandler, authMiddleware, rateLimits)
 
 	// 启动服务器
 	serverAddr := cfg.ServerHost + ":" + cfg.ServerPort
@@ -66,7 +183,136 @@ func Main() {
 	}
 }
 
-// main 是命令行入口点
+// newLLMClient 按配置注册模型提供方，返回按模型名称路由的客户端
+func newLLMClient(cfg *config.Config) *ai.LLMRouter {
+	router := ai.NewLLMRouter(cfg.LLMModel)
+	resilience := ai.ResilienceOptions{
+		Timeout:          time.Duration(cfg.LLMTimeout) * time.Second,
+		MaxAttempts:      cfg.LLMMaxAttempts,
+		MaxDelay:         time.Duration(cfg.LLMRetryMaxDelaySeconds) * time.Second,
+		BreakerThreshold: cfg.LLMBreakerThreshold,
+		BreakerCooldown:  time.Duration(cfg.LLMBreakerCooldownSeconds) * time.Second,
+	}
+	for _, provider := range cfg.LLMProviders {
+		var client ai.LLMClient
+		switch provider.Type {
+		case "anthropic":
+			client = ai.NewAnthropicClient(provider.APIKey, provider.BaseURL, "", nil)
+		case "openai", "ark", "openai_compatible":
+			// 豆包和Ollama、vLLM等本地服务都提供OpenAI兼容的接口
+			client = ai.NewOpenAIClient(provider.APIKey, provider.BaseURL, "")
+		default:
+			log.Printf("Warning: unknown LLM provider type %q for %s, skipped", provider.Type, provider.Name)
+			continue
+		}
+		// 每个提供方有独立的重试策略和熔断器
+		client = ai.NewResilientClient(provider.Name, client, resilience)
+		router.Register(ai.LLMProvider{Name: provider.Name, Client: client, Models: provider.Models})
+	}
+	for alias, target := range cfg.LLMModelAliases {
+		router.Alias(alias, target)
+	}
+	return router
+}
+
+// newGenerationLimits 用户在会话和请求中覆盖生成参数时允许的范围
+func newGenerationLimits(cfg *config.Config) service.GenerationLimits {
+	return service.GenerationLimits{
+		MinTemperature: float32(cfg.GenerationMinTemperature),
+		MaxTemperature: float32(cfg.GenerationMaxTemperature),
+		MinTopP:        float32(cfg.GenerationMinTopP),
+		MaxTopP:        float32(cfg.GenerationMaxTopP),
+		MaxTokens:      cfg.GenerationMaxTokens,
+		MinPenalty:     float32(cfg.GenerationMinPenalty),
+		MaxPenalty:     float32(cfg.GenerationMaxPenalty),
+		MaxStop:        cfg.GenerationMaxStop,
+	}
+}
+
+// newPromptTemplate 根据配置创建提示词模板生成器，配置了BPE词表时按词表计算token数
+func newPromptTemplate(cfg *config.Config) *ai.PromptTemplate {
+	var counter ai.TokenCounter = ai.EstimateCounter{}
+	if cfg.TokenizerBPEFile != "" {
+		bpe, err := ai.LoadBPECounter(cfg.TokenizerBPEFile)
+		if err != nil {
+			log.Printf("Warning: failed to load tokenizer %s, estimating tokens instead: %v", cfg.TokenizerBPEFile, err)
+		} else {
+			counter = bpe
+		}
+	}
+	return ai.NewPromptTemplateWithOptions(ai.PromptOptions{
+		Counter:           counter,
+		ContextWindows:    ai.NewContextWindows(cfg.LLMContextWindows, cfg.LLMDefaultContextWindow),
+		CompletionReserve: cfg.LLMCompletionReserve,
+	})
+}
+
+// newMemoryManager 按配置创建记忆管理器（服务和命令行工具共用）
+func newMemoryManager(cfg *config.Config, redisClient *redis.Client, memoryRepo repository.MemoryRepository) ai.MemoryManager {
+	memoryDecay := ai.MemoryDecay{
+		HalfLife:       time.Duration(cfg.MemoryHalfLifeHours) * time.Hour,
+		ReinforceBoost: cfg.MemoryReinforceBoost,
+		MaxWeight:      cfg.MemoryMaxWeight,
+		PruneBelow:     cfg.MemoryPruneBelow,
+	}
+	memoryTTL := ai.MemoryTTL{
+		ShortTerm: time.Duration(cfg.MemoryShortTermTTLHours) * time.Hour,
+		LongTerm:  time.Duration(cfg.MemoryLongTermTTLHours) * time.Hour,
+	}
+	var memoryManager ai.MemoryManager
+	switch cfg.MemoryBackend {
+	case "redis":
+		// 记忆保存在Redis中，重启不丢失且多实例共享
+		memoryManager = ai.NewRedisMemoryManager(redisClient, ai.RedisMemoryOptions{
+			ShortTermCap: cfg.MemoryShortTermCap,
+			LongTermCap:  cfg.MemoryLongTermCap,
+			TTL:          time.Duration(cfg.MemoryRedisTTL) * time.Hour,
+			Decay:        memoryDecay,
+			ItemTTL:      memoryTTL,
+		})
+	case "database":
+		// 记忆持久化在MySQL的memories表中，便于审计和备份
+		memoryManager = ai.NewGormMemoryManager(memoryRepo, cfg.MemoryShortTermCap, cfg.MemoryLongTermCap, memoryDecay, memoryTTL)
+	default:
+		// 默认使用内存记忆管理器，总内存超出预算时淘汰最久未访问的会话
+		memoryManager = ai.NewInMemoryManagerWithOptions(ai.InMemoryOptions{
+			MaxBytes:     int64(cfg.MemoryMaxMB) << 20,
+			ShortTermCap: cfg.MemoryShortTermCap,
+			LongTermCap:  cfg.MemoryLongTermCap,
+			Decay:        memoryDecay,
+			TTL:          memoryTTL,
+		})
+	}
+
+	// 在记忆管理器之上增加语义检索
+	var embedder ai.Embedder
+	switch cfg.EmbeddingBackend {
+	case "openai":
+		embedder = ai.NewOpenAIEmbedder(cfg.OpenAIAPIKey, cfg.LLMBaseURL, cfg.EmbeddingModel)
+	case "hash":
+		embedder = ai.NewHashEmbedder(cfg.EmbeddingDimensions)
+	}
+	if embedder != nil {
+		memoryManager = ai.NewSemanticMemoryManager(memoryManager, embedder, ai.NewVectorIndex(cfg.MemoryLongTermCap), cfg.MemoryRecallMinScore)
+	}
+
+	return memoryManager
+}
+
+// main 是命令行入口点，"memory"子命令用于导出和导入记忆，-fake-llm 使用内置的模拟模型服务
 func main() {
-	Main()
+	if len(os.Args) > 1 && os.Args[1] == "memory" {
+		if err := runMemoryCommand(os.Args[2:]); err != nil {
+			log.Fatalf("memory: %v", err)
+		}
+		return
+	}
+	fakeLLM, err := parseFakeLLMFlags(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		os.Exit(2)
+	}
+	Main(fakeLLM)
 }
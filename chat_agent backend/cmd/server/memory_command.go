@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"chat_agent/internal/ai"
+	"chat_agent/internal/config"
+	"chat_agent/internal/repository"
+	"chat_agent/internal/service"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// memoryCommandUsage 记忆子命令的用法
+const memoryCommandUsage = `用法:
+  server memory export (-chat ID | -user ID) [-o 文件]            导出会话或用户的记忆快照，默认输出到标准输出
+  server memory import (-chat ID | -user ID) [-replace] [-i 文件]  导入记忆快照，默认从标准输入读取；-replace 替换已有记忆（从快照恢复）`
+
+// runMemoryCommand 执行记忆导出、导入子命令，直接读写配置的记忆存储（redis或database）
+func runMemoryCommand(args []string) error {
+	if len(args) == 0 || (args[0] != "export" && args[0] != "import") {
+		return errors.New(memoryCommandUsage)
+	}
+	action := args[0]
+
+	flags := flag.NewFlagSet("memory "+action, flag.ContinueOnError)
+	chatID := flags.Uint("chat", 0, "会话ID")
+	userID := flags.Uint("user", 0, "用户ID")
+	output := flags.String("o", "", "导出的快照文件")
+	input := flags.String("i", "", "导入的快照文件")
+	replace := flags.Bool("replace", false, "替换已有记忆（从快照恢复）")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if (*chatID == 0) == (*userID == 0) {
+		return errors.New("需要指定 -chat 或 -user 其中之一\n" + memoryCommandUsage)
+	}
+
+	cfg := config.LoadConfig()
+	if cfg.MemoryBackend != "redis" && cfg.MemoryBackend != "database" {
+		return errors.New("进程内记忆只能通过服务的HTTP接口导出导入，请设置 MEMORY_BACKEND=redis 或 database")
+	}
+
+	db, err := config.InitDatabase(cfg)
+	if err != nil {
+		return fmt.Errorf("connect database: %w", err)
+	}
+	var redisClient *redis.Client
+	if cfg.MemoryBackend == "redis" {
+		if redisClient, err = config.InitRedis(cfg); err != nil {
+			return fmt.Errorf("connect redis: %w", err)
+		}
+	}
+
+	chatRepo := repository.NewChatRepository(db)
+	memoryService := service.NewMemoryService(chatRepo, repository.NewStarRepository(db),
+		repository.NewProfileRepository(db), newMemoryManager(cfg, redisClient, repository.NewMemoryRepository(db)))
+
+	ctx := context.Background()
+
+	// 命令行以会话所属用户的身份导出导入
+	owner := *userID
+	if *chatID != 0 {
+		chat, err := chatRepo.GetByID(ctx, *chatID)
+		if err != nil {
+			return fmt.Errorf("load chat %d: %w", *chatID, err)
+		}
+		owner = chat.UserID
+	} else if _, err := repository.NewUserRepository(db).GetByID(ctx, owner); err != nil {
+		return fmt.Errorf("load user %d: %w", owner, err)
+	}
+
+	if action == "export" {
+		var snapshot *ai.MemorySnapshot
+		if *chatID != 0 {
+			snapshot, err = memoryService.ExportChat(ctx, owner, *chatID)
+		} else {
+			snapshot, err = memoryService.ExportUser(ctx, owner)
+		}
+		if err != nil {
+			return err
+		}
+		data, err := ai.SerializeMemory(snapshot)
+		if err != nil {
+			return err
+		}
+		if *output == "" {
+			_, err = fmt.Println(data)
+			return err
+		}
+		return os.WriteFile(*output, []byte(data+"\n"), 0o600)
+	}
+
+	var data []byte
+	if *input == "" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(*input)
+	}
+	if err != nil {
+		return err
+	}
+	snapshot, err := ai.DeserializeMemory(string(data))
+	if err != nil {
+		return err
+	}
+
+	if *chatID != 0 {
+		if err := memoryService.ImportChat(ctx, owner, *chatID, snapshot, *replace); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "已导入会话%d的记忆\n", *chatID)
+		return nil
+	}
+	imported, err := memoryService.ImportUser(ctx, owner, snapshot, *replace)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "已为用户%d导入%d个会话的记忆\n", owner, imported)
+	return nil
+}
@@ -0,0 +1,274 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	ark "github.com/sashabaranov/go-openai"
+)
+
+// anthropicVersion 调用Messages API时使用的接口版本
+const anthropicVersion = "2023-06-01"
+
+// anthropicMaxTokens Messages API必须指定回复的最大token数，未配置时使用该值
+const anthropicMaxTokens = 1024
+
+// AnthropicClient 调用Anthropic Messages API的大语言模型客户端
+type AnthropicClient struct {
+	apiKey     string
+	baseURL    string
+	model      string
+	httpClient *http.Client
+}
+
+// NewAnthropicClient 创建新的Anthropic客户端，httpClient为nil时使用默认客户端
+func NewAnthropicClient(apiKey, baseURL, model string, httpClient *http.Client) *AnthropicClient {
+	if baseURL == "" {
+		baseURL = "https://api.anthropic.com"
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	return &AnthropicClient{
+		apiKey:     apiKey,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		model:      model,
+		httpClient: httpClient,
+	}
+}
+
+// anthropicMessage Messages API的一条消息
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// anthropicRequest Messages API的请求体，不支持presence/frequency penalty和seed
+type anthropicRequest struct {
+	Model         string             `json:"model"`
+	MaxTokens     int                `json:"max_tokens"`
+	System        string             `json:"system,omitempty"`
+	Messages      []anthropicMessage `json:"messages"`
+	Stream        bool               `json:"stream,omitempty"`
+	Temperature   *float32           `json:"temperature,omitempty"`
+	TopP          *float32           `json:"top_p,omitempty"`
+	StopSequences []string           `json:"stop_sequences,omitempty"`
+}
+
+// anthropicUsage Messages API返回的token用量
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// anthropicResponse Messages API的非流式响应
+type anthropicResponse struct {
+	Model   string `json:"model"`
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Usage anthropicUsage `json:"usage"`
+}
+
+// anthropicEvent Messages API流式响应中的一个事件
+type anthropicEvent struct {
+	Type    string `json:"type"`
+	Message struct {
+		Model string         `json:"model"`
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+	Usage anthropicUsage `json:"usage"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// GenerateResponse 生成非流式响应
+func (c *AnthropicClient) GenerateResponse(ctx context.Context, messages []map[string]string, options GenerateOptions) (*Completion, error) {
+	useModel := c.useModel(options.Model)
+	resp, err := c.send(ctx, messages, useModel, options, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result anthropicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode anthropic response: %w", err)
+	}
+
+	var content strings.Builder
+	for _, block := range result.Content {
+		if block.Type == "text" {
+			content.WriteString(block.Text)
+		}
+	}
+	if content.Len() == 0 {
+		return nil, fmt.Errorf("no response content received")
+	}
+	return &Completion{
+		Content: content.String(),
+		Model:   responseModel(result.Model, useModel),
+		Usage:   anthropicTokenUsage(result.Usage, messages, content.String()),
+	}, nil
+}
+
+// GenerateStreamResponse 生成流式响应
+func (c *AnthropicClient) GenerateStreamResponse(ctx context.Context, messages []map[string]string, options GenerateOptions, callback func(string) error) (*Completion, error) {
+	useModel := c.useModel(options.Model)
+	resp, err := c.send(ctx, messages, useModel, options, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var content strings.Builder
+	var usage anthropicUsage
+	respModel := ""
+
+	// 服务端推送的事件格式为"event: 类型"和"data: JSON"，只需要解析data行
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var event anthropicEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			continue
+		}
+
+		switch event.Type {
+		case "message_start":
+			respModel = event.Message.Model
+			usage.InputTokens = event.Message.Usage.InputTokens
+		case "content_block_delta":
+			if event.Delta.Type != "text_delta" || event.Delta.Text == "" {
+				continue
+			}
+			content.WriteString(event.Delta.Text)
+			if err := callback(event.Delta.Text); err != nil {
+				return nil, fmt.Errorf("callback error: %w", err)
+			}
+		case "message_delta":
+			usage.OutputTokens = event.Usage.OutputTokens
+		case "message_stop":
+			return &Completion{
+				Content: content.String(),
+				Model:   responseModel(respModel, useModel),
+				Usage:   anthropicTokenUsage(usage, messages, content.String()),
+			}, nil
+		case "error":
+			return nil, fmt.Errorf("anthropic stream error: %s: %s", event.Error.Type, event.Error.Message)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("anthropic stream error: %w", err)
+	}
+	return nil, fmt.Errorf("anthropic stream ended unexpectedly")
+}
+
+// useModel 使用传入的模型或客户端配置的模型
+func (c *AnthropicClient) useModel(model string) string {
+	if model != "" {
+		return model
+	}
+	return c.model
+}
+
+// send 发送Messages API请求，非2xx响应作为错误返回
+func (c *AnthropicClient) send(ctx context.Context, messages []map[string]string, model string, options GenerateOptions, stream bool) (*http.Response, error) {
+	system, converted := convertAnthropicMessages(messages)
+	maxTokens := anthropicMaxTokens
+	if options.MaxTokens != nil {
+		maxTokens = *options.MaxTokens
+	}
+	body, err := json.Marshal(anthropicRequest{
+		Model:         model,
+		MaxTokens:     maxTokens,
+		System:        system,
+		Messages:      converted,
+		Stream:        stream,
+		Temperature:   options.Temperature,
+		TopP:          options.TopP,
+		StopSequences: options.Stop,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", c.apiKey)
+	req.Header.Set("anthropic-version", anthropicVersion)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("anthropic request: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("anthropic error: %w", &APIError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+			Message:    strings.TrimSpace(string(detail)),
+		})
+	}
+	return resp, nil
+}
+
+// convertAnthropicMessages 转换为Messages API的格式：系统消息合并为system参数，
+// 相邻的同角色消息合并为一条，第一条消息必须来自用户
+func convertAnthropicMessages(messages []map[string]string) (string, []anthropicMessage) {
+	var system []string
+	var converted []anthropicMessage
+	for _, msg := range messages {
+		role, content := msg["role"], msg["content"]
+		if role == "system" {
+			system = append(system, content)
+			continue
+		}
+		if role != "assistant" {
+			role = "user"
+		}
+		if len(converted) == 0 && role == "assistant" {
+			converted = append(converted, anthropicMessage{Role: "user", Content: "（对话开始）"})
+		}
+		if last := len(converted) - 1; last >= 0 && converted[last].Role == role {
+			converted[last].Content += "\n\n" + content
+			continue
+		}
+		converted = append(converted, anthropicMessage{Role: role, Content: content})
+	}
+	return strings.Join(system, "\n\n"), converted
+}
+
+// anthropicTokenUsage 转换token用量，服务端未返回时按文本长度估算
+func anthropicTokenUsage(usage anthropicUsage, messages []map[string]string, content string) TokenUsage {
+	if usage.InputTokens+usage.OutputTokens == 0 {
+		return usageOrEstimate(ark.Usage{}, messages, content)
+	}
+	return TokenUsage{
+		PromptTokens:     usage.InputTokens,
+		CompletionTokens: usage.OutputTokens,
+		TotalTokens:      usage.InputTokens + usage.OutputTokens,
+	}
+}
@@ -0,0 +1,81 @@
+package ai
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultContextWindows 常见模型的上下文长度（token），键为完整的模型名称或"前缀*"
+var DefaultContextWindows = map[string]int{
+	"gpt-3.5-turbo*": 16385,
+	"gpt-4":          8192,
+	"gpt-4-32k*":     32768,
+	"gpt-4-turbo*":   128000,
+	"gpt-4o*":        128000,
+	"gpt-4.1*":       1047576,
+	"o1*":            200000,
+	"o3*":            200000,
+	"claude-*":       200000,
+}
+
+// windowSizeHint 模型名称中标注的上下文长度，例如doubao-1.5-pro-32k-250115
+var windowSizeHint = regexp.MustCompile(`-(\d+)k(?:-|$)`)
+
+// ContextWindows 按模型名称查找上下文长度
+type ContextWindows struct {
+	windows       map[string]int
+	defaultWindow int
+}
+
+// NewContextWindows 创建上下文长度表，overrides覆盖DefaultContextWindows中的同名项，未知模型使用defaultWindow
+func NewContextWindows(overrides map[string]int, defaultWindow int) *ContextWindows {
+	windows := make(map[string]int, len(DefaultContextWindows)+len(overrides))
+	for pattern, size := range DefaultContextWindows {
+		windows[pattern] = size
+	}
+	for pattern, size := range overrides {
+		if size > 0 {
+			windows[pattern] = size
+		}
+	}
+	if defaultWindow <= 0 {
+		defaultWindow = 8192
+	}
+	return &ContextWindows{windows: windows, defaultWindow: defaultWindow}
+}
+
+// Lookup 模型的上下文长度：先按完整名称，再按最长的前缀匹配，再按名称中标注的长度（如"-32k"），都没有时使用默认值。
+// "提供方/模型"形式的名称按其中的模型名称查找
+func (w *ContextWindows) Lookup(model string) int {
+	if size, ok := w.match(model); ok {
+		return size
+	}
+	if _, upstream, found := strings.Cut(model, "/"); found {
+		if size, ok := w.match(upstream); ok {
+			return size
+		}
+		model = upstream
+	}
+	if hint := windowSizeHint.FindStringSubmatch(model); hint != nil {
+		if k, err := strconv.Atoi(hint[1]); err == nil && k > 0 {
+			return k * 1024
+		}
+	}
+	return w.defaultWindow
+}
+
+// match 按完整名称或最长的前缀查找
+func (w *ContextWindows) match(model string) (int, bool) {
+	if size, ok := w.windows[model]; ok {
+		return size, true
+	}
+	best, bestLen := 0, -1
+	for pattern, size := range w.windows {
+		prefix, isPrefix := strings.CutSuffix(pattern, "*")
+		if isPrefix && strings.HasPrefix(model, prefix) && len(prefix) > bestLen {
+			best, bestLen = size, len(prefix)
+		}
+	}
+	return best, bestLen >= 0
+}
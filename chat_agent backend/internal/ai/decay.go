@@ -0,0 +1,99 @@
+package ai
+
+import (
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// minMemoryWeight 计算对数时使用的最小权重，避免权重为0时得到负无穷
+const minMemoryWeight = 1e-6
+
+// pinnedRankScore 置顶记忆的排序分数，高于任何未置顶记忆，因此总是排在最前且不会被淘汰或清理
+const pinnedRankScore = 1e300
+
+// MemoryDecay 长期记忆的衰减与强化策略：记忆的有效权重随时间按半衰期衰减，
+// 被回复引用时获得强化并重新开始衰减，有效权重过低的记忆在后台被清理
+type MemoryDecay struct {
+	HalfLife       time.Duration // 半衰期，0表示不衰减
+	ReinforceBoost float64       // 每次被引用时增加的权重
+	MaxWeight      float64       // 强化后的权重上限，0表示不限制
+	PruneBelow     float64       // 有效权重低于该值的记忆会被清理，0表示不清理
+}
+
+// EffectiveWeight 计算记忆在now时刻的有效权重，weight为updatedAt时刻的权重
+func (d MemoryDecay) EffectiveWeight(weight float64, updatedAt, now time.Time) float64 {
+	if d.HalfLife <= 0 || !now.After(updatedAt) {
+		return weight
+	}
+	return weight * math.Pow(0.5, now.Sub(updatedAt).Seconds()/d.HalfLife.Seconds())
+}
+
+// Reinforce 计算记忆在now时刻被引用后的新权重
+func (d MemoryDecay) Reinforce(weight float64, updatedAt, now time.Time) float64 {
+	reinforced := d.EffectiveWeight(weight, updatedAt, now) + d.ReinforceBoost
+	if d.MaxWeight > 0 && reinforced > d.MaxWeight {
+		reinforced = d.MaxWeight
+	}
+	return reinforced
+}
+
+// RankScore 计算记忆的排序分数：ln(weight) + updatedAt·ln2/半衰期。
+// 分数与当前时间无关，任意时刻按分数排序都等价于按有效权重排序，因此可以直接保存在
+// Redis有序集合和数据库中用于排序、淘汰和清理
+func (d MemoryDecay) RankScore(weight float64, updatedAt time.Time) float64 {
+	score := math.Log(math.Max(weight, minMemoryWeight))
+	if d.HalfLife > 0 {
+		score += d.timeOffset(updatedAt)
+	}
+	return score
+}
+
+// WeightFromScore 由排序分数反推记忆在now时刻的有效权重
+func (d MemoryDecay) WeightFromScore(score float64, now time.Time) float64 {
+	if d.HalfLife > 0 {
+		score -= d.timeOffset(now)
+	}
+	return math.Exp(score)
+}
+
+// PruneScore 清理阈值对应的排序分数，分数低于该值的记忆在now时刻的有效权重低于PruneBelow
+func (d MemoryDecay) PruneScore(now time.Time) float64 {
+	return d.RankScore(d.PruneBelow, now)
+}
+
+// timeOffset 排序分数中的时间项
+func (d MemoryDecay) timeOffset(at time.Time) float64 {
+	return float64(at.UnixNano()) / float64(d.HalfLife) * math.Ln2
+}
+
+// referenceThreshold 记忆的特征有多大比例出现在回复中时认为记忆被引用
+const referenceThreshold = 0.5
+
+// MemoryReferenced 判断回复是否引用了记忆：记忆中的双字词和单词有一半以上出现在回复中。
+// 记忆开头的"用户"只是主语，不参与判断
+func MemoryReferenced(memory, reply string) bool {
+	memory = strings.TrimPrefix(strings.TrimSpace(memory), "用户")
+
+	var features []string
+	for _, feature := range textFeatures(memory) {
+		// 单个汉字太常见，只使用双字词和非中文单词
+		if utf8.RuneCountInString(feature) == 1 && feature[0] >= utf8.RuneSelf {
+			continue
+		}
+		features = append(features, feature)
+	}
+	if len(features) == 0 {
+		return false
+	}
+
+	reply = strings.ToLower(reply)
+	matched := 0
+	for _, feature := range features {
+		if strings.Contains(reply, feature) {
+			matched++
+		}
+	}
+	return float64(matched)/float64(len(features)) >= referenceThreshold
+}
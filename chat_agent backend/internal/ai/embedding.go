@@ -0,0 +1,175 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+
+	ark "github.com/sashabaranov/go-openai"
+)
+
+// Embedder 文本向量化接口
+type Embedder interface {
+	// Embed 将多段文本转换为向量，返回的向量与输入一一对应
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// OpenAIEmbedder 调用OpenAI兼容的embeddings接口生成向量
+type OpenAIEmbedder struct {
+	client *ark.Client
+	model  string
+}
+
+// NewOpenAIEmbedder 创建新的OpenAI兼容向量化客户端
+func NewOpenAIEmbedder(apiKey, baseURL, model string) *OpenAIEmbedder {
+	config := ark.DefaultConfig(apiKey)
+	config.BaseURL = baseURL
+	if model == "" {
+		model = string(ark.SmallEmbedding3)
+	}
+	return &OpenAIEmbedder{
+		client: ark.NewClientWithConfig(config),
+		model:  model,
+	}
+}
+
+// Embed 将多段文本转换为向量
+func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	resp, err := e.client.CreateEmbeddings(ctx, ark.EmbeddingRequest{
+		Input: texts,
+		Model: ark.EmbeddingModel(e.model),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create embeddings: %w", err)
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, item := range resp.Data {
+		if item.Index < 0 || item.Index >= len(vectors) {
+			return nil, fmt.Errorf("unexpected embedding index %d", item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	for i, vector := range vectors {
+		if vector == nil {
+			return nil, fmt.Errorf("missing embedding for input %d", i)
+		}
+	}
+	return vectors, nil
+}
+
+// HashEmbedder 本地的哈希向量化实现：把中文单字和双字、英文单词哈希到固定维度，
+// 结果确定、无需网络，适合测试和没有向量化接口的部署
+type HashEmbedder struct {
+	dimensions int
+}
+
+// NewHashEmbedder 创建新的哈希向量化实现
+func NewHashEmbedder(dimensions int) *HashEmbedder {
+	if dimensions <= 0 {
+		dimensions = 256
+	}
+	return &HashEmbedder{dimensions: dimensions}
+}
+
+// Embed 将多段文本转换为向量
+func (e *HashEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = e.embedOne(text)
+	}
+	return vectors, nil
+}
+
+// embedOne 把文本的特征哈希到向量中并归一化
+func (e *HashEmbedder) embedOne(text string) []float32 {
+	vector := make([]float32, e.dimensions)
+	for _, feature := range textFeatures(text) {
+		hasher := fnv.New64a()
+		hasher.Write([]byte(feature))
+		sum := hasher.Sum64()
+
+		// 用哈希的最高位决定符号，减少哈希冲突带来的偏差
+		sign := float32(1)
+		if sum>>63 == 1 {
+			sign = -1
+		}
+		vector[sum%uint64(e.dimensions)] += sign
+	}
+	normalize(vector)
+	return vector
+}
+
+// textFeatures 提取文本特征：中文按单字和相邻双字，其他文字按小写单词
+func textFeatures(text string) []string {
+	var features []string
+	var word strings.Builder
+	var prevHan rune
+
+	flushWord := func() {
+		if word.Len() > 0 {
+			features = append(features, word.String())
+			word.Reset()
+		}
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flushWord()
+			features = append(features, string(r))
+			if prevHan != 0 {
+				features = append(features, string([]rune{prevHan, r}))
+			}
+			prevHan = r
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			prevHan = 0
+			word.WriteRune(r)
+		default:
+			prevHan = 0
+			flushWord()
+		}
+	}
+	flushWord()
+	return features
+}
+
+// normalize 将向量归一化为单位长度
+func normalize(vector []float32) {
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 {
+		return
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range vector {
+		vector[i] /= norm
+	}
+}
+
+// CosineSimilarity 计算两个向量的余弦相似度，维度不同或存在零向量时返回0
+func CosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,234 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Fact 从用户消息中提取出的事实
+type Fact struct {
+	Key     string  `json:"key"`    // 事实的键，如"生日"、"喜好:猫"，相同键的事实相互替换
+	Content string  `json:"fact"`   // 规范化后的事实，如"用户的生日是5月3日"
+	Weight  float64 `json:"weight"` // 重要性，作为长期记忆的权重
+}
+
+// FactExtractor 事实提取接口
+type FactExtractor interface {
+	// Extract 从用户的一条消息中提取事实，同一键只返回最后一条
+	Extract(ctx context.Context, message string) ([]Fact, error)
+}
+
+// factRule 关键词规则：匹配句子后按模板生成事实
+type factRule struct {
+	pattern  *regexp.Regexp
+	key      string // 可包含%s，替换为第一个捕获组
+	template string // 按顺序使用全部捕获组
+	weight   float64
+}
+
+// factRules 事实规则，按顺序匹配，每个句子只使用第一条匹配的规则
+var factRules = []factRule{
+	{regexp.MustCompile(`^我(?:的名字是|的名字叫|名字叫|叫)(.+)$`), "名字", "用户的名字是%s", 2.0},
+	{regexp.MustCompile(`^(?:你可以)?叫我(.+)$`), "称呼", "用户希望被称呼为%s", 1.8},
+	{regexp.MustCompile(`^我的?生日(?:是|在)?(.+)$`), "生日", "用户的生日是%s", 1.8},
+	{regexp.MustCompile(`^我(?:今年|已经)?(\d{1,3})岁`), "年龄", "用户今年%s岁", 1.2},
+	{regexp.MustCompile(`^我(?:现在)?(?:住在|生活在)(.+)$`), "城市", "用户住在%s", 1.3},
+	{regexp.MustCompile(`^我的?(?:家乡|老家)(?:是|在)(.+)$`), "家乡", "用户的家乡是%s", 1.3},
+	{regexp.MustCompile(`^我来自(.+)$`), "家乡", "用户的家乡是%s", 1.3},
+	{regexp.MustCompile(`^我的?(?:工作|职业)是(.+)$`), "职业", "用户的职业是%s", 1.5},
+	{regexp.MustCompile(`^我是(?:一名|一位)(.+)$`), "职业", "用户的职业是%s", 1.5},
+	{regexp.MustCompile(`^我是做(.+)的$`), "职业", "用户的职业是做%s的", 1.5},
+	{regexp.MustCompile(`^我的?(?:宠物|猫|狗)(?:叫|的名字是|名字叫)(.+)$`), "宠物名字", "用户的宠物叫%s", 1.2},
+	{regexp.MustCompile(`^我养了(.+)$`), "宠物", "用户养了%s", 1.2},
+	{regexp.MustCompile(`^我的?(?:梦想|愿望)是(.+)$`), "梦想", "用户的梦想是%s", 1.3},
+	{regexp.MustCompile(`^我最喜欢的(.+?)是(.+)$`), "最喜欢的%s", "用户最喜欢的%s是%s", 1.4},
+	{regexp.MustCompile(`^我(?:不喜欢|讨厌)(.+)$`), "喜好:%s", "用户不喜欢%s", 1.0},
+	{regexp.MustCompile(`^我(?:很|非常|特别|最)?(?:喜欢|爱)(.+)$`), "喜好:%s", "用户喜欢%s", 1.0},
+}
+
+// sentenceSeparators 切分句子的标点
+const sentenceSeparators = "。！？!?；;，,\n"
+
+// trailingParticles 句尾可以去掉的语气词
+const trailingParticles = "了啊呀呢哦噢啦吧嘛哈~～ "
+
+// maxFactValueLength 规则捕获值的最大长度（按字符），过长的多半不是简单事实
+const maxFactValueLength = 30
+
+// factExtractionPrompt 使用模型提取事实时的系统提示词
+const factExtractionPrompt = `你是信息抽取助手。请从用户的话中提取关于用户本人、值得长期记住的事实（如姓名、生日、年龄、城市、职业、家人、宠物、喜好、经历、目标）。
+只输出JSON数组，不要输出其他内容，每一项的格式为：
+{"key": "事实类别，如生日、名字、职业、喜好:猫", "fact": "以"用户"开头的完整陈述，如"用户的生日是5月3日"", "importance": 0到1之间的数字}
+同一类别只保留最新的一条；没有可提取的事实时输出[]。`
+
+// KeywordFactExtractor 事实提取实现：先用关键词规则把常见的自我介绍规范化为事实，
+// 规则无法处理但包含关键信息的句子再交给模型提取（未配置模型时跳过）
+type KeywordFactExtractor struct {
+	promptBuilder *PromptTemplate
+	llmClient     LLMClient
+	model         string
+}
+
+// NewKeywordFactExtractor 创建新的事实提取器，llmClient为nil时只使用关键词规则
+func NewKeywordFactExtractor(promptBuilder *PromptTemplate, llmClient LLMClient, model string) *KeywordFactExtractor {
+	return &KeywordFactExtractor{
+		promptBuilder: promptBuilder,
+		llmClient:     llmClient,
+		model:         model,
+	}
+}
+
+// Extract 从用户的一条消息中提取事实
+func (e *KeywordFactExtractor) Extract(ctx context.Context, message string) ([]Fact, error) {
+	var facts []Fact
+	var pending []string
+	for _, sentence := range splitSentences(message) {
+		if fact, ok := matchFactRule(sentence); ok {
+			facts = append(facts, fact)
+			continue
+		}
+		pending = append(pending, sentence)
+	}
+
+	// 规则没有覆盖、但包含关键信息的句子交给模型提取
+	if e.llmClient != nil && len(pending) > 0 {
+		lines := make([]string, len(pending))
+		for i, sentence := range pending {
+			lines[i] = "用户: " + sentence
+		}
+		if candidates := e.promptBuilder.ExtractKeyInfo(strings.Join(lines, "\n")); len(candidates) > 0 {
+			extracted, err := e.extractWithLLM(ctx, candidates)
+			if err != nil {
+				return mergeFacts(facts), err
+			}
+			facts = append(facts, extracted...)
+		}
+	}
+
+	return mergeFacts(facts), nil
+}
+
+// extractWithLLM 调用模型从句子中提取事实
+func (e *KeywordFactExtractor) extractWithLLM(ctx context.Context, sentences []string) ([]Fact, error) {
+	messages := []map[string]string{
+		{"role": "system", "content": factExtractionPrompt},
+		{"role": "user", "content": strings.Join(sentences, "\n")},
+	}
+	completion, err := e.llmClient.GenerateResponse(ctx, messages, GenerateOptions{Model: e.model})
+	if err != nil {
+		return nil, fmt.Errorf("extract facts: %w", err)
+	}
+	return parseLLMFacts(completion.Content)
+}
+
+// parseLLMFacts 解析模型返回的JSON数组，忽略代码块标记等多余内容
+func parseLLMFacts(content string) ([]Fact, error) {
+	start := strings.Index(content, "[")
+	end := strings.LastIndex(content, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("extract facts: no json array in response")
+	}
+
+	var items []struct {
+		Key        string  `json:"key"`
+		Fact       string  `json:"fact"`
+		Importance float64 `json:"importance"`
+	}
+	if err := json.Unmarshal([]byte(content[start:end+1]), &items); err != nil {
+		return nil, fmt.Errorf("extract facts: %w", err)
+	}
+
+	facts := make([]Fact, 0, len(items))
+	for _, item := range items {
+		key := strings.TrimSpace(item.Key)
+		content := strings.TrimSpace(item.Fact)
+		if key == "" || content == "" {
+			continue
+		}
+		importance := item.Importance
+		if importance < 0 {
+			importance = 0
+		}
+		if importance > 1 {
+			importance = 1
+		}
+		// 与规则的权重范围保持一致：1.0 ~ 2.0
+		facts = append(facts, Fact{Key: key, Content: content, Weight: 1 + importance})
+	}
+	return facts, nil
+}
+
+// matchFactRule 用关键词规则把句子规范化为事实
+func matchFactRule(sentence string) (Fact, bool) {
+	for _, rule := range factRules {
+		groups := rule.pattern.FindStringSubmatch(sentence)
+		if groups == nil {
+			continue
+		}
+
+		values := make([]interface{}, 0, len(groups)-1)
+		valid := true
+		for _, group := range groups[1:] {
+			value := strings.TrimRight(strings.TrimSpace(group), trailingParticles)
+			if value == "" || utf8.RuneCountInString(value) > maxFactValueLength || isPronoun(value) {
+				valid = false
+				break
+			}
+			values = append(values, value)
+		}
+		if !valid {
+			continue
+		}
+
+		key := rule.key
+		if strings.Contains(key, "%s") {
+			key = fmt.Sprintf(key, values[0])
+		}
+		return Fact{Key: key, Content: fmt.Sprintf(rule.template, values...), Weight: rule.weight}, true
+	}
+	return Fact{}, false
+}
+
+// isPronoun 判断捕获值是否只是代词（如"我爱你"），这类句子不是关于用户的事实
+func isPronoun(value string) bool {
+	switch value {
+	case "你", "您", "你们", "他", "她", "它", "他们", "她们", "这个", "那个":
+		return true
+	}
+	return false
+}
+
+// splitSentences 按标点切分句子
+func splitSentences(message string) []string {
+	parts := strings.FieldsFunc(message, func(r rune) bool {
+		return strings.ContainsRune(sentenceSeparators, r)
+	})
+
+	sentences := make([]string, 0, len(parts))
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			sentences = append(sentences, part)
+		}
+	}
+	return sentences
+}
+
+// mergeFacts 合并相同键的事实，后出现的覆盖先出现的
+func mergeFacts(facts []Fact) []Fact {
+	index := make(map[string]int, len(facts))
+	merged := make([]Fact, 0, len(facts))
+	for _, fact := range facts {
+		if i, ok := index[fact.Key]; ok {
+			merged[i] = fact
+			continue
+		}
+		index[fact.Key] = len(merged)
+		merged = append(merged, fact)
+	}
+	return merged
+}
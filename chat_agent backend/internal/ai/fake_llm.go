@@ -0,0 +1,256 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	ark "github.com/sashabaranov/go-openai"
+)
+
+// FakeRule 模拟模型脚本中的一条规则，按最后一条用户消息和请求的模型匹配
+type FakeRule struct {
+	Model      string `json:"model,omitempty"`       // 请求的模型为该模型时匹配
+	Contains   string `json:"contains,omitempty"`    // 用户消息包含该文本时匹配
+	Pattern    string `json:"pattern,omitempty"`     // 用户消息匹配该正则表达式时匹配
+	Reply      string `json:"reply,omitempty"`       // 回复内容，"{{message}}"替换为用户消息
+	StatusCode int    `json:"status_code,omitempty"` // 不为0时返回该状态码的错误，用于注入错误；FakeLLMClient流式调用时先输出Reply再返回错误
+	RetryAfter int    `json:"retry_after,omitempty"` // 错误响应的Retry-After（秒）
+	LatencyMS  int    `json:"latency_ms,omitempty"`  // 响应前等待的毫秒数
+	Times      int    `json:"times,omitempty"`       // 最多匹配的次数，0表示不限制，用于"先失败几次再成功"
+
+	pattern *regexp.Regexp
+	matched int
+}
+
+// FakeResponse 一次模拟调用的结果
+type FakeResponse struct {
+	Reply      string
+	StatusCode int
+	RetryAfter time.Duration
+	Latency    time.Duration
+}
+
+// Err 需要注入错误时返回对应的APIError
+func (r FakeResponse) Err() error {
+	if r.StatusCode == 0 {
+		return nil
+	}
+	return &APIError{StatusCode: r.StatusCode, RetryAfter: r.RetryAfter, Message: "fake llm injected error"}
+}
+
+// FakeScript 模拟模型的脚本：按顺序匹配规则，没有规则匹配时使用默认回复。相同的输入总是得到相同的输出
+type FakeScript struct {
+	mu           sync.Mutex
+	rules        []*FakeRule
+	defaultReply string
+}
+
+// defaultFakeReply 没有规则匹配时的默认回复
+const defaultFakeReply = "（模拟回复）我收到了你的消息：{{message}}"
+
+// NewFakeScript 创建模拟模型脚本，defaultReply为空时使用内置的默认回复
+func NewFakeScript(rules []FakeRule, defaultReply string) (*FakeScript, error) {
+	if defaultReply == "" {
+		defaultReply = defaultFakeReply
+	}
+	script := &FakeScript{defaultReply: defaultReply}
+	for i := range rules {
+		rule := rules[i]
+		if rule.Pattern != "" {
+			pattern, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("fake llm rule %d: %w", i, err)
+			}
+			rule.pattern = pattern
+		}
+		script.rules = append(script.rules, &rule)
+	}
+	return script, nil
+}
+
+// LoadFakeScript 从JSON文件加载模拟模型脚本，格式为 {"rules":[{"contains":"你好","reply":"..."}],"default_reply":"..."}
+func LoadFakeScript(path string) (*FakeScript, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Rules        []FakeRule `json:"rules"`
+		DefaultReply string     `json:"default_reply"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse fake llm script: %w", err)
+	}
+	return NewFakeScript(file.Rules, file.DefaultReply)
+}
+
+// DefaultFakeScript 内置的模拟模型脚本，覆盖常见的问候和几种错误场景，便于离线开发前端
+func DefaultFakeScript() *FakeScript {
+	script, _ := NewFakeScript([]FakeRule{
+		{Contains: "你好", Reply: "你好呀！很高兴见到你，今天过得怎么样？"},
+		{Contains: "在吗", Reply: "在的在的，有什么想和我聊的吗？"},
+		{Contains: "[slow]", Reply: "抱歉让你久等啦，刚刚在忙。", LatencyMS: 3000},
+		{Contains: "[429]", StatusCode: 429, RetryAfter: 1},
+		{Contains: "[500]", StatusCode: 500},
+		{Contains: "[long]", Reply: strings.Repeat("这是一段用来测试长回复和流式输出的模拟内容。", 20)},
+	}, "")
+	return script
+}
+
+// Match 返回与最后一条用户消息和请求的模型匹配的结果
+func (s *FakeScript) Match(messages []map[string]string, model string) FakeResponse {
+	message := lastUserMessage(messages)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, rule := range s.rules {
+		if !rule.matches(message, model) {
+			continue
+		}
+		rule.matched++
+		return FakeResponse{
+			Reply:      strings.ReplaceAll(rule.Reply, "{{message}}", message),
+			StatusCode: rule.StatusCode,
+			RetryAfter: time.Duration(rule.RetryAfter) * time.Second,
+			Latency:    time.Duration(rule.LatencyMS) * time.Millisecond,
+		}
+	}
+	return FakeResponse{Reply: strings.ReplaceAll(s.defaultReply, "{{message}}", message)}
+}
+
+// matches 判断规则是否匹配用户消息和模型，达到匹配次数上限的规则不再匹配
+func (r *FakeRule) matches(message, model string) bool {
+	if r.Times > 0 && r.matched >= r.Times {
+		return false
+	}
+	if r.Model != "" && r.Model != model {
+		return false
+	}
+	if r.Contains != "" && !strings.Contains(message, r.Contains) {
+		return false
+	}
+	if r.pattern != nil && !r.pattern.MatchString(message) {
+		return false
+	}
+	return true
+}
+
+// lastUserMessage 最后一条用户消息。提示词把历史对话和当前消息合并在一条用户消息中，当前消息在最后一个"用户: "之后
+func lastUserMessage(messages []map[string]string) string {
+	const prefix = "用户: "
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i]["role"] != "user" {
+			continue
+		}
+		content := messages[i]["content"]
+		if index := strings.LastIndex(content, prefix); index == 0 || (index > 0 && content[index-1] == '\n') {
+			content = content[index+len(prefix):]
+		}
+		return content
+	}
+	return ""
+}
+
+// FakeCall FakeLLMClient收到的一次调用
+type FakeCall struct {
+	Messages []map[string]string
+	Options  GenerateOptions
+}
+
+// FakeLLMClient 按脚本回复的模拟模型客户端，不需要网络和密钥，用于离线开发和测试
+type FakeLLMClient struct {
+	script    *FakeScript
+	model     string
+	chunkSize int
+
+	mu    sync.Mutex
+	calls []FakeCall
+}
+
+// NewFakeLLMClient 创建模拟模型客户端，script为空时使用内置脚本
+func NewFakeLLMClient(script *FakeScript) *FakeLLMClient {
+	if script == nil {
+		script = DefaultFakeScript()
+	}
+	return &FakeLLMClient{script: script, model: "fake-model", chunkSize: 4}
+}
+
+// GenerateResponse 实现LLMClient接口
+func (c *FakeLLMClient) GenerateResponse(ctx context.Context, messages []map[string]string, options GenerateOptions) (*Completion, error) {
+	response := c.match(messages, options)
+	if err := sleepContext(ctx, response.Latency); err != nil {
+		return nil, err
+	}
+	if err := response.Err(); err != nil {
+		return nil, err
+	}
+	return c.completion(messages, options.Model, response.Reply), nil
+}
+
+// GenerateStreamResponse 实现LLMClient接口，回复按固定的字数分块返回。
+// 规则同时指定了回复和错误时，先输出回复再返回错误，模拟输出到一半时断开
+func (c *FakeLLMClient) GenerateStreamResponse(ctx context.Context, messages []map[string]string, options GenerateOptions, callback func(string) error) (*Completion, error) {
+	response := c.match(messages, options)
+	if err := sleepContext(ctx, response.Latency); err != nil {
+		return nil, err
+	}
+	for _, chunk := range FakeChunks(response.Reply, c.chunkSize) {
+		if err := callback(chunk); err != nil {
+			return nil, fmt.Errorf("callback error: %w", err)
+		}
+	}
+	if err := response.Err(); err != nil {
+		return nil, err
+	}
+	return c.completion(messages, options.Model, response.Reply), nil
+}
+
+// Calls 按顺序返回收到的调用，用于在测试中检查请求的消息和参数
+func (c *FakeLLMClient) Calls() []FakeCall {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]FakeCall(nil), c.calls...)
+}
+
+// match 记录调用并按脚本匹配结果
+func (c *FakeLLMClient) match(messages []map[string]string, options GenerateOptions) FakeResponse {
+	c.mu.Lock()
+	c.calls = append(c.calls, FakeCall{Messages: messages, Options: options})
+	c.mu.Unlock()
+	return c.script.Match(messages, options.Model)
+}
+
+// completion 构造模拟回复，用量按文本长度估算
+func (c *FakeLLMClient) completion(messages []map[string]string, model, reply string) *Completion {
+	if model == "" {
+		model = c.model
+	}
+	return &Completion{
+		Content: reply,
+		Model:   model,
+		Usage:   usageOrEstimate(ark.Usage{}, messages, reply),
+	}
+}
+
+// FakeChunks 把回复按size个字符分块，模拟流式输出
+func FakeChunks(reply string, size int) []string {
+	if size <= 0 {
+		size = 4
+	}
+	runes := []rune(reply)
+	var chunks []string
+	for start := 0; start < len(runes); start += size {
+		end := start + size
+		if end > len(runes) {
+			end = len(runes)
+		}
+		chunks = append(chunks, string(runes[start:end]))
+	}
+	return chunks
+}
@@ -0,0 +1,175 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	ark "github.com/sashabaranov/go-openai"
+)
+
+// fakeCreated 模拟响应中固定的创建时间，保证相同的请求得到相同的响应
+const fakeCreated = 1700000000
+
+// FakeServerOptions 模拟OpenAI兼容服务的配置
+type FakeServerOptions struct {
+	Latency    time.Duration // 每个请求在规则延迟之外额外等待的时间
+	ChunkDelay time.Duration // 流式输出时两个数据块之间的间隔
+	ChunkSize  int           // 流式输出时每个数据块的字符数，默认4
+	FailEvery  int           // 每N个请求注入一次503错误，0表示不注入
+}
+
+// FakeOpenAIServer 模拟的OpenAI兼容接口（/chat/completions 和 /models），按脚本回复，
+// 支持流式SSE、用量统计、延迟和错误注入，用于没有模型密钥时的离线开发和测试
+type FakeOpenAIServer struct {
+	script   *FakeScript
+	options  FakeServerOptions
+	requests atomic.Int64
+}
+
+// NewFakeOpenAIServer 创建模拟的OpenAI兼容服务，script为空时使用内置脚本
+func NewFakeOpenAIServer(script *FakeScript, options FakeServerOptions) *FakeOpenAIServer {
+	if script == nil {
+		script = DefaultFakeScript()
+	}
+	if options.ChunkSize <= 0 {
+		options.ChunkSize = 4
+	}
+	return &FakeOpenAIServer{script: script, options: options}
+}
+
+// fakeChatRequest 模拟服务接受的聊天请求
+type fakeChatRequest struct {
+	Model    string `json:"model"`
+	Messages []struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	} `json:"messages"`
+	Stream        bool `json:"stream"`
+	StreamOptions *struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options"`
+}
+
+// ServeHTTP 实现http.Handler接口，接口地址可以带或不带/v1前缀
+func (s *FakeOpenAIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/chat/completions") && r.Method == http.MethodPost:
+		s.chatCompletions(w, r)
+	case strings.HasSuffix(r.URL.Path, "/models") && r.Method == http.MethodGet:
+		writeFakeJSON(w, http.StatusOK, map[string]interface{}{
+			"object": "list",
+			"data":   []map[string]interface{}{{"id": "fake-model", "object": "model", "owned_by": "fake"}},
+		})
+	default:
+		writeFakeError(w, http.StatusNotFound, "not_found", 0, "unknown endpoint "+r.URL.Path)
+	}
+}
+
+// chatCompletions 处理聊天请求
+func (s *FakeOpenAIServer) chatCompletions(w http.ResponseWriter, r *http.Request) {
+	var req fakeChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeFakeError(w, http.StatusBadRequest, "invalid_request_error", 0, "invalid request body: "+err.Error())
+		return
+	}
+	messages := make([]map[string]string, len(req.Messages))
+	for i, message := range req.Messages {
+		messages[i] = map[string]string{"role": message.Role, "content": message.Content}
+	}
+	if req.Model == "" {
+		req.Model = "fake-model"
+	}
+
+	count := s.requests.Add(1)
+	response := s.script.Match(messages, req.Model)
+	if err := sleepContext(r.Context(), s.options.Latency+response.Latency); err != nil {
+		return
+	}
+
+	// 错误注入：脚本规则指定的错误，或每N个请求一次的503
+	if s.options.FailEvery > 0 && count%int64(s.options.FailEvery) == 0 {
+		writeFakeError(w, http.StatusServiceUnavailable, "server_error", 0, "fake llm injected error")
+		return
+	}
+	if response.StatusCode != 0 {
+		errorType := "server_error"
+		if response.StatusCode == http.StatusTooManyRequests {
+			errorType = "rate_limit_exceeded"
+		}
+		writeFakeError(w, response.StatusCode, errorType, response.RetryAfter, "fake llm injected error")
+		return
+	}
+
+	id := fmt.Sprintf("chatcmpl-fake-%d", count)
+	usage := usageOrEstimate(ark.Usage{}, messages, response.Reply)
+	apiUsage := ark.Usage{PromptTokens: usage.PromptTokens, CompletionTokens: usage.CompletionTokens, TotalTokens: usage.TotalTokens}
+	if !req.Stream {
+		writeFakeJSON(w, http.StatusOK, ark.ChatCompletionResponse{
+			ID:      id,
+			Object:  "chat.completion",
+			Created: fakeCreated,
+			Model:   req.Model,
+			Choices: []ark.ChatCompletionChoice{{
+				Index:        0,
+				Message:      ark.ChatCompletionMessage{Role: ark.ChatMessageRoleAssistant, Content: response.Reply},
+				FinishReason: ark.FinishReasonStop,
+			}},
+			Usage: apiUsage,
+		})
+		return
+	}
+
+	// 流式响应：按字数分块输出SSE数据块，最后按请求返回用量
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher, _ := w.(http.Flusher)
+	chunk := func(delta ark.ChatCompletionStreamChoiceDelta, finish ark.FinishReason, usage *ark.Usage) {
+		data := ark.ChatCompletionStreamResponse{ID: id, Object: "chat.completion.chunk", Created: fakeCreated, Model: req.Model, Usage: usage}
+		if usage == nil {
+			data.Choices = []ark.ChatCompletionStreamChoice{{Index: 0, Delta: delta, FinishReason: finish}}
+		}
+		encoded, _ := json.Marshal(data)
+		fmt.Fprintf(w, "data: %s\n\n", encoded)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	chunk(ark.ChatCompletionStreamChoiceDelta{Role: ark.ChatMessageRoleAssistant}, "", nil)
+	for _, content := range FakeChunks(response.Reply, s.options.ChunkSize) {
+		if err := sleepContext(r.Context(), s.options.ChunkDelay); err != nil {
+			return
+		}
+		chunk(ark.ChatCompletionStreamChoiceDelta{Content: content}, "", nil)
+	}
+	chunk(ark.ChatCompletionStreamChoiceDelta{}, ark.FinishReasonStop, nil)
+	if req.StreamOptions != nil && req.StreamOptions.IncludeUsage {
+		chunk(ark.ChatCompletionStreamChoiceDelta{}, "", &apiUsage)
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
+
+// writeFakeJSON 写入JSON响应
+func writeFakeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeFakeError 写入OpenAI格式的错误响应
+func writeFakeError(w http.ResponseWriter, status int, errorType string, retryAfter time.Duration, message string) {
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter/time.Second)))
+	}
+	writeFakeJSON(w, status, map[string]interface{}{
+		"error": map[string]interface{}{"message": message, "type": errorType},
+	})
+}
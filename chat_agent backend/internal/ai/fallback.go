@@ -0,0 +1,72 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// ErrAllModelsFailed 回退链中的所有模型都调用失败
+var ErrAllModelsFailed = errors.New("all models in fallback chain failed")
+
+// FallbackChain 组成回退链：先是请求的模型，再依次是回退模型，空的和重复的模型被忽略
+func FallbackChain(primary string, fallbacks []string) []string {
+	chain := make([]string, 0, len(fallbacks)+1)
+	seen := make(map[string]bool)
+	for _, model := range append([]string{primary}, fallbacks...) {
+		if model == "" || seen[model] {
+			continue
+		}
+		seen[model] = true
+		chain = append(chain, model)
+	}
+	return chain
+}
+
+// GenerateWithFallback 按回退链的顺序调用模型，返回第一个成功的回复及其模型在回退链中的位置。
+// 每个模型都使用options中的生成参数
+func GenerateWithFallback(ctx context.Context, client LLMClient, messages []map[string]string, options GenerateOptions, chain []string) (*Completion, int, error) {
+	return withFallback(ctx, chain, func(model string) (*Completion, bool, error) {
+		options.Model = model
+		completion, err := client.GenerateResponse(ctx, messages, options)
+		return completion, true, err
+	})
+}
+
+// GenerateStreamWithFallback 按回退链的顺序流式调用模型，已经输出内容后失败不再回退，避免把两个模型的回复拼在一起
+func GenerateStreamWithFallback(ctx context.Context, client LLMClient, messages []map[string]string, options GenerateOptions, chain []string, callback func(string) error) (*Completion, int, error) {
+	return withFallback(ctx, chain, func(model string) (*Completion, bool, error) {
+		options.Model = model
+		started := false
+		completion, err := client.GenerateStreamResponse(ctx, messages, options, func(chunk string) error {
+			started = true
+			return callback(chunk)
+		})
+		return completion, !started, err
+	})
+}
+
+// withFallback 依次用回退链中的模型调用generate，generate返回的canFallback为false时不再尝试后面的模型
+func withFallback(ctx context.Context, chain []string, generate func(model string) (*Completion, bool, error)) (*Completion, int, error) {
+	if len(chain) == 0 {
+		chain = []string{""} // 未指定任何模型时使用客户端的默认模型
+	}
+
+	var errs []error
+	for i, model := range chain {
+		completion, canFallback, err := generate(model)
+		if err == nil {
+			return completion, i, nil
+		}
+		if ctx.Err() != nil || !canFallback {
+			return nil, i, err
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", model, err))
+		if i+1 < len(chain) {
+			log.Printf("模型%s调用失败，改用%s: %v", model, chain[i+1], err)
+		}
+	}
+	return nil, len(chain) - 1, fmt.Errorf("%w: %w", ErrAllModelsFailed, errors.Join(errs...))
+}
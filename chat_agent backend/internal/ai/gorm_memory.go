@@ -0,0 +1,351 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"chat_agent/internal/models"
+	"chat_agent/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// GormMemoryManager 基于数据库的记忆管理器，记忆持久化在memories表中，便于审计和备份
+type GormMemoryManager struct {
+	memoryRepo   repository.MemoryRepository
+	shortTermCap int
+	longTermCap  int
+	decay        MemoryDecay
+	ttl          MemoryTTL
+}
+
+// NewGormMemoryManager 创建新的数据库记忆管理器
+func NewGormMemoryManager(memoryRepo repository.MemoryRepository, shortTermCap, longTermCap int, decay MemoryDecay, ttl MemoryTTL) *GormMemoryManager {
+	if shortTermCap <= 0 {
+		shortTermCap = 10
+	}
+	if longTermCap <= 0 {
+		longTermCap = 50
+	}
+	return &GormMemoryManager{
+		memoryRepo:   memoryRepo,
+		shortTermCap: shortTermCap,
+		longTermCap:  longTermCap,
+		decay:        decay,
+		ttl:          ttl,
+	}
+}
+
+// AddShortTermMemory 添加短期记忆
+func (m *GormMemoryManager) AddShortTermMemory(ctx context.Context, chatID uint, content string) error {
+	now := time.Now()
+	memory := &models.Memory{
+		UpdatedAt: now,
+		ChatID:    chatID,
+		Type:      string(ShortTermMemory),
+		Content:   content,
+		Weight:    1.0,
+		RankScore: m.decay.RankScore(1.0, now),
+	}
+	if err := m.memoryRepo.Create(ctx, memory); err != nil {
+		return err
+	}
+
+	// 只保留最近的若干条短期记忆
+	return m.memoryRepo.Trim(ctx, chatID, string(ShortTermMemory), m.shortTermCap, false)
+}
+
+// GetShortTermMemory 获取最近的短期记忆（按时间从早到晚排列）
+func (m *GormMemoryManager) GetShortTermMemory(ctx context.Context, chatID uint, limit int) ([]string, error) {
+	if limit <= 0 {
+		limit = 10 // 默认返回10条
+	}
+
+	memories, err := m.memoryRepo.GetRecent(ctx, chatID, string(ShortTermMemory), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0, len(memories))
+	for i := len(memories) - 1; i >= 0; i-- {
+		result = append(result, memories[i].Content)
+	}
+	return result, nil
+}
+
+// ClearShortTermMemory 清除短期记忆
+func (m *GormMemoryManager) ClearShortTermMemory(ctx context.Context, chatID uint) error {
+	_, err := m.memoryRepo.DeleteByChat(ctx, chatID, string(ShortTermMemory))
+	return err
+}
+
+// AddLongTermMemory 添加长期记忆（内容相同的记忆只保留一条）
+func (m *GormMemoryManager) AddLongTermMemory(ctx context.Context, chatID uint, content string, weight float64) error {
+	existing, err := m.memoryRepo.FindByContent(ctx, chatID, string(LongTermMemory), content)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return nil // 避免重复添加
+	}
+
+	now := time.Now()
+	memory := &models.Memory{
+		UpdatedAt: now,
+		ChatID:    chatID,
+		Type:      string(LongTermMemory),
+		Content:   content,
+		Weight:    weight,
+		RankScore: m.decay.RankScore(weight, now),
+	}
+	if err := m.memoryRepo.Create(ctx, memory); err != nil {
+		return err
+	}
+
+	// 超出上限时淘汰有效权重最低的长期记忆
+	return m.memoryRepo.Trim(ctx, chatID, string(LongTermMemory), m.longTermCap, true)
+}
+
+// UpsertLongTermMemory 按键写入长期记忆
+func (m *GormMemoryManager) UpsertLongTermMemory(ctx context.Context, chatID uint, key, content string, weight float64) error {
+	if key == "" {
+		return m.AddLongTermMemory(ctx, chatID, content, weight)
+	}
+
+	existing, err := m.memoryRepo.FindByKey(ctx, chatID, string(LongTermMemory), key)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		// 已有相同内容的记忆时不重复添加，没有键时补上key
+		existing, err = m.memoryRepo.FindByContent(ctx, chatID, string(LongTermMemory), content)
+		if err != nil {
+			return err
+		}
+		if existing != nil && existing.Key != "" {
+			return nil
+		}
+	}
+	now := time.Now()
+	if existing == nil {
+		memory := &models.Memory{
+			UpdatedAt: now,
+			ChatID:    chatID,
+			Type:      string(LongTermMemory),
+			Key:       key,
+			Content:   content,
+			Weight:    weight,
+			RankScore: m.decay.RankScore(weight, now),
+		}
+		if err := m.memoryRepo.Create(ctx, memory); err != nil {
+			return err
+		}
+		return m.memoryRepo.Trim(ctx, chatID, string(LongTermMemory), m.longTermCap, true)
+	}
+
+	existing.Key = key
+	existing.Content = content
+	existing.Weight = math.Max(m.decay.EffectiveWeight(existing.Weight, existing.UpdatedAt, now), weight)
+	existing.UpdatedAt = now
+	existing.RankScore = m.rankScore(existing)
+	return m.memoryRepo.Update(ctx, existing)
+}
+
+// ReinforceMemory 强化被回复引用的长期记忆
+func (m *GormMemoryManager) ReinforceMemory(ctx context.Context, chatID uint, content string) error {
+	existing, err := m.memoryRepo.FindByContent(ctx, chatID, string(LongTermMemory), content)
+	if err != nil || existing == nil {
+		return err
+	}
+
+	now := time.Now()
+	existing.Weight = m.decay.Reinforce(existing.Weight, existing.UpdatedAt, now)
+	existing.UpdatedAt = now
+	return m.memoryRepo.UpdateWeight(ctx, existing.ID, existing.Weight, m.rankScore(existing))
+}
+
+// CollectGarbage 清理所有会话中过期的记忆和有效权重过低的长期记忆
+func (m *GormMemoryManager) CollectGarbage(ctx context.Context) (MemoryGCStats, error) {
+	var stats MemoryGCStats
+	now := time.Now()
+
+	// 短期记忆写入后不会更新，最后更新时间即写入时间
+	if m.ttl.ShortTerm > 0 {
+		count, err := m.memoryRepo.DeleteUpdatedBefore(ctx, string(ShortTermMemory), now.Add(-m.ttl.ShortTerm))
+		stats.ExpiredShortTerm = int(count)
+		if err != nil {
+			return stats, err
+		}
+	}
+	if m.ttl.LongTerm > 0 {
+		count, err := m.memoryRepo.DeleteUpdatedBefore(ctx, string(LongTermMemory), now.Add(-m.ttl.LongTerm))
+		stats.ExpiredLongTerm = int(count)
+		if err != nil {
+			return stats, err
+		}
+	}
+	if m.decay.PruneBelow > 0 {
+		count, err := m.memoryRepo.DeleteBelowRankScore(ctx, string(LongTermMemory), m.decay.PruneScore(now))
+		stats.Pruned = int(count)
+		if err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// DeleteChatMemory 删除会话的全部记忆
+func (m *GormMemoryManager) DeleteChatMemory(ctx context.Context, chatID uint) (int, error) {
+	count, err := m.memoryRepo.DeleteByChat(ctx, chatID, "")
+	return int(count), err
+}
+
+// ForgetMemory 删除会话中内容与content完全相同的短期和长期记忆，置顶的记忆保留
+func (m *GormMemoryManager) ForgetMemory(ctx context.Context, chatID uint, content string) (int, error) {
+	count, err := m.memoryRepo.DeleteByContent(ctx, chatID, content)
+	return int(count), err
+}
+
+// ListMemories 列出会话的全部长期记忆
+func (m *GormMemoryManager) ListMemories(ctx context.Context, chatID uint) ([]MemoryItem, error) {
+	memories, err := m.memoryRepo.GetTopWeighted(ctx, chatID, string(LongTermMemory), m.longTermCap)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	items := make([]MemoryItem, len(memories))
+	for i, memory := range memories {
+		items[i] = memoryItem(memory)
+		items[i].Weight = m.decay.EffectiveWeight(memory.Weight, memory.UpdatedAt, now)
+	}
+	return items, nil
+}
+
+// UpdateMemory 修改长期记忆的内容或置顶状态
+func (m *GormMemoryManager) UpdateMemory(ctx context.Context, chatID uint, memoryID string, update MemoryUpdate) (*MemoryItem, error) {
+	memory, err := m.getOwnedLongTerm(ctx, chatID, memoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	item := memoryItem(*memory)
+	update.apply(&item, m.decay, time.Now())
+	memory.Content = item.Content
+	memory.Weight = item.Weight
+	memory.Pinned = item.Pinned
+	memory.UpdatedAt = item.UpdatedAt
+	memory.RankScore = m.rankScore(memory)
+	if err := m.memoryRepo.Update(ctx, memory); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// DeleteMemory 删除长期记忆
+func (m *GormMemoryManager) DeleteMemory(ctx context.Context, chatID uint, memoryID string) error {
+	memory, err := m.getOwnedLongTerm(ctx, chatID, memoryID)
+	if err != nil {
+		return err
+	}
+	return m.memoryRepo.Delete(ctx, memory.ID)
+}
+
+// GetLongTermMemory 获取有效权重最高的长期记忆
+func (m *GormMemoryManager) GetLongTermMemory(ctx context.Context, chatID uint, limit int) ([]string, error) {
+	if limit <= 0 {
+		limit = 20 // 默认返回20条
+	}
+
+	memories, err := m.memoryRepo.GetTopWeighted(ctx, chatID, string(LongTermMemory), limit)
+	if err != nil {
+		return nil, err
+	}
+	return memoryContents(memories), nil
+}
+
+// UpdateMemoryWeight 更新记忆权重，记忆ID为memories表的主键
+func (m *GormMemoryManager) UpdateMemoryWeight(ctx context.Context, memoryID string, weight float64) error {
+	id, err := strconv.ParseUint(memoryID, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid memory id: %s", memoryID)
+	}
+
+	memory, err := m.memoryRepo.GetByID(ctx, uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("memory not found: %s", memoryID)
+	}
+	if err != nil {
+		return err
+	}
+
+	memory.Weight = weight
+	memory.UpdatedAt = time.Now()
+	return m.memoryRepo.UpdateWeight(ctx, memory.ID, weight, m.rankScore(memory))
+}
+
+// SearchMemory 搜索包含关键词的记忆，按有效权重降序返回
+func (m *GormMemoryManager) SearchMemory(ctx context.Context, chatID uint, query string, limit int) ([]string, error) {
+	if limit <= 0 {
+		limit = 5 // 默认返回5条
+	}
+
+	memories, err := m.memoryRepo.Search(ctx, chatID, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	return memoryContents(memories), nil
+}
+
+// getOwnedLongTerm 读取属于该会话的一条长期记忆，不存在时返回ErrMemoryNotFound
+func (m *GormMemoryManager) getOwnedLongTerm(ctx context.Context, chatID uint, memoryID string) (*models.Memory, error) {
+	id, err := strconv.ParseUint(memoryID, 10, 64)
+	if err != nil {
+		return nil, ErrMemoryNotFound
+	}
+
+	memory, err := m.memoryRepo.GetByID(ctx, uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrMemoryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if memory.ChatID != chatID || memory.Type != string(LongTermMemory) {
+		return nil, ErrMemoryNotFound
+	}
+	return memory, nil
+}
+
+// rankScore 计算记忆的排序分数
+func (m *GormMemoryManager) rankScore(memory *models.Memory) float64 {
+	return memoryRankScore(memoryItem(*memory), m.decay)
+}
+
+// memoryItem 将数据库中的记忆转换为记忆项
+func memoryItem(memory models.Memory) MemoryItem {
+	return MemoryItem{
+		ID:        strconv.FormatUint(uint64(memory.ID), 10),
+		ChatID:    memory.ChatID,
+		Type:      MemoryType(memory.Type),
+		Key:       memory.Key,
+		Content:   memory.Content,
+		Weight:    memory.Weight,
+		Pinned:    memory.Pinned,
+		CreatedAt: memory.CreatedAt,
+		UpdatedAt: memory.UpdatedAt,
+	}
+}
+
+// memoryContents 提取记忆内容
+func memoryContents(memories []models.Memory) []string {
+	result := make([]string, 0, len(memories))
+	for _, memory := range memories {
+		result = append(result, memory.Content)
+	}
+	return result
+}
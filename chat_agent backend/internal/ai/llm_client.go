@@ -4,13 +4,40 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"math"
+	"net/http"
+	"strings"
+
+	"chat_agent/internal/models"
+
 	ark "github.com/sashabaranov/go-openai"
 )
 
+// TokenUsage 一次模型调用消耗的token数量
+type TokenUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Completion 模型调用结果
+type Completion struct {
+	Content string     // 回复内容
+	Model   string     // 实际响应的模型
+	Usage   TokenUsage // token用量
+}
+
+// GenerateOptions 一次模型调用的选项，未设置的生成参数使用提供方的默认值
+type GenerateOptions struct {
+	Model string // 模型名称，为空时使用客户端配置的模型
+	models.GenerationParams
+}
+
 // LLMClient 大语言模型客户端接口
 type LLMClient interface {
-	GenerateResponse(ctx context.Context, messages []map[string]string, model string) (string, error)
-	GenerateStreamResponse(ctx context.Context, messages []map[string]string, model string, callback func(string) error) error
+	GenerateResponse(ctx context.Context, messages []map[string]string, options GenerateOptions) (*Completion, error)
+	// GenerateStreamResponse 流式生成，每个内容片段通过callback返回，结束后返回完整结果和用量
+	GenerateStreamResponse(ctx context.Context, messages []map[string]string, options GenerateOptions, callback func(string) error) (*Completion, error)
 }
 
 // OpenAIClient 大语言模型客户端实现
@@ -23,6 +50,8 @@ type OpenAIClient struct {
 func NewOpenAIClient(apiKey, baseURL string, model string) *OpenAIClient {
 	config := ark.DefaultConfig(apiKey)
 	config.BaseURL = baseURL
+	// 记录错误响应中的Retry-After，供重试时使用
+	config.HTTPClient = &retryAfterRecorder{client: &http.Client{}}
 	client := ark.NewClientWithConfig(config)
 	// 如果没有提供模型，使用默认值
 	if model == "" {
@@ -50,17 +79,17 @@ func convertMessages(messages []map[string]string) []ark.ChatCompletionMessage {
 }
 
 // GenerateResponse 生成非流式响应
-func (c *OpenAIClient) GenerateResponse(ctx context.Context, messages []map[string]string, model string) (string, error) {
+func (c *OpenAIClient) GenerateResponse(ctx context.Context, messages []map[string]string, options GenerateOptions) (*Completion, error) {
 	// 使用配置的模型或传入的模型
 	useModel := c.model
-	if model != "" {
-		useModel = model
+	if options.Model != "" {
+		useModel = options.Model
 	}
 	
 	// 重要调试信息 - 确保日志中能看到这个
 	fmt.Printf("[CRITICAL] 最终使用的模型名称: %s\n", useModel)
 	fmt.Printf("[CRITICAL] 客户端配置的模型: %s\n", c.model)
-	fmt.Printf("[CRITICAL] 传入的模型参数: %s\n", model)
+	fmt.Printf("[CRITICAL] 传入的模型参数: %s\n", options.Model)
 	
 	// 如果模型名称不是预期的豆包模型，记录警告
 	if useModel != "doubao-1.5-pro-32k-250115" {
@@ -77,6 +106,7 @@ func (c *OpenAIClient) GenerateResponse(ctx context.Context, messages []map[stri
 		Model:    useModel,
 		Messages: convertedMessages,
 	}
+	applyGenerationParams(&req, options.GenerationParams)
 	
 	// 非常详细的调试信息
 	fmt.Printf("[CRITICAL] 准备发送API请求\n")
@@ -91,12 +121,13 @@ func (c *OpenAIClient) GenerateResponse(ctx context.Context, messages []map[stri
 	}
 	
 	fmt.Printf("[DEBUG] 发送请求: 模型=%s, 消息数量=%d\n", req.Model, len(req.Messages))
+	ctx, retryAfter := withRetryAfterRecorder(ctx)
 	resp, err := c.client.CreateChatCompletion(ctx, req)
 	
 	if err != nil {
 		fmt.Printf("[ERROR] API调用失败: %v\n", err)
 		// 返回友好的错误信息
-		return "", fmt.Errorf("ChatCompletion error: %v", err)
+		return nil, fmt.Errorf("ChatCompletion error: %w", openAIError(err, *retryAfter))
 	}
 	
 	fmt.Printf("[DEBUG] API调用成功，返回选择数量: %d\n", len(resp.Choices))
@@ -104,53 +135,139 @@ func (c *OpenAIClient) GenerateResponse(ctx context.Context, messages []map[stri
 	// 返回响应内容
 	if len(resp.Choices) > 0 && resp.Choices[0].Message.Content != "" {
 		fmt.Printf("[DEBUG] 收到有效响应，内容长度: %d\n", len(resp.Choices[0].Message.Content))
-		return resp.Choices[0].Message.Content, nil
+		content := resp.Choices[0].Message.Content
+		return &Completion{
+			Content: content,
+			Model:   responseModel(resp.Model, useModel),
+			Usage:   usageOrEstimate(resp.Usage, messages, content),
+		}, nil
 	}
 	
 	fmt.Printf("[ERROR] 未收到响应内容\n")
-	return "", fmt.Errorf("no response content received")
+	return nil, fmt.Errorf("no response content received")
 }
 
 // GenerateStreamResponse 生成流式响应
-func (c *OpenAIClient) GenerateStreamResponse(ctx context.Context, messages []map[string]string, model string, callback func(string) error) error {
+func (c *OpenAIClient) GenerateStreamResponse(ctx context.Context, messages []map[string]string, options GenerateOptions, callback func(string) error) (*Completion, error) {
 	// 使用配置的模型或传入的模型
 	useModel := c.model
-	if model != "" {
-		useModel = model
+	if options.Model != "" {
+		useModel = options.Model
 	}
 	
 	// 转换消息格式
 	convertedMessages := convertMessages(messages)
 	
 	// 创建流式聊天完成请求
-	stream, err := c.client.CreateChatCompletionStream(
-		ctx,
-		ark.ChatCompletionRequest{
-			Model:    useModel,
-			Messages: convertedMessages,
-		},
-	)
+	req := ark.ChatCompletionRequest{
+		Model:    useModel,
+		Messages: convertedMessages,
+		// 要求在最后一个数据块中返回本次调用的token用量
+		StreamOptions: &ark.StreamOptions{IncludeUsage: true},
+	}
+	applyGenerationParams(&req, options.GenerationParams)
+	ctx, retryAfter := withRetryAfterRecorder(ctx)
+	stream, err := c.client.CreateChatCompletionStream(ctx, req)
 	
 	if err != nil {
-		return fmt.Errorf("stream chat error: %w", err)
+		return nil, fmt.Errorf("stream chat error: %w", openAIError(err, *retryAfter))
 	}
 	defer stream.Close()
 	
+	var content strings.Builder
+	var usage ark.Usage
+	respModel := ""
+
 	// 处理流式响应
 	for {
 		recv, err := stream.Recv()
 		if err != nil {
 			if err == io.EOF {
-				return nil
+				return &Completion{
+					Content: content.String(),
+					Model:   responseModel(respModel, useModel),
+					Usage:   usageOrEstimate(usage, messages, content.String()),
+				}, nil
 			}
-			return fmt.Errorf("Stream chat error: %w", err)
+			return nil, fmt.Errorf("Stream chat error: %w", err)
+		}
+
+		if recv.Model != "" {
+			respModel = recv.Model
+		}
+		if recv.Usage != nil {
+			usage = *recv.Usage
 		}
 		
 		// 提取内容并调用回调函数
 		if len(recv.Choices) > 0 && recv.Choices[0].Delta.Content != "" {
+			content.WriteString(recv.Choices[0].Delta.Content)
 			if err := callback(recv.Choices[0].Delta.Content); err != nil {
-				return fmt.Errorf("callback error: %w", err)
+				return nil, fmt.Errorf("callback error: %w", err)
 			}
 		}
 	}
 }
+
+// applyGenerationParams 把已设置的生成参数写入请求。
+// 请求中值为0的参数不会发送，temperature和top_p为0时改用最小的正数，效果相同
+func applyGenerationParams(req *ark.ChatCompletionRequest, params models.GenerationParams) {
+	if params.Temperature != nil {
+		req.Temperature = nonZero(*params.Temperature)
+	}
+	if params.TopP != nil {
+		req.TopP = nonZero(*params.TopP)
+	}
+	if params.MaxTokens != nil {
+		req.MaxTokens = *params.MaxTokens
+	}
+	if params.PresencePenalty != nil {
+		req.PresencePenalty = *params.PresencePenalty
+	}
+	if params.FrequencyPenalty != nil {
+		req.FrequencyPenalty = *params.FrequencyPenalty
+	}
+	if len(params.Stop) > 0 {
+		req.Stop = params.Stop
+	}
+	req.Seed = params.Seed
+}
+
+// nonZero 把0替换为最小的正数，使参数能够发送
+func nonZero(value float32) float32 {
+	if value == 0 {
+		return math.SmallestNonzeroFloat32
+	}
+	return value
+}
+
+// responseModel 优先使用服务端返回的模型名称
+func responseModel(respModel, requestModel string) string {
+	if respModel != "" {
+		return respModel
+	}
+	return requestModel
+}
+
+// usageOrEstimate 服务端未返回用量时（部分兼容接口不支持），按文本长度估算
+func usageOrEstimate(usage ark.Usage, messages []map[string]string, content string) TokenUsage {
+	if usage.TotalTokens > 0 {
+		return TokenUsage{
+			PromptTokens:     usage.PromptTokens,
+			CompletionTokens: usage.CompletionTokens,
+			TotalTokens:      usage.TotalTokens,
+		}
+	}
+
+	promptTokens := 0
+	for _, msg := range messages {
+		// 每条消息额外计入角色等格式开销
+		promptTokens += estimateTokens(msg["content"]) + 4
+	}
+	completionTokens := estimateTokens(content)
+	return TokenUsage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      promptTokens + completionTokens,
+	}
+}
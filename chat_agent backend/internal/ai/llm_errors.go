@@ -0,0 +1,131 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	ark "github.com/sashabaranov/go-openai"
+)
+
+// APIError 模型接口返回的错误响应，保留状态码和Retry-After供重试判断
+type APIError struct {
+	StatusCode int
+	RetryAfter time.Duration // 服务端要求的重试等待时间，0表示未指定
+	Message    string
+	Err        error
+}
+
+// Error 实现error接口
+func (e *APIError) Error() string {
+	message := e.Message
+	if message == "" && e.Err != nil {
+		message = e.Err.Error()
+	}
+	return fmt.Sprintf("status %d: %s", e.StatusCode, message)
+}
+
+// Unwrap 返回原始错误
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable 判断错误是否可以重试：限流、超时和服务端错误可以重试，其他客户端错误重试也不会成功
+func (e *APIError) Retryable() bool {
+	switch {
+	case e.StatusCode == http.StatusTooManyRequests, e.StatusCode == http.StatusRequestTimeout:
+		return true
+	case e.StatusCode >= 500:
+		return true
+	}
+	return false
+}
+
+// IsRetryableError 判断一次模型调用的错误是否值得重试，parent为调用方的上下文
+func IsRetryableError(parent context.Context, err error) bool {
+	if err == nil || parent.Err() != nil {
+		return false // 调用方已取消或超时，不再重试
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Retryable()
+	}
+	// 单次调用超时和网络错误可以重试
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// retryAfterOf 错误中服务端要求的重试等待时间
+func retryAfterOf(err error) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.RetryAfter
+	}
+	return 0
+}
+
+// parseRetryAfter 解析Retry-After响应头（秒数或HTTP日期）
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+// retryAfterKey 在请求上下文中保存Retry-After的键
+type retryAfterKey struct{}
+
+// withRetryAfterRecorder 返回带有Retry-After记录位置的上下文，用于从OpenAI兼容接口的错误响应中取回该响应头
+func withRetryAfterRecorder(ctx context.Context) (context.Context, *time.Duration) {
+	recorded := new(time.Duration)
+	return context.WithValue(ctx, retryAfterKey{}, recorded), recorded
+}
+
+// retryAfterRecorder 记录错误响应中Retry-After的HTTP客户端
+type retryAfterRecorder struct {
+	client *http.Client
+}
+
+// Do 实现go-openai的HTTPDoer接口
+func (r *retryAfterRecorder) Do(req *http.Request) (*http.Response, error) {
+	resp, err := r.client.Do(req)
+	if err != nil || resp.StatusCode < 400 {
+		return resp, err
+	}
+	if recorded, ok := req.Context().Value(retryAfterKey{}).(*time.Duration); ok {
+		*recorded = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+	return resp, nil
+}
+
+// openAIError 把go-openai的错误转换为APIError，网络错误等其他错误原样返回
+func openAIError(err error, retryAfter time.Duration) error {
+	var apiErr *ark.APIError
+	if errors.As(err, &apiErr) && apiErr.HTTPStatusCode > 0 {
+		return &APIError{StatusCode: apiErr.HTTPStatusCode, RetryAfter: retryAfter, Message: apiErr.Message, Err: err}
+	}
+	var reqErr *ark.RequestError
+	if errors.As(err, &reqErr) && reqErr.HTTPStatusCode > 0 {
+		return &APIError{StatusCode: reqErr.HTTPStatusCode, RetryAfter: retryAfter, Message: strings.TrimSpace(string(reqErr.Body)), Err: err}
+	}
+	return err
+}
@@ -0,0 +1,198 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrUnknownModel 没有任何提供方提供该模型
+var ErrUnknownModel = errors.New("unknown model")
+
+// maxAliasDepth 别名解析的最大层数，避免别名互相指向时死循环
+const maxAliasDepth = 8
+
+// LLMProvider 注册到路由的模型提供方
+type LLMProvider struct {
+	Name   string    // 提供方名称，也可以用"名称/模型"的形式直接指定提供方
+	Client LLMClient // 调用该提供方的客户端
+	// 提供的模型：完整的模型名称，"前缀*"匹配该前缀的所有模型，"*"匹配任意模型
+	Models []string
+}
+
+// serves 判断提供方是否提供该模型，返回匹配的精确程度（0表示不匹配，精确匹配最高，前缀越长越高）
+func (p *LLMProvider) serves(model string) int {
+	best := 0
+	for _, pattern := range p.Models {
+		switch {
+		case pattern == model:
+			return len(model) + 2
+		case strings.HasSuffix(pattern, "*") && strings.HasPrefix(model, strings.TrimSuffix(pattern, "*")):
+			if score := len(pattern); score > best {
+				best = score
+			}
+		}
+	}
+	return best
+}
+
+// LLMRouter 按模型名称把请求路由到对应提供方的LLMClient：
+// 先展开别名，再按"提供方/模型"前缀或各提供方声明的模型选择提供方，没有提供方时拒绝请求
+type LLMRouter struct {
+	providers    []*LLMProvider
+	aliases      map[string]string
+	defaultModel string
+}
+
+// NewLLMRouter 创建新的模型路由，未指定模型的请求使用defaultModel
+func NewLLMRouter(defaultModel string) *LLMRouter {
+	return &LLMRouter{
+		aliases:      make(map[string]string),
+		defaultModel: defaultModel,
+	}
+}
+
+// Register 注册提供方，同名的提供方会被替换；多个提供方匹配程度相同时先注册的优先
+func (r *LLMRouter) Register(provider LLMProvider) {
+	for i, existing := range r.providers {
+		if existing.Name == provider.Name {
+			r.providers[i] = &provider
+			return
+		}
+	}
+	r.providers = append(r.providers, &provider)
+}
+
+// Alias 注册模型别名，target可以是模型名称、"提供方/模型"或另一个别名
+func (r *LLMRouter) Alias(alias, target string) {
+	r.aliases[alias] = target
+}
+
+// Providers 已注册的提供方名称
+func (r *LLMRouter) Providers() []string {
+	names := make([]string, len(r.providers))
+	for i, provider := range r.providers {
+		names[i] = provider.Name
+	}
+	return names
+}
+
+// BreakerStatuses 各提供方熔断器的状态，没有熔断器的提供方不列出
+func (r *LLMRouter) BreakerStatuses() []BreakerStatus {
+	statuses := make([]BreakerStatus, 0, len(r.providers))
+	for _, provider := range r.providers {
+		if client, ok := provider.Client.(*ResilientClient); ok {
+			statuses = append(statuses, client.BreakerStatus())
+		}
+	}
+	return statuses
+}
+
+// Resolve 解析模型名称，返回提供方和发送给提供方的模型名称
+func (r *LLMRouter) Resolve(model string) (*LLMProvider, string, error) {
+	requested := model
+	if model == "" {
+		model = r.defaultModel
+	}
+	for i := 0; i < maxAliasDepth; i++ {
+		target, ok := r.aliases[model]
+		if !ok {
+			break
+		}
+		model = target
+	}
+	if model == "" {
+		return nil, "", fmt.Errorf("%w: no model specified and no default model configured", ErrUnknownModel)
+	}
+
+	// "提供方/模型"直接指定提供方
+	if name, upstream, found := strings.Cut(model, "/"); found {
+		for _, provider := range r.providers {
+			if provider.Name == name {
+				return provider, upstream, nil
+			}
+		}
+	}
+
+	var matched *LLMProvider
+	best := 0
+	for _, provider := range r.providers {
+		if score := provider.serves(model); score > best {
+			matched, best = provider, score
+		}
+	}
+	if matched == nil {
+		if requested != model {
+			return nil, "", fmt.Errorf("%w: %s (resolved from %s), available models: %s", ErrUnknownModel, model, requested, r.availableModels())
+		}
+		return nil, "", fmt.Errorf("%w: %s, available models: %s", ErrUnknownModel, model, r.availableModels())
+	}
+	return matched, model, nil
+}
+
+// ValidateModel 检查模型能否被路由，不能时返回ErrUnknownModel
+func (r *LLMRouter) ValidateModel(model string) error {
+	_, _, err := r.Resolve(model)
+	return err
+}
+
+// ResolveModelName 返回model解析别名和默认模型后实际调用的"提供方/模型"名称，无法路由时原样返回
+func (r *LLMRouter) ResolveModelName(model string) string {
+	provider, upstream, err := r.Resolve(model)
+	if err != nil {
+		return model
+	}
+	return provider.Name + "/" + upstream
+}
+
+// GenerateResponse 把非流式请求转发给模型所属的提供方
+func (r *LLMRouter) GenerateResponse(ctx context.Context, messages []map[string]string, options GenerateOptions) (*Completion, error) {
+	provider, upstream, err := r.Resolve(options.Model)
+	if err != nil {
+		return nil, err
+	}
+	options.Model = upstream
+	return provider.Client.GenerateResponse(ctx, messages, options)
+}
+
+// GenerateStreamResponse 把流式请求转发给模型所属的提供方
+func (r *LLMRouter) GenerateStreamResponse(ctx context.Context, messages []map[string]string, options GenerateOptions, callback func(string) error) (*Completion, error) {
+	provider, upstream, err := r.Resolve(options.Model)
+	if err != nil {
+		return nil, err
+	}
+	options.Model = upstream
+	return provider.Client.GenerateStreamResponse(ctx, messages, options, callback)
+}
+
+// availableModels 列出可用的模型和别名，用于错误提示
+func (r *LLMRouter) availableModels() string {
+	var names []string
+	for _, provider := range r.providers {
+		for _, pattern := range provider.Models {
+			names = append(names, provider.Name+"/"+pattern)
+		}
+	}
+	aliases := make([]string, 0, len(r.aliases))
+	for alias := range r.aliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	names = append(names, aliases...)
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
+
+// ModelValidator 可以在调用前检查模型是否可用的客户端（如LLMRouter）
+type ModelValidator interface {
+	ValidateModel(model string) error
+}
+
+// ModelResolver 可以在调用前解析模型别名的客户端（如LLMRouter），用于按实际调用的模型查找上下文长度
+type ModelResolver interface {
+	ResolveModelName(model string) string
+}
@@ -2,8 +2,13 @@ package ai
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -22,8 +27,10 @@ type MemoryItem struct {
 	ID        string    `json:"id"`
 	ChatID    uint      `json:"chat_id"`
 	Type      MemoryType `json:"type"`
+	Key       string    `json:"key,omitempty"` // 事实的键（如"生日"），相同键的长期记忆会相互替换
 	Content   string    `json:"content"`
 	Weight    float64   `json:"weight"`
+	Pinned    bool      `json:"pinned,omitempty"` // 用户置顶的记忆总会出现在提示词中，不会衰减清理
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -39,190 +46,523 @@ type MemoryManager interface {
 	AddLongTermMemory(ctx context.Context, chatID uint, content string, weight float64) error
 	GetLongTermMemory(ctx context.Context, chatID uint, limit int) ([]string, error)
 	UpdateMemoryWeight(ctx context.Context, memoryID string, weight float64) error
+	// 按键写入长期记忆：相同键的记忆被新内容替换，权重取两者中较大的值
+	UpsertLongTermMemory(ctx context.Context, chatID uint, key, content string, weight float64) error
+	// 强化被回复引用的长期记忆：权重提升并重新开始衰减
+	ReinforceMemory(ctx context.Context, chatID uint, content string) error
+
+	// 垃圾回收：清理所有会话中过期的记忆和有效权重过低的长期记忆，返回各类清理的数量
+	CollectGarbage(ctx context.Context) (MemoryGCStats, error)
+	// 删除会话的全部记忆（会话被删除时调用），返回删除的数量
+	DeleteChatMemory(ctx context.Context, chatID uint) (int, error)
+	// 删除会话中内容与content完全相同的短期和长期记忆（消息被删除时调用），置顶的记忆保留，返回删除的数量
+	ForgetMemory(ctx context.Context, chatID uint, content string) (int, error)
+
+	// 长期记忆的查看和编辑（Weight为当前的有效权重），记忆不属于该会话时返回ErrMemoryNotFound
+	ListMemories(ctx context.Context, chatID uint) ([]MemoryItem, error)
+	UpdateMemory(ctx context.Context, chatID uint, memoryID string, update MemoryUpdate) (*MemoryItem, error)
+	DeleteMemory(ctx context.Context, chatID uint, memoryID string) error
 
 	// 记忆检索
 	SearchMemory(ctx context.Context, chatID uint, query string, limit int) ([]string, error)
 }
 
-// InMemoryManager 内存实现的记忆管理器（简单版本）
+// ErrMemoryNotFound 记忆不存在或不属于该会话
+var ErrMemoryNotFound = errors.New("memory not found")
+
+// MemoryUpdate 用户对长期记忆的修改，为nil的字段保持不变
+type MemoryUpdate struct {
+	Content *string
+	Pinned  *bool
+}
+
+// apply 应用修改，并把权重换算为now时刻的有效权重，使衰减从now重新计算
+func (u MemoryUpdate) apply(item *MemoryItem, decay MemoryDecay, now time.Time) {
+	item.Weight = decay.EffectiveWeight(item.Weight, item.UpdatedAt, now)
+	item.UpdatedAt = now
+	if u.Content != nil {
+		item.Content = *u.Content
+	}
+	if u.Pinned != nil {
+		item.Pinned = *u.Pinned
+	}
+}
+
+// memoryItemOverhead 估算每条记忆除内容以外占用的字节数（ID、时间戳等）
+const memoryItemOverhead = 128
+
+// InMemoryOptions 内存记忆管理器的可配置项
+type InMemoryOptions struct {
+	Shards       int         // 分片数量，分片越多锁竞争越少
+	MaxBytes     int64       // 所有会话记忆的总内存预算，超出后淘汰最久未访问的会话
+	ShortTermCap int         // 每个会话保留的短期记忆条数
+	LongTermCap  int         // 每个会话保留的长期记忆条数
+	Decay        MemoryDecay // 长期记忆的衰减与强化策略
+	TTL          MemoryTTL   // 各类记忆的过期时间
+}
+
+// chatMemory 单个会话的记忆
+type chatMemory struct {
+	shortTerm  []MemoryItem // 按时间从早到晚排列
+	longTerm   []MemoryItem // 按有效权重降序排列
+	bytes      int64        // 估算占用的内存
+	lastAccess int64        // 最近访问时间（UnixNano），用于LRU淘汰
+}
+
+// memoryShard 记忆分片，每个分片有独立的锁
+type memoryShard struct {
+	mu    sync.Mutex
+	chats map[uint]*chatMemory
+}
+
+// InMemoryManager 内存实现的记忆管理器：按会话ID分片加锁，可以被多个请求并发访问；
+// 总内存超出预算时按LRU淘汰最久未访问的会话
 type InMemoryManager struct {
-	memories map[uint][]MemoryItem
+	shards    []*memoryShard
+	options   InMemoryOptions
+	usedBytes atomic.Int64
+	evictMu   sync.Mutex    // 同一时间只允许一个淘汰过程
+	seq       atomic.Uint64 // 记忆ID的序号，同一管理器内递增
 }
 
-// NewInMemoryManager 创建新的内存记忆管理器
+// NewInMemoryManager 使用默认配置创建新的内存记忆管理器
 func NewInMemoryManager() *InMemoryManager {
+	return NewInMemoryManagerWithOptions(InMemoryOptions{})
+}
+
+// NewInMemoryManagerWithOptions 创建新的内存记忆管理器
+func NewInMemoryManagerWithOptions(options InMemoryOptions) *InMemoryManager {
+	if options.Shards <= 0 {
+		options.Shards = 16
+	}
+	if options.MaxBytes <= 0 {
+		options.MaxBytes = 64 << 20
+	}
+	if options.ShortTermCap <= 0 {
+		options.ShortTermCap = 10
+	}
+	if options.LongTermCap <= 0 {
+		options.LongTermCap = 50
+	}
+
+	shards := make([]*memoryShard, options.Shards)
+	for i := range shards {
+		shards[i] = &memoryShard{chats: make(map[uint]*chatMemory)}
+	}
 	return &InMemoryManager{
-		memories: make(map[uint][]MemoryItem),
+		shards:  shards,
+		options: options,
 	}
 }
 
 // AddShortTermMemory 添加短期记忆
 func (m *InMemoryManager) AddShortTermMemory(ctx context.Context, chatID uint, content string) error {
+	now := time.Now()
 	item := MemoryItem{
-		ID:        fmt.Sprintf("%d_%d", chatID, time.Now().UnixNano()),
+		ID:        m.nextMemoryID(chatID),
 		ChatID:    chatID,
 		Type:      ShortTermMemory,
 		Content:   content,
 		Weight:    1.0,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	}
-
-	if _, exists := m.memories[chatID]; !exists {
-		m.memories[chatID] = []MemoryItem{}
+		CreatedAt: now,
+		UpdatedAt: now,
 	}
 
-	// 添加到开头（最新的在前）
-	m.memories[chatID] = append([]MemoryItem{item}, m.memories[chatID]...)
+	shard := m.shard(chatID)
+	shard.mu.Lock()
+	chat := shard.getOrCreate(chatID)
+	chat.shortTerm = append(chat.shortTerm, item)
+	delta := itemSize(item)
 
-	// 限制短期记忆数量（保持最近的10条）
-	if len(m.memories[chatID]) > 50 {
-		// 筛选出所有短期记忆
-		var shortTermMemories []MemoryItem
-		var otherMemories []MemoryItem
-		for _, mem := range m.memories[chatID] {
-			if mem.Type == ShortTermMemory {
-				shortTermMemories = append(shortTermMemories, mem)
-			} else {
-				otherMemories = append(otherMemories, mem)
-			}
-		}
-
-		// 只保留最近的10条短期记忆
-		if len(shortTermMemories) > 10 {
-			shortTermMemories = shortTermMemories[:10]
+	// 只保留最近的若干条短期记忆
+	if excess := len(chat.shortTerm) - m.options.ShortTermCap; excess > 0 {
+		for _, dropped := range chat.shortTerm[:excess] {
+			delta -= itemSize(dropped)
 		}
-
-		// 合并回所有记忆
-		m.memories[chatID] = append(shortTermMemories, otherMemories...)
+		chat.shortTerm = append([]MemoryItem(nil), chat.shortTerm[excess:]...)
 	}
+	chat.bytes += delta
+	shard.mu.Unlock()
 
+	m.addUsage(chatID, delta)
 	return nil
 }
 
-// GetShortTermMemory 获取短期记忆
+// GetShortTermMemory 获取最近的短期记忆（按时间从早到晚排列）
 func (m *InMemoryManager) GetShortTermMemory(ctx context.Context, chatID uint, limit int) ([]string, error) {
 	if limit <= 0 {
 		limit = 10 // 默认返回10条
 	}
 
-	memories, exists := m.memories[chatID]
-	if !exists {
+	shard := m.shard(chatID)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	chat := shard.get(chatID)
+	if chat == nil {
 		return []string{}, nil
 	}
 
-	var result []string
-	count := 0
-
-	// 倒序遍历，获取最近的记忆
-	for i := len(memories) - 1; i >= 0; i-- {
-		if memories[i].Type == ShortTermMemory {
-			result = append(result, memories[i].Content)
-			count++
-			if count >= limit {
-				break
-			}
-		}
+	start := len(chat.shortTerm) - limit
+	if start < 0 {
+		start = 0
 	}
-
-	return result, nil
+	return itemContents(chat.shortTerm[start:]), nil
 }
 
 // ClearShortTermMemory 清除短期记忆
 func (m *InMemoryManager) ClearShortTermMemory(ctx context.Context, chatID uint) error {
-	memories, exists := m.memories[chatID]
-	if !exists {
+	shard := m.shard(chatID)
+	shard.mu.Lock()
+	chat := shard.get(chatID)
+	if chat == nil {
+		shard.mu.Unlock()
 		return nil
 	}
 
-	// 保留非短期记忆
-	var otherMemories []MemoryItem
-	for _, mem := range memories {
-		if mem.Type != ShortTermMemory {
-			otherMemories = append(otherMemories, mem)
-		}
+	var delta int64
+	for _, item := range chat.shortTerm {
+		delta -= itemSize(item)
+	}
+	chat.shortTerm = nil
+	chat.bytes += delta
+	if len(chat.longTerm) == 0 {
+		delete(shard.chats, chatID)
 	}
+	shard.mu.Unlock()
 
-	m.memories[chatID] = otherMemories
+	m.usedBytes.Add(delta)
 	return nil
 }
 
 // AddLongTermMemory 添加长期记忆
 func (m *InMemoryManager) AddLongTermMemory(ctx context.Context, chatID uint, content string, weight float64) error {
-	// 检查是否已存在相似内容的记忆
-	if m.isDuplicateMemory(chatID, content) {
-		return nil // 避免重复添加
+	return m.addLongTerm(chatID, "", content, weight)
+}
+
+// addLongTerm 在同一次加锁中查找并写入长期记忆：key不为空时替换相同键的记忆，
+// 内容相同的记忆只保留一条，已有的记忆没有键时补上key
+func (m *InMemoryManager) addLongTerm(chatID uint, key, content string, weight float64) error {
+	now := time.Now()
+
+	shard := m.shard(chatID)
+	shard.mu.Lock()
+	chat := shard.getOrCreate(chatID)
+
+	keyed, same := -1, -1
+	for i := range chat.longTerm {
+		if key != "" && chat.longTerm[i].Key == key {
+			keyed = i
+		}
+		if chat.longTerm[i].Content == content {
+			same = i
+		}
 	}
 
-	item := MemoryItem{
-		ID:        fmt.Sprintf("%d_%d", chatID, time.Now().UnixNano()),
-		ChatID:    chatID,
-		Type:      LongTermMemory,
-		Content:   content,
-		Weight:    weight,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+	var delta int64
+	switch {
+	case keyed >= 0:
+		// 相同键的记忆替换为新内容，权重取旧记忆当前的有效权重和新权重中较大的值
+		existing := &chat.longTerm[keyed]
+		delta = int64(len(content) - len(existing.Content))
+		existing.Content = content
+		existing.Weight = math.Max(m.options.Decay.EffectiveWeight(existing.Weight, existing.UpdatedAt, now), weight)
+		existing.UpdatedAt = now
+	case same >= 0:
+		// 已有相同内容的记忆，没有键时补上key，避免之后按键写入时重复添加
+		existing := &chat.longTerm[same]
+		if key == "" || existing.Key != "" {
+			shard.mu.Unlock()
+			return nil
+		}
+		existing.Key = key
+		existing.Weight = math.Max(m.options.Decay.EffectiveWeight(existing.Weight, existing.UpdatedAt, now), weight)
+		existing.UpdatedAt = now
+	default:
+		item := MemoryItem{
+			ID:        m.nextMemoryID(chatID),
+			ChatID:    chatID,
+			Type:      LongTermMemory,
+			Key:       key,
+			Content:   content,
+			Weight:    weight,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		chat.longTerm = append(chat.longTerm, item)
+		delta = itemSize(item)
 	}
+	sortMemoriesByRank(chat.longTerm, m.options.Decay)
 
-	if _, exists := m.memories[chatID]; !exists {
-		m.memories[chatID] = []MemoryItem{}
+	// 限制长期记忆数量，淘汰有效权重最低的记忆
+	if len(chat.longTerm) > m.options.LongTermCap {
+		for _, dropped := range chat.longTerm[m.options.LongTermCap:] {
+			delta -= itemSize(dropped)
+		}
+		chat.longTerm = chat.longTerm[:m.options.LongTermCap]
 	}
+	chat.bytes += delta
+	shard.mu.Unlock()
+
+	m.addUsage(chatID, delta)
+	return nil
+}
 
-	// 添加长期记忆（按权重排序）
-	m.memories[chatID] = append(m.memories[chatID], item)
+// UpsertLongTermMemory 按键写入长期记忆
+func (m *InMemoryManager) UpsertLongTermMemory(ctx context.Context, chatID uint, key, content string, weight float64) error {
+	return m.addLongTerm(chatID, key, content, weight)
+}
 
-	// 限制长期记忆数量（最多50条）
-	m.limitAndSortMemories(chatID)
+// ReinforceMemory 强化被回复引用的长期记忆
+func (m *InMemoryManager) ReinforceMemory(ctx context.Context, chatID uint, content string) error {
+	shard := m.shard(chatID)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
 
+	chat := shard.get(chatID)
+	if chat == nil {
+		return nil
+	}
+	for i := range chat.longTerm {
+		if chat.longTerm[i].Content == content {
+			now := time.Now()
+			chat.longTerm[i].Weight = m.options.Decay.Reinforce(chat.longTerm[i].Weight, chat.longTerm[i].UpdatedAt, now)
+			chat.longTerm[i].UpdatedAt = now
+			sortMemoriesByRank(chat.longTerm, m.options.Decay)
+			return nil
+		}
+	}
 	return nil
 }
 
-// GetLongTermMemory 获取长期记忆
-func (m *InMemoryManager) GetLongTermMemory(ctx context.Context, chatID uint, limit int) ([]string, error) {
-	if limit <= 0 {
-		limit = 20 // 默认返回20条
+// CollectGarbage 清理所有会话中过期的记忆和有效权重过低的长期记忆
+func (m *InMemoryManager) CollectGarbage(ctx context.Context) (MemoryGCStats, error) {
+	var stats MemoryGCStats
+	decay := m.options.Decay
+	now := time.Now()
+	for _, shard := range m.shards {
+		shard.mu.Lock()
+		var delta int64
+		for chatID, chat := range shard.chats {
+			var freed int64
+
+			// 短期记忆按时间排列，过期的都在前面
+			expired := 0
+			for expired < len(chat.shortTerm) && m.options.TTL.shortTermExpired(chat.shortTerm[expired], now) {
+				freed += itemSize(chat.shortTerm[expired])
+				expired++
+			}
+			if expired > 0 {
+				chat.shortTerm = append([]MemoryItem(nil), chat.shortTerm[expired:]...)
+				stats.ExpiredShortTerm += expired
+			}
+
+			kept := chat.longTerm[:0]
+			for _, item := range chat.longTerm {
+				switch {
+				case m.options.TTL.longTermExpired(item, now):
+					stats.ExpiredLongTerm++
+				case decay.PruneBelow > 0 && !item.Pinned && decay.EffectiveWeight(item.Weight, item.UpdatedAt, now) < decay.PruneBelow:
+					stats.Pruned++
+				default:
+					kept = append(kept, item)
+					continue
+				}
+				freed += itemSize(item)
+			}
+			chat.longTerm = kept
+			chat.bytes -= freed
+			delta -= freed
+			if len(chat.longTerm) == 0 && len(chat.shortTerm) == 0 {
+				delete(shard.chats, chatID)
+			}
+		}
+		shard.mu.Unlock()
+		m.usedBytes.Add(delta)
+	}
+	return stats, nil
+}
+
+// DeleteChatMemory 删除会话的全部记忆
+func (m *InMemoryManager) DeleteChatMemory(ctx context.Context, chatID uint) (int, error) {
+	shard := m.shard(chatID)
+	shard.mu.Lock()
+	chat, ok := shard.chats[chatID]
+	if !ok {
+		shard.mu.Unlock()
+		return 0, nil
 	}
+	delete(shard.chats, chatID)
+	shard.mu.Unlock()
 
-	memories, exists := m.memories[chatID]
-	if !exists {
-		return []string{}, nil
+	m.usedBytes.Add(-chat.bytes)
+	return len(chat.shortTerm) + len(chat.longTerm), nil
+}
+
+// ForgetMemory 删除会话中内容与content完全相同的短期和长期记忆，置顶的记忆保留
+func (m *InMemoryManager) ForgetMemory(ctx context.Context, chatID uint, content string) (int, error) {
+	shard := m.shard(chatID)
+	shard.mu.Lock()
+	chat := shard.get(chatID)
+	if chat == nil {
+		shard.mu.Unlock()
+		return 0, nil
 	}
 
-	var longTermMemories []MemoryItem
-	for _, mem := range memories {
-		if mem.Type == LongTermMemory {
-			longTermMemories = append(longTermMemories, mem)
+	forgotten := 0
+	var delta int64
+	shortTerm := chat.shortTerm[:0]
+	for _, item := range chat.shortTerm {
+		if item.Content == content {
+			delta -= itemSize(item)
+			forgotten++
+			continue
+		}
+		shortTerm = append(shortTerm, item)
+	}
+	chat.shortTerm = shortTerm
+
+	longTerm := chat.longTerm[:0]
+	for _, item := range chat.longTerm {
+		if item.Content == content && !item.Pinned {
+			delta -= itemSize(item)
+			forgotten++
+			continue
 		}
+		longTerm = append(longTerm, item)
+	}
+	chat.longTerm = longTerm
+	chat.bytes += delta
+	if len(chat.longTerm) == 0 && len(chat.shortTerm) == 0 {
+		delete(shard.chats, chatID)
+	}
+	shard.mu.Unlock()
+
+	m.usedBytes.Add(delta)
+	return forgotten, nil
+}
+
+// ListMemories 列出会话的全部长期记忆
+func (m *InMemoryManager) ListMemories(ctx context.Context, chatID uint) ([]MemoryItem, error) {
+	shard := m.shard(chatID)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	chat := shard.get(chatID)
+	if chat == nil {
+		return []MemoryItem{}, nil
 	}
 
-	// 按权重排序（降序）
-	m.sortMemoriesByWeight(longTermMemories)
+	now := time.Now()
+	items := make([]MemoryItem, len(chat.longTerm))
+	for i, item := range chat.longTerm {
+		item.Weight = m.options.Decay.EffectiveWeight(item.Weight, item.UpdatedAt, now)
+		items[i] = item
+	}
+	return items, nil
+}
 
-	var result []string
-	for i, mem := range longTermMemories {
-		result = append(result, mem.Content)
-		if i+1 >= limit {
-			break
+// UpdateMemory 修改长期记忆的内容或置顶状态
+func (m *InMemoryManager) UpdateMemory(ctx context.Context, chatID uint, memoryID string, update MemoryUpdate) (*MemoryItem, error) {
+	shard := m.shard(chatID)
+	shard.mu.Lock()
+	chat := shard.get(chatID)
+	if chat == nil {
+		shard.mu.Unlock()
+		return nil, ErrMemoryNotFound
+	}
+
+	for i := range chat.longTerm {
+		if chat.longTerm[i].ID != memoryID {
+			continue
 		}
+		delta := -itemSize(chat.longTerm[i])
+		update.apply(&chat.longTerm[i], m.options.Decay, time.Now())
+		delta += itemSize(chat.longTerm[i])
+		item := chat.longTerm[i]
+		sortMemoriesByRank(chat.longTerm, m.options.Decay)
+		chat.bytes += delta
+		shard.mu.Unlock()
+
+		m.addUsage(chatID, delta)
+		return &item, nil
 	}
+	shard.mu.Unlock()
+	return nil, ErrMemoryNotFound
+}
 
-	return result, nil
+// DeleteMemory 删除长期记忆
+func (m *InMemoryManager) DeleteMemory(ctx context.Context, chatID uint, memoryID string) error {
+	shard := m.shard(chatID)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	chat := shard.get(chatID)
+	if chat == nil {
+		return ErrMemoryNotFound
+	}
+	for i, item := range chat.longTerm {
+		if item.ID == memoryID {
+			chat.longTerm = append(chat.longTerm[:i], chat.longTerm[i+1:]...)
+			chat.bytes -= itemSize(item)
+			m.usedBytes.Add(-itemSize(item))
+			return nil
+		}
+	}
+	return ErrMemoryNotFound
+}
+
+// GetLongTermMemory 获取有效权重最高的长期记忆
+func (m *InMemoryManager) GetLongTermMemory(ctx context.Context, chatID uint, limit int) ([]string, error) {
+	if limit <= 0 {
+		limit = 20 // 默认返回20条
+	}
+
+	shard := m.shard(chatID)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	chat := shard.get(chatID)
+	if chat == nil {
+		return []string{}, nil
+	}
+
+	end := limit
+	if end > len(chat.longTerm) {
+		end = len(chat.longTerm)
+	}
+	return itemContents(chat.longTerm[:end]), nil
 }
 
 // UpdateMemoryWeight 更新记忆权重
 func (m *InMemoryManager) UpdateMemoryWeight(ctx context.Context, memoryID string, weight float64) error {
-	// 在所有聊天中查找并更新记忆权重
-	for chatID, memories := range m.memories {
-		for i, mem := range memories {
-			if mem.ID == memoryID {
-				memories[i].Weight = weight
-				memories[i].UpdatedAt = time.Now()
-				m.memories[chatID] = memories
-
-				// 重新排序和限制数量
-				m.limitAndSortMemories(chatID)
-				return nil
-			}
+	chatID, err := chatIDFromMemoryID(memoryID)
+	if err != nil {
+		return err
+	}
+
+	shard := m.shard(chatID)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	chat := shard.get(chatID)
+	if chat == nil {
+		return fmt.Errorf("memory not found: %s", memoryID)
+	}
+
+	for i := range chat.longTerm {
+		if chat.longTerm[i].ID == memoryID {
+			chat.longTerm[i].Weight = weight
+			chat.longTerm[i].UpdatedAt = time.Now()
+			sortMemoriesByRank(chat.longTerm, m.options.Decay)
+			return nil
+		}
+	}
+	for i := range chat.shortTerm {
+		if chat.shortTerm[i].ID == memoryID {
+			chat.shortTerm[i].Weight = weight
+			chat.shortTerm[i].UpdatedAt = time.Now()
+			return nil
 		}
 	}
 
@@ -235,118 +575,159 @@ func (m *InMemoryManager) SearchMemory(ctx context.Context, chatID uint, query s
 		limit = 5 // 默认返回5条
 	}
 
-	memories, exists := m.memories[chatID]
-	if !exists {
+	shard := m.shard(chatID)
+	shard.mu.Lock()
+	chat := shard.get(chatID)
+	if chat == nil {
+		shard.mu.Unlock()
 		return []string{}, nil
 	}
 
+	// 简单的字符串匹配，实际应用中可以使用更复杂的算法
 	var matchingMemories []MemoryItem
-	for _, mem := range memories {
-		// 简单的字符串匹配，实际应用中可以使用更复杂的算法
-		if contains(mem.Content, query) {
+	for _, mem := range chat.shortTerm {
+		if strings.Contains(mem.Content, query) {
 			matchingMemories = append(matchingMemories, mem)
 		}
 	}
-
-	// 按权重和时间排序
-	m.sortMemoriesByWeight(matchingMemories)
-
-	var result []string
-	for i, mem := range matchingMemories {
-		result = append(result, mem.Content)
-		if i+1 >= limit {
-			break
+	for _, mem := range chat.longTerm {
+		if strings.Contains(mem.Content, query) {
+			matchingMemories = append(matchingMemories, mem)
 		}
 	}
+	shard.mu.Unlock()
 
-	return result, nil
-}
+	// 按有效权重排序
+	sortMemoriesByRank(matchingMemories, m.options.Decay)
 
-// isDuplicateMemory 检查是否存在相似内容的记忆
-func (m *InMemoryManager) isDuplicateMemory(chatID uint, content string) bool {
-	memories, exists := m.memories[chatID]
-	if !exists {
-		return false
+	if len(matchingMemories) > limit {
+		matchingMemories = matchingMemories[:limit]
 	}
+	return itemContents(matchingMemories), nil
+}
 
-	for _, mem := range memories {
-		if mem.Type == LongTermMemory && mem.Content == content {
-			return true
-		}
+// UsedBytes 当前所有会话记忆估算占用的内存
+func (m *InMemoryManager) UsedBytes() int64 {
+	return m.usedBytes.Load()
+}
+
+// ChatCount 当前保存了记忆的会话数量
+func (m *InMemoryManager) ChatCount() int {
+	count := 0
+	for _, shard := range m.shards {
+		shard.mu.Lock()
+		count += len(shard.chats)
+		shard.mu.Unlock()
 	}
+	return count
+}
 
-	return false
+// nextMemoryID 生成记忆ID（"会话ID_序号"），同一时刻并发写入的记忆也不会重复
+func (m *InMemoryManager) nextMemoryID(chatID uint) string {
+	return fmt.Sprintf("%d_%d", chatID, m.seq.Add(1))
 }
 
-// limitAndSortMemories 限制记忆数量并排序
-func (m *InMemoryManager) limitAndSortMemories(chatID uint) {
-	memories := m.memories[chatID]
-	var longTermMemories []MemoryItem
+// shard 获取会话所在的分片
+func (m *InMemoryManager) shard(chatID uint) *memoryShard {
+	return m.shards[chatID%uint(len(m.shards))]
+}
 
-	// 提取长期记忆
-	for _, mem := range memories {
-		if mem.Type == LongTermMemory {
-			longTermMemories = append(longTermMemories, mem)
-		}
+// addUsage 累加内存占用，超出预算时淘汰空闲会话
+func (m *InMemoryManager) addUsage(chatID uint, delta int64) {
+	if m.usedBytes.Add(delta) > m.options.MaxBytes {
+		m.evict(chatID)
 	}
+}
 
-	// 按权重排序（降序）
-	m.sortMemoriesByWeight(longTermMemories)
+// evict 按最近访问时间从早到晚淘汰会话，直到内存占用降到预算的90%以下；正在写入的会话不会被淘汰
+func (m *InMemoryManager) evict(activeChatID uint) {
+	m.evictMu.Lock()
+	defer m.evictMu.Unlock()
 
-	// 限制长期记忆数量
-	if len(longTermMemories) > 50 {
-		longTermMemories = longTermMemories[:50]
+	if m.usedBytes.Load() <= m.options.MaxBytes {
+		return // 其他请求已经完成了淘汰
 	}
 
-	// 重新构建记忆列表：短期记忆 + 排序后的长期记忆
-	var newMemories []MemoryItem
-	for _, mem := range memories {
-		if mem.Type == ShortTermMemory {
-			newMemories = append(newMemories, mem)
+	type candidate struct {
+		chatID     uint
+		lastAccess int64
+	}
+	var candidates []candidate
+	for _, shard := range m.shards {
+		shard.mu.Lock()
+		for chatID, chat := range shard.chats {
+			if chatID != activeChatID {
+				candidates = append(candidates, candidate{chatID: chatID, lastAccess: chat.lastAccess})
+			}
 		}
+		shard.mu.Unlock()
 	}
-	newMemories = append(newMemories, longTermMemories...)
 
-	m.memories[chatID] = newMemories
-}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].lastAccess < candidates[j].lastAccess
+	})
 
-// sortMemoriesByWeight 按权重排序记忆（降序）
-func (m *InMemoryManager) sortMemoriesByWeight(memories []MemoryItem) {
-	// 简单的冒泡排序，实际应用中可以使用更高效的排序算法
-	for i := 0; i < len(memories)-1; i++ {
-		for j := 0; j < len(memories)-i-1; j++ {
-			if memories[j].Weight < memories[j+1].Weight {
-				memories[j], memories[j+1] = memories[j+1], memories[j]
-			}
+	target := m.options.MaxBytes / 10 * 9
+	for _, c := range candidates {
+		if m.usedBytes.Load() <= target {
+			return
 		}
+
+		shard := m.shard(c.chatID)
+		shard.mu.Lock()
+		// 收集候选后被再次访问的会话不再淘汰
+		if chat, ok := shard.chats[c.chatID]; ok && chat.lastAccess == c.lastAccess {
+			delete(shard.chats, c.chatID)
+			m.usedBytes.Add(-chat.bytes)
+		}
+		shard.mu.Unlock()
 	}
 }
 
-// contains 简单的字符串包含检查
-func contains(s, substr string) bool {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return true
-		}
+// get 获取会话记忆并更新访问时间，调用方需持有分片锁
+func (s *memoryShard) get(chatID uint) *chatMemory {
+	chat, ok := s.chats[chatID]
+	if ok {
+		chat.lastAccess = time.Now().UnixNano()
 	}
-	return false
+	return chat
 }
 
-// SerializeMemory 序列化记忆数据
-func SerializeMemory(memories []MemoryItem) (string, error) {
-	data, err := json.Marshal(memories)
-	if err != nil {
-		return "", err
+// getOrCreate 获取会话记忆，不存在时创建，调用方需持有分片锁
+func (s *memoryShard) getOrCreate(chatID uint) *chatMemory {
+	if chat := s.get(chatID); chat != nil {
+		return chat
 	}
-	return string(data), nil
+	chat := &chatMemory{lastAccess: time.Now().UnixNano()}
+	s.chats[chatID] = chat
+	return chat
 }
 
-// DeserializeMemory 反序列化记忆数据
-func DeserializeMemory(data string) ([]MemoryItem, error) {
-	var memories []MemoryItem
-	err := json.Unmarshal([]byte(data), &memories)
-	if err != nil {
-		return nil, err
+// sortMemoriesByRank 按有效权重排序记忆（降序，置顶的记忆在最前），权重相同时保持原有顺序
+func sortMemoriesByRank(memories []MemoryItem, decay MemoryDecay) {
+	sort.SliceStable(memories, func(i, j int) bool {
+		return memoryRankScore(memories[i], decay) > memoryRankScore(memories[j], decay)
+	})
+}
+
+// memoryRankScore 记忆的排序分数，置顶的记忆使用固定的最高分数
+func memoryRankScore(item MemoryItem, decay MemoryDecay) float64 {
+	if item.Pinned {
+		return pinnedRankScore
+	}
+	return decay.RankScore(item.Weight, item.UpdatedAt)
+}
+
+// itemSize 估算一条记忆占用的内存
+func itemSize(item MemoryItem) int64 {
+	return int64(len(item.Content)) + memoryItemOverhead
+}
+
+// itemContents 提取记忆内容
+func itemContents(items []MemoryItem) []string {
+	result := make([]string, 0, len(items))
+	for _, item := range items {
+		result = append(result, item.Content)
 	}
-	return memories, nil
-}
\ No newline at end of file
+	return result
+}
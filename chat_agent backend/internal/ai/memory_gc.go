@@ -0,0 +1,62 @@
+package ai
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// MemoryTTL 各类记忆的过期时间，0表示不过期：短期记忆从写入时开始计时，
+// 长期记忆从最后一次写入、强化或修改时开始计时，置顶的长期记忆不会过期
+type MemoryTTL struct {
+	ShortTerm time.Duration
+	LongTerm  time.Duration
+}
+
+// shortTermExpired 判断短期记忆在now时刻是否已过期
+func (t MemoryTTL) shortTermExpired(item MemoryItem, now time.Time) bool {
+	return t.ShortTerm > 0 && now.Sub(item.CreatedAt) > t.ShortTerm
+}
+
+// longTermExpired 判断长期记忆在now时刻是否已过期
+func (t MemoryTTL) longTermExpired(item MemoryItem, now time.Time) bool {
+	return t.LongTerm > 0 && !item.Pinned && now.Sub(item.UpdatedAt) > t.LongTerm
+}
+
+// MemoryGCStats 一次垃圾回收清理的记忆数量
+type MemoryGCStats struct {
+	ExpiredShortTerm int // 过期的短期记忆
+	ExpiredLongTerm  int // 过期的长期记忆
+	Pruned           int // 有效权重过低的长期记忆
+}
+
+// Total 清理的记忆总数
+func (s MemoryGCStats) Total() int {
+	return s.ExpiredShortTerm + s.ExpiredLongTerm + s.Pruned
+}
+
+// RunMemoryGC 定期清理过期的记忆和有效权重过低的长期记忆，直到ctx结束
+func RunMemoryGC(ctx context.Context, manager MemoryManager, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stats, err := manager.CollectGarbage(ctx)
+			if err != nil {
+				log.Printf("清理记忆失败: %v", err)
+			}
+			if stats.Total() > 0 {
+				log.Printf("已清理%d条记忆（过期短期记忆%d条，过期长期记忆%d条，权重过低的长期记忆%d条）",
+					stats.Total(), stats.ExpiredShortTerm, stats.ExpiredLongTerm, stats.Pruned)
+			}
+		}
+	}
+}
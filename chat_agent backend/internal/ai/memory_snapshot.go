@@ -0,0 +1,168 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"chat_agent/internal/models"
+)
+
+// MemorySnapshotVersion 当前的记忆快照格式版本，格式不兼容地变化时递增
+const MemorySnapshotVersion = 1
+
+// maxSnapshotShortTerm 导出短期记忆的最大条数
+const maxSnapshotShortTerm = 1000
+
+// MemorySnapshot 带版本的记忆快照，用于导出、导入以及在不同会话或部署之间迁移记忆
+type MemorySnapshot struct {
+	Version    int                  `json:"version"`
+	ExportedAt time.Time            `json:"exported_at"`
+	UserID     uint                 `json:"user_id,omitempty"`
+	Chats      []ChatMemorySnapshot `json:"chats"`
+	Profile    []models.ProfileFact `json:"profile,omitempty"` // 用户画像，只在导出用户的记忆时包含
+}
+
+// ChatMemorySnapshot 单个会话的记忆快照
+type ChatMemorySnapshot struct {
+	ChatID         uint         `json:"chat_id"`
+	StarID         uint         `json:"star_id"`
+	Summary        string       `json:"summary,omitempty"`
+	SummaryUntilID uint         `json:"summary_until_id,omitempty"`
+	ShortTerm      []string     `json:"short_term,omitempty"` // 按时间从早到晚排列
+	LongTerm       []MemoryItem `json:"long_term"`            // Weight为导出时的有效权重
+}
+
+// SerializeMemory 序列化记忆快照，写入当前的格式版本
+func SerializeMemory(snapshot *MemorySnapshot) (string, error) {
+	snapshot.Version = MemorySnapshotVersion
+	if snapshot.Chats == nil {
+		snapshot.Chats = []ChatMemorySnapshot{}
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// DeserializeMemory 反序列化记忆快照，兼容旧版导出的长期记忆数组
+func DeserializeMemory(data string) (*MemorySnapshot, error) {
+	trimmed := bytes.TrimSpace([]byte(data))
+
+	// 旧格式：只有长期记忆的数组，按所属会话分组
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var items []MemoryItem
+		if err := json.Unmarshal(trimmed, &items); err != nil {
+			return nil, fmt.Errorf("decode memory snapshot: %w", err)
+		}
+		snapshot := &MemorySnapshot{Version: MemorySnapshotVersion}
+		index := make(map[uint]int)
+		for _, item := range items {
+			i, ok := index[item.ChatID]
+			if !ok {
+				i = len(snapshot.Chats)
+				index[item.ChatID] = i
+				snapshot.Chats = append(snapshot.Chats, ChatMemorySnapshot{ChatID: item.ChatID})
+			}
+			snapshot.Chats[i].LongTerm = append(snapshot.Chats[i].LongTerm, item)
+		}
+		return snapshot, nil
+	}
+
+	var snapshot MemorySnapshot
+	if err := json.Unmarshal(trimmed, &snapshot); err != nil {
+		return nil, fmt.Errorf("decode memory snapshot: %w", err)
+	}
+	if snapshot.Version < 1 || snapshot.Version > MemorySnapshotVersion {
+		return nil, fmt.Errorf("unsupported memory snapshot version %d", snapshot.Version)
+	}
+	return &snapshot, nil
+}
+
+// ExportChatMemory 导出会话的短期和长期记忆
+func ExportChatMemory(ctx context.Context, manager MemoryManager, chatID uint) (*ChatMemorySnapshot, error) {
+	shortTerm, err := manager.GetShortTermMemory(ctx, chatID, maxSnapshotShortTerm)
+	if err != nil {
+		return nil, fmt.Errorf("export short-term memory: %w", err)
+	}
+	longTerm, err := manager.ListMemories(ctx, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("export long-term memory: %w", err)
+	}
+
+	return &ChatMemorySnapshot{
+		ChatID:    chatID,
+		ShortTerm: shortTerm,
+		LongTerm:  longTerm,
+	}, nil
+}
+
+// ImportChatMemory 把快照中的记忆导入会话。replace为true时先清空会话已有的记忆（用于恢复快照），
+// 否则与已有记忆合并：相同键或相同内容的长期记忆只保留一条。
+// 长期记忆以导出时的有效权重写入，从导入时刻重新开始衰减
+func ImportChatMemory(ctx context.Context, manager MemoryManager, chatID uint, snapshot *ChatMemorySnapshot, replace bool) error {
+	if replace {
+		if err := clearChatMemory(ctx, manager, chatID); err != nil {
+			return err
+		}
+	}
+
+	for _, content := range snapshot.ShortTerm {
+		if err := manager.AddShortTermMemory(ctx, chatID, content); err != nil {
+			return fmt.Errorf("import short-term memory: %w", err)
+		}
+	}
+
+	pinned := make(map[string]bool)
+	for _, item := range snapshot.LongTerm {
+		if item.Content == "" {
+			continue
+		}
+		if err := manager.UpsertLongTermMemory(ctx, chatID, item.Key, item.Content, item.Weight); err != nil {
+			return fmt.Errorf("import long-term memory: %w", err)
+		}
+		if item.Pinned {
+			pinned[item.Content] = true
+		}
+	}
+	if len(pinned) == 0 {
+		return nil
+	}
+
+	// 写入后再恢复置顶状态
+	items, err := manager.ListMemories(ctx, chatID)
+	if err != nil {
+		return fmt.Errorf("import long-term memory: %w", err)
+	}
+	pin := true
+	for _, item := range items {
+		if !pinned[item.Content] || item.Pinned {
+			continue
+		}
+		if _, err := manager.UpdateMemory(ctx, chatID, item.ID, MemoryUpdate{Pinned: &pin}); err != nil {
+			return fmt.Errorf("import long-term memory: %w", err)
+		}
+	}
+	return nil
+}
+
+// clearChatMemory 清空会话的短期和长期记忆
+func clearChatMemory(ctx context.Context, manager MemoryManager, chatID uint) error {
+	if err := manager.ClearShortTermMemory(ctx, chatID); err != nil {
+		return fmt.Errorf("clear short-term memory: %w", err)
+	}
+	items, err := manager.ListMemories(ctx, chatID)
+	if err != nil {
+		return fmt.Errorf("clear long-term memory: %w", err)
+	}
+	for _, item := range items {
+		if err := manager.DeleteMemory(ctx, chatID, item.ID); err != nil && !errors.Is(err, ErrMemoryNotFound) {
+			return fmt.Errorf("clear long-term memory: %w", err)
+		}
+	}
+	return nil
+}
@@ -7,12 +7,81 @@ import (
 	"chat_agent/internal/models"
 )
 
-// PromptTemplate 提示词模板生成器
-type PromptTemplate struct{}
+// messageTokenOverhead 每条消息在token计数之外的角色和格式开销
+const messageTokenOverhead = 4
+
+// PromptOptions 提示词的token预算配置
+type PromptOptions struct {
+	Counter           TokenCounter    // token计数器，为空时使用EstimateCounter
+	ContextWindows    *ContextWindows // 各模型的上下文长度，为空时使用默认表
+	CompletionReserve int             // 为回复预留的token数，默认1024
+}
+
+// PromptTemplate 提示词模板生成器，按模型的上下文长度把系统提示词、记忆、摘要和尽量多的历史对话装进预算
+type PromptTemplate struct {
+	counter           TokenCounter
+	windows           *ContextWindows
+	completionReserve int
+}
 
 // NewPromptTemplate 创建新的提示词模板生成器
 func NewPromptTemplate() *PromptTemplate {
-	return &PromptTemplate{}
+	return NewPromptTemplateWithOptions(PromptOptions{})
+}
+
+// NewPromptTemplateWithOptions 使用指定的token预算配置创建提示词模板生成器
+func NewPromptTemplateWithOptions(options PromptOptions) *PromptTemplate {
+	if options.Counter == nil {
+		options.Counter = EstimateCounter{}
+	}
+	if options.ContextWindows == nil {
+		options.ContextWindows = NewContextWindows(nil, 0)
+	}
+	if options.CompletionReserve <= 0 {
+		options.CompletionReserve = 1024
+	}
+	return &PromptTemplate{
+		counter:           options.Counter,
+		windows:           options.ContextWindows,
+		completionReserve: options.CompletionReserve,
+	}
+}
+
+// PromptBudget 提示词可用的token数：modelNames中上下文最短的模型（回退链中的每个模型都要装得下）减去为回复预留的部分，
+// 未指定模型时按默认上下文长度计算
+func (p *PromptTemplate) PromptBudget(modelNames ...string) int {
+	window := 0
+	for _, model := range modelNames {
+		if size := p.windows.Lookup(model); window == 0 || size < window {
+			window = size
+		}
+	}
+	if window == 0 {
+		window = p.windows.Lookup("")
+	}
+
+	budget := window - p.completionReserve
+	if budget < window/2 {
+		budget = window / 2 // 上下文很短时至少留一半给提示词
+	}
+	return budget
+}
+
+// CountMessageTokens 计算消息列表占用的token数
+func (p *PromptTemplate) CountMessageTokens(messages []map[string]string) int {
+	total := 0
+	for _, message := range messages {
+		total += p.messageTokens(message["content"])
+	}
+	return total
+}
+
+// messageTokens 一条消息占用的token数，空内容的消息不会发送
+func (p *PromptTemplate) messageTokens(content string) int {
+	if content == "" {
+		return 0
+	}
+	return p.counter.CountTokens(content) + messageTokenOverhead
 }
 
 // BuildSystemPrompt 构建系统提示词
@@ -58,25 +127,13 @@ func (p *PromptTemplate) BuildSystemPrompt(star *models.Star) string {
 	)
 }
 
-// BuildUserPrompt 构建用户提示词（包含历史对话上下文）
+// BuildUserPrompt 构建用户提示词（包含历史对话上下文），历史消息由调用方按token预算选择
 func (p *PromptTemplate) BuildUserPrompt(messages []models.Message, currentMessage string) string {
 	var historyBuilder strings.Builder
 
-	// 只保留最近的对话历史，避免token过长
-	maxHistoryLength := 10
-	historyStart := 0
-	if len(messages) > maxHistoryLength {
-		historyStart = len(messages) - maxHistoryLength
-	}
-
 	// 添加历史对话上下文
-	for i := historyStart; i < len(messages); i++ {
-		msg := messages[i]
-		sender := "用户"
-		if msg.SenderType == "star" {
-			sender = "你"
-		}
-		historyBuilder.WriteString(fmt.Sprintf("%s: %s\n", sender, msg.Content))
+	for _, msg := range messages {
+		historyBuilder.WriteString(historyLine(msg))
 	}
 
 	// 添加当前用户消息
@@ -85,18 +142,57 @@ func (p *PromptTemplate) BuildUserPrompt(messages []models.Message, currentMessa
 	return historyBuilder.String()
 }
 
-// BuildMemoryPrompt 构建记忆增强提示词
-func (p *PromptTemplate) BuildMemoryPrompt(star *models.Star, memories []string) string {
-	if len(memories) == 0 {
+// BuildMemoryPrompt 构建记忆增强提示词，用户置顶的记忆总是排在最前面
+func (p *PromptTemplate) BuildMemoryPrompt(star *models.Star, pinned []string, memories []string) string {
+	// 合并置顶记忆和召回的记忆，去掉重复内容
+	seen := make(map[string]bool, len(pinned)+len(memories))
+	var merged []string
+	appendUnique := func(items []string) {
+		for _, memory := range items {
+			if !seen[memory] {
+				seen[memory] = true
+				merged = append(merged, memory)
+			}
+		}
+	}
+	appendUnique(pinned)
+	pinnedCount := len(merged)
+	appendUnique(memories)
+	if len(merged) == 0 {
 		return ""
 	}
 
-	// 按重要性排序（这里简单按时间顺序，最新的在前面）
-	memoriesText := strings.Join(memories, "\n- ")
-	return fmt.Sprintf(`作为%s，请记住以下重要信息，并在对话中自然地引用和参考：
+	// 置顶记忆在前，其余按召回时的相关性和重要性排列
+	memoriesText := strings.Join(merged, "\n- ")
+	prompt := fmt.Sprintf(`作为%s，请记住以下重要信息，并在对话中自然地引用和参考：
 - %s
 
 这些信息来自之前的对话，请将它们融入到你的回应中，保持自然流畅。`, star.Name, memoriesText)
+	if pinnedCount > 0 {
+		prompt += fmt.Sprintf("其中前%d条是用户特别要求你记住的，请务必牢记。", pinnedCount)
+	}
+	return prompt
+}
+
+// BuildProfilePrompt 构建用户画像提示词（你对这位粉丝的了解），没有画像时返回空字符串
+func (p *PromptTemplate) BuildProfilePrompt(star *models.Star, profile []string) string {
+	if len(profile) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(`## 你对这位粉丝的了解
+作为%s，你已经知道关于这位粉丝的以下信息：
+- %s
+
+请在对话中自然地体现你对TA的了解，不必逐条复述，也不要提及这些信息的来源。`, star.Name, strings.Join(profile, "\n- "))
+}
+
+// BuildSummaryPrompt 构建较早对话的摘要提示词，没有摘要时返回空字符串
+func (p *PromptTemplate) BuildSummaryPrompt(star *models.Star, summary string) string {
+	if summary == "" {
+		return ""
+	}
+	return fmt.Sprintf(`以下是你（%s）与用户更早之前对话的摘要，请保持与其中内容的连贯和一致：
+%s`, star.Name, summary)
 }
 
 // ExtractKeyInfo 从对话中提取关键信息（用于长期记忆）
@@ -114,7 +210,12 @@ func (p *PromptTemplate) ExtractKeyInfo(conversation string) []string {
 
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
-		if line == "" || strings.HasPrefix(line, "用户:") || strings.HasPrefix(line, "你:") {
+		// 明星的回复不是关于用户的信息；用户的话去掉前缀后保留
+		if line == "" || strings.HasPrefix(line, "你:") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "用户:"))
+		if line == "" {
 			continue
 		}
 
@@ -149,12 +250,27 @@ func (p *PromptTemplate) ExtractKeyInfo(conversation string) []string {
 	return keyInfos
 }
 
-// BuildChatCompletionMessages 构建完整的聊天完成请求消息
+// historyLine 历史对话中的一行
+func historyLine(msg models.Message) string {
+	sender := "用户"
+	if msg.SenderType == models.SenderTypeStar {
+		sender = "你"
+	}
+	return fmt.Sprintf("%s: %s\n", sender, msg.Content)
+}
+
+// BuildChatCompletionMessages 构建完整的聊天完成请求消息。
+// 系统提示词、用户画像、摘要、置顶记忆和当前消息总会加入；召回的记忆按顺序加入，最多使用剩余预算的一半；
+// 剩余的预算从最新的消息开始尽量装入历史对话。modelNames为可能用来回复的模型，按其中上下文最短的模型计算预算
 func (p *PromptTemplate) BuildChatCompletionMessages(
 	star *models.Star,
+	profile []string,
+	summary string,
 	messages []models.Message,
 	currentMessage string,
+	pinned []string,
 	memories []string,
+	modelNames ...string,
 ) []map[string]string {
 	var completionMessages []map[string]string
 
@@ -165,8 +281,35 @@ func (p *PromptTemplate) BuildChatCompletionMessages(
 		"content": systemPrompt,
 	})
 
-	// 添加记忆增强提示词
-	memoryPrompt := p.BuildMemoryPrompt(star, memories)
+	// 添加用户画像，会话记忆中与画像重复的内容不再重复列出
+	profilePrompt := p.BuildProfilePrompt(star, profile)
+	if profilePrompt != "" {
+		completionMessages = append(completionMessages, map[string]string{
+			"role":    "system",
+			"content": profilePrompt,
+		})
+		memories = excludeItems(memories, profile)
+	}
+
+	// 添加较早对话的摘要
+	summaryPrompt := p.BuildSummaryPrompt(star, summary)
+	if summaryPrompt != "" {
+		completionMessages = append(completionMessages, map[string]string{
+			"role":    "system",
+			"content": summaryPrompt,
+		})
+	}
+
+	// 添加记忆增强提示词：召回的记忆最多使用剩余预算的一半，给最近的对话留出空间，超出时从最不相关的开始舍弃
+	budget := p.PromptBudget(modelNames...)
+	used := p.CountMessageTokens(completionMessages) + p.messageTokens(p.BuildUserPrompt(nil, currentMessage))
+	memoryBudget := (budget - used) / 2
+	memoryPrompt := p.BuildMemoryPrompt(star, pinned, memories)
+	for len(memories) > 0 && p.messageTokens(memoryPrompt) > memoryBudget {
+		memories = memories[:len(memories)-1]
+		memoryPrompt = p.BuildMemoryPrompt(star, pinned, memories)
+	}
+	used += p.messageTokens(memoryPrompt)
 	if memoryPrompt != "" {
 		completionMessages = append(completionMessages, map[string]string{
 			"role":    "system",
@@ -174,12 +317,39 @@ func (p *PromptTemplate) BuildChatCompletionMessages(
 		})
 	}
 
+	// 从最新的消息开始装入历史对话，直到用完预算
+	start := len(messages)
+	for start > 0 {
+		cost := p.counter.CountTokens(historyLine(messages[start-1]))
+		if used+cost > budget {
+			break
+		}
+		used += cost
+		start--
+	}
+
 	// 添加对话历史和当前消息
-	historyPrompt := p.BuildUserPrompt(messages, currentMessage)
+	historyPrompt := p.BuildUserPrompt(messages[start:], currentMessage)
 	completionMessages = append(completionMessages, map[string]string{
 		"role":    "user",
 		"content": historyPrompt,
 	})
 
 	return completionMessages
-}
\ No newline at end of file
+}
+
+// excludeItems 返回items中不在excluded里的内容
+func excludeItems(items, excluded []string) []string {
+	skip := make(map[string]bool, len(excluded))
+	for _, item := range excluded {
+		skip[item] = true
+	}
+
+	var kept []string
+	for _, item := range items {
+		if !skip[item] {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,716 @@
+package ai
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// addLongTermScript 原子地添加长期记忆：带键的记忆替换同键的旧记忆，否则按内容去重，
+// 超出上限时淘汰有效权重最低的记忆
+//
+// KEYS[1] 长期记忆有序集合（成员为记忆ID，分数为MemoryDecay.RankScore）
+// KEYS[2] 记忆内容哈希表（记忆ID -> MemoryItem JSON）
+// KEYS[3] 内容索引哈希表（内容SHA1 -> 记忆ID，"key:"+键 -> 记忆ID）
+// ARGV[1] 记忆ID  ARGV[2] 权重  ARGV[3] MemoryItem JSON  ARGV[4] 记忆内容
+// ARGV[5] 长期记忆上限  ARGV[6] 过期时间（秒，0表示不过期）  ARGV[7] 记忆的键（可为空）
+// ARGV[8] 当前时间在排序分数中的时间项，排序分数 = ln(权重) + 时间项
+var addLongTermScript = redis.NewScript(`
+local digest = redis.sha1hex(ARGV[4])
+local key = ARGV[7]
+local offset = tonumber(ARGV[8])
+local score = math.log(math.max(tonumber(ARGV[2]), 1e-6)) + offset
+
+if key ~= '' then
+  local existingID = redis.call('HGET', KEYS[3], 'key:' .. key)
+  if existingID then
+    local raw = redis.call('HGET', KEYS[2], existingID)
+    if raw then
+      local old = cjson.decode(raw)
+      local item = cjson.decode(ARGV[3])
+      redis.call('HDEL', KEYS[3], redis.sha1hex(old.content))
+      item.id = existingID
+      item.created_at = old.created_at
+      local oldScore = redis.call('ZSCORE', KEYS[1], existingID)
+      if old.pinned then
+        -- 置顶的记忆保持置顶和原有权重，只替换内容
+        item.pinned = true
+        item.weight = old.weight
+        item.updated_at = old.updated_at
+        score = tonumber(oldScore) or score
+      elseif oldScore and tonumber(oldScore) > score then
+        -- 权重取旧记忆当前的有效权重和新权重中较大的值
+        score = tonumber(oldScore)
+        item.weight = math.exp(score - offset)
+      end
+      redis.call('HSET', KEYS[2], existingID, cjson.encode(item))
+      redis.call('HSET', KEYS[3], digest, existingID)
+      redis.call('ZADD', KEYS[1], score, existingID)
+      return 1
+    end
+  end
+end
+
+local sameID = redis.call('HGET', KEYS[3], digest)
+if sameID then
+  -- 已有相同内容的记忆，没有键时补上key，权重取两者中较大的值
+  local raw = redis.call('HGET', KEYS[2], sameID)
+  if key == '' or not raw then
+    return 0
+  end
+  local old = cjson.decode(raw)
+  if old.key and old.key ~= '' then
+    return 0
+  end
+  old.key = key
+  local oldScore = redis.call('ZSCORE', KEYS[1], sameID)
+  if not old.pinned and (not oldScore or score > tonumber(oldScore)) then
+    local item = cjson.decode(ARGV[3])
+    old.weight = item.weight
+    old.updated_at = item.updated_at
+    redis.call('ZADD', KEYS[1], score, sameID)
+  end
+  redis.call('HSET', KEYS[2], sameID, cjson.encode(old))
+  redis.call('HSET', KEYS[3], 'key:' .. key, sameID)
+  return 1
+end
+
+redis.call('HSET', KEYS[2], ARGV[1], ARGV[3])
+redis.call('HSET', KEYS[3], digest, ARGV[1])
+if key ~= '' then
+  redis.call('HSET', KEYS[3], 'key:' .. key, ARGV[1])
+end
+redis.call('ZADD', KEYS[1], score, ARGV[1])
+
+local excess = redis.call('ZCARD', KEYS[1]) - tonumber(ARGV[5])
+if excess > 0 then
+  local removed = redis.call('ZPOPMIN', KEYS[1], excess)
+  for i = 1, #removed, 2 do
+    local raw = redis.call('HGET', KEYS[2], removed[i])
+    if raw then
+      local item = cjson.decode(raw)
+      redis.call('HDEL', KEYS[3], redis.sha1hex(item.content))
+      if item.key then
+        redis.call('HDEL', KEYS[3], 'key:' .. item.key)
+      end
+    end
+    redis.call('HDEL', KEYS[2], removed[i])
+  end
+end
+
+local ttl = tonumber(ARGV[6])
+if ttl > 0 then
+  redis.call('EXPIRE', KEYS[1], ttl)
+  redis.call('EXPIRE', KEYS[2], ttl)
+  redis.call('EXPIRE', KEYS[3], ttl)
+end
+return 1
+`)
+
+// RedisMemoryOptions Redis记忆管理器的可配置项
+type RedisMemoryOptions struct {
+	KeyPrefix    string        // 键前缀
+	ShortTermCap int           // 每个会话保留的短期记忆条数
+	LongTermCap  int           // 每个会话保留的长期记忆条数
+	TTL          time.Duration // 会话记忆的空闲过期时间，0表示不过期
+	Decay        MemoryDecay   // 长期记忆的衰减与强化策略
+	ItemTTL      MemoryTTL     // 每条记忆的过期时间，由CollectGarbage清理
+}
+
+// RedisMemoryManager 基于Redis的记忆管理器：短期记忆使用定长列表，长期记忆使用按有效权重排序的有序集合，
+// 重启后记忆不丢失，多个实例共享同一份记忆
+type RedisMemoryManager struct {
+	client  *redis.Client
+	options RedisMemoryOptions
+}
+
+// NewRedisMemoryManager 创建新的Redis记忆管理器
+func NewRedisMemoryManager(client *redis.Client, options RedisMemoryOptions) *RedisMemoryManager {
+	if options.KeyPrefix == "" {
+		options.KeyPrefix = "memory"
+	}
+	if options.ShortTermCap <= 0 {
+		options.ShortTermCap = 10
+	}
+	if options.LongTermCap <= 0 {
+		options.LongTermCap = 50
+	}
+	return &RedisMemoryManager{
+		client:  client,
+		options: options,
+	}
+}
+
+// AddShortTermMemory 添加短期记忆
+func (m *RedisMemoryManager) AddShortTermMemory(ctx context.Context, chatID uint, content string) error {
+	memoryID, err := m.nextMemoryID(ctx, chatID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	data, err := json.Marshal(MemoryItem{
+		ID:        memoryID,
+		ChatID:    chatID,
+		Type:      ShortTermMemory,
+		Content:   content,
+		Weight:    1.0,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		return err
+	}
+
+	// 最新的在列表头部，只保留最近的若干条
+	key := m.shortTermKey(chatID)
+	pipe := m.client.TxPipeline()
+	pipe.LPush(ctx, key, data)
+	pipe.LTrim(ctx, key, 0, int64(m.options.ShortTermCap-1))
+	if m.options.TTL > 0 {
+		pipe.Expire(ctx, key, m.options.TTL)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// GetShortTermMemory 获取最近的短期记忆（按时间从早到晚排列）
+func (m *RedisMemoryManager) GetShortTermMemory(ctx context.Context, chatID uint, limit int) ([]string, error) {
+	if limit <= 0 {
+		limit = 10 // 默认返回10条
+	}
+
+	items, err := m.loadShortTerm(ctx, chatID, int64(limit))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0, len(items))
+	for i := len(items) - 1; i >= 0; i-- {
+		result = append(result, items[i].Content)
+	}
+	return result, nil
+}
+
+// ClearShortTermMemory 清除短期记忆
+func (m *RedisMemoryManager) ClearShortTermMemory(ctx context.Context, chatID uint) error {
+	return m.client.Del(ctx, m.shortTermKey(chatID)).Err()
+}
+
+// AddLongTermMemory 添加长期记忆（内容相同的记忆只保留一条）
+func (m *RedisMemoryManager) AddLongTermMemory(ctx context.Context, chatID uint, content string, weight float64) error {
+	return m.addLongTerm(ctx, chatID, "", content, weight)
+}
+
+// UpsertLongTermMemory 按键写入长期记忆
+func (m *RedisMemoryManager) UpsertLongTermMemory(ctx context.Context, chatID uint, key, content string, weight float64) error {
+	return m.addLongTerm(ctx, chatID, key, content, weight)
+}
+
+// addLongTerm 通过脚本原子地写入长期记忆
+func (m *RedisMemoryManager) addLongTerm(ctx context.Context, chatID uint, key, content string, weight float64) error {
+	memoryID, err := m.nextMemoryID(ctx, chatID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	item := MemoryItem{
+		ID:        memoryID,
+		ChatID:    chatID,
+		Type:      LongTermMemory,
+		Key:       key,
+		Content:   content,
+		Weight:    weight,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	keys := []string{m.longTermKey(chatID), m.itemsKey(chatID), m.indexKey(chatID)}
+	return addLongTermScript.Run(ctx, m.client, keys,
+		item.ID, weight, data, content, m.options.LongTermCap, int64(m.options.TTL/time.Second), key,
+		m.options.Decay.RankScore(1, now),
+	).Err()
+}
+
+// ReinforceMemory 强化被回复引用的长期记忆
+func (m *RedisMemoryManager) ReinforceMemory(ctx context.Context, chatID uint, content string) error {
+	memoryID, err := m.client.HGet(ctx, m.indexKey(chatID), contentDigest(content)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	item, err := m.loadLongTerm(ctx, chatID, memoryID)
+	if err != nil || item == nil {
+		return err
+	}
+	now := time.Now()
+	return m.saveWeight(ctx, item, m.options.Decay.Reinforce(item.Weight, item.UpdatedAt, now), now)
+}
+
+// CollectGarbage 清理所有会话中过期的记忆和有效权重过低的长期记忆
+func (m *RedisMemoryManager) CollectGarbage(ctx context.Context) (MemoryGCStats, error) {
+	var stats MemoryGCStats
+	now := time.Now()
+
+	if m.options.ItemTTL.ShortTerm > 0 {
+		iter := m.client.Scan(ctx, 0, m.options.KeyPrefix+":chat:*:short", 100).Iterator()
+		for iter.Next(ctx) {
+			expired, err := m.removeShortTerm(ctx, iter.Val(), func(item MemoryItem) bool {
+				return m.options.ItemTTL.shortTermExpired(item, now)
+			})
+			stats.ExpiredShortTerm += expired
+			if err != nil {
+				return stats, err
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return stats, err
+		}
+	}
+
+	if m.options.ItemTTL.LongTerm <= 0 && m.options.Decay.PruneBelow <= 0 {
+		return stats, nil
+	}
+	threshold := "(" + strconv.FormatFloat(m.options.Decay.PruneScore(now), 'f', -1, 64)
+	iter := m.client.Scan(ctx, 0, m.options.KeyPrefix+":chat:*:long", 100).Iterator()
+	for iter.Next(ctx) {
+		longKey := iter.Val()
+		chatID, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(longKey, m.options.KeyPrefix+":chat:"), ":long"), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		// 先清理过期的长期记忆
+		if m.options.ItemTTL.LongTerm > 0 {
+			values, err := m.client.HVals(ctx, m.itemsKey(uint(chatID))).Result()
+			if err != nil {
+				return stats, err
+			}
+			var ids []string
+			for _, raw := range values {
+				var item MemoryItem
+				if err := json.Unmarshal([]byte(raw), &item); err != nil {
+					continue
+				}
+				if m.options.ItemTTL.longTermExpired(item, now) {
+					ids = append(ids, item.ID)
+				}
+			}
+			if len(ids) > 0 {
+				if err := m.removeLongTerm(ctx, uint(chatID), ids); err != nil {
+					return stats, err
+				}
+				stats.ExpiredLongTerm += len(ids)
+			}
+		}
+
+		// 再清理有效权重过低的长期记忆
+		if m.options.Decay.PruneBelow <= 0 {
+			continue
+		}
+		ids, err := m.client.ZRangeByScore(ctx, longKey, &redis.ZRangeBy{Min: "-inf", Max: threshold}).Result()
+		if err != nil {
+			return stats, err
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		if err := m.removeLongTerm(ctx, uint(chatID), ids); err != nil {
+			return stats, err
+		}
+		stats.Pruned += len(ids)
+	}
+	return stats, iter.Err()
+}
+
+// DeleteChatMemory 删除会话的全部记忆
+func (m *RedisMemoryManager) DeleteChatMemory(ctx context.Context, chatID uint) (int, error) {
+	pipe := m.client.TxPipeline()
+	shortTerm := pipe.LLen(ctx, m.shortTermKey(chatID))
+	longTerm := pipe.ZCard(ctx, m.longTermKey(chatID))
+	pipe.Del(ctx, m.shortTermKey(chatID), m.longTermKey(chatID), m.itemsKey(chatID), m.indexKey(chatID))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return int(shortTerm.Val() + longTerm.Val()), nil
+}
+
+// ForgetMemory 删除会话中内容与content完全相同的短期和长期记忆，置顶的记忆保留
+func (m *RedisMemoryManager) ForgetMemory(ctx context.Context, chatID uint, content string) (int, error) {
+	forgotten, err := m.removeShortTerm(ctx, m.shortTermKey(chatID), func(item MemoryItem) bool {
+		return item.Content == content
+	})
+	if err != nil {
+		return forgotten, err
+	}
+
+	memoryID, err := m.client.HGet(ctx, m.indexKey(chatID), contentDigest(content)).Result()
+	if errors.Is(err, redis.Nil) {
+		return forgotten, nil
+	}
+	if err != nil {
+		return forgotten, err
+	}
+	item, err := m.loadLongTerm(ctx, chatID, memoryID)
+	if err != nil || item == nil || item.Pinned {
+		return forgotten, err
+	}
+	if err := m.removeLongTerm(ctx, chatID, []string{memoryID}); err != nil {
+		return forgotten, err
+	}
+	return forgotten + 1, nil
+}
+
+// ListMemories 列出会话的全部长期记忆
+func (m *RedisMemoryManager) ListMemories(ctx context.Context, chatID uint) ([]MemoryItem, error) {
+	values, err := m.client.HVals(ctx, m.itemsKey(chatID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]MemoryItem, 0, len(values))
+	for _, raw := range values {
+		var item MemoryItem
+		if err := json.Unmarshal([]byte(raw), &item); err != nil {
+			continue
+		}
+		items = append(items, item)
+	}
+	sortMemoriesByRank(items, m.options.Decay)
+
+	now := time.Now()
+	for i := range items {
+		items[i].Weight = m.options.Decay.EffectiveWeight(items[i].Weight, items[i].UpdatedAt, now)
+	}
+	return items, nil
+}
+
+// UpdateMemory 修改长期记忆的内容或置顶状态
+func (m *RedisMemoryManager) UpdateMemory(ctx context.Context, chatID uint, memoryID string, update MemoryUpdate) (*MemoryItem, error) {
+	item, err := m.loadOwnedLongTerm(ctx, chatID, memoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	oldDigest := contentDigest(item.Content)
+	update.apply(item, m.options.Decay, time.Now())
+	data, err := json.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := m.client.TxPipeline()
+	pipe.HSet(ctx, m.itemsKey(chatID), memoryID, data)
+	pipe.ZAddXX(ctx, m.longTermKey(chatID), redis.Z{Score: memoryRankScore(*item, m.options.Decay), Member: memoryID})
+	if digest := contentDigest(item.Content); digest != oldDigest {
+		pipe.HDel(ctx, m.indexKey(chatID), oldDigest)
+		pipe.HSet(ctx, m.indexKey(chatID), digest, memoryID)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// DeleteMemory 删除长期记忆
+func (m *RedisMemoryManager) DeleteMemory(ctx context.Context, chatID uint, memoryID string) error {
+	if _, err := m.loadOwnedLongTerm(ctx, chatID, memoryID); err != nil {
+		return err
+	}
+	return m.removeLongTerm(ctx, chatID, []string{memoryID})
+}
+
+// GetLongTermMemory 获取有效权重最高的长期记忆
+func (m *RedisMemoryManager) GetLongTermMemory(ctx context.Context, chatID uint, limit int) ([]string, error) {
+	if limit <= 0 {
+		limit = 20 // 默认返回20条
+	}
+
+	ids, err := m.client.ZRevRange(ctx, m.longTermKey(chatID), 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return []string{}, nil
+	}
+
+	values, err := m.client.HMGet(ctx, m.itemsKey(chatID), ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		raw, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var item MemoryItem
+		if err := json.Unmarshal([]byte(raw), &item); err != nil {
+			continue
+		}
+		result = append(result, item.Content)
+	}
+	return result, nil
+}
+
+// UpdateMemoryWeight 更新长期记忆的权重
+func (m *RedisMemoryManager) UpdateMemoryWeight(ctx context.Context, memoryID string, weight float64) error {
+	chatID, err := chatIDFromMemoryID(memoryID)
+	if err != nil {
+		return err
+	}
+
+	item, err := m.loadLongTerm(ctx, chatID, memoryID)
+	if err != nil {
+		return err
+	}
+	if item == nil {
+		return fmt.Errorf("memory not found: %s", memoryID)
+	}
+	return m.saveWeight(ctx, item, weight, time.Now())
+}
+
+// SearchMemory 在短期和长期记忆中搜索包含关键词的记忆，按权重降序返回
+func (m *RedisMemoryManager) SearchMemory(ctx context.Context, chatID uint, query string, limit int) ([]string, error) {
+	if limit <= 0 {
+		limit = 5 // 默认返回5条
+	}
+
+	shortTerm, err := m.loadShortTerm(ctx, chatID, int64(m.options.ShortTermCap))
+	if err != nil {
+		return nil, err
+	}
+	values, err := m.client.HVals(ctx, m.itemsKey(chatID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var matching []MemoryItem
+	for _, item := range shortTerm {
+		if strings.Contains(item.Content, query) {
+			matching = append(matching, item)
+		}
+	}
+	for _, raw := range values {
+		var item MemoryItem
+		if err := json.Unmarshal([]byte(raw), &item); err != nil {
+			continue
+		}
+		if strings.Contains(item.Content, query) {
+			matching = append(matching, item)
+		}
+	}
+
+	sortMemoriesByRank(matching, m.options.Decay)
+
+	result := make([]string, 0, limit)
+	for i := 0; i < len(matching) && i < limit; i++ {
+		result = append(result, matching[i].Content)
+	}
+	return result, nil
+}
+
+// loadShortTerm 读取最近的count条短期记忆（最新的在前）
+func (m *RedisMemoryManager) loadShortTerm(ctx context.Context, chatID uint, count int64) ([]MemoryItem, error) {
+	values, err := m.client.LRange(ctx, m.shortTermKey(chatID), 0, count-1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]MemoryItem, 0, len(values))
+	for _, raw := range values {
+		var item MemoryItem
+		if err := json.Unmarshal([]byte(raw), &item); err != nil {
+			continue
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// nextMemoryID 通过会话的计数器生成记忆ID（"会话ID_序号"），多个实例并发写入同一会话时也不会重复；
+// 计数器的过期时间随每次写入刷新，不早于会话的其他键过期，清除会话记忆时保留，已删除记忆的ID不会被复用
+func (m *RedisMemoryManager) nextMemoryID(ctx context.Context, chatID uint) (string, error) {
+	key := m.seqKey(chatID)
+	pipe := m.client.TxPipeline()
+	seq := pipe.Incr(ctx, key)
+	if m.options.TTL > 0 {
+		pipe.Expire(ctx, key, m.options.TTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d_%d", chatID, seq.Val()), nil
+}
+
+// removeShortTerm 从短期记忆列表中删除满足条件的记忆，返回删除的数量。
+// 按原始值逐条删除，不会误删并发写入的新记忆
+func (m *RedisMemoryManager) removeShortTerm(ctx context.Context, key string, match func(MemoryItem) bool) (int, error) {
+	values, err := m.client.LRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, raw := range values {
+		var item MemoryItem
+		if err := json.Unmarshal([]byte(raw), &item); err != nil || !match(item) {
+			continue
+		}
+		count, err := m.client.LRem(ctx, key, 1, raw).Result()
+		if err != nil {
+			return removed, err
+		}
+		removed += int(count)
+	}
+	return removed, nil
+}
+
+// loadLongTerm 读取一条长期记忆，不存在时返回nil
+func (m *RedisMemoryManager) loadLongTerm(ctx context.Context, chatID uint, memoryID string) (*MemoryItem, error) {
+	raw, err := m.client.HGet(ctx, m.itemsKey(chatID), memoryID).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var item MemoryItem
+	if err := json.Unmarshal([]byte(raw), &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// loadOwnedLongTerm 读取属于该会话的一条长期记忆，不存在时返回ErrMemoryNotFound
+func (m *RedisMemoryManager) loadOwnedLongTerm(ctx context.Context, chatID uint, memoryID string) (*MemoryItem, error) {
+	owner, err := chatIDFromMemoryID(memoryID)
+	if err != nil || owner != chatID {
+		return nil, ErrMemoryNotFound
+	}
+
+	item, err := m.loadLongTerm(ctx, chatID, memoryID)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, ErrMemoryNotFound
+	}
+	return item, nil
+}
+
+// saveWeight 保存长期记忆的新权重，并以now为起点重新开始衰减
+func (m *RedisMemoryManager) saveWeight(ctx context.Context, item *MemoryItem, weight float64, now time.Time) error {
+	item.Weight = weight
+	item.UpdatedAt = now
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	pipe := m.client.TxPipeline()
+	pipe.ZAddXX(ctx, m.longTermKey(item.ChatID), redis.Z{Score: memoryRankScore(*item, m.options.Decay), Member: item.ID})
+	pipe.HSet(ctx, m.itemsKey(item.ChatID), item.ID, data)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// removeLongTerm 删除长期记忆及其内容索引和键索引
+func (m *RedisMemoryManager) removeLongTerm(ctx context.Context, chatID uint, ids []string) error {
+	values, err := m.client.HMGet(ctx, m.itemsKey(chatID), ids...).Result()
+	if err != nil {
+		return err
+	}
+
+	var indexFields []string
+	for _, value := range values {
+		raw, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var item MemoryItem
+		if err := json.Unmarshal([]byte(raw), &item); err != nil {
+			continue
+		}
+		indexFields = append(indexFields, contentDigest(item.Content))
+		if item.Key != "" {
+			indexFields = append(indexFields, "key:"+item.Key)
+		}
+	}
+
+	members := make([]interface{}, len(ids))
+	for i, id := range ids {
+		members[i] = id
+	}
+
+	pipe := m.client.TxPipeline()
+	pipe.ZRem(ctx, m.longTermKey(chatID), members...)
+	pipe.HDel(ctx, m.itemsKey(chatID), ids...)
+	if len(indexFields) > 0 {
+		pipe.HDel(ctx, m.indexKey(chatID), indexFields...)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// shortTermKey 短期记忆列表的键
+func (m *RedisMemoryManager) shortTermKey(chatID uint) string {
+	return fmt.Sprintf("%s:chat:%d:short", m.options.KeyPrefix, chatID)
+}
+
+// longTermKey 长期记忆有序集合的键
+func (m *RedisMemoryManager) longTermKey(chatID uint) string {
+	return fmt.Sprintf("%s:chat:%d:long", m.options.KeyPrefix, chatID)
+}
+
+// itemsKey 长期记忆内容哈希表的键
+func (m *RedisMemoryManager) itemsKey(chatID uint) string {
+	return fmt.Sprintf("%s:chat:%d:items", m.options.KeyPrefix, chatID)
+}
+
+// indexKey 长期记忆内容索引的键，用于去重
+func (m *RedisMemoryManager) indexKey(chatID uint) string {
+	return fmt.Sprintf("%s:chat:%d:index", m.options.KeyPrefix, chatID)
+}
+
+// seqKey 记忆ID计数器的键
+func (m *RedisMemoryManager) seqKey(chatID uint) string {
+	return fmt.Sprintf("%s:chat:%d:seq", m.options.KeyPrefix, chatID)
+}
+
+// contentDigest 记忆内容的SHA1，与脚本中redis.sha1hex的结果一致
+func contentDigest(content string) string {
+	sum := sha1.Sum([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// chatIDFromMemoryID 从记忆ID（"会话ID_序号"）中解析会话ID
+func chatIDFromMemoryID(memoryID string) (uint, error) {
+	prefix, _, found := strings.Cut(memoryID, "_")
+	if !found {
+		return 0, fmt.Errorf("invalid memory id: %s", memoryID)
+	}
+	chatID, err := strconv.ParseUint(prefix, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid memory id: %s", memoryID)
+	}
+	return uint(chatID), nil
+}
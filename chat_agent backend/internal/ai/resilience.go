@@ -0,0 +1,265 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen 提供方的熔断器处于打开状态，请求未发送
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// errFirstChunkTimeout 流式调用在超时时间内没有返回第一个数据块，与单次调用超时一样可以重试
+var errFirstChunkTimeout = fmt.Errorf("no stream chunk before timeout: %w", context.DeadlineExceeded)
+
+// 熔断器状态
+const (
+	BreakerClosed   = "closed"    // 正常放行
+	BreakerOpen     = "open"      // 连续失败过多，拒绝请求
+	BreakerHalfOpen = "half_open" // 冷却结束，放行一个探测请求
+)
+
+// BreakerStatus 一个提供方的熔断器状态，用于健康检查
+type BreakerStatus struct {
+	Provider            string     `json:"provider"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+}
+
+// CircuitBreaker 熔断器：连续失败达到阈值后打开，冷却一段时间后进入半开状态，
+// 放行一个探测请求，探测成功则关闭，失败则重新打开
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     string
+	failures  int
+	openedAt  time.Time
+	probing   bool
+}
+
+// NewCircuitBreaker 创建新的熔断器，threshold为打开前允许的连续失败次数，cooldown为打开后的冷却时间
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold <= 0 {
+		threshold = 5
+	}
+	if cooldown <= 0 {
+		cooldown = 30 * time.Second
+	}
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     BreakerClosed,
+	}
+}
+
+// Allow 判断是否放行请求，放行后必须调用Record报告结果
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return nil
+	case BreakerHalfOpen:
+		// 半开状态同一时间只放行一个探测请求
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+		return nil
+	}
+	return nil
+}
+
+// Record 报告请求结果，failed表示提供方不可用（可重试的错误），请求本身有误不算失败
+func (b *CircuitBreaker) Record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if !failed {
+		b.state = BreakerClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// Status 熔断器当前的状态
+func (b *CircuitBreaker) Status(provider string) BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{
+		Provider:            provider,
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+	}
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.cooldown {
+		status.State = BreakerHalfOpen // 冷却已结束，下一个请求将作为探测请求
+	}
+	if b.state != BreakerClosed {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+	return status
+}
+
+// ResilienceOptions 模型调用的超时、重试和熔断配置
+type ResilienceOptions struct {
+	Timeout          time.Duration // 单次调用的超时时间，流式调用只限制收到第一个数据块之前的时间，0表示不限制
+	MaxAttempts      int           // 最多尝试的次数（包含第一次）
+	BaseDelay        time.Duration // 第一次重试前的基础等待时间，之后按指数增长
+	MaxDelay         time.Duration // 单次等待的上限，服务端要求等待更久时不再重试
+	BreakerThreshold int           // 熔断器打开前允许的连续失败次数
+	BreakerCooldown  time.Duration // 熔断器打开后的冷却时间
+}
+
+// ResilientClient 为一个提供方的LLMClient增加单次调用超时、带抖动的指数退避重试（遵循Retry-After）和熔断
+type ResilientClient struct {
+	name    string
+	client  LLMClient
+	options ResilienceOptions
+	breaker *CircuitBreaker
+}
+
+// NewResilientClient 创建新的弹性客户端，name为提供方名称
+func NewResilientClient(name string, client LLMClient, options ResilienceOptions) *ResilientClient {
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = 3
+	}
+	if options.BaseDelay <= 0 {
+		options.BaseDelay = 500 * time.Millisecond
+	}
+	if options.MaxDelay <= 0 {
+		options.MaxDelay = 10 * time.Second
+	}
+	return &ResilientClient{
+		name:    name,
+		client:  client,
+		options: options,
+		breaker: NewCircuitBreaker(options.BreakerThreshold, options.BreakerCooldown),
+	}
+}
+
+// GenerateResponse 生成非流式响应，失败时按策略重试
+func (c *ResilientClient) GenerateResponse(ctx context.Context, messages []map[string]string, options GenerateOptions) (*Completion, error) {
+	return c.do(ctx, func(ctx context.Context) (*Completion, bool, error) {
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if c.options.Timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, c.options.Timeout)
+		}
+		defer cancel()
+
+		completion, err := c.client.GenerateResponse(attemptCtx, messages, options)
+		return completion, true, err
+	})
+}
+
+// GenerateStreamResponse 生成流式响应，只有在还没有返回任何内容时失败才会重试；
+// 超时只限制等待第一个数据块的时间，开始输出后长回复不会被中途截断
+func (c *ResilientClient) GenerateStreamResponse(ctx context.Context, messages []map[string]string, options GenerateOptions, callback func(string) error) (*Completion, error) {
+	return c.do(ctx, func(ctx context.Context) (*Completion, bool, error) {
+		attemptCtx, cancel := context.WithCancelCause(ctx)
+		defer cancel(nil)
+		stopTimer := func() bool { return false }
+		if c.options.Timeout > 0 {
+			stopTimer = time.AfterFunc(c.options.Timeout, func() { cancel(errFirstChunkTimeout) }).Stop
+		}
+		defer stopTimer()
+
+		started := false
+		completion, err := c.client.GenerateStreamResponse(attemptCtx, messages, options, func(chunk string) error {
+			if !started {
+				started = true
+				stopTimer()
+			}
+			return callback(chunk)
+		})
+		if err != nil && errors.Is(context.Cause(attemptCtx), errFirstChunkTimeout) {
+			err = errFirstChunkTimeout
+		}
+		return completion, !started, err
+	})
+}
+
+// BreakerStatus 提供方熔断器的状态
+func (c *ResilientClient) BreakerStatus() BreakerStatus {
+	return c.breaker.Status(c.name)
+}
+
+// do 执行一次带重试和熔断的调用，单次调用的超时由attempt负责，attempt返回的retryable为false时即使错误可重试也不再重试
+func (c *ResilientClient) do(ctx context.Context, attempt func(ctx context.Context) (*Completion, bool, error)) (*Completion, error) {
+	var lastErr error
+	for i := 1; ; i++ {
+		if err := c.breaker.Allow(); err != nil {
+			if lastErr != nil {
+				return nil, lastErr
+			}
+			return nil, fmt.Errorf("%s: %w", c.name, err)
+		}
+
+		completion, retryable, err := attempt(ctx)
+		if err == nil {
+			c.breaker.Record(false)
+			return completion, nil
+		}
+
+		transient := IsRetryableError(ctx, err)
+		c.breaker.Record(transient)
+		if !transient || !retryable || i >= c.options.MaxAttempts {
+			return nil, err
+		}
+
+		delay := c.backoff(i)
+		if retryAfter := retryAfterOf(err); retryAfter > 0 {
+			if retryAfter > c.options.MaxDelay {
+				return nil, err // 服务端要求等待太久，直接返回错误
+			}
+			delay = retryAfter
+		}
+		log.Printf("调用模型失败(provider=%s, 第%d次)，%v后重试: %v", c.name, i, delay, err)
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+		lastErr = err
+	}
+}
+
+// backoff 第attempt次失败后的等待时间：指数增长，在[一半, 全部]之间随机抖动
+func (c *ResilientClient) backoff(attempt int) time.Duration {
+	delay := c.options.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > c.options.MaxDelay {
+		delay = c.options.MaxDelay
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// sleepContext 等待一段时间，ctx结束时提前返回
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
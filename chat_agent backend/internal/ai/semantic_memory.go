@@ -0,0 +1,194 @@
+package ai
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+)
+
+// ScoredMemory 带相似度得分的记忆
+type ScoredMemory struct {
+	Content string
+	Score   float64
+}
+
+// vectorEntry 向量索引中的一条记忆
+type vectorEntry struct {
+	content string
+	vector  []float32
+}
+
+// VectorIndex 进程内的向量索引，按会话分别保存记忆向量，使用余弦相似度检索
+type VectorIndex struct {
+	mu         sync.RWMutex
+	chats      map[uint][]vectorEntry
+	maxPerChat int
+}
+
+// NewVectorIndex 创建新的向量索引，每个会话最多保存maxPerChat条记忆
+func NewVectorIndex(maxPerChat int) *VectorIndex {
+	if maxPerChat <= 0 {
+		maxPerChat = 200
+	}
+	return &VectorIndex{
+		chats:      make(map[uint][]vectorEntry),
+		maxPerChat: maxPerChat,
+	}
+}
+
+// Add 添加记忆向量，内容相同时更新向量，超出上限时淘汰最早的记忆
+func (idx *VectorIndex) Add(chatID uint, content string, vector []float32) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	entries := idx.chats[chatID]
+	for i := range entries {
+		if entries[i].content == content {
+			entries[i].vector = vector
+			return
+		}
+	}
+
+	entries = append(entries, vectorEntry{content: content, vector: vector})
+	if len(entries) > idx.maxPerChat {
+		entries = append([]vectorEntry(nil), entries[len(entries)-idx.maxPerChat:]...)
+	}
+	idx.chats[chatID] = entries
+}
+
+// Search 返回与查询向量最相似的k条记忆，相似度低于minScore的记忆会被过滤
+func (idx *VectorIndex) Search(chatID uint, query []float32, k int, minScore float64) []ScoredMemory {
+	idx.mu.RLock()
+	entries := idx.chats[chatID]
+	scored := make([]ScoredMemory, 0, len(entries))
+	for _, entry := range entries {
+		score := CosineSimilarity(query, entry.vector)
+		if score >= minScore {
+			scored = append(scored, ScoredMemory{Content: entry.content, Score: score})
+		}
+	}
+	idx.mu.RUnlock()
+
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].Score > scored[j].Score
+	})
+	if len(scored) > k {
+		scored = scored[:k]
+	}
+	return scored
+}
+
+// Clear 删除会话的全部记忆向量
+func (idx *VectorIndex) Clear(chatID uint) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	delete(idx.chats, chatID)
+}
+
+// Sync 使会话的记忆向量与contents一致：删除不在contents中的记忆向量，返回尚未向量化的内容
+func (idx *VectorIndex) Sync(chatID uint, contents []string) []string {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	wanted := make(map[string]bool, len(contents))
+	for _, content := range contents {
+		wanted[content] = true
+	}
+
+	entries := idx.chats[chatID][:0]
+	for _, entry := range idx.chats[chatID] {
+		if wanted[entry.content] {
+			entries = append(entries, entry)
+			delete(wanted, entry.content)
+		}
+	}
+	if len(entries) == 0 {
+		delete(idx.chats, chatID)
+	} else {
+		idx.chats[chatID] = entries
+	}
+
+	missing := make([]string, 0, len(wanted))
+	for _, content := range contents {
+		if wanted[content] {
+			missing = append(missing, content)
+			delete(wanted, content)
+		}
+	}
+	return missing
+}
+
+// SemanticMemoryManager 在任意记忆管理器之上增加语义检索：SearchMemory按与查询的语义相似度返回记忆。
+// 索引只是进程内的向量缓存，每次检索前都与底层记忆同步（只向量化新增的内容，删除已不存在的内容），
+// 因此多个实例共享Redis或数据库中的记忆时，其他实例写入、修改和删除的记忆也能立即反映到检索结果中
+type SemanticMemoryManager struct {
+	MemoryManager
+
+	embedder Embedder
+	index    *VectorIndex
+	minScore float64
+}
+
+// NewSemanticMemoryManager 创建新的语义记忆管理器，minScore为检索结果的最低相似度
+func NewSemanticMemoryManager(base MemoryManager, embedder Embedder, index *VectorIndex, minScore float64) *SemanticMemoryManager {
+	return &SemanticMemoryManager{
+		MemoryManager: base,
+		embedder:      embedder,
+		index:         index,
+		minScore:      minScore,
+	}
+}
+
+// DeleteChatMemory 删除会话的全部记忆及其向量
+func (m *SemanticMemoryManager) DeleteChatMemory(ctx context.Context, chatID uint) (int, error) {
+	count, err := m.MemoryManager.DeleteChatMemory(ctx, chatID)
+	m.index.Clear(chatID)
+	return count, err
+}
+
+// SearchMemory 按语义相似度检索记忆，向量化失败时退回底层记忆管理器的检索
+func (m *SemanticMemoryManager) SearchMemory(ctx context.Context, chatID uint, query string, limit int) ([]string, error) {
+	if limit <= 0 {
+		limit = 5 // 默认返回5条
+	}
+
+	if err := m.sync(ctx, chatID); err != nil {
+		log.Printf("同步记忆向量失败，使用关键词检索: %v", err)
+		return m.MemoryManager.SearchMemory(ctx, chatID, query, limit)
+	}
+
+	vectors, err := m.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		log.Printf("查询向量化失败，使用关键词检索: %v", err)
+		return m.MemoryManager.SearchMemory(ctx, chatID, query, limit)
+	}
+
+	scored := m.index.Search(chatID, vectors[0], limit, m.minScore)
+	result := make([]string, 0, len(scored))
+	for _, item := range scored {
+		result = append(result, item.Content)
+	}
+	return result, nil
+}
+
+// sync 从底层记忆读取会话当前的长期记忆，使索引与之一致，只向量化索引中还没有的内容
+func (m *SemanticMemoryManager) sync(ctx context.Context, chatID uint) error {
+	contents, err := m.MemoryManager.GetLongTermMemory(ctx, chatID, m.index.maxPerChat)
+	if err != nil {
+		return err
+	}
+
+	missing := m.index.Sync(chatID, contents)
+	if len(missing) == 0 {
+		return nil
+	}
+	vectors, err := m.embedder.Embed(ctx, missing)
+	if err != nil {
+		return err
+	}
+	for i, content := range missing {
+		m.index.Add(chatID, content, vectors[i])
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"chat_agent/internal/models"
+)
+
+// maxSummaryLength 摘要的最大长度（按字符）
+const maxSummaryLength = 500
+
+// chatSummaryPrompt 生成滚动摘要时的系统提示词
+const chatSummaryPrompt = `你是对话摘要助手。下面是用户与%s之间已有的对话摘要和之后新的对话记录，请把它们合并为一份新的摘要。
+要求：
+1. 使用第三人称，按时间顺序概括
+2. 保留用户透露的个人信息、双方的约定、聊过的重要话题和尚未结束的话题，省略寒暄和重复内容
+3. 不超过%d字，只输出摘要本身`
+
+// Summarizer 滚动摘要生成器：把滑出上下文窗口的较早对话压缩进会话的摘要，
+// 每积累Every条新消息更新一次
+type Summarizer struct {
+	llmClient LLMClient
+	model     string
+	every     int
+}
+
+// NewSummarizer 创建新的摘要生成器，model为空时使用客户端的默认模型
+func NewSummarizer(llmClient LLMClient, model string, every int) *Summarizer {
+	return &Summarizer{
+		llmClient: llmClient,
+		model:     model,
+		every:     every,
+	}
+}
+
+// Every 每积累多少条未摘要的消息更新一次摘要
+func (s *Summarizer) Every() int {
+	return s.every
+}
+
+// Summarize 把新的对话记录合并进已有摘要，返回新的摘要
+func (s *Summarizer) Summarize(ctx context.Context, star *models.Star, summary string, messages []models.Message) (string, error) {
+	if len(messages) == 0 {
+		return summary, nil
+	}
+
+	var builder strings.Builder
+	if summary != "" {
+		builder.WriteString("已有摘要：\n")
+		builder.WriteString(summary)
+		builder.WriteString("\n\n")
+	}
+	builder.WriteString("新的对话记录：\n")
+	for _, msg := range messages {
+		sender := "用户"
+		if msg.SenderType == models.SenderTypeStar {
+			sender = star.Name
+		}
+		builder.WriteString(fmt.Sprintf("%s: %s\n", sender, msg.Content))
+	}
+
+	completion, err := s.llmClient.GenerateResponse(ctx, []map[string]string{
+		{"role": "system", "content": fmt.Sprintf(chatSummaryPrompt, star.Name, maxSummaryLength)},
+		{"role": "user", "content": builder.String()},
+	}, GenerateOptions{Model: s.model})
+	if err != nil {
+		return "", fmt.Errorf("summarize chat: %w", err)
+	}
+
+	result := strings.TrimSpace(completion.Content)
+	if result == "" {
+		return "", errors.New("summarize chat: empty summary")
+	}
+	// 模型没有遵守长度要求时截断，避免摘要无限增长
+	if runes := []rune(result); len(runes) > maxSummaryLength*2 {
+		result = string(runes[:maxSummaryLength*2])
+	}
+	return result, nil
+}
@@ -0,0 +1,179 @@
+package ai
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TokenCounter 计算文本的token数，用于按模型的上下文长度分配提示词预算
+type TokenCounter interface {
+	CountTokens(text string) int
+}
+
+// EstimateCounter 不需要词表的估算器：中日韩字符按每字1个token，其他字符按每4个字符1个token
+type EstimateCounter struct{}
+
+// CountTokens 实现TokenCounter接口
+func (EstimateCounter) CountTokens(text string) int {
+	return estimateTokens(text)
+}
+
+// estimateTokens 粗略估算文本的token数：中日韩字符按每字1个token，其他字符按每4个字符1个token
+func estimateTokens(text string) int {
+	cjk, other := 0, 0
+	for _, r := range text {
+		if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + (other+3)/4
+}
+
+// bpePattern cl100k_base的预分词规则。RE2的\s只包含ASCII空白，这里补上Unicode空白；
+// RE2也不支持其中的`\s+(?!\S)`，由splitPieces单独处理空白
+var bpePattern = regexp.MustCompile(`^(?:(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\v\x{85}\p{Z}\p{L}\p{N}]+[\r\n]*|[\s\v\x{85}\p{Z}]*[\r\n]+)`)
+
+// BPECounter 与tiktoken兼容的字节级BPE分词器，词表为tiktoken格式（每行是base64编码的token和它的rank），
+// 例如OpenAI公开的cl100k_base.tiktoken
+type BPECounter struct {
+	ranks map[string]int
+}
+
+// LoadBPECounter 从tiktoken格式的词表文件创建BPE分词器
+func LoadBPECounter(path string) (*BPECounter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return NewBPECounter(file)
+}
+
+// NewBPECounter 从tiktoken格式的词表创建BPE分词器
+func NewBPECounter(r io.Reader) (*BPECounter, error) {
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		encoded, rankText, found := strings.Cut(text, " ")
+		if !found {
+			return nil, fmt.Errorf("invalid bpe ranks at line %d", line)
+		}
+		token, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bpe token at line %d: %w", line, err)
+		}
+		rank, err := strconv.Atoi(rankText)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bpe rank at line %d: %w", line, err)
+		}
+		ranks[string(token)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("empty bpe ranks")
+	}
+	return &BPECounter{ranks: ranks}, nil
+}
+
+// CountTokens 实现TokenCounter接口
+func (c *BPECounter) CountTokens(text string) int {
+	return len(c.Encode(text))
+}
+
+// Encode 把文本编码为token的rank序列
+func (c *BPECounter) Encode(text string) []int {
+	var tokens []int
+	for _, piece := range splitPieces(text) {
+		if rank, ok := c.ranks[piece]; ok {
+			tokens = append(tokens, rank)
+			continue
+		}
+		tokens = append(tokens, c.mergePiece(piece)...)
+	}
+	return tokens
+}
+
+// mergePiece 对一个预分词片段做字节对合并：每次合并rank最小的相邻片段，直到没有可以合并的片段
+func (c *BPECounter) mergePiece(piece string) []int {
+	parts := make([]string, len(piece))
+	for i := 0; i < len(piece); i++ {
+		parts[i] = piece[i : i+1]
+	}
+
+	for len(parts) > 1 {
+		best, bestRank := -1, 0
+		for i := 0; i+1 < len(parts); i++ {
+			if rank, ok := c.ranks[parts[i]+parts[i+1]]; ok && (best < 0 || rank < bestRank) {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		parts[best] += parts[best+1]
+		parts = append(parts[:best+1], parts[best+2:]...)
+	}
+
+	tokens := make([]int, 0, len(parts))
+	for _, part := range parts {
+		if rank, ok := c.ranks[part]; ok {
+			tokens = append(tokens, rank)
+		} else {
+			tokens = append(tokens, -1) // 词表中缺少单字节时仍计为一个token
+		}
+	}
+	return tokens
+}
+
+// splitPieces 按cl100k_base的规则预分词。连续空白后面跟着非空白字符时，最后一个空白字符留给下一个片段，
+// 与tiktoken中`\s+(?!\S)|\s+`的效果相同
+func splitPieces(text string) []string {
+	var pieces []string
+	for len(text) > 0 {
+		if match := bpePattern.FindString(text); match != "" {
+			pieces = append(pieces, match)
+			text = text[len(match):]
+			continue
+		}
+
+		// 剩下的情况只有空白字符
+		end := 0
+		lastSize := 0
+		for end < len(text) {
+			r, size := utf8.DecodeRuneInString(text[end:])
+			if !unicode.IsSpace(r) {
+				break
+			}
+			end += size
+			lastSize = size
+		}
+		if end == 0 {
+			// 无法识别的字节，单独作为一个片段
+			_, size := utf8.DecodeRuneInString(text)
+			end = size
+		} else if end < len(text) && end > lastSize {
+			end -= lastSize
+		}
+		pieces = append(pieces, text[:end])
+		text = text[end:]
+	}
+	return pieces
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"strconv"
+
+	"chat_agent/internal/models"
+	"chat_agent/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHandler API密钥API处理器
+type APIKeyHandler struct {
+	apiKeyService service.APIKeyService
+}
+
+// NewAPIKeyHandler 创建新的API密钥API处理器
+func NewAPIKeyHandler(apiKeyService service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+	}
+}
+
+// CreateKey 创建API密钥
+func (h *APIKeyHandler) CreateKey(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateAPIKeyRequest
+
+	// 绑定请求参数
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ParamError(c, err)
+		return
+	}
+
+	// 调用服务层创建API密钥
+	result, err := h.apiKeyService.CreateKey(c.Request.Context(), userID, &req)
+	if err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+
+	// 返回成功响应
+	SuccessWithMessage(c, "创建成功，请妥善保存密钥，它不会再次显示", result)
+}
+
+// ListKeys 获取当前用户的API密钥列表
+func (h *APIKeyHandler) ListKeys(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	// 调用服务层获取API密钥列表
+	keys, err := h.apiKeyService.ListKeys(c.Request.Context(), userID)
+	if err != nil {
+		ServerError(c, err)
+		return
+	}
+
+	// 返回成功响应
+	Success(c, keys)
+}
+
+// RevokeKey 吊销API密钥
+func (h *APIKeyHandler) RevokeKey(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	// 获取密钥ID
+	keyID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		ParamError(c, err)
+		return
+	}
+
+	// 调用服务层吊销API密钥
+	if err := h.apiKeyService.RevokeKey(c.Request.Context(), userID, uint(keyID)); err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+
+	// 返回成功响应
+	SuccessWithMessage(c, "吊销成功", nil)
+}
+
+// RegisterRoutes 注册API密钥相关路由
+func (h *APIKeyHandler) RegisterRoutes(router *gin.RouterGroup) {
+	// API密钥只能通过登录会话管理，不能用API密钥再创建密钥
+	keys := router.Group("/api-keys")
+	keys.Use(Authorize(PolicySession))
+	{
+		keys.GET("", h.ListKeys)
+		keys.POST("", h.CreateKey)
+		keys.DELETE("/:id", h.RevokeKey)
+	}
+}
@@ -0,0 +1,252 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"chat_agent/internal/middleware"
+	"chat_agent/internal/models"
+	"chat_agent/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuthHandler 认证API处理器
+type AuthHandler struct {
+	authService  service.AuthService
+	guestService service.GuestService
+}
+
+// NewAuthHandler 创建新的认证API处理器
+func NewAuthHandler(authService service.AuthService, guestService service.GuestService) *AuthHandler {
+	return &AuthHandler{
+		authService:  authService,
+		guestService: guestService,
+	}
+}
+
+// Register 用户注册
+func (h *AuthHandler) Register(c *gin.Context) {
+	var req models.RegisterRequest
+
+	// 绑定请求参数
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ParamError(c, err)
+		return
+	}
+
+	// 调用服务层注册用户
+	result, err := h.authService.Register(c.Request.Context(), &req)
+	if err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+
+	// 游客注册时，把游客期间的会话转移到新账号
+	if guestToken, err := c.Cookie(middleware.GuestCookieName); err == nil && guestToken != "" {
+		if err := h.guestService.UpgradeGuest(c.Request.Context(), guestToken, result.User.ID); err != nil {
+			log.Printf("转移游客数据失败: %v", err)
+		}
+		setCookie(c, middleware.GuestCookieName, "", "/", -1)
+	}
+
+	// 返回成功响应
+	SuccessWithMessage(c, "注册成功", result)
+}
+
+// CreateGuestSession 创建游客会话，游客令牌通过Cookie下发
+func (h *AuthHandler) CreateGuestSession(c *gin.Context) {
+	// 调用服务层创建游客
+	session, err := h.guestService.CreateGuest(c.Request.Context(), c.ClientIP())
+	if err != nil {
+		ServerError(c, err)
+		return
+	}
+
+	setCookie(c, middleware.GuestCookieName, session.GuestToken, "/", int(time.Until(session.ExpiresAt).Seconds()))
+
+	// 返回成功响应
+	SuccessWithMessage(c, "已开始游客会话", session)
+}
+
+// Login 用户登录
+func (h *AuthHandler) Login(c *gin.Context) {
+	var req models.LoginRequest
+
+	// 绑定请求参数
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ParamError(c, err)
+		return
+	}
+
+	// 调用服务层登录
+	result, err := h.authService.Login(c.Request.Context(), &req)
+	if err != nil {
+		Fail(c, 401, err.Error())
+		return
+	}
+
+	// 返回成功响应
+	SuccessWithMessage(c, "登录成功", result)
+}
+
+// Refresh 刷新访问令牌
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req models.RefreshTokenRequest
+
+	// 绑定请求参数
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ParamError(c, err)
+		return
+	}
+
+	// 调用服务层刷新令牌
+	result, err := h.authService.Refresh(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		Fail(c, 401, err.Error())
+		return
+	}
+
+	// 返回成功响应
+	Success(c, result)
+}
+
+// Logout 退出登录
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req models.RefreshTokenRequest
+
+	// 绑定请求参数
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ParamError(c, err)
+		return
+	}
+
+	// 调用服务层吊销刷新令牌
+	if err := h.authService.Logout(c.Request.Context(), req.RefreshToken); err != nil {
+		ServerError(c, err)
+		return
+	}
+
+	// 返回成功响应
+	SuccessWithMessage(c, "已退出登录", nil)
+}
+
+// GetCurrentUser 获取当前登录用户信息
+func (h *AuthHandler) GetCurrentUser(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	// 调用服务层获取用户信息
+	user, err := h.authService.GetCurrentUser(c.Request.Context(), userID)
+	if err != nil {
+		NotFound(c, err.Error())
+		return
+	}
+
+	// 返回成功响应
+	Success(c, user)
+}
+
+// ChangePassword 修改密码
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.ChangePasswordRequest
+
+	// 绑定请求参数
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ParamError(c, err)
+		return
+	}
+
+	// 调用服务层修改密码
+	if err := h.authService.ChangePassword(c.Request.Context(), userID, &req); err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+
+	// 返回成功响应
+	SuccessWithMessage(c, "密码修改成功，请重新登录", nil)
+}
+
+// ForgotPassword 申请重置密码
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+
+	// 绑定请求参数
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ParamError(c, err)
+		return
+	}
+
+	// 调用服务层发送重置令牌
+	if err := h.authService.RequestPasswordReset(c.Request.Context(), req.Email); err != nil {
+		ServerError(c, err)
+		return
+	}
+
+	// 无论邮箱是否存在都返回相同的响应
+	SuccessWithMessage(c, "如果该邮箱已注册，重置密码的链接将发送到该邮箱", nil)
+}
+
+// ResetPassword 使用重置令牌设置新密码
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+
+	// 绑定请求参数
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ParamError(c, err)
+		return
+	}
+
+	// 调用服务层重置密码
+	if err := h.authService.ResetPassword(c.Request.Context(), &req); err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+
+	// 返回成功响应
+	SuccessWithMessage(c, "密码重置成功，请使用新密码登录", nil)
+}
+
+// RegisterRoutes 注册认证相关路由
+func (h *AuthHandler) RegisterRoutes(router *gin.RouterGroup, authMiddleware gin.HandlerFunc, rateLimits RateLimits) {
+	authGroup := router.Group("/auth")
+	{
+		// 公开路由
+		authGroup.POST("/register", h.Register)
+		authGroup.POST("/login", h.Login)
+		authGroup.POST("/refresh", h.Refresh)
+		authGroup.POST("/logout", h.Logout)
+		authGroup.POST("/password/forgot", h.ForgotPassword)
+		authGroup.POST("/password/reset", h.ResetPassword)
+		authGroup.POST("/guest", orPassThrough(rateLimits.CreateGuest), h.CreateGuestSession)
+
+		// 需要登录的路由
+		authGroup.GET("/me", authMiddleware, h.GetCurrentUser)
+		authGroup.POST("/password/change", authMiddleware, Authorize(PolicySession), h.ChangePassword)
+	}
+}
+
+// currentUserID 获取当前认证用户的ID，未认证时直接返回401响应
+func currentUserID(c *gin.Context) (uint, bool) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		Unauthorized(c)
+		return 0, false
+	}
+	return userID, true
+}
+
+// setCookie 写入或清除仅限HTTP访问的Cookie，maxAge小于0表示清除
+func setCookie(c *gin.Context, name, value, path string, maxAge int) {
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(name, value, maxAge, path, "", secure, true)
+}
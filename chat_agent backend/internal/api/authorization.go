@@ -0,0 +1,77 @@
+package api
+
+import (
+	"chat_agent/internal/auth"
+	"chat_agent/internal/middleware"
+	"chat_agent/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Policy 路由授权策略
+type Policy struct {
+	MinRole     string   // 访问该路由所需的最低角色
+	Scopes      []string // 使用API密钥访问时必须具备的权限范围
+	SessionOnly bool     // 只允许用户登录会话访问，不接受API密钥
+	AllowGuest  bool     // 是否允许未注册的游客访问
+}
+
+// 预定义的路由授权策略
+var (
+	// PolicyChatRead 读取聊天会话和消息
+	PolicyChatRead = Policy{MinRole: models.RoleUser, Scopes: []string{models.ScopeChatRead}, AllowGuest: true}
+	// PolicyChatWrite 创建会话、发送和删除消息
+	PolicyChatWrite = Policy{MinRole: models.RoleUser, Scopes: []string{models.ScopeChatWrite}, AllowGuest: true}
+	// PolicyEditor 编辑及以上角色可访问
+	PolicyEditor = Policy{MinRole: models.RoleEditor, Scopes: []string{models.ScopeStarsWrite}}
+	// PolicyAdmin 仅管理员可访问
+	PolicyAdmin = Policy{MinRole: models.RoleAdmin, Scopes: []string{models.ScopeStarsAdmin}}
+	// PolicyAdminSession 仅管理员通过登录会话访问
+	PolicyAdminSession = Policy{MinRole: models.RoleAdmin, SessionOnly: true}
+	// PolicySession 任意登录用户通过登录会话访问（如管理自己的API密钥）
+	PolicySession = Policy{MinRole: models.RoleUser, SessionOnly: true}
+)
+
+// Authorize 按策略校验当前调用方的权限，需挂载在认证中间件之后
+func Authorize(policy Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := middleware.GetUserID(c); !ok {
+			Unauthorized(c)
+			c.Abort()
+			return
+		}
+
+		if !policy.Allows(c) {
+			Forbidden(c)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Allows 判断已通过认证的调用方是否满足策略，用于在处理器中校验只有部分调用方可以修改的字段
+func (p Policy) Allows(c *gin.Context) bool {
+	if !models.RoleAtLeast(middleware.GetRole(c), p.MinRole) {
+		return false
+	}
+
+	// 游客只能访问明确允许的路由
+	if middleware.GetAuthMethod(c) == auth.MethodGuest && !p.AllowGuest {
+		return false
+	}
+
+	// API密钥还需要校验权限范围
+	if middleware.GetAuthMethod(c) == auth.MethodAPIKey {
+		if p.SessionOnly {
+			return false
+		}
+		granted := middleware.GetScopes(c)
+		for _, scope := range p.Scopes {
+			if !models.HasScope(granted, scope) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -1,10 +1,13 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 
+	"chat_agent/internal/auth"
+	"chat_agent/internal/middleware"
 	"chat_agent/internal/models"
 	"chat_agent/internal/service"
 
@@ -13,20 +16,24 @@ import (
 
 // ChatHandler 聊天API处理器
 type ChatHandler struct {
-	chatService service.ChatService
+	chatService  service.ChatService
+	guestService service.GuestService
 }
 
 // NewChatHandler 创建新的聊天API处理器
-func NewChatHandler(chatService service.ChatService) *ChatHandler {
+func NewChatHandler(chatService service.ChatService, guestService service.GuestService) *ChatHandler {
 	return &ChatHandler{
-		chatService: chatService,
+		chatService:  chatService,
+		guestService: guestService,
 	}
 }
 
 // CreateChat 创建聊天会话
 func (h *ChatHandler) CreateChat(c *gin.Context) {
-	// 使用固定用户ID 1，简化为无需登录的聊天
-	userID := uint(1)
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
 
 	var req struct {
 		StarID uint `json:"star_id" binding:"required"`
@@ -51,8 +58,10 @@ func (h *ChatHandler) CreateChat(c *gin.Context) {
 
 // GetUserChats 获取用户的聊天会话列表
 func (h *ChatHandler) GetUserChats(c *gin.Context) {
-	// 使用固定用户ID 1，简化为无需登录的聊天
-	userID := uint(1)
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
 
 	// 获取分页参数
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
@@ -79,8 +88,10 @@ func (h *ChatHandler) GetUserChats(c *gin.Context) {
 
 // GetChatByID 获取聊天会话详情
 func (h *ChatHandler) GetChatByID(c *gin.Context) {
-	// 使用固定用户ID 1，简化为无需登录的聊天
-	userID := uint(1)
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
 
 	// 获取聊天ID
 	chatID, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -102,8 +113,10 @@ func (h *ChatHandler) GetChatByID(c *gin.Context) {
 
 // GetOrCreateChatWithStar 获取或创建与特定明星的聊天会话
 func (h *ChatHandler) GetOrCreateChatWithStar(c *gin.Context) {
-	// 使用固定用户ID 1，简化为无需登录的聊天
-	userID := uint(1)
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
 
 	// 获取明星ID
 	starID, err := strconv.ParseUint(c.Query("star_id"), 10, 32)
@@ -125,8 +138,10 @@ func (h *ChatHandler) GetOrCreateChatWithStar(c *gin.Context) {
 
 // UpdateChat 更新聊天会话信息
 func (h *ChatHandler) UpdateChat(c *gin.Context) {
-	// 使用固定用户ID 1，简化为无需登录的聊天
-	userID := uint(1)
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
 
 	// 获取聊天ID
 	chatID, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -135,19 +150,22 @@ func (h *ChatHandler) UpdateChat(c *gin.Context) {
 		return
 	}
 
-	var req struct {
-		Title string `json:"title" binding:"required"`
-	}
+	var req models.UpdateChatRequest
 
 	// 绑定请求参数
 	if err := c.ShouldBindJSON(&req); err != nil {
 		ParamError(c, err)
 		return
 	}
+	if req.Title == "" && req.GenerationParams.IsEmpty() {
+		BadRequest(c, "标题和生成参数不能都为空")
+		return
+	}
 
 	// 构建更新字段
 	updates := map[string]interface{}{
-		"title": req.Title,
+		"title":      req.Title,
+		"generation": req.GenerationParams,
 	}
 
 	// 调用服务层更新聊天会话信息
@@ -163,8 +181,10 @@ func (h *ChatHandler) UpdateChat(c *gin.Context) {
 
 // DeleteChat 删除聊天会话
 func (h *ChatHandler) DeleteChat(c *gin.Context) {
-	// 使用固定用户ID 1，简化为无需登录的聊天
-	userID := uint(1)
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
 
 	// 获取聊天ID
 	chatID, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -186,8 +206,10 @@ func (h *ChatHandler) DeleteChat(c *gin.Context) {
 
 // SendMessage 发送消息
 func (h *ChatHandler) SendMessage(c *gin.Context) {
-	// 使用固定用户ID 1，简化为无需登录的聊天
-	userID := uint(1)
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
 	
 	// 添加详细日志
 	fmt.Println("收到发送消息请求")
@@ -204,17 +226,19 @@ func (h *ChatHandler) SendMessage(c *gin.Context) {
 	// 记录请求参数
 	fmt.Printf("请求参数: ChatID=%d, Content=%s, Model=%s\n", req.ChatID, req.Content, req.Model)
 
-	// 保留原始模型参数，如果未指定则使用默认豆包模型
-	if req.Model == "" {
-		req.Model = "doubao-1.5-pro-32k-250115"
-	}
-	fmt.Printf("使用模型: %s\n", req.Model)
-
 	// 调用服务层发送消息
 	fmt.Println("调用服务层发送消息...")
 	responseMessage, err := h.chatService.SendMessage(c.Request.Context(), userID, &req)
 	if err != nil {
 		fmt.Printf("服务层处理失败: %v\n", err)
+		if errors.Is(err, service.ErrQuotaExceeded) {
+			TooManyRequests(c, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrUnsupportedModel) || errors.Is(err, service.ErrInvalidGenerationParams) {
+			BadRequest(c, err.Error())
+			return
+		}
 		ServerError(c, err)
 		return
 	}
@@ -227,8 +251,10 @@ func (h *ChatHandler) SendMessage(c *gin.Context) {
 
 // SendMessageStream 流式发送消息
 func (h *ChatHandler) SendMessageStream(c *gin.Context) {
-	// 使用固定用户ID 1，简化为无需登录的聊天
-	userID := uint(1)
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
 
 	var req models.SendMessageRequest
 
@@ -238,26 +264,18 @@ func (h *ChatHandler) SendMessageStream(c *gin.Context) {
 		return
 	}
 
-	// 保留原始模型参数，如果未指定则使用默认豆包模型
-	if req.Model == "" {
-		req.Model = "doubao-1.5-pro-32k-250115"
-	}
-	fmt.Printf("使用模型: %s\n", req.Model)
-	
-
 	// 调用服务层流式发送消息
 	streamChan, errChan, err := h.chatService.SendMessageStream(c.Request.Context(), userID, &req)
+	if errors.Is(err, service.ErrQuotaExceeded) {
+		TooManyRequests(c, err.Error())
+		return
+	}
+	if errors.Is(err, service.ErrUnsupportedModel) || errors.Is(err, service.ErrInvalidGenerationParams) {
+		BadRequest(c, err.Error())
+		return
+	}
 	if err != nil {
-		// 设置响应头
-		c.Header("Content-Type", "text/event-stream")
-		c.Header("Cache-Control", "no-cache")
-		c.Header("Connection", "keep-alive")
-		
-		// 返回默认回复
-		defaultResponse := "你好！很高兴能和你聊天。虽然我的AI功能暂时无法使用，但我依然可以陪伴你。有什么想聊的吗？"
-		c.String(http.StatusOK, "data: "+defaultResponse+"\n\n")
-		c.String(http.StatusOK, "data: [DONE]\n\n")
-		c.Writer.Flush()
+		ServerError(c, err)
 		return
 	}
 
@@ -298,8 +316,10 @@ func (h *ChatHandler) SendMessageStream(c *gin.Context) {
 
 // GetChatMessages 获取聊天消息列表
 func (h *ChatHandler) GetChatMessages(c *gin.Context) {
-	// 使用固定用户ID 1，简化为无需登录的聊天
-	userID := uint(1)
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
 
 	// 获取聊天ID
 	chatID, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -344,8 +364,10 @@ func (h *ChatHandler) GetChatMessages(c *gin.Context) {
 
 // DeleteMessage 删除消息
 func (h *ChatHandler) DeleteMessage(c *gin.Context) {
-	// 使用固定用户ID 1，简化为无需登录的聊天
-	userID := uint(1)
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
 
 	// 获取消息ID
 	messageID, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -365,23 +387,141 @@ func (h *ChatHandler) DeleteMessage(c *gin.Context) {
 	SuccessWithMessage(c, "删除成功", nil)
 }
 
+// ListMemories 获取会话的长期记忆，支持q参数检索
+func (h *ChatHandler) ListMemories(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	// 获取聊天ID
+	chatID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		ParamError(c, err)
+		return
+	}
+
+	// 调用服务层获取记忆
+	memories, err := h.chatService.ListMemories(c.Request.Context(), userID, uint(chatID), c.Query("q"))
+	if err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+
+	// 返回成功响应
+	Success(c, memories)
+}
+
+// UpdateMemory 修改记忆的内容或置顶状态
+func (h *ChatHandler) UpdateMemory(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	// 获取聊天ID
+	chatID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		ParamError(c, err)
+		return
+	}
+
+	var req models.UpdateMemoryRequest
+
+	// 绑定请求参数
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ParamError(c, err)
+		return
+	}
+	if req.Content == nil && req.Pinned == nil {
+		BadRequest(c, "没有需要修改的内容")
+		return
+	}
+
+	// 调用服务层修改记忆
+	memory, err := h.chatService.UpdateMemory(c.Request.Context(), userID, uint(chatID), c.Param("memory_id"), &req)
+	if err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+
+	// 返回成功响应
+	SuccessWithMessage(c, "更新成功", memory)
+}
+
+// DeleteMemory 删除记忆
+func (h *ChatHandler) DeleteMemory(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	// 获取聊天ID
+	chatID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		ParamError(c, err)
+		return
+	}
+
+	// 调用服务层删除记忆
+	err = h.chatService.DeleteMemory(c.Request.Context(), userID, uint(chatID), c.Param("memory_id"))
+	if err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+
+	// 返回成功响应
+	SuccessWithMessage(c, "删除成功", nil)
+}
+
 // RegisterRoutes 注册聊天相关路由
-func (h *ChatHandler) RegisterRoutes(router *gin.RouterGroup) {
+func (h *ChatHandler) RegisterRoutes(router *gin.RouterGroup, rateLimits RateLimits) {
+	// 调用方需要在传入的路由组上挂载认证中间件
 	chats := router.Group("/chats")
-	// 移除认证中间件，允许直接访问
 	{
 		// 聊天会话相关路由
-		chats.GET("", h.GetUserChats)
-		chats.POST("", h.CreateChat)
-		chats.GET("/star", h.GetOrCreateChatWithStar)
-		chats.GET("/:id", h.GetChatByID)
-		chats.PUT("/:id", h.UpdateChat)
-		chats.DELETE("/:id", h.DeleteChat)
+		chats.GET("", Authorize(PolicyChatRead), h.GetUserChats)
+		chats.POST("", Authorize(PolicyChatWrite), h.CreateChat)
+		chats.GET("/star", Authorize(PolicyChatWrite), h.GetOrCreateChatWithStar)
+		chats.GET("/:id", Authorize(PolicyChatRead), h.GetChatByID)
+		chats.PUT("/:id", Authorize(PolicyChatWrite), h.UpdateChat)
+		chats.DELETE("/:id", Authorize(PolicyChatWrite), h.DeleteChat)
 
 		// 消息相关路由
-		chats.GET("/:id/messages", h.GetChatMessages)
-		chats.POST("/messages", h.SendMessage)
-		chats.POST("/messages/stream", h.SendMessageStream)
-		chats.DELETE("/messages/:id", h.DeleteMessage)
+		chats.GET("/:id/messages", Authorize(PolicyChatRead), h.GetChatMessages)
+		chats.POST("/messages", Authorize(PolicyChatWrite), orPassThrough(rateLimits.SendMessage), h.guestMessageLimit, h.SendMessage)
+		chats.POST("/messages/stream", Authorize(PolicyChatWrite), orPassThrough(rateLimits.StreamMessage), h.guestMessageLimit, h.SendMessageStream)
+		chats.DELETE("/messages/:id", Authorize(PolicyChatWrite), h.DeleteMessage)
+
+		// 记忆相关路由
+		chats.GET("/:id/memories", Authorize(PolicyChatRead), h.ListMemories)
+		chats.PATCH("/:id/memories/:memory_id", Authorize(PolicyChatWrite), h.UpdateMemory)
+		chats.DELETE("/:id/memories/:memory_id", Authorize(PolicyChatWrite), h.DeleteMemory)
 	}
 }
+
+// guestMessageLimit 游客发送消息前检查是否已达到消息数上限
+func (h *ChatHandler) guestMessageLimit(c *gin.Context) {
+	if h.guestService == nil || middleware.GetAuthMethod(c) != auth.MethodGuest {
+		c.Next()
+		return
+	}
+
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.Abort()
+		return
+	}
+
+	if err := h.guestService.CheckMessageLimit(c.Request.Context(), userID); err != nil {
+		if errors.Is(err, service.ErrGuestLimitReached) {
+			TooManyRequests(c, err.Error())
+			return
+		}
+		ServerError(c, err)
+		c.Abort()
+		return
+	}
+
+	c.Next()
+}
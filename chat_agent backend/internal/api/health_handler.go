@@ -0,0 +1,47 @@
+package api
+
+import (
+	"net/http"
+
+	"chat_agent/internal/ai"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LLMHealthReporter 报告模型提供方熔断状态的组件（如ai.LLMRouter）
+type LLMHealthReporter interface {
+	BreakerStatuses() []ai.BreakerStatus
+}
+
+// HealthHandler 健康检查处理器
+type HealthHandler struct {
+	llm LLMHealthReporter
+}
+
+// NewHealthHandler 创建新的健康检查处理器，llm为nil时不报告模型提供方的状态
+func NewHealthHandler(llm LLMHealthReporter) *HealthHandler {
+	return &HealthHandler{
+		llm: llm,
+	}
+}
+
+// HealthCheck 健康检查，同时返回各模型提供方的熔断状态；有提供方熔断时状态为degraded，服务本身仍可用
+func (h *HealthHandler) HealthCheck(c *gin.Context) {
+	status, message := "ok", "服务运行正常"
+	providers := []ai.BreakerStatus{}
+	if h.llm != nil {
+		providers = h.llm.BreakerStatuses()
+	}
+	for _, provider := range providers {
+		if provider.State != ai.BreakerClosed {
+			status, message = "degraded", "部分模型提供方暂时不可用"
+			break
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":        status,
+		"message":       message,
+		"llm_providers": providers,
+	})
+}
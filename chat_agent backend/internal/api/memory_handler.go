@@ -0,0 +1,158 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"chat_agent/internal/ai"
+	"chat_agent/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSnapshotBytes 导入的记忆快照的最大字节数
+const maxSnapshotBytes = 10 << 20
+
+// MemoryHandler 记忆导出导入API处理器
+type MemoryHandler struct {
+	memoryService service.MemoryService
+}
+
+// NewMemoryHandler 创建新的记忆导出导入API处理器
+func NewMemoryHandler(memoryService service.MemoryService) *MemoryHandler {
+	return &MemoryHandler{
+		memoryService: memoryService,
+	}
+}
+
+// ExportChat 导出会话的记忆快照
+func (h *MemoryHandler) ExportChat(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	// 获取聊天ID
+	chatID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		ParamError(c, err)
+		return
+	}
+
+	// 调用服务层导出记忆
+	snapshot, err := h.memoryService.ExportChat(c.Request.Context(), userID, uint(chatID))
+	if err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+
+	writeSnapshot(c, snapshot, fmt.Sprintf("chat-%d-memory.json", chatID))
+}
+
+// ExportUser 导出当前用户全部会话的记忆和用户画像
+func (h *MemoryHandler) ExportUser(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	// 调用服务层导出记忆
+	snapshot, err := h.memoryService.ExportUser(c.Request.Context(), userID)
+	if err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+
+	writeSnapshot(c, snapshot, fmt.Sprintf("user-%d-memory.json", userID))
+}
+
+// ImportChat 把记忆快照导入会话，replace=true时替换已有记忆（从快照恢复）
+func (h *MemoryHandler) ImportChat(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	// 获取聊天ID
+	chatID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		ParamError(c, err)
+		return
+	}
+
+	snapshot, ok := readSnapshot(c)
+	if !ok {
+		return
+	}
+
+	// 调用服务层导入记忆
+	replace := c.Query("replace") == "true"
+	if err := h.memoryService.ImportChat(c.Request.Context(), userID, uint(chatID), snapshot, replace); err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+
+	// 返回成功响应
+	SuccessWithMessage(c, "导入成功", nil)
+}
+
+// ImportUser 把记忆快照导入当前用户，replace=true时替换已有记忆（从快照恢复）
+func (h *MemoryHandler) ImportUser(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	snapshot, ok := readSnapshot(c)
+	if !ok {
+		return
+	}
+
+	// 调用服务层导入记忆
+	replace := c.Query("replace") == "true"
+	imported, err := h.memoryService.ImportUser(c.Request.Context(), userID, snapshot, replace)
+	if err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+
+	// 返回成功响应
+	SuccessWithMessage(c, "导入成功", gin.H{"chats": imported})
+}
+
+// writeSnapshot 以JSON文件的形式返回记忆快照，返回的内容可以直接用于导入
+func writeSnapshot(c *gin.Context, snapshot *ai.MemorySnapshot, filename string) {
+	data, err := ai.SerializeMemory(snapshot)
+	if err != nil {
+		ServerError(c, err)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Data(http.StatusOK, "application/json; charset=utf-8", []byte(data))
+}
+
+// readSnapshot 从请求体读取记忆快照
+func readSnapshot(c *gin.Context) (*ai.MemorySnapshot, bool) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSnapshotBytes)
+	data, err := c.GetRawData()
+	if err != nil {
+		BadRequest(c, "读取记忆快照失败")
+		return nil, false
+	}
+
+	snapshot, err := ai.DeserializeMemory(string(data))
+	if err != nil {
+		BadRequest(c, "记忆快照格式错误: "+err.Error())
+		return nil, false
+	}
+	return snapshot, true
+}
+
+// RegisterRoutes 注册记忆导出导入相关路由
+func (h *MemoryHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/chats/:id/memories/export", Authorize(PolicyChatRead), h.ExportChat)
+	router.POST("/chats/:id/memories/import", Authorize(PolicyChatWrite), h.ImportChat)
+	router.GET("/memories/export", Authorize(PolicyChatRead), h.ExportUser)
+	router.POST("/memories/import", Authorize(PolicyChatWrite), h.ImportUser)
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"strconv"
+
+	"chat_agent/internal/models"
+	"chat_agent/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProfileHandler 用户画像API处理器
+type ProfileHandler struct {
+	profileService service.ProfileService
+}
+
+// NewProfileHandler 创建新的用户画像API处理器
+func NewProfileHandler(profileService service.ProfileService) *ProfileHandler {
+	return &ProfileHandler{
+		profileService: profileService,
+	}
+}
+
+// GetProfile 获取当前用户的画像和不共享画像的明星
+func (h *ProfileHandler) GetProfile(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	// 调用服务层获取用户画像
+	profile, err := h.profileService.GetProfile(c.Request.Context(), userID)
+	if err != nil {
+		ServerError(c, err)
+		return
+	}
+
+	// 返回成功响应
+	Success(c, profile)
+}
+
+// DeleteFact 删除一条画像事实
+func (h *ProfileHandler) DeleteFact(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	// 获取事实ID
+	factID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		ParamError(c, err)
+		return
+	}
+
+	// 调用服务层删除画像事实
+	if err := h.profileService.DeleteFact(c.Request.Context(), userID, uint(factID)); err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+
+	// 返回成功响应
+	SuccessWithMessage(c, "删除成功", nil)
+}
+
+// UpdateSharing 设置是否与某位明星共享画像
+func (h *ProfileHandler) UpdateSharing(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	// 获取明星ID
+	starID, err := strconv.ParseUint(c.Param("star_id"), 10, 32)
+	if err != nil {
+		ParamError(c, err)
+		return
+	}
+
+	var req models.UpdateProfileSharingRequest
+
+	// 绑定请求参数
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ParamError(c, err)
+		return
+	}
+
+	// 调用服务层更新共享设置
+	if err := h.profileService.SetSharing(c.Request.Context(), userID, uint(starID), *req.Enabled); err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+
+	// 返回成功响应
+	SuccessWithMessage(c, "设置成功", gin.H{"star_id": starID, "enabled": *req.Enabled})
+}
+
+// RegisterRoutes 注册用户画像相关路由
+func (h *ProfileHandler) RegisterRoutes(router *gin.RouterGroup) {
+	profile := router.Group("/profile")
+	{
+		profile.GET("", Authorize(PolicyChatRead), h.GetProfile)
+		profile.DELETE("/facts/:id", Authorize(PolicyChatWrite), h.DeleteFact)
+		profile.PUT("/stars/:star_id/sharing", Authorize(PolicyChatWrite), h.UpdateSharing)
+	}
+}
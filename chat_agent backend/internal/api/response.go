@@ -100,9 +100,22 @@ func Unauthorized(c *gin.Context) {
 	Fail(c, 401, "未授权访问")
 }
 
-// Forbidden 403错误响应
+// Forbidden 403错误响应（同时返回403状态码并中止后续处理）
 func Forbidden(c *gin.Context) {
-	Fail(c, 403, "权限不足")
+	c.AbortWithStatusJSON(http.StatusForbidden, Response{
+		Code:    403,
+		Message: "权限不足",
+		Data:    nil,
+	})
+}
+
+// TooManyRequests 429错误响应（同时返回429状态码并中止后续处理）
+func TooManyRequests(c *gin.Context, message string) {
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, Response{
+		Code:    429,
+		Message: message,
+		Data:    nil,
+	})
 }
 
 // NotFound 404错误响应
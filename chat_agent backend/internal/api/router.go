@@ -2,15 +2,43 @@ package api
 
 import (
 	"chat_agent/internal/middleware"
-	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
+// RateLimits 各类接口的限流中间件，为nil表示不限流
+type RateLimits struct {
+	SendMessage   gin.HandlerFunc // 发送消息
+	StreamMessage gin.HandlerFunc // 流式发送消息
+	SearchStars   gin.HandlerFunc // 搜索明星
+	EnhanceStar   gin.HandlerFunc // 爬虫增强明星资料
+	CreateGuest   gin.HandlerFunc // 创建游客会话（未登录，按IP限流）
+}
+
+// orPassThrough 中间件为nil时返回直接放行的中间件
+func orPassThrough(handler gin.HandlerFunc) gin.HandlerFunc {
+	if handler == nil {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+	return handler
+}
+
 // SetupRouter 配置路由
 func SetupRouter(
+	authHandler *AuthHandler,
 	chatHandler *ChatHandler,
 	starHandler *StarHandler,
+	userHandler *UserHandler,
+	apiKeyHandler *APIKeyHandler,
+	usageHandler *UsageHandler,
+	profileHandler *ProfileHandler,
+	memoryHandler *MemoryHandler,
+	healthHandler *HealthHandler,
+	ssoHandler *SSOHandler,
+	authMiddleware gin.HandlerFunc,
+	rateLimits RateLimits,
 ) *gin.Engine {
 	// 创建Gin引擎
 	r := gin.Default()
@@ -19,14 +47,29 @@ func SetupRouter(
 	r.Use(middleware.CORSMiddleware())
 
 	// 健康检查
-	r.GET("/health", HealthCheck)
+	r.GET("/health", healthHandler.HealthCheck)
 
-	// API路由组 - 移除用户相关路由，只保留聊天和明星相关路由
+	// API路由组
 	api := r.Group("/api/v1")
 	{
-		// 注册简化的路由
-		chatHandler.RegisterRoutes(api)
-		starHandler.RegisterRoutes(api)
+		// 认证路由（登录、注册等公开接口）
+		authHandler.RegisterRoutes(api, authMiddleware, rateLimits)
+		starHandler.RegisterRoutes(api, authMiddleware, rateLimits)
+
+		// 单点登录路由（未配置身份提供方时不注册）
+		if ssoHandler != nil {
+			ssoHandler.RegisterRoutes(api)
+		}
+
+		// 需要登录的路由
+		protected := api.Group("")
+		protected.Use(authMiddleware)
+		chatHandler.RegisterRoutes(protected, rateLimits)
+		userHandler.RegisterRoutes(protected)
+		apiKeyHandler.RegisterRoutes(protected)
+		usageHandler.RegisterRoutes(protected)
+		profileHandler.RegisterRoutes(protected)
+		memoryHandler.RegisterRoutes(protected)
 	}
 
 	// 静态文件服务
@@ -39,11 +82,3 @@ func SetupRouter(
 
 	return r
 }
-
-// HealthCheck 健康检查
-func HealthCheck(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"status":  "ok",
-		"message": "服务运行正常",
-	})
-}
\ No newline at end of file
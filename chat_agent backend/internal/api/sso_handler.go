@@ -0,0 +1,94 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"chat_agent/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// oidcStateCookieName 单点登录跳转期间保存状态的Cookie名称
+const oidcStateCookieName = "oidc_state"
+
+// SSOHandler 单点登录API处理器
+type SSOHandler struct {
+	ssoService   service.SSOService
+	postLoginURL string // 登录成功后跳转的前端地址，为空时直接返回JSON
+}
+
+// NewSSOHandler 创建新的单点登录API处理器
+func NewSSOHandler(ssoService service.SSOService, postLoginURL string) *SSOHandler {
+	return &SSOHandler{
+		ssoService:   ssoService,
+		postLoginURL: postLoginURL,
+	}
+}
+
+// Login 跳转到身份提供方登录
+func (h *SSOHandler) Login(c *gin.Context) {
+	// 调用服务层生成授权地址
+	authURL, stateToken, err := h.ssoService.BeginLogin(c.Request.Context())
+	if err != nil {
+		ServerError(c, err)
+		return
+	}
+
+	setCookie(c, oidcStateCookieName, stateToken, "/", 600)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback 身份提供方回调，完成登录并签发令牌
+func (h *SSOHandler) Callback(c *gin.Context) {
+	// 用户在身份提供方拒绝授权等情况
+	if errCode := c.Query("error"); errCode != "" {
+		Fail(c, 401, "单点登录失败: "+errCode)
+		return
+	}
+
+	code := c.Query("code")
+	state := c.Query("state")
+	if code == "" || state == "" {
+		BadRequest(c, "缺少授权码或状态参数")
+		return
+	}
+
+	stateToken, err := c.Cookie(oidcStateCookieName)
+	if err != nil || stateToken == "" {
+		Fail(c, 401, "登录状态无效或已过期，请重新登录")
+		return
+	}
+	setCookie(c, oidcStateCookieName, "", "/", -1)
+
+	// 调用服务层完成登录
+	result, err := h.ssoService.CompleteLogin(c.Request.Context(), stateToken, state, code)
+	if err != nil {
+		Fail(c, 401, err.Error())
+		return
+	}
+
+	// 浏览器登录：通过URL片段把令牌交给前端，片段不会发送到服务器
+	if h.postLoginURL != "" {
+		fragment := url.Values{}
+		fragment.Set("access_token", result.AccessToken)
+		fragment.Set("refresh_token", result.RefreshToken)
+		fragment.Set("token_type", result.TokenType)
+		fragment.Set("expires_in", strconv.FormatInt(result.ExpiresIn, 10))
+		c.Redirect(http.StatusFound, h.postLoginURL+"#"+fragment.Encode())
+		return
+	}
+
+	// 返回成功响应
+	SuccessWithMessage(c, "登录成功", result)
+}
+
+// RegisterRoutes 注册单点登录相关路由
+func (h *SSOHandler) RegisterRoutes(router *gin.RouterGroup) {
+	oidc := router.Group("/auth/oidc")
+	{
+		oidc.GET("/login", h.Login)
+		oidc.GET("/callback", h.Callback)
+	}
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
 
@@ -92,7 +93,7 @@ func (h *StarHandler) SearchStars(c *gin.Context) {
 	Success(c, stars)
 }
 
-// CreateStar 创建明星（管理员功能）
+// CreateStar 创建明星（编辑功能）
 func (h *StarHandler) CreateStar(c *gin.Context) {
 	var req models.CreateStarRequest
 
@@ -105,6 +106,10 @@ func (h *StarHandler) CreateStar(c *gin.Context) {
 	// 调用服务层创建明星
 	star, err := h.starService.CreateStar(c.Request.Context(), &req)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidGenerationParams) {
+			BadRequest(c, err.Error())
+			return
+		}
 		ServerError(c, err)
 		return
 	}
@@ -113,7 +118,7 @@ func (h *StarHandler) CreateStar(c *gin.Context) {
 	SuccessWithMessage(c, "创建明星成功", star)
 }
 
-// UpdateStar 更新明星信息（管理员功能）
+// UpdateStar 更新明星信息（编辑功能）
 func (h *StarHandler) UpdateStar(c *gin.Context) {
 	// 获取明星ID
 	starID, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -130,9 +135,19 @@ func (h *StarHandler) UpdateStar(c *gin.Context) {
 		return
 	}
 
+	// 上下线只允许管理员操作，编辑不能通过更新接口修改
+	if req.IsActive != nil && !PolicyAdmin.Allows(c) {
+		Forbidden(c)
+		return
+	}
+
 	// 调用服务层更新明星信息
 	star, err := h.starService.UpdateStar(c.Request.Context(), uint(starID), &req)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidGenerationParams) {
+			BadRequest(c, err.Error())
+			return
+		}
 		ServerError(c, err)
 		return
 	}
@@ -195,7 +210,7 @@ func (h *StarHandler) ToggleStarActive(c *gin.Context) {
 	SuccessWithMessage(c, status+"成功", nil)
 }
 
-// EnhanceStarProfile 手动触发爬虫增强明星资料
+// EnhanceStarProfile 手动触发爬虫增强明星资料（编辑功能）
 func (h *StarHandler) EnhanceStarProfile(c *gin.Context) {
 	// 从路径参数中获取明星ID
 	starID, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -224,21 +239,23 @@ func (h *StarHandler) EnhanceStarProfile(c *gin.Context) {
 }
 
 // RegisterRoutes 注册明星相关路由
-func (h *StarHandler) RegisterRoutes(router *gin.RouterGroup) {
+func (h *StarHandler) RegisterRoutes(router *gin.RouterGroup, authMiddleware gin.HandlerFunc, rateLimits RateLimits) {
 	stars := router.Group("/stars")
 	{
-		// 所有路由都直接访问，不使用认证中间件
+		// 浏览类路由无需登录
 		stars.GET("", h.GetAllActiveStars)
 		stars.GET("/list", h.GetStarList)
-		stars.GET("/search", h.SearchStars)
+		stars.GET("/search", orPassThrough(rateLimits.SearchStars), h.SearchStars)
 		stars.GET("/:id", h.GetStarByID)
 
-		// 管理员功能也直接访问（演示版本）
-		stars.POST("", h.CreateStar)
-		stars.PUT("/:id", h.UpdateStar)
-		stars.DELETE("/:id", h.DeleteStar)
-		stars.PUT("/:id/active", h.ToggleStarActive)
+		// 编辑人设需要编辑及以上角色
+		stars.POST("", authMiddleware, Authorize(PolicyEditor), h.CreateStar)
+		stars.PUT("/:id", authMiddleware, Authorize(PolicyEditor), h.UpdateStar)
 		// 增强明星资料（爬虫）
-		stars.POST("/:id/enhance", h.EnhanceStarProfile)
+		stars.POST("/:id/enhance", authMiddleware, Authorize(PolicyEditor), orPassThrough(rateLimits.EnhanceStar), h.EnhanceStarProfile)
+
+		// 删除和上下线只允许管理员操作
+		stars.DELETE("/:id", authMiddleware, Authorize(PolicyAdmin), h.DeleteStar)
+		stars.PUT("/:id/active", authMiddleware, Authorize(PolicyAdmin), h.ToggleStarActive)
 	}
 }
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"chat_agent/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UsageHandler 用量API处理器
+type UsageHandler struct {
+	usageService service.UsageService
+}
+
+// NewUsageHandler 创建新的用量API处理器
+func NewUsageHandler(usageService service.UsageService) *UsageHandler {
+	return &UsageHandler{
+		usageService: usageService,
+	}
+}
+
+// GetUsage 获取当前用户的token用量、额度和估算费用
+func (h *UsageHandler) GetUsage(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	// 统计周期：day 或 month，默认为month
+	period := c.DefaultQuery("period", service.UsagePeriodMonth)
+
+	// 调用服务层获取用量报告
+	report, err := h.usageService.GetUsageReport(c.Request.Context(), userID, period)
+	if err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+
+	// 返回成功响应
+	Success(c, report)
+}
+
+// RegisterRoutes 注册用量相关路由
+func (h *UsageHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/usage", Authorize(PolicyChatRead), h.GetUsage)
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"strconv"
+
+	"chat_agent/internal/models"
+	"chat_agent/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UserHandler 用户管理API处理器
+type UserHandler struct {
+	userService service.UserService
+}
+
+// NewUserHandler 创建新的用户管理API处理器
+func NewUserHandler(userService service.UserService) *UserHandler {
+	return &UserHandler{
+		userService: userService,
+	}
+}
+
+// UpdateUserRole 修改用户角色（管理员功能）
+func (h *UserHandler) UpdateUserRole(c *gin.Context) {
+	operatorID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	// 获取用户ID
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		ParamError(c, err)
+		return
+	}
+
+	var req models.UpdateUserRoleRequest
+
+	// 绑定请求参数
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ParamError(c, err)
+		return
+	}
+
+	// 调用服务层修改角色
+	user, err := h.userService.UpdateUserRole(c.Request.Context(), operatorID, uint(userID), req.Role)
+	if err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+
+	// 返回成功响应
+	SuccessWithMessage(c, "角色修改成功", user)
+}
+
+// RegisterRoutes 注册用户管理相关路由
+func (h *UserHandler) RegisterRoutes(router *gin.RouterGroup) {
+	users := router.Group("/users")
+	users.Use(Authorize(PolicyAdminSession))
+	{
+		users.PUT("/:id/role", h.UpdateUserRole)
+	}
+}
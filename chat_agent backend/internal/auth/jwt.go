@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrInvalidToken 令牌无效或已过期
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// 特殊用途令牌的受众标识，用于和访问令牌区分
+const (
+	guestAudience     = "guest"
+	oidcStateAudience = "oidc_state"
+)
+
+// Claims 访问令牌中携带的声明
+type Claims struct {
+	UserID uint   `json:"uid"`
+	Role   string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// TokenManager 负责签发和校验JWT访问令牌
+type TokenManager struct {
+	secret    []byte
+	accessTTL time.Duration
+	issuer    string
+}
+
+// NewTokenManager 创建新的令牌管理器
+func NewTokenManager(secret string, accessTTL time.Duration) *TokenManager {
+	if accessTTL <= 0 {
+		accessTTL = 30 * time.Minute
+	}
+	return &TokenManager{
+		secret:    []byte(secret),
+		accessTTL: accessTTL,
+		issuer:    "chat_agent",
+	}
+}
+
+// GenerateAccessToken 为用户签发访问令牌，返回令牌和过期时间
+func (m *TokenManager) GenerateAccessToken(userID uint, role string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(m.accessTTL)
+
+	claims := Claims{
+		UserID: userID,
+		Role:   role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    m.issuer,
+			Subject:   strconv.FormatUint(uint64(userID), 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString(m.secret)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("sign access token: %w", err)
+	}
+	return signed, expiresAt, nil
+}
+
+// ParseAccessToken 校验访问令牌并返回其中的声明
+func (m *TokenManager) ParseAccessToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return m.secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(m.issuer),
+	)
+	if err != nil || !token.Valid || claims.UserID == 0 {
+		return nil, ErrInvalidToken
+	}
+	// 访问令牌不带受众，带受众的是游客令牌等其他用途的令牌
+	if len(claims.Audience) > 0 {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// GenerateGuestToken 为游客签发写入Cookie的令牌，有效期与游客账号一致
+func (m *TokenManager) GenerateGuestToken(userID uint, expiresAt time.Time) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		UserID: userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    m.issuer,
+			Subject:   strconv.FormatUint(uint64(userID), 10),
+			Audience:  jwt.ClaimStrings{guestAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString(m.secret)
+	if err != nil {
+		return "", fmt.Errorf("sign guest token: %w", err)
+	}
+	return signed, nil
+}
+
+// ParseGuestToken 校验游客令牌并返回游客用户ID
+func (m *TokenManager) ParseGuestToken(tokenString string) (uint, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return m.secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(m.issuer),
+		jwt.WithAudience(guestAudience),
+	)
+	if err != nil || !token.Valid || claims.UserID == 0 {
+		return 0, ErrInvalidToken
+	}
+	return claims.UserID, nil
+}
+
+// OIDCState 单点登录跳转期间需要保存的状态
+type OIDCState struct {
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+// oidcStateClaims 单点登录状态令牌中的声明
+type oidcStateClaims struct {
+	OIDCState
+	jwt.RegisteredClaims
+}
+
+// GenerateOIDCStateToken 将单点登录状态签名后写入Cookie，避免在服务端保存
+func (m *TokenManager) GenerateOIDCStateToken(state OIDCState, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := oidcStateClaims{
+		OIDCState: state,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    m.issuer,
+			Audience:  jwt.ClaimStrings{oidcStateAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString(m.secret)
+	if err != nil {
+		return "", fmt.Errorf("sign oidc state: %w", err)
+	}
+	return signed, nil
+}
+
+// ParseOIDCStateToken 校验单点登录状态令牌
+func (m *TokenManager) ParseOIDCStateToken(tokenString string) (*OIDCState, error) {
+	claims := &oidcStateClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return m.secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(m.issuer),
+		jwt.WithAudience(oidcStateAudience),
+	)
+	if err != nil || !token.Valid || claims.State == "" {
+		return nil, ErrInvalidToken
+	}
+	return &claims.OIDCState, nil
+}
+
+// GenerateOpaqueToken 生成随机的不透明令牌（用于刷新令牌等场景）
+func GenerateOpaqueToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// HashToken 计算令牌的SHA-256哈希，数据库中只保存哈希值
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
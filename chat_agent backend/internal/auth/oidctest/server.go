@@ -0,0 +1,268 @@
+// Package oidctest 提供进程内的OIDC身份提供方，用于在没有网络的环境下验证单点登录流程
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// keyID 签名密钥的kid
+const keyID = "oidctest-key"
+
+// User 身份提供方中登录的用户
+type User struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+	Picture           string
+}
+
+// authCode 已签发、尚未使用的授权码
+type authCode struct {
+	user          User
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+}
+
+// Server 进程内的OIDC身份提供方：授权端点自动同意并跳回，令牌端点校验PKCE后签发RS256的ID令牌
+type Server struct {
+	ClientID     string
+	ClientSecret string
+
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu    sync.Mutex
+	user  User
+	codes map[string]authCode
+}
+
+// NewServer 启动新的身份提供方，使用完后需要调用Close
+func NewServer(clientID, clientSecret string) *Server {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic("oidctest: generate key: " + err.Error())
+	}
+
+	s := &Server{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		key:          key,
+		user: User{
+			Subject:           "user-1",
+			Email:             "user1@example.com",
+			EmailVerified:     true,
+			Name:              "Test User",
+			PreferredUsername: "testuser",
+		},
+		codes: make(map[string]authCode),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.handleDiscovery)
+	mux.HandleFunc("/authorize", s.handleAuthorize)
+	mux.HandleFunc("/token", s.handleToken)
+	mux.HandleFunc("/jwks", s.handleJWKS)
+	s.server = httptest.NewServer(mux)
+	return s
+}
+
+// Issuer 身份提供方地址（即issuer）
+func (s *Server) Issuer() string {
+	return s.server.URL
+}
+
+// Client 可以访问该身份提供方的HTTP客户端
+func (s *Server) Client() *http.Client {
+	return s.server.Client()
+}
+
+// SetUser 设置下一次授权时登录的用户
+func (s *Server) SetUser(user User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.user = user
+}
+
+// Close 关闭身份提供方
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// handleDiscovery 返回发现文档
+func (s *Server) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                s.Issuer(),
+		"authorization_endpoint":                s.Issuer() + "/authorize",
+		"token_endpoint":                        s.Issuer() + "/token",
+		"jwks_uri":                              s.Issuer() + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+// handleAuthorize 授权端点：校验参数后直接以当前用户身份同意授权
+func (s *Server) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	redirectURI := query.Get("redirect_uri")
+	if query.Get("client_id") != s.ClientID || redirectURI == "" {
+		http.Error(w, "invalid client", http.StatusBadRequest)
+		return
+	}
+	if query.Get("response_type") != "code" ||
+		query.Get("code_challenge") == "" ||
+		query.Get("code_challenge_method") != "S256" {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	code := randomString()
+	s.mu.Lock()
+	s.codes[code] = authCode{
+		user:          s.user,
+		redirectURI:   redirectURI,
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+	}
+	s.mu.Unlock()
+
+	target, err := url.Parse(redirectURI)
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	params := target.Query()
+	params.Set("code", code)
+	params.Set("state", query.Get("state"))
+	target.RawQuery = params.Encode()
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+// handleToken 令牌端点：校验客户端、授权码和PKCE后签发ID令牌
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID = r.PostForm.Get("client_id")
+		clientSecret = r.PostForm.Get("client_secret")
+	}
+	if clientID != s.ClientID || (s.ClientSecret != "" && clientSecret != s.ClientSecret) {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	// 授权码只能使用一次
+	s.mu.Lock()
+	code, found := s.codes[r.PostForm.Get("code")]
+	delete(s.codes, r.PostForm.Get("code"))
+	s.mu.Unlock()
+
+	if !found || code.redirectURI != r.PostForm.Get("redirect_uri") {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != code.codeChallenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{
+			"error":             "invalid_grant",
+			"error_description": "PKCE verification failed",
+		})
+		return
+	}
+
+	idToken, err := s.signIDToken(code)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+// handleJWKS 返回签名公钥
+func (s *Server) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	publicKey := s.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": keyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+		}},
+	})
+}
+
+// signIDToken 为授权码对应的用户签发ID令牌
+func (s *Server) signIDToken(code authCode) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":                s.Issuer(),
+		"sub":                code.user.Subject,
+		"aud":                s.ClientID,
+		"iat":                now.Unix(),
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"nonce":              code.nonce,
+		"email":              code.user.Email,
+		"email_verified":     code.user.EmailVerified,
+		"name":               code.user.Name,
+		"preferred_username": code.user.PreferredUsername,
+		"picture":            code.user.Picture,
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keyID
+	return token.SignedString(s.key)
+}
+
+// writeJSON 输出JSON响应
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// randomString 生成随机字符串
+func randomString() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		panic("oidctest: random: " + err.Error())
+	}
+	return hex.EncodeToString(buf)
+}
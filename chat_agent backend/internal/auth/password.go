@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"unicode"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 密码策略
+const (
+	MinPasswordLength = 8
+	MaxPasswordLength = 72 // bcrypt只使用前72个字节
+)
+
+// 密码策略错误
+var (
+	ErrPasswordTooShort = errors.New("密码长度不能少于8位")
+	ErrPasswordTooLong  = errors.New("密码长度不能超过72个字节")
+	ErrPasswordTooWeak  = errors.New("密码必须同时包含字母和数字")
+)
+
+// HashPassword 使用bcrypt对密码进行哈希
+func HashPassword(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+// CheckPassword 校验密码是否与哈希匹配
+func CheckPassword(hashed, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hashed), []byte(password)) == nil
+}
+
+// dummyPasswordHash 固定的bcrypt哈希（代价与HashPassword相同），用于用户不存在时消耗相同的校验时间
+const dummyPasswordHash = "$2a$10$ql/LbIJvJQsfFy/rNW90JebkngzbbRlkGf6m2kB8nBLvI4EbL9gXm"
+
+// CheckDummyPassword 用户不存在时对固定哈希执行一次校验，使登录耗时不泄露用户名是否存在
+func CheckDummyPassword(password string) {
+	CheckPassword(dummyPasswordHash, password)
+}
+
+// IsPasswordHash 判断存储的值是否为bcrypt哈希（用于识别历史遗留的明文密码）
+func IsPasswordHash(value string) bool {
+	_, err := bcrypt.Cost([]byte(value))
+	return err == nil
+}
+
+// ValidatePassword 校验密码是否符合密码策略
+func ValidatePassword(password string) error {
+	if len([]rune(password)) < MinPasswordLength {
+		return ErrPasswordTooShort
+	}
+	if len(password) > MaxPasswordLength {
+		return ErrPasswordTooLong
+	}
+
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	if !hasLetter || !hasDigit {
+		return ErrPasswordTooWeak
+	}
+	return nil
+}
+
+// GenerateRandomPassword 生成符合密码策略的随机密码
+func GenerateRandomPassword(length int) (string, error) {
+	const letters = "abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ"
+	const digits = "23456789"
+	if length < MinPasswordLength {
+		length = MinPasswordLength
+	}
+
+	charset := letters + digits
+	for {
+		buf := make([]byte, length)
+		for i := range buf {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+			if err != nil {
+				return "", err
+			}
+			buf[i] = charset[n.Int64()]
+		}
+		password := string(buf)
+		if ValidatePassword(password) == nil {
+			return password, nil
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package auth
+
+// 认证方式
+const (
+	MethodSession = "session" // 用户登录后的JWT访问令牌
+	MethodAPIKey  = "api_key" // 服务端调用使用的API密钥
+	MethodGuest   = "guest"   // 未注册游客的签名Cookie
+)
+
+// Principal 已认证的调用方
+type Principal struct {
+	UserID   uint
+	Role     string
+	Method   string
+	Scopes   []string // 仅API密钥认证时有效
+	APIKeyID uint     // 仅API密钥认证时有效
+}
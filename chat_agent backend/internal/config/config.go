@@ -1,10 +1,16 @@
 package config
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"strconv"
+	"strings"
+
+	"chat_agent/internal/models"
 
 	"github.com/joho/godotenv"
 )
@@ -32,7 +38,109 @@ type Config struct {
 	OpenAIAPIKey string
 	LLMModel     string
 	LLMBaseURL   string
-	LLMTimeout   int
+	LLMTimeout   int // 单次模型调用的超时时间（秒），流式调用只限制第一个数据块之前的时间，0表示不限制
+
+	// 模型调用的重试与熔断配置
+	LLMMaxAttempts            int // 最多尝试的次数（包含第一次）
+	LLMRetryMaxDelaySeconds   int // 单次重试等待的上限（秒），服务端要求等待更久时不再重试
+	LLMBreakerThreshold       int // 提供方连续失败多少次后熔断
+	LLMBreakerCooldownSeconds int // 熔断后的冷却时间（秒），之后放行一个探测请求
+
+	// 模型提供方与路由配置
+	LLMProviders    []LLMProviderConfig // 模型提供方，按模型名称路由到对应的提供方
+	LLMModelAliases map[string]string   // 模型别名，例如 {"fast":"doubao-lite-32k","claude":"anthropic/claude-3-5-sonnet-latest"}
+	LLMFallbacks    []string            // 请求的模型失败时依次尝试的模型，明星未配置回退模型时使用
+
+	// 提示词token预算配置
+	TokenizerBPEFile        string         // tiktoken格式的BPE词表文件（如cl100k_base.tiktoken），为空时按字符估算token数
+	LLMContextWindows       map[string]int // 各模型的上下文长度，键为模型名称或"前缀*"，覆盖内置的默认值
+	LLMDefaultContextWindow int            // 未知模型的上下文长度
+	LLMCompletionReserve    int            // 为回复预留的token数
+
+	// 生成参数的允许范围，用户在会话和请求中覆盖的参数不能超出该范围
+	GenerationMinTemperature float64
+	GenerationMaxTemperature float64
+	GenerationMinTopP        float64
+	GenerationMaxTopP        float64
+	GenerationMaxTokens      int // 回复的最大token数上限
+	GenerationMinPenalty     float64
+	GenerationMaxPenalty     float64
+	GenerationMaxStop        int // 停止序列的最大数量
+
+	// 记忆存储配置
+	MemoryBackend      string // memory、redis 或 database
+	MemoryRedisTTL     int    // Redis中会话记忆的空闲过期时间（小时），0表示不过期
+	MemoryShortTermCap int    // 每个会话保留的短期记忆条数
+	MemoryLongTermCap  int    // 每个会话保留的长期记忆条数
+	MemoryMaxMB        int    // 内存记忆管理器的总内存预算（MB），超出后淘汰最久未访问的会话
+
+	// 记忆衰减与强化配置
+	MemoryHalfLifeHours        int     // 长期记忆权重的半衰期（小时），0表示不衰减
+	MemoryReinforceBoost       float64 // 记忆被回复引用时增加的权重
+	MemoryMaxWeight            float64 // 强化后的权重上限
+	MemoryPruneBelow           float64 // 有效权重低于该值的长期记忆会被清理，0表示不清理
+	MemoryPruneIntervalMinutes int     // 后台清理（记忆垃圾回收）的间隔（分钟）
+
+	// 记忆过期配置
+	MemoryShortTermTTLHours int // 短期记忆写入后的过期时间（小时），0表示不过期
+	MemoryLongTermTTLHours  int // 长期记忆最后一次更新或强化后的过期时间（小时），0表示不过期，置顶的记忆不过期
+
+	// 语义记忆检索配置
+	EmbeddingBackend     string  // hash（本地哈希向量）、openai（OpenAI兼容接口）或 none（关闭语义检索）
+	EmbeddingModel       string  // openai后端使用的向量模型
+	EmbeddingDimensions  int     // hash后端的向量维度
+	MemoryRecallMinScore float64 // 语义检索结果的最低相似度
+
+	// 事实提取配置
+	FactExtraction      string // rules（只用关键词规则）、llm（规则之外再调用模型）或 none（关闭）
+	FactExtractionModel string // llm模式下使用的模型，为空时使用LLM_MODEL
+
+	// 滚动摘要配置
+	SummaryEveryMessages int    // 滑出上下文窗口的消息每积累多少条更新一次会话摘要，0表示关闭
+	SummaryModel         string // 生成摘要使用的模型，为空时使用LLM_MODEL
+
+	// 用量与额度配置
+	DailyTokenQuota   int                          // 每个用户每日token额度，0表示不限制
+	MonthlyTokenQuota int                          // 每个用户每月token额度，0表示不限制
+	LLMPrices         map[string]models.ModelPrice // 模型单价表（每千token），"*"为默认价格
+	LLMPriceCurrency  string
+
+	// 认证配置
+	JWTSecret       string
+	AccessTokenTTL  int // 访问令牌有效期（分钟）
+	RefreshTokenTTL int // 刷新令牌有效期（小时）
+
+	// 凭证安全配置
+	LoginMaxAttempts    int    // 连续登录失败多少次后锁定账号
+	LoginLockoutMinutes int    // 账号锁定时长（分钟）
+	PasswordResetTTL    int    // 密码重置令牌有效期（分钟）
+	PasswordResetURL    string // 重置密码页面地址，令牌会以token参数附加在后面
+
+	// 单点登录（OIDC）配置，OIDCIssuerURL和OIDCClientID都不为空时启用
+	OIDCIssuerURL    string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string   // 身份提供方回调地址，指向 /api/v1/auth/oidc/callback
+	OIDCScopes       []string // 申请的scope
+	OIDCPostLoginURL string   // 登录成功后跳转的前端地址，令牌通过URL片段传递；为空时回调直接返回JSON
+
+	// 游客配置
+	GuestTTLHours             int // 游客账号有效期（小时），过期未注册的游客会被清理
+	GuestMessageLimit         int // 游客最多可发送的消息数，0表示不限制
+	GuestSweepIntervalMinutes int // 清理过期游客的间隔（分钟）
+
+	// 限流配置（速率单位为每分钟请求数）
+	RateLimitBackend      string // memory 或 redis
+	RateLimitMessageRate  int
+	RateLimitMessageBurst int
+	RateLimitStreamRate   int
+	RateLimitStreamBurst  int
+	RateLimitSearchRate   int
+	RateLimitSearchBurst  int
+	RateLimitEnhanceRate  int
+	RateLimitEnhanceBurst int
+	RateLimitGuestRate    int
+	RateLimitGuestBurst   int
 
 	// 应用配置
 	Environment string
@@ -73,7 +181,109 @@ func LoadConfig() *Config {
 		OpenAIAPIKey: getEnv("LLM_API_KEY", ""),
 		LLMModel:     getEnv("LLM_MODEL", "gpt-3.5-turbo"),
 		LLMBaseURL:   getEnv("LLM_BASE_URL", "https://api.openai.com/v1"),
-		LLMTimeout:   30,
+		LLMTimeout:   getEnvInt("LLM_TIMEOUT_SECONDS", 30),
+
+		// 模型调用的重试与熔断配置
+		LLMMaxAttempts:            getEnvInt("LLM_MAX_ATTEMPTS", 3),
+		LLMRetryMaxDelaySeconds:   getEnvInt("LLM_RETRY_MAX_DELAY_SECONDS", 10),
+		LLMBreakerThreshold:       getEnvInt("LLM_BREAKER_THRESHOLD", 5),
+		LLMBreakerCooldownSeconds: getEnvInt("LLM_BREAKER_COOLDOWN_SECONDS", 30),
+
+		// 模型提供方与路由配置
+		LLMProviders:    loadProviders(getEnv("LLM_PROVIDERS", "")),
+		LLMModelAliases: loadModelAliases(getEnv("LLM_MODEL_ALIASES", "")),
+		LLMFallbacks:    splitList(getEnv("LLM_FALLBACK_MODELS", "")),
+
+		// 提示词token预算配置
+		TokenizerBPEFile:        getEnv("TOKENIZER_BPE_FILE", ""),
+		LLMContextWindows:       loadContextWindows(getEnv("LLM_CONTEXT_WINDOWS", "")),
+		LLMDefaultContextWindow: getEnvInt("LLM_DEFAULT_CONTEXT_WINDOW", 8192),
+		LLMCompletionReserve:    getEnvInt("LLM_COMPLETION_RESERVE", 1024),
+
+		// 生成参数的允许范围
+		GenerationMinTemperature: getEnvFloat("GENERATION_TEMPERATURE_MIN", 0),
+		GenerationMaxTemperature: getEnvFloat("GENERATION_TEMPERATURE_MAX", 2),
+		GenerationMinTopP:        getEnvFloat("GENERATION_TOP_P_MIN", 0),
+		GenerationMaxTopP:        getEnvFloat("GENERATION_TOP_P_MAX", 1),
+		GenerationMaxTokens:      getEnvInt("GENERATION_MAX_TOKENS", 2048),
+		GenerationMinPenalty:     getEnvFloat("GENERATION_PENALTY_MIN", -2),
+		GenerationMaxPenalty:     getEnvFloat("GENERATION_PENALTY_MAX", 2),
+		GenerationMaxStop:        getEnvInt("GENERATION_MAX_STOP", 4),
+
+		// 记忆存储配置
+		MemoryBackend:      getEnv("MEMORY_BACKEND", "memory"),
+		MemoryRedisTTL:     getEnvInt("MEMORY_REDIS_TTL_HOURS", 0),
+		MemoryShortTermCap: getEnvInt("MEMORY_SHORT_TERM_CAP", 10),
+		MemoryLongTermCap:  getEnvInt("MEMORY_LONG_TERM_CAP", 50),
+		MemoryMaxMB:        getEnvInt("MEMORY_MAX_MB", 64),
+
+		// 记忆衰减与强化配置
+		MemoryHalfLifeHours:        getEnvInt("MEMORY_HALF_LIFE_HOURS", 24*7),
+		MemoryReinforceBoost:       getEnvFloat("MEMORY_REINFORCE_BOOST", 0.5),
+		MemoryMaxWeight:            getEnvFloat("MEMORY_MAX_WEIGHT", 5),
+		MemoryPruneBelow:           getEnvFloat("MEMORY_PRUNE_BELOW", 0.1),
+		MemoryPruneIntervalMinutes: getEnvInt("MEMORY_PRUNE_INTERVAL_MINUTES", 60),
+
+		// 记忆过期配置
+		MemoryShortTermTTLHours: getEnvInt("MEMORY_SHORT_TERM_TTL_HOURS", 24),
+		MemoryLongTermTTLHours:  getEnvInt("MEMORY_LONG_TERM_TTL_HOURS", 0),
+
+		// 语义记忆检索配置
+		EmbeddingBackend:     getEnv("EMBEDDING_BACKEND", "hash"),
+		EmbeddingModel:       getEnv("EMBEDDING_MODEL", "text-embedding-3-small"),
+		EmbeddingDimensions:  getEnvInt("EMBEDDING_DIMENSIONS", 256),
+		MemoryRecallMinScore: getEnvFloat("MEMORY_RECALL_MIN_SCORE", 0.2),
+
+		// 事实提取配置
+		FactExtraction:      getEnv("FACT_EXTRACTION", "rules"),
+		FactExtractionModel: getEnv("FACT_EXTRACTION_MODEL", ""),
+
+		// 滚动摘要配置
+		SummaryEveryMessages: getEnvInt("SUMMARY_EVERY_MESSAGES", 20),
+		SummaryModel:         getEnv("SUMMARY_MODEL", ""),
+
+		// 用量与额度配置
+		DailyTokenQuota:   getEnvInt("DAILY_TOKEN_QUOTA", 200000),
+		MonthlyTokenQuota: getEnvInt("MONTHLY_TOKEN_QUOTA", 3000000),
+		LLMPrices:         loadPriceTable(getEnv("LLM_PRICE_TABLE", "")),
+		LLMPriceCurrency:  getEnv("LLM_PRICE_CURRENCY", "CNY"),
+
+		// 认证配置
+		JWTSecret:       getEnv("JWT_SECRET", ""),
+		AccessTokenTTL:  getEnvInt("JWT_ACCESS_TTL_MINUTES", 30),
+		RefreshTokenTTL: getEnvInt("JWT_REFRESH_TTL_HOURS", 24*7),
+
+		// 凭证安全配置
+		LoginMaxAttempts:    getEnvInt("LOGIN_MAX_ATTEMPTS", 5),
+		LoginLockoutMinutes: getEnvInt("LOGIN_LOCKOUT_MINUTES", 15),
+		PasswordResetTTL:    getEnvInt("PASSWORD_RESET_TTL_MINUTES", 30),
+		PasswordResetURL:    getEnv("PASSWORD_RESET_URL", "http://localhost:3000/reset-password"),
+
+		// 单点登录（OIDC）配置
+		OIDCIssuerURL:    getEnv("OIDC_ISSUER_URL", ""),
+		OIDCClientID:     getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret: getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:  getEnv("OIDC_REDIRECT_URL", "http://localhost:8000/api/v1/auth/oidc/callback"),
+		OIDCScopes:       strings.Split(getEnv("OIDC_SCOPES", "openid,profile,email"), ","),
+		OIDCPostLoginURL: getEnv("OIDC_POST_LOGIN_URL", ""),
+
+		// 游客配置
+		GuestTTLHours:             getEnvInt("GUEST_TTL_HOURS", 24),
+		GuestMessageLimit:         getEnvInt("GUEST_MESSAGE_LIMIT", 10),
+		GuestSweepIntervalMinutes: getEnvInt("GUEST_SWEEP_INTERVAL_MINUTES", 30),
+
+		// 限流配置
+		RateLimitBackend:      getEnv("RATE_LIMIT_BACKEND", "memory"),
+		RateLimitMessageRate:  getEnvInt("RATE_LIMIT_MESSAGE_PER_MINUTE", 20),
+		RateLimitMessageBurst: getEnvInt("RATE_LIMIT_MESSAGE_BURST", 5),
+		RateLimitStreamRate:   getEnvInt("RATE_LIMIT_STREAM_PER_MINUTE", 20),
+		RateLimitStreamBurst:  getEnvInt("RATE_LIMIT_STREAM_BURST", 5),
+		RateLimitSearchRate:   getEnvInt("RATE_LIMIT_SEARCH_PER_MINUTE", 60),
+		RateLimitSearchBurst:  getEnvInt("RATE_LIMIT_SEARCH_BURST", 20),
+		RateLimitEnhanceRate:  getEnvInt("RATE_LIMIT_ENHANCE_PER_MINUTE", 2),
+		RateLimitEnhanceBurst: getEnvInt("RATE_LIMIT_ENHANCE_BURST", 2),
+		RateLimitGuestRate:    getEnvInt("RATE_LIMIT_GUEST_PER_MINUTE", 1),
+		RateLimitGuestBurst:   getEnvInt("RATE_LIMIT_GUEST_BURST", 3),
 
 		// 应用配置
 		Environment: getEnv("GO_ENV", "development"),
@@ -94,6 +304,33 @@ func (c *Config) GetRedisAddr() string {
 	return fmt.Sprintf("%s:%s", c.RedisHost, c.RedisPort)
 }
 
+// OIDCEnabled 判断是否配置了单点登录
+func (c *Config) OIDCEnabled() bool {
+	return c.OIDCIssuerURL != "" && c.OIDCClientID != ""
+}
+
+// MinJWTSecretLength JWT签名密钥的最小字节数
+const MinJWTSecretLength = 32
+
+// EnsureJWTSecret 检查JWT签名密钥：开发环境下未设置或过短时生成仅本进程有效的随机密钥（重启后已签发的令牌失效），
+// 其他环境下返回错误，避免使用可被猜到的密钥签发令牌
+func (c *Config) EnsureJWTSecret() error {
+	if len(c.JWTSecret) >= MinJWTSecretLength {
+		return nil
+	}
+	if c.Environment != "development" {
+		return fmt.Errorf("JWT_SECRET must be set to at least %d bytes when GO_ENV=%s", MinJWTSecretLength, c.Environment)
+	}
+
+	secret := make([]byte, MinJWTSecretLength)
+	if _, err := rand.Read(secret); err != nil {
+		return fmt.Errorf("generate JWT secret: %w", err)
+	}
+	c.JWTSecret = hex.EncodeToString(secret)
+	log.Printf("Warning: JWT_SECRET is unset or shorter than %d bytes, using a random secret for this process; tokens will not survive a restart", MinJWTSecretLength)
+	return nil
+}
+
 // IsProduction 判断是否为生产环境
 func (c *Config) IsProduction() bool {
 	return c.Environment == "production"
@@ -107,3 +344,135 @@ func getEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+// getEnvInt 获取整型环境变量，如果不存在或格式错误则返回默认值
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvFloat 获取浮点型环境变量，如果不存在或格式错误则返回默认值
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// LLMProviderConfig 一个模型提供方的配置
+type LLMProviderConfig struct {
+	Name      string   `json:"name"`
+	Type      string   `json:"type"` // openai、ark（豆包）、openai_compatible（Ollama、vLLM等兼容接口）或 anthropic
+	APIKey    string   `json:"api_key"`
+	APIKeyEnv string   `json:"api_key_env"` // 从该环境变量读取密钥，避免把密钥写在配置中
+	BaseURL   string   `json:"base_url"`
+	Models    []string `json:"models"` // 提供的模型，"前缀*"匹配该前缀的所有模型，"*"匹配任意模型
+}
+
+// defaultProviderBaseURLs 各类提供方默认的接口地址
+var defaultProviderBaseURLs = map[string]string{
+	"openai":            "https://api.openai.com/v1",
+	"ark":               "https://ark.cn-beijing.volces.com/api/v3",
+	"openai_compatible": "http://localhost:11434/v1",
+	"anthropic":         "https://api.anthropic.com",
+}
+
+// loadProviders 解析JSON格式的模型提供方列表，例如
+// [{"name":"ark","type":"ark","api_key_env":"ARK_API_KEY","models":["doubao-*"]},{"name":"ollama","type":"openai_compatible","models":["llama*","qwen*"]}]。
+// 未配置时使用LLM_API_KEY和LLM_BASE_URL作为唯一的提供方，提供LLM_MODELS中的模型（默认任意模型）
+func loadProviders(raw string) []LLMProviderConfig {
+	var providers []LLMProviderConfig
+	if raw != "" {
+		if err := json.Unmarshal([]byte(raw), &providers); err != nil {
+			log.Printf("Warning: invalid LLM_PROVIDERS, using LLM_API_KEY and LLM_BASE_URL: %v", err)
+			providers = nil
+		}
+	}
+	if len(providers) == 0 {
+		return []LLMProviderConfig{{
+			Name:    "default",
+			Type:    "openai",
+			APIKey:  getEnv("LLM_API_KEY", ""),
+			BaseURL: getEnv("LLM_BASE_URL", "https://api.openai.com/v1"),
+			Models:  strings.Split(getEnv("LLM_MODELS", "*"), ","),
+		}}
+	}
+
+	for i := range providers {
+		provider := &providers[i]
+		if provider.Type == "" {
+			provider.Type = "openai"
+		}
+		if provider.Name == "" {
+			provider.Name = provider.Type
+		}
+		if provider.APIKey == "" && provider.APIKeyEnv != "" {
+			provider.APIKey = os.Getenv(provider.APIKeyEnv)
+		}
+		if provider.BaseURL == "" {
+			provider.BaseURL = defaultProviderBaseURLs[provider.Type]
+		}
+	}
+	return providers
+}
+
+// splitList 解析逗号分隔的列表，忽略空项
+func splitList(raw string) []string {
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// loadModelAliases 解析JSON格式的模型别名表
+func loadModelAliases(raw string) map[string]string {
+	aliases := make(map[string]string)
+	if raw == "" {
+		return aliases
+	}
+	if err := json.Unmarshal([]byte(raw), &aliases); err != nil {
+		log.Printf("Warning: invalid LLM_MODEL_ALIASES, ignoring aliases: %v", err)
+		return make(map[string]string)
+	}
+	return aliases
+}
+
+// loadContextWindows 解析JSON格式的模型上下文长度表，例如 {"qwen2.5*":32768}
+func loadContextWindows(raw string) map[string]int {
+	windows := make(map[string]int)
+	if raw == "" {
+		return windows
+	}
+	if err := json.Unmarshal([]byte(raw), &windows); err != nil {
+		log.Printf("Warning: invalid LLM_CONTEXT_WINDOWS, using defaults: %v", err)
+		return make(map[string]int)
+	}
+	return windows
+}
+
+// defaultPriceTable 默认模型单价表（每千token，人民币）
+var defaultPriceTable = map[string]models.ModelPrice{
+	"doubao-1.5-pro-32k-250115": {Prompt: 0.0008, Completion: 0.002},
+	"*":                         {Prompt: 0.0008, Completion: 0.002},
+}
+
+// loadPriceTable 解析JSON格式的模型单价表，例如 {"gpt-4o":{"prompt":0.018,"completion":0.072}}
+func loadPriceTable(raw string) map[string]models.ModelPrice {
+	if raw == "" {
+		return defaultPriceTable
+	}
+
+	var prices map[string]models.ModelPrice
+	if err := json.Unmarshal([]byte(raw), &prices); err != nil {
+		log.Printf("Warning: invalid LLM_PRICE_TABLE, using default prices: %v", err)
+		return defaultPriceTable
+	}
+	return prices
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 
+	"chat_agent/internal/auth"
 	"chat_agent/internal/models"
 	
 	"gorm.io/driver/mysql"
@@ -67,6 +68,14 @@ func MigrateDatabase(db *gorm.DB) error {
 		&models.Star{},
 		&models.Chat{},
 		&models.Message{},
+		&models.RefreshToken{},
+		&models.PasswordResetToken{},
+		&models.APIKey{},
+		&models.UsageRecord{},
+		&models.UserIdentity{},
+		&models.Memory{},
+		&models.ProfileFact{},
+		&models.ProfileOptOut{},
 	)
 	if err != nil {
 		return fmt.Errorf("failed to migrate database: %w", err)
@@ -84,14 +93,21 @@ func SeedData(db *gorm.DB) error {
 	var userCount int64
 	db.Model(&models.User{}).Count(&userCount)
 	if userCount == 0 {
+		// 默认用户使用随机密码，只在首次启动时打印一次
+		password, hashedPassword, err := generateSeedPassword()
+		if err != nil {
+			return fmt.Errorf("failed to generate default user password: %w", err)
+		}
+
 		// 创建默认用户（ID为1）
 		defaultUser := models.User{
 			Username:  "default_user",
 			Email:     "default@example.com",
-			Password:  "password", // 实际应用中应该加密存储
+			Password:  hashedPassword,
 			Nickname:  "默认用户",
 			Avatar:    "",
 			IsActive:  true,
+			Role:      models.RoleAdmin, // 默认用户作为初始管理员
 		}
 
 		result := db.Create(&defaultUser)
@@ -99,6 +115,14 @@ func SeedData(db *gorm.DB) error {
 			return fmt.Errorf("failed to seed default user: %w", result.Error)
 		}
 		log.Printf("Seeded default user with ID: %d", defaultUser.ID)
+		log.Printf("默认用户 %s 的初始密码为: %s （仅显示一次，请登录后立即修改）", defaultUser.Username, password)
+	} else {
+		if err := rehashLegacyPasswords(db); err != nil {
+			return err
+		}
+		if err := ensureAdminExists(db); err != nil {
+			return err
+		}
 	}
 
 	// 检查是否已有明星数据
@@ -139,4 +163,63 @@ func SeedData(db *gorm.DB) error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// generateSeedPassword 生成随机密码及其哈希
+func generateSeedPassword() (string, string, error) {
+	password, err := auth.GenerateRandomPassword(16)
+	if err != nil {
+		return "", "", err
+	}
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		return "", "", err
+	}
+	return password, hashedPassword, nil
+}
+
+// rehashLegacyPasswords 为历史版本中以明文保存密码的用户重新生成随机密码
+func rehashLegacyPasswords(db *gorm.DB) error {
+	var users []models.User
+	// 游客和单点登录创建的用户没有密码，不需要处理
+	if err := db.Select("id", "username", "password").Where("is_guest = ? AND password <> ''", false).Find(&users).Error; err != nil {
+		return fmt.Errorf("failed to load users: %w", err)
+	}
+
+	for _, user := range users {
+		if auth.IsPasswordHash(user.Password) {
+			continue
+		}
+
+		password, hashedPassword, err := generateSeedPassword()
+		if err != nil {
+			return fmt.Errorf("failed to generate password: %w", err)
+		}
+		if err := db.Model(&models.User{}).Where("id = ?", user.ID).Update("password", hashedPassword).Error; err != nil {
+			return fmt.Errorf("failed to rehash password for user %d: %w", user.ID, err)
+		}
+		log.Printf("用户 %s 的明文密码已替换，新密码为: %s （仅显示一次，请登录后立即修改）", user.Username, password)
+	}
+
+	return nil
+}
+
+// ensureAdminExists 升级前创建的数据库没有管理员时，把默认用户提升为管理员
+func ensureAdminExists(db *gorm.DB) error {
+	var adminCount int64
+	if err := db.Model(&models.User{}).Where("role = ?", models.RoleAdmin).Count(&adminCount).Error; err != nil {
+		return fmt.Errorf("failed to count admins: %w", err)
+	}
+	if adminCount > 0 {
+		return nil
+	}
+
+	result := db.Model(&models.User{}).Where("username = ?", "default_user").Update("role", models.RoleAdmin)
+	if result.Error != nil {
+		return fmt.Errorf("failed to promote default user: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		log.Println("No admin found, promoted default_user to admin")
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var Redis *redis.Client
+
+// InitRedis 初始化Redis连接
+func InitRedis(config *Config) (*redis.Client, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     config.GetRedisAddr(),
+		Password: config.RedisPassword,
+		DB:       config.RedisDB,
+	})
+
+	// 检查连接是否可用
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+
+	log.Println("Connected to redis successfully")
+	Redis = client
+	return client, nil
+}
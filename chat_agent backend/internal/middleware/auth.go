@@ -0,0 +1,177 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"chat_agent/internal/auth"
+	"chat_agent/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 认证通过后写入gin上下文的键
+const (
+	ContextUserIDKey     = "user_id"
+	ContextRoleKey       = "user_role"
+	ContextAuthMethodKey = "auth_method"
+	ContextScopesKey     = "auth_scopes"
+	ContextAPIKeyIDKey   = "api_key_id"
+)
+
+// APIKeyHeader 服务端调用携带API密钥的请求头
+const APIKeyHeader = "X-API-Key"
+
+// GuestCookieName 游客会话Cookie的名称
+const GuestCookieName = "guest_session"
+
+// APIKeyAuthenticator 校验API密钥的接口
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, rawKey string) (*auth.Principal, error)
+}
+
+// SessionAuthenticator 按用户的当前状态校验登录会话的接口，issuedAt为访问令牌的签发时间
+type SessionAuthenticator interface {
+	AuthenticateSession(ctx context.Context, userID uint, issuedAt time.Time) (*auth.Principal, error)
+}
+
+// AuthMiddleware 认证中间件：优先使用X-API-Key，其次校验Bearer访问令牌，
+// 都没有时尝试游客Cookie，并将调用方信息写入上下文。apiKeys为nil时不接受API密钥；
+// sessions不为nil时访问令牌的用户每次从数据库重新读取，角色调整、禁用账号和修改密码立即生效，否则使用令牌中的角色
+func AuthMiddleware(tokenManager *auth.TokenManager, apiKeys APIKeyAuthenticator, sessions SessionAuthenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 服务端调用：API密钥
+		if rawKey := strings.TrimSpace(c.GetHeader(APIKeyHeader)); rawKey != "" && apiKeys != nil {
+			principal, err := apiKeys.AuthenticateAPIKey(c.Request.Context(), rawKey)
+			if err != nil {
+				abortAuthError(c, err, "API密钥无效或已失效")
+				return
+			}
+
+			setPrincipal(c, principal)
+			c.Next()
+			return
+		}
+
+		// 用户会话：从Authorization头中提取令牌
+		header := c.GetHeader("Authorization")
+		tokenString, found := strings.CutPrefix(header, "Bearer ")
+		if !found || strings.TrimSpace(tokenString) == "" {
+			// 未登录时回退到游客会话
+			if guestToken, err := c.Cookie(GuestCookieName); err == nil && guestToken != "" {
+				userID, err := tokenManager.ParseGuestToken(guestToken)
+				if err != nil {
+					abortUnauthorized(c, "游客会话已失效，请重新开始")
+					return
+				}
+				setPrincipal(c, &auth.Principal{
+					UserID: userID,
+					Role:   models.RoleUser,
+					Method: auth.MethodGuest,
+				})
+				c.Next()
+				return
+			}
+			abortUnauthorized(c, "缺少访问令牌")
+			return
+		}
+
+		// 校验令牌
+		claims, err := tokenManager.ParseAccessToken(strings.TrimSpace(tokenString))
+		if err != nil {
+			abortUnauthorized(c, "访问令牌无效或已过期")
+			return
+		}
+
+		principal := &auth.Principal{
+			UserID: claims.UserID,
+			Role:   claims.Role,
+			Method: auth.MethodSession,
+		}
+		if sessions != nil {
+			var issuedAt time.Time
+			if claims.IssuedAt != nil {
+				issuedAt = claims.IssuedAt.Time
+			}
+			principal, err = sessions.AuthenticateSession(c.Request.Context(), claims.UserID, issuedAt)
+			if err != nil {
+				abortAuthError(c, err, "访问令牌无效或已过期")
+				return
+			}
+		}
+
+		setPrincipal(c, principal)
+		c.Next()
+	}
+}
+
+// GetUserID 从上下文中获取已认证的用户ID
+func GetUserID(c *gin.Context) (uint, bool) {
+	value, exists := c.Get(ContextUserIDKey)
+	if !exists {
+		return 0, false
+	}
+	userID, ok := value.(uint)
+	return userID, ok && userID != 0
+}
+
+// GetRole 从上下文中获取已认证用户的角色
+func GetRole(c *gin.Context) string {
+	return c.GetString(ContextRoleKey)
+}
+
+// GetAuthMethod 从上下文中获取认证方式
+func GetAuthMethod(c *gin.Context) string {
+	return c.GetString(ContextAuthMethodKey)
+}
+
+// GetScopes 从上下文中获取API密钥的权限范围
+func GetScopes(c *gin.Context) []string {
+	return c.GetStringSlice(ContextScopesKey)
+}
+
+// GetAPIKeyID 从上下文中获取API密钥ID，非API密钥认证时返回0
+func GetAPIKeyID(c *gin.Context) uint {
+	value, exists := c.Get(ContextAPIKeyIDKey)
+	if !exists {
+		return 0
+	}
+	keyID, _ := value.(uint)
+	return keyID
+}
+
+// setPrincipal 将调用方信息写入上下文
+func setPrincipal(c *gin.Context, principal *auth.Principal) {
+	c.Set(ContextUserIDKey, principal.UserID)
+	c.Set(ContextRoleKey, principal.Role)
+	c.Set(ContextAuthMethodKey, principal.Method)
+	c.Set(ContextScopesKey, principal.Scopes)
+	if principal.APIKeyID != 0 {
+		c.Set(ContextAPIKeyIDKey, principal.APIKeyID)
+	}
+}
+
+// abortAuthError 令牌无效时返回401，其他错误返回500
+func abortAuthError(c *gin.Context, err error, message string) {
+	if errors.Is(err, auth.ErrInvalidToken) {
+		abortUnauthorized(c, message)
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+		"code":    500,
+		"message": "服务器内部错误",
+		"data":    nil,
+	})
+}
+
+// abortUnauthorized 以统一的响应格式中止请求
+func abortUnauthorized(c *gin.Context, message string) {
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+		"code":    401,
+		"message": message,
+		"data":    nil,
+	})
+}
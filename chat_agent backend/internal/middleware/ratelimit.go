@@ -0,0 +1,233 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// RateLimit 令牌桶限流参数
+type RateLimit struct {
+	PerMinute int // 每分钟补充的令牌数
+	Burst     int // 桶容量，允许的瞬时突发请求数
+}
+
+// Enabled 判断限流参数是否有效
+func (l RateLimit) Enabled() bool {
+	return l.PerMinute > 0
+}
+
+// capacity 桶容量，至少为1
+func (l RateLimit) capacity() float64 {
+	if l.Burst < 1 {
+		return 1
+	}
+	return float64(l.Burst)
+}
+
+// ratePerSecond 每秒补充的令牌数
+func (l RateLimit) ratePerSecond() float64 {
+	return float64(l.PerMinute) / 60
+}
+
+// RateLimitResult 一次限流判断的结果
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration // 被拒绝时距离下一个可用令牌的时间
+}
+
+// RateLimiter 限流器接口
+type RateLimiter interface {
+	Allow(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error)
+}
+
+// RateLimitMiddleware 限流中间件，按API密钥、用户或IP区分调用方。
+// name用于区分不同的接口，使各接口的配额互不影响
+func RateLimitMiddleware(limiter RateLimiter, name string, limit RateLimit) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limiter == nil || !limit.Enabled() {
+			c.Next()
+			return
+		}
+
+		key := name + ":" + rateLimitIdentity(c)
+		result, err := limiter.Allow(c.Request.Context(), key, limit)
+		if err != nil {
+			// 限流后端不可用时放行，避免影响正常服务
+			log.Printf("rate limiter error for %s: %v", key, err)
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limit.PerMinute))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+
+		if !result.Allowed {
+			retryAfter := int(math.Ceil(result.RetryAfter.Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"code":    429,
+				"message": fmt.Sprintf("请求过于频繁，请%d秒后重试", retryAfter),
+				"data":    nil,
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// rateLimitIdentity 获取限流使用的调用方标识
+func rateLimitIdentity(c *gin.Context) string {
+	if keyID := GetAPIKeyID(c); keyID != 0 {
+		return "key:" + strconv.FormatUint(uint64(keyID), 10)
+	}
+	if userID, ok := GetUserID(c); ok {
+		return "user:" + strconv.FormatUint(uint64(userID), 10)
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// tokenBucket 内存令牌桶
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// MemoryRateLimiter 进程内令牌桶限流器，适用于单实例部署
+type MemoryRateLimiter struct {
+	mutex       sync.Mutex
+	buckets     map[string]*tokenBucket
+	idleTimeout time.Duration
+	lastSweep   time.Time
+}
+
+// NewMemoryRateLimiter 创建新的进程内限流器
+func NewMemoryRateLimiter() *MemoryRateLimiter {
+	return &MemoryRateLimiter{
+		buckets:     make(map[string]*tokenBucket),
+		idleTimeout: 10 * time.Minute,
+		lastSweep:   time.Now(),
+	}
+}
+
+// Allow 尝试从令牌桶中取出一个令牌
+func (l *MemoryRateLimiter) Allow(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	capacity := limit.capacity()
+	rate := limit.ratePerSecond()
+
+	bucket, exists := l.buckets[key]
+	if !exists {
+		bucket = &tokenBucket{tokens: capacity, lastSeen: now}
+		l.buckets[key] = bucket
+	}
+
+	// 按流逝的时间补充令牌
+	elapsed := now.Sub(bucket.lastSeen).Seconds()
+	bucket.tokens = math.Min(capacity, bucket.tokens+elapsed*rate)
+	bucket.lastSeen = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return RateLimitResult{Allowed: true, Remaining: int(bucket.tokens)}, nil
+	}
+
+	retryAfter := time.Duration((1 - bucket.tokens) / rate * float64(time.Second))
+	return RateLimitResult{Allowed: false, Remaining: 0, RetryAfter: retryAfter}, nil
+}
+
+// sweep 定期清理长时间未使用的令牌桶，避免内存无限增长
+func (l *MemoryRateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.idleTimeout {
+		return
+	}
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.lastSeen) > l.idleTimeout {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// redisTokenBucketScript 在Redis中原子地执行令牌桶算法
+// 返回值：{是否允许, 剩余令牌数, 需要等待的毫秒数}
+var redisTokenBucketScript = redis.NewScript(`
+local key = KEYS[1]
+local rate = tonumber(ARGV[1])
+local capacity = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local data = redis.call('HMGET', key, 'tokens', 'ts')
+local tokens = tonumber(data[1])
+local ts = tonumber(data[2])
+if tokens == nil or ts == nil then
+  tokens = capacity
+  ts = now
+end
+
+local elapsed = math.max(0, now - ts)
+tokens = math.min(capacity, tokens + elapsed * rate / 1000)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  retry = math.ceil((1 - tokens) * 1000 / rate)
+end
+
+redis.call('HSET', key, 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('PEXPIRE', key, math.ceil(capacity * 1000 / rate) + 1000)
+return {allowed, math.floor(tokens), retry}
+`)
+
+// RedisRateLimiter 基于Redis的令牌桶限流器，多实例部署时共享配额
+type RedisRateLimiter struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisRateLimiter 创建新的Redis限流器
+func NewRedisRateLimiter(client *redis.Client) *RedisRateLimiter {
+	return &RedisRateLimiter{
+		client: client,
+		prefix: "ratelimit:",
+	}
+}
+
+// Allow 尝试从令牌桶中取出一个令牌
+func (l *RedisRateLimiter) Allow(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	values, err := redisTokenBucketScript.Run(ctx, l.client, []string{l.prefix + key},
+		limit.ratePerSecond(), limit.capacity(), time.Now().UnixMilli()).Int64Slice()
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	if len(values) != 3 {
+		return RateLimitResult{}, fmt.Errorf("unexpected rate limit script result: %v", values)
+	}
+
+	return RateLimitResult{
+		Allowed:    values[0] == 1,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+	}, nil
+}
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// API密钥权限范围常量
+const (
+	ScopeChatRead   = "chat:read"   // 读取聊天会话和消息
+	ScopeChatWrite  = "chat:write"  // 创建会话、发送和删除消息
+	ScopeStarsWrite = "stars:write" // 创建和编辑明星人设
+	ScopeStarsAdmin = "stars:admin" // 删除明星、切换上下线
+)
+
+// scopeRequiredRoles 申请各权限范围所需的最低用户角色
+var scopeRequiredRoles = map[string]string{
+	ScopeChatRead:   RoleUser,
+	ScopeChatWrite:  RoleUser,
+	ScopeStarsWrite: RoleEditor,
+	ScopeStarsAdmin: RoleAdmin,
+}
+
+// scopeImplies 权限范围的包含关系，高级权限自动拥有低级权限
+var scopeImplies = map[string][]string{
+	ScopeChatWrite:  {ScopeChatRead},
+	ScopeStarsAdmin: {ScopeStarsWrite},
+}
+
+// IsValidScope 判断权限范围是否合法
+func IsValidScope(scope string) bool {
+	_, ok := scopeRequiredRoles[scope]
+	return ok
+}
+
+// ScopeRequiredRole 获取申请某权限范围所需的最低角色
+func ScopeRequiredRole(scope string) string {
+	return scopeRequiredRoles[scope]
+}
+
+// HasScope 判断已授予的权限范围是否包含要求的权限
+func HasScope(granted []string, required string) bool {
+	for _, scope := range granted {
+		if scope == required {
+			return true
+		}
+		for _, implied := range scopeImplies[scope] {
+			if implied == required {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// APIKey API密钥模型（只保存密钥的哈希值）
+type APIKey struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	Name       string     `gorm:"size:100;not null" json:"name"`
+	Prefix     string     `gorm:"size:16;not null" json:"prefix"` // 密钥前缀，便于用户识别
+	KeyHash    string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	Scopes     string     `gorm:"size:500" json:"scopes"` // 逗号分隔的权限范围
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// TableName 指定表名
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// ScopeList 获取权限范围列表
+func (k *APIKey) ScopeList() []string {
+	if k.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(k.Scopes, ",")
+}
+
+// IsValid 判断API密钥是否仍然可用
+func (k *APIKey) IsValid(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// APIKeyResponse API密钥响应数据
+type APIKeyResponse struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// ToAPIKeyResponse 转换为API密钥响应数据
+func (k *APIKey) ToAPIKeyResponse() APIKeyResponse {
+	return APIKeyResponse{
+		ID:         k.ID,
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Scopes:     k.ScopeList(),
+		LastUsedAt: k.LastUsedAt,
+		ExpiresAt:  k.ExpiresAt,
+		RevokedAt:  k.RevokedAt,
+		CreatedAt:  k.CreatedAt,
+	}
+}
+
+// CreateAPIKeyRequest 创建API密钥请求
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`
+	ExpiresInDays int      `json:"expires_in_days" binding:"omitempty,min=1,max=3650"` // 为空表示永不过期
+}
+
+// CreateAPIKeyResponse 创建API密钥响应，明文密钥只在创建时返回一次
+type CreateAPIKeyResponse struct {
+	Key    string         `json:"key"`
+	APIKey APIKeyResponse `json:"api_key"`
+}
@@ -20,6 +20,13 @@ type Chat struct {
 	LastActive  time.Time `json:"last_active"`
 	MessageCount int      `gorm:"default:0" json:"message_count"`
 
+	// 滚动摘要：滑出上下文窗口的较早对话被压缩为摘要，SummaryUntilID为已摘要的最后一条消息
+	Summary        string `gorm:"type:text" json:"summary,omitempty"`
+	SummaryUntilID uint   `gorm:"default:0" json:"-"`
+
+	// 会话的生成参数，覆盖明星的配置
+	GenerationParams `gorm:"embedded;embeddedPrefix:gen_"`
+
 	// 关联关系
 	Star     Star      `gorm:"foreignKey:StarID" json:"star,omitempty"`
 	Messages []Message `gorm:"foreignKey:ChatID" json:"messages,omitempty"`
@@ -39,9 +46,11 @@ type ChatResponse struct {
 	LastMessage  string       `json:"last_message"`
 	LastActive   time.Time    `json:"last_active"`
 	MessageCount int          `json:"message_count"`
+	Summary      string       `json:"summary,omitempty"`
 	CreatedAt    time.Time    `json:"created_at"`
 	UpdatedAt    time.Time    `json:"updated_at"`
 	Star         StarResponse `json:"star,omitempty"`
+	GenerationParams
 }
 
 // ToChatResponse 转换为聊天会话响应数据
@@ -54,8 +63,10 @@ func (c *Chat) ToChatResponse(withStar bool) ChatResponse {
 		LastMessage:  c.LastMessage,
 		LastActive:   c.LastActive,
 		MessageCount: c.MessageCount,
+		Summary:      c.Summary,
 		CreatedAt:    c.CreatedAt,
 		UpdatedAt:    c.UpdatedAt,
+		GenerationParams: c.GenerationParams,
 	}
 
 	if withStar && c.Star.ID != 0 {
@@ -74,4 +85,5 @@ type CreateChatRequest struct {
 // UpdateChatRequest 更新聊天会话请求
 type UpdateChatRequest struct {
 	Title string `json:"title"`
+	GenerationParams // 只修改设置了的参数
 }
\ No newline at end of file
@@ -0,0 +1,44 @@
+package models
+
+// GenerationParams 模型生成参数，未设置（nil）的参数沿用上一级的配置：请求覆盖会话，会话覆盖明星，都未设置时使用提供方的默认值
+type GenerationParams struct {
+	Temperature      *float32 `json:"temperature,omitempty"`
+	TopP             *float32 `json:"top_p,omitempty"`
+	MaxTokens        *int     `json:"max_tokens,omitempty"`
+	PresencePenalty  *float32 `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float32 `json:"frequency_penalty,omitempty"`
+	Stop             []string `gorm:"serializer:json;type:text" json:"stop,omitempty"` // 停止序列，空数组表示清除上一级的配置
+	Seed             *int     `json:"seed,omitempty"`
+}
+
+// Merge 用override中已设置的参数覆盖当前参数
+func (p GenerationParams) Merge(override GenerationParams) GenerationParams {
+	if override.Temperature != nil {
+		p.Temperature = override.Temperature
+	}
+	if override.TopP != nil {
+		p.TopP = override.TopP
+	}
+	if override.MaxTokens != nil {
+		p.MaxTokens = override.MaxTokens
+	}
+	if override.PresencePenalty != nil {
+		p.PresencePenalty = override.PresencePenalty
+	}
+	if override.FrequencyPenalty != nil {
+		p.FrequencyPenalty = override.FrequencyPenalty
+	}
+	if override.Stop != nil {
+		p.Stop = override.Stop
+	}
+	if override.Seed != nil {
+		p.Seed = override.Seed
+	}
+	return p
+}
+
+// IsEmpty 是否没有设置任何参数
+func (p GenerationParams) IsEmpty() bool {
+	return p.Temperature == nil && p.TopP == nil && p.MaxTokens == nil && p.PresencePenalty == nil &&
+		p.FrequencyPenalty == nil && p.Stop == nil && p.Seed == nil
+}
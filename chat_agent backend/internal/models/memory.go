@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 记忆类型常量（与ai.MemoryType的取值一致）
+const (
+	MemoryTypeShortTerm = "short_term"
+	MemoryTypeLongTerm  = "long_term"
+)
+
+// Memory 持久化的会话记忆，便于审计和备份智能体记住的内容
+type Memory struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	ChatID  uint    `gorm:"not null;index:idx_memory_chat_type" json:"chat_id"`
+	Type    string  `gorm:"size:20;not null;index:idx_memory_chat_type" json:"type"` // "short_term", "long_term"
+	Key     string  `gorm:"column:fact_key;size:100;index" json:"key,omitempty"`     // 事实的键，相同键的长期记忆会相互替换
+	Content string  `gorm:"type:text;not null" json:"content"`
+	Weight  float64 `gorm:"not null;default:1" json:"weight"`     // UpdatedAt时刻的权重，之后随时间衰减
+	Pinned  bool    `gorm:"not null;default:false" json:"pinned"` // 用户置顶的记忆总会出现在提示词中
+	// 排序分数，任意时刻按该分数排序都等价于按衰减后的有效权重排序（见ai.MemoryDecay.RankScore）
+	RankScore float64 `gorm:"not null;default:0;index" json:"-"`
+}
+
+// TableName 指定表名
+func (Memory) TableName() string {
+	return "memories"
+}
+
+// MemoryResponse 返回给用户的长期记忆
+type MemoryResponse struct {
+	ID        string    `json:"id"`
+	Key       string    `json:"key,omitempty"`
+	Content   string    `json:"content"`
+	Weight    float64   `json:"weight"` // 当前的有效权重
+	Pinned    bool      `json:"pinned"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// UpdateMemoryRequest 修改记忆请求，未提供的字段保持不变
+type UpdateMemoryRequest struct {
+	Content *string `json:"content" binding:"omitempty,min=1,max=500"`
+	Pinned  *bool   `json:"pinned"`
+}
@@ -15,11 +15,11 @@ const (
 
 // 消息状态常量
 const (
-	MessageStatusSending    = "sending"
-	MessageStatusSent       = "sent"
-	MessageStatusDelivered  = "delivered"
-	MessageStatusRead       = "read"
-	MessageStatusFailed     = "failed"
+	MessageStatusSending   = "sending"
+	MessageStatusSent      = "sent"
+	MessageStatusDelivered = "delivered"
+	MessageStatusRead      = "read"
+	MessageStatusFailed    = "failed"
 )
 
 // 消息类型常量
@@ -36,12 +36,18 @@ type Message struct {
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 
-	ChatID    uint   `gorm:"not null;index" json:"chat_id"`
-	SenderID  uint   `gorm:"index" json:"sender_id"` // 0表示系统/明星，非0表示用户ID
-	SenderType string `gorm:"size:20;not null" json:"sender_type"` // "user", "star", "system"
-	Content   string `gorm:"type:text;not null" json:"content"`
+	ChatID      uint   `gorm:"not null;index" json:"chat_id"`
+	SenderID    uint   `gorm:"index" json:"sender_id"`              // 0表示系统/明星，非0表示用户ID
+	SenderType  string `gorm:"size:20;not null" json:"sender_type"` // "user", "star", "system"
+	Content     string `gorm:"type:text;not null" json:"content"`
 	MessageType string `gorm:"size:20;default:'text'" json:"message_type"` // "text", "image", "voice"
-	Status    string `gorm:"size:20;default:'sent'" json:"status"` // "sending", "sent", "delivered", "read", "failed"
+	Status      string `gorm:"size:20;default:'sent'" json:"status"`       // "sending", "sent", "delivered", "read", "failed"
+
+	// 模型调用信息（仅明星回复消息）
+	Model            string `gorm:"size:100" json:"model"`
+	PromptTokens     int    `gorm:"default:0" json:"prompt_tokens"`
+	CompletionTokens int    `gorm:"default:0" json:"completion_tokens"`
+	Fallback         bool   `gorm:"default:false" json:"fallback"` // 由回退模型或兜底回复生成
 
 	// 关联关系
 	Chat Chat `gorm:"foreignKey:ChatID" json:"chat,omitempty"`
@@ -54,29 +60,37 @@ func (Message) TableName() string {
 
 // MessageResponse 消息响应数据
 type MessageResponse struct {
-	ID          uint      `json:"id"`
-	ChatID      uint      `json:"chat_id"`
-	SenderID    uint      `json:"sender_id"`
-	SenderType  string    `json:"sender_type"`
-	Content     string    `json:"content"`
-	MessageType string    `json:"message_type"`
-	Status      string    `json:"status"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID               uint      `json:"id"`
+	ChatID           uint      `json:"chat_id"`
+	SenderID         uint      `json:"sender_id"`
+	SenderType       string    `json:"sender_type"`
+	Content          string    `json:"content"`
+	MessageType      string    `json:"message_type"`
+	Status           string    `json:"status"`
+	Model            string    `json:"model,omitempty"`
+	PromptTokens     int       `json:"prompt_tokens,omitempty"`
+	CompletionTokens int       `json:"completion_tokens,omitempty"`
+	Fallback         bool      `json:"fallback,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
 }
 
 // ToMessageResponse 转换为消息响应数据
 func (m *Message) ToMessageResponse() MessageResponse {
 	return MessageResponse{
-		ID:          m.ID,
-		ChatID:      m.ChatID,
-		SenderID:    m.SenderID,
-		SenderType:  m.SenderType,
-		Content:     m.Content,
-		MessageType: m.MessageType,
-		Status:      m.Status,
-		CreatedAt:   m.CreatedAt,
-		UpdatedAt:   m.UpdatedAt,
+		ID:               m.ID,
+		ChatID:           m.ChatID,
+		SenderID:         m.SenderID,
+		SenderType:       m.SenderType,
+		Content:          m.Content,
+		MessageType:      m.MessageType,
+		Status:           m.Status,
+		Model:            m.Model,
+		PromptTokens:     m.PromptTokens,
+		CompletionTokens: m.CompletionTokens,
+		Fallback:         m.Fallback,
+		CreatedAt:        m.CreatedAt,
+		UpdatedAt:        m.UpdatedAt,
 	}
 }
 
@@ -86,12 +100,13 @@ type SendMessageRequest struct {
 	Content     string `json:"content" binding:"required"`
 	MessageType string `json:"message_type" binding:"omitempty,oneof=text image voice"`
 	Model       string `json:"model" binding:"omitempty"`
+	GenerationParams // 仅对本次请求生效，覆盖会话和明星的配置
 }
 
 // MessageListQuery 消息列表查询参数
 type MessageListQuery struct {
-	Page     int `form:"page,default=1" binding:"min=1"`
-	PageSize int `form:"page_size,default=50" binding:"min=1,max=200"`
+	Page     int  `form:"page,default=1" binding:"min=1"`
+	PageSize int  `form:"page_size,default=50" binding:"min=1,max=200"`
 	BeforeID uint `form:"before_id" binding:"omitempty"` // 获取该ID之前的消息
 	AfterID  uint `form:"after_id" binding:"omitempty"`  // 获取该ID之后的消息
-}
\ No newline at end of file
+}
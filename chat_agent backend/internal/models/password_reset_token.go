@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+)
+
+// PasswordResetToken 密码重置令牌模型（只保存令牌的哈希值）
+type PasswordResetToken struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	TokenHash string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+}
+
+// TableName 指定表名
+func (PasswordResetToken) TableName() string {
+	return "password_reset_tokens"
+}
+
+// IsValid 判断重置令牌是否仍然可用
+func (t *PasswordResetToken) IsValid(now time.Time) bool {
+	return t.UsedAt == nil && now.Before(t.ExpiresAt)
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// ProfileFact 用户画像中的一条事实，由各会话中提取的事实汇总而成，在该用户与所有明星的会话中共享
+type ProfileFact struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID       uint    `gorm:"not null;uniqueIndex:idx_profile_user_key" json:"-"`
+	Key          string  `gorm:"column:fact_key;size:100;not null;uniqueIndex:idx_profile_user_key" json:"key"` // 事实的键，相同键的事实相互替换
+	Content      string  `gorm:"size:500;not null" json:"content"`
+	Weight       float64 `gorm:"not null;default:1" json:"weight"`
+	SourceStarID uint    `json:"source_star_id"` // 最近一次在与哪位明星的会话中得知
+}
+
+// TableName 指定表名
+func (ProfileFact) TableName() string {
+	return "profile_facts"
+}
+
+// ProfileOptOut 用户选择不与某位明星共享画像：该明星既看不到画像，也不会向画像中添加事实
+type ProfileOptOut struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	UserID uint `gorm:"not null;uniqueIndex:idx_profile_opt_out" json:"user_id"`
+	StarID uint `gorm:"not null;uniqueIndex:idx_profile_opt_out" json:"star_id"`
+}
+
+// TableName 指定表名
+func (ProfileOptOut) TableName() string {
+	return "profile_opt_outs"
+}
+
+// ProfileResponse 用户画像响应数据
+type ProfileResponse struct {
+	Facts           []ProfileFact `json:"facts"`
+	OptedOutStarIDs []uint        `json:"opted_out_star_ids"` // 不共享画像的明星
+}
+
+// UpdateProfileSharingRequest 设置是否与某位明星共享画像
+type UpdateProfileSharingRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
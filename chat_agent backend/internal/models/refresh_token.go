@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+)
+
+// RefreshToken 刷新令牌模型（只保存令牌的哈希值）
+type RefreshToken struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	TokenHash string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
+// TableName 指定表名
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+// IsValid 判断刷新令牌是否仍然有效
+func (t *RefreshToken) IsValid(now time.Time) bool {
+	return t.RevokedAt == nil && now.Before(t.ExpiresAt)
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -25,6 +26,13 @@ type Star struct {
 	StyleFeatures string `gorm:"type:text" json:"style_features"` // 语言风格特征描述
 	IsActive      bool   `gorm:"default:true" json:"is_active"`
 
+	// 模型回退配置
+	FallbackModels string `gorm:"size:500" json:"fallback_models"` // 请求的模型失败时依次尝试的模型，逗号分隔，为空时使用全局配置
+	FallbackReply  string `gorm:"type:text" json:"fallback_reply"` // 所有模型都失败时的兜底回复，以明星的口吻编写，为空时返回错误
+
+	// 生成参数，会话和请求可以在管理员设置的范围内覆盖
+	GenerationParams `gorm:"embedded;embeddedPrefix:gen_"`
+
 	// 关联关系
 	Chats []Chat `gorm:"foreignKey:StarID" json:"-"`
 }
@@ -34,65 +42,82 @@ func (Star) TableName() string {
 	return "stars"
 }
 
+// FallbackModelList 明星配置的回退模型列表
+func (s *Star) FallbackModelList() []string {
+	var models []string
+	for _, model := range strings.Split(s.FallbackModels, ",") {
+		if model = strings.TrimSpace(model); model != "" {
+			models = append(models, model)
+		}
+	}
+	return models
+}
+
 // StarResponse 明星响应数据
 type StarResponse struct {
-	ID            uint      `json:"id"`
-	Name          string    `json:"name"`
-	EnglishName   string    `json:"english_name"`
-	Gender        string    `json:"gender"`
-	BirthDate     string    `json:"birth_date"`
-	Nationality   string    `json:"nationality"`
-	Occupation    string    `json:"occupation"`
-	Avatar        string    `json:"avatar"`
-	CoverImage    string    `json:"cover_image"`
-	Introduction  string    `json:"introduction"`
-	IsActive      bool      `json:"is_active"`
-	CreatedAt     time.Time `json:"created_at"`
+	ID           uint      `json:"id"`
+	Name         string    `json:"name"`
+	EnglishName  string    `json:"english_name"`
+	Gender       string    `json:"gender"`
+	BirthDate    string    `json:"birth_date"`
+	Nationality  string    `json:"nationality"`
+	Occupation   string    `json:"occupation"`
+	Avatar       string    `json:"avatar"`
+	CoverImage   string    `json:"cover_image"`
+	Introduction string    `json:"introduction"`
+	IsActive     bool      `json:"is_active"`
+	CreatedAt    time.Time `json:"created_at"`
 }
 
 // ToStarResponse 转换为明星响应数据
 func (s *Star) ToStarResponse() StarResponse {
 	return StarResponse{
-		ID:            s.ID,
-		Name:          s.Name,
-		EnglishName:   s.EnglishName,
-		Gender:        s.Gender,
-		BirthDate:     s.BirthDate,
-		Nationality:   s.Nationality,
-		Occupation:    s.Occupation,
-		Avatar:        s.Avatar,
-		CoverImage:    s.CoverImage,
-		Introduction:  s.Introduction,
-		IsActive:      s.IsActive,
-		CreatedAt:     s.CreatedAt,
+		ID:           s.ID,
+		Name:         s.Name,
+		EnglishName:  s.EnglishName,
+		Gender:       s.Gender,
+		BirthDate:    s.BirthDate,
+		Nationality:  s.Nationality,
+		Occupation:   s.Occupation,
+		Avatar:       s.Avatar,
+		CoverImage:   s.CoverImage,
+		Introduction: s.Introduction,
+		IsActive:     s.IsActive,
+		CreatedAt:    s.CreatedAt,
 	}
 }
 
 // CreateStarRequest 创建明星请求
 type CreateStarRequest struct {
-	Name          string `json:"name" binding:"required"`
-	EnglishName   string `json:"english_name"`
-	Gender        string `json:"gender"`
-	BirthDate     string `json:"birth_date"`
-	Nationality   string `json:"nationality"`
-	Occupation    string `json:"occupation"`
-	Avatar        string `json:"avatar"`
-	CoverImage    string `json:"cover_image"`
-	Introduction  string `json:"introduction"`
-	StyleFeatures string `json:"style_features" binding:"required"`
+	Name           string `json:"name" binding:"required"`
+	EnglishName    string `json:"english_name"`
+	Gender         string `json:"gender"`
+	BirthDate      string `json:"birth_date"`
+	Nationality    string `json:"nationality"`
+	Occupation     string `json:"occupation"`
+	Avatar         string `json:"avatar"`
+	CoverImage     string `json:"cover_image"`
+	Introduction   string `json:"introduction"`
+	StyleFeatures  string `json:"style_features" binding:"required"`
+	FallbackModels string `json:"fallback_models"`
+	FallbackReply  string `json:"fallback_reply"`
+	GenerationParams
 }
 
 // UpdateStarRequest 更新明星请求
 type UpdateStarRequest struct {
-	Name          string `json:"name"`
-	EnglishName   string `json:"english_name"`
-	Gender        string `json:"gender"`
-	BirthDate     string `json:"birth_date"`
-	Nationality   string `json:"nationality"`
-	Occupation    string `json:"occupation"`
-	Avatar        string `json:"avatar"`
-	CoverImage    string `json:"cover_image"`
-	Introduction  string `json:"introduction"`
-	StyleFeatures string `json:"style_features"`
-	IsActive      *bool  `json:"is_active"`
-}
\ No newline at end of file
+	Name             string  `json:"name"`
+	EnglishName      string  `json:"english_name"`
+	Gender           string  `json:"gender"`
+	BirthDate        string  `json:"birth_date"`
+	Nationality      string  `json:"nationality"`
+	Occupation       string  `json:"occupation"`
+	Avatar           string  `json:"avatar"`
+	CoverImage       string  `json:"cover_image"`
+	Introduction     string  `json:"introduction"`
+	StyleFeatures    string  `json:"style_features"`
+	IsActive         *bool   `json:"is_active"`
+	FallbackModels   *string `json:"fallback_models"`
+	FallbackReply    *string `json:"fallback_reply"`
+	GenerationParams         // 只修改设置了的参数
+}
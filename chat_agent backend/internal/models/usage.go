@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+)
+
+// UsageRecord 一次模型调用的token用量记录
+type UsageRecord struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+
+	UserID           uint    `gorm:"not null;index" json:"user_id"`
+	ChatID           uint    `gorm:"not null;index" json:"chat_id"`
+	StarID           uint    `gorm:"not null;index" json:"star_id"`
+	MessageID        uint    `gorm:"index" json:"message_id"`
+	Model            string  `gorm:"size:100;index" json:"model"`
+	PromptTokens     int     `gorm:"not null;default:0" json:"prompt_tokens"`
+	CompletionTokens int     `gorm:"not null;default:0" json:"completion_tokens"`
+	TotalTokens      int     `gorm:"not null;default:0" json:"total_tokens"`
+	EstimatedCost    float64 `gorm:"not null;default:0" json:"estimated_cost"` // 按记录时的价格表估算的费用
+}
+
+// TableName 指定表名
+func (UsageRecord) TableName() string {
+	return "usage_records"
+}
+
+// ModelPrice 模型单价（每千token）
+type ModelPrice struct {
+	Prompt     float64 `json:"prompt"`
+	Completion float64 `json:"completion"`
+}
+
+// UsageRollup 按某一维度汇总的用量
+type UsageRollup struct {
+	Key              string  `json:"key"` // 汇总维度的值，如模型名、明星ID、会话ID
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	Requests         int64   `json:"requests"`
+	EstimatedCost    float64 `json:"estimated_cost"`
+}
+
+// QuotaStatus 额度使用情况
+type QuotaStatus struct {
+	Limit     int64 `json:"limit"` // 0表示不限制
+	Used      int64 `json:"used"`
+	Remaining int64 `json:"remaining"`
+}
+
+// UsageReport 用量报告
+type UsageReport struct {
+	Period           string        `json:"period"` // day 或 month
+	Since            time.Time     `json:"since"`
+	PromptTokens     int64         `json:"prompt_tokens"`
+	CompletionTokens int64         `json:"completion_tokens"`
+	TotalTokens      int64         `json:"total_tokens"`
+	Requests         int64         `json:"requests"`
+	EstimatedCost    float64       `json:"estimated_cost"`
+	Currency         string        `json:"currency"`
+	DailyQuota       QuotaStatus   `json:"daily_quota"`
+	MonthlyQuota     QuotaStatus   `json:"monthly_quota"`
+	ByModel          []UsageRollup `json:"by_model"`
+	ByStar           []UsageRollup `json:"by_star"`
+	ByChat           []UsageRollup `json:"by_chat"`
+}
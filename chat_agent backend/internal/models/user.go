@@ -6,6 +6,31 @@ import (
 	"gorm.io/gorm"
 )
 
+// 用户角色常量
+const (
+	RoleUser   = "user"   // 普通用户
+	RoleEditor = "editor" // 编辑，可维护明星人设
+	RoleAdmin  = "admin"  // 管理员，拥有全部权限
+)
+
+// roleLevels 角色等级，高等级角色拥有低等级角色的全部权限
+var roleLevels = map[string]int{
+	RoleUser:   1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+}
+
+// IsValidRole 判断角色是否合法
+func IsValidRole(role string) bool {
+	_, ok := roleLevels[role]
+	return ok
+}
+
+// RoleAtLeast 判断角色是否不低于要求的角色
+func RoleAtLeast(role, required string) bool {
+	return roleLevels[role] >= roleLevels[required] && roleLevels[required] > 0
+}
+
 // User 用户模型
 type User struct {
 	ID        uint           `gorm:"primarykey" json:"id"`
@@ -13,12 +38,23 @@ type User struct {
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 
-	Username  string `gorm:"size:50;uniqueIndex" json:"username"`
-	Email     string `gorm:"size:100;uniqueIndex" json:"email"`
-	Password  string `gorm:"size:200" json:"-"` // 密码不返回给前端
-	Nickname  string `gorm:"size:50" json:"nickname"`
-	Avatar    string `gorm:"size:500" json:"avatar"`
-	IsActive  bool   `gorm:"default:true" json:"is_active"`
+	Username string `gorm:"size:50;uniqueIndex" json:"username"`
+	Email    string `gorm:"size:100;uniqueIndex" json:"email"`
+	Password string `gorm:"size:200" json:"-"` // 密码不返回给前端
+	Nickname string `gorm:"size:50" json:"nickname"`
+	Avatar   string `gorm:"size:500" json:"avatar"`
+	IsActive bool   `gorm:"default:true" json:"is_active"`
+	Role     string `gorm:"size:20;default:'user';index" json:"role"`
+
+	// 凭证安全相关
+	FailedLoginCount  int        `gorm:"default:0" json:"-"` // 连续登录失败次数
+	LockedUntil       *time.Time `json:"-"`                  // 账号锁定截止时间
+	PasswordChangedAt *time.Time `json:"-"`                  // 最近一次修改密码的时间
+
+	// 游客相关
+	IsGuest        bool       `gorm:"default:false;index" json:"is_guest"` // 是否为未注册的游客
+	GuestExpiresAt *time.Time `gorm:"index" json:"-"`                      // 游客账号过期时间，过期后由清理任务删除
+	GuestIP        string     `gorm:"size:64;index" json:"-"`              // 创建游客时的客户端IP，同一IP的游客共用消息数上限
 
 	// 关联关系
 	Chats []Chat `gorm:"foreignKey:UserID" json:"chats,omitempty"`
@@ -29,6 +65,11 @@ func (User) TableName() string {
 	return "users"
 }
 
+// IsLocked 判断账号当前是否处于锁定状态
+func (u *User) IsLocked(now time.Time) bool {
+	return u.LockedUntil != nil && now.Before(*u.LockedUntil)
+}
+
 // UserResponse 用户响应数据
 type UserResponse struct {
 	ID        uint      `json:"id"`
@@ -37,6 +78,8 @@ type UserResponse struct {
 	Nickname  string    `json:"nickname"`
 	Avatar    string    `json:"avatar"`
 	IsActive  bool      `json:"is_active"`
+	Role      string    `json:"role"`
+	IsGuest   bool      `json:"is_guest"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
@@ -49,6 +92,66 @@ func (u *User) ToUserResponse() UserResponse {
 		Nickname:  u.Nickname,
 		Avatar:    u.Avatar,
 		IsActive:  u.IsActive,
+		Role:      u.Role,
+		IsGuest:   u.IsGuest,
 		CreatedAt: u.CreatedAt,
 	}
-}
\ No newline at end of file
+}
+
+// GuestSessionResponse 创建游客会话的响应数据
+type GuestSessionResponse struct {
+	User         UserResponse `json:"user"`
+	ExpiresAt    time.Time    `json:"expires_at"`
+	MessageLimit int          `json:"message_limit"` // 游客最多可发送的消息数，0表示不限制
+	GuestToken   string       `json:"-"`             // 写入Cookie的签名令牌，不出现在响应体中
+}
+
+// RegisterRequest 用户注册请求
+type RegisterRequest struct {
+	Username string `json:"username" binding:"required,min=3,max=50"`
+	Email    string `json:"email" binding:"required,email,max=100"`
+	Password string `json:"password" binding:"required"`
+	Nickname string `json:"nickname" binding:"omitempty,max=50"`
+}
+
+// LoginRequest 用户登录请求
+type LoginRequest struct {
+	Username string `json:"username" binding:"required"` // 用户名或邮箱
+	Password string `json:"password" binding:"required"`
+}
+
+// RefreshTokenRequest 刷新令牌请求
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// AuthResponse 认证成功响应数据
+type AuthResponse struct {
+	AccessToken  string       `json:"access_token"`
+	RefreshToken string       `json:"refresh_token"`
+	TokenType    string       `json:"token_type"`
+	ExpiresIn    int64        `json:"expires_in"` // 访问令牌剩余有效秒数
+	User         UserResponse `json:"user"`
+}
+
+// ChangePasswordRequest 修改密码请求
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// ForgotPasswordRequest 申请重置密码请求
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest 重置密码请求
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// UpdateUserRoleRequest 修改用户角色请求
+type UpdateUserRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=user editor admin"`
+}
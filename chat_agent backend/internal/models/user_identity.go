@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// UserIdentity 用户绑定的外部身份（OIDC单点登录），按issuer+subject唯一确定
+type UserIdentity struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID      uint       `gorm:"not null;index" json:"user_id"`
+	Issuer      string     `gorm:"size:255;not null;uniqueIndex:idx_identity_issuer_subject" json:"issuer"`
+	Subject     string     `gorm:"size:255;not null;uniqueIndex:idx_identity_issuer_subject" json:"subject"`
+	Email       string     `gorm:"size:100" json:"email"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+}
+
+// TableName 指定表名
+func (UserIdentity) TableName() string {
+	return "user_identities"
+}
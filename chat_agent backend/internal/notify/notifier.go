@@ -0,0 +1,32 @@
+package notify
+
+import (
+	"context"
+	"log"
+)
+
+// Message 通知消息
+type Message struct {
+	To      string // 接收方（如邮箱地址）
+	Subject string
+	Body    string
+}
+
+// Notifier 通知发送接口，可替换为邮件、短信等实现
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// LogNotifier 只把通知写入日志的默认实现
+type LogNotifier struct{}
+
+// NewLogNotifier 创建新的日志通知器
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{}
+}
+
+// Send 将通知内容写入日志
+func (n *LogNotifier) Send(ctx context.Context, msg Message) error {
+	log.Printf("[notify] to=%s subject=%s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"chat_agent/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// APIKeyRepository API密钥仓库接口
+type APIKeyRepository interface {
+	// 创建API密钥
+	Create(ctx context.Context, key *models.APIKey) error
+
+	// 根据ID获取API密钥
+	GetByID(ctx context.Context, id uint) (*models.APIKey, error)
+
+	// 根据密钥哈希获取API密钥
+	GetByHash(ctx context.Context, keyHash string) (*models.APIKey, error)
+
+	// 获取用户的API密钥列表
+	GetUserKeys(ctx context.Context, userID uint) ([]models.APIKey, error)
+
+	// 吊销API密钥
+	Revoke(ctx context.Context, id uint) error
+
+	// 更新最后使用时间
+	UpdateLastUsed(ctx context.Context, id uint, usedAt time.Time) error
+}
+
+// APIKeyRepositoryImpl API密钥仓库实现
+type APIKeyRepositoryImpl struct {
+	db *gorm.DB
+}
+
+// NewAPIKeyRepository 创建新的API密钥仓库
+func NewAPIKeyRepository(db *gorm.DB) APIKeyRepository {
+	return &APIKeyRepositoryImpl{db: db}
+}
+
+// Create 创建API密钥
+func (r *APIKeyRepositoryImpl) Create(ctx context.Context, key *models.APIKey) error {
+	return r.db.WithContext(ctx).Create(key).Error
+}
+
+// GetByID 根据ID获取API密钥
+func (r *APIKeyRepositoryImpl) GetByID(ctx context.Context, id uint) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.db.WithContext(ctx).First(&key, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// GetByHash 根据密钥哈希获取API密钥
+func (r *APIKeyRepositoryImpl) GetByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.db.WithContext(ctx).Where("key_hash = ?", keyHash).First(&key).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// GetUserKeys 获取用户的API密钥列表
+func (r *APIKeyRepositoryImpl) GetUserKeys(ctx context.Context, userID uint) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&keys).Error
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Revoke 吊销API密钥
+func (r *APIKeyRepositoryImpl) Revoke(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Model(&models.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error
+}
+
+// UpdateLastUsed 更新最后使用时间
+func (r *APIKeyRepositoryImpl) UpdateLastUsed(ctx context.Context, id uint, usedAt time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.APIKey{}).
+		Where("id = ?", id).
+		UpdateColumn("last_used_at", usedAt).Error
+}
@@ -34,6 +34,9 @@ type ChatRepository interface {
 
 	// 更新消息计数
 	IncrementMessageCount(ctx context.Context, chatID uint) error
+
+	// 更新会话的滚动摘要和已摘要的最后一条消息
+	UpdateSummary(ctx context.Context, chatID uint, summary string, untilMessageID uint) error
 }
 
 // ChatRepositoryImpl 聊天仓库实现
@@ -119,6 +122,11 @@ func (r *ChatRepositoryImpl) Delete(ctx context.Context, id uint) error {
 			return err
 		}
 
+		// 删除会话的记忆
+		if err := tx.Where("chat_id = ?", id).Delete(&models.Memory{}).Error; err != nil {
+			return err
+		}
+
 		// 再删除聊天会话
 		if err := tx.Delete(&models.Chat{}, id).Error; err != nil {
 			return err
@@ -141,6 +149,14 @@ func (r *ChatRepositoryImpl) IncrementMessageCount(ctx context.Context, chatID u
 	return r.db.WithContext(ctx).Model(&models.Chat{}).Where("id = ?", chatID).UpdateColumn("message_count", gorm.Expr("message_count + ?", 1)).Error
 }
 
+// UpdateSummary 更新会话的滚动摘要和已摘要的最后一条消息
+func (r *ChatRepositoryImpl) UpdateSummary(ctx context.Context, chatID uint, summary string, untilMessageID uint) error {
+	return r.db.WithContext(ctx).Model(&models.Chat{}).Where("id = ?", chatID).UpdateColumns(map[string]interface{}{
+		"summary":          summary,
+		"summary_until_id": untilMessageID,
+	}).Error
+}
+
 // MessageRepository 消息仓库接口
 type MessageRepository interface {
 	// 创建消息
@@ -161,8 +177,20 @@ type MessageRepository interface {
 	// 删除消息
 	Delete(ctx context.Context, id uint) error
 
-	// 获取会话的最后几条消息
+	// 获取会话的最后几条消息，按时间顺序返回
 	GetLastMessages(ctx context.Context, chatID uint, limit int) ([]models.Message, error)
+
+	// 统计会话中ID大于afterID的消息数
+	CountMessagesAfter(ctx context.Context, chatID, afterID uint) (int64, error)
+
+	// 按时间顺序获取会话中ID大于afterID的消息
+	GetMessagesAfter(ctx context.Context, chatID, afterID uint, limit int) ([]models.Message, error)
+
+	// 统计用户发送过的消息数（包含已删除的消息）
+	CountUserMessages(ctx context.Context, userID uint) (int64, error)
+
+	// 统计从同一IP创建的游客发送过的消息数（包含已删除的消息）
+	CountGuestMessagesByIP(ctx context.Context, ip string) (int64, error)
 }
 
 // MessageRepositoryImpl 消息仓库实现
@@ -237,12 +265,12 @@ func (r *MessageRepositoryImpl) Delete(ctx context.Context, id uint) error {
 	return r.db.WithContext(ctx).Delete(&models.Message{}, id).Error
 }
 
-// GetLastMessages 获取会话的最后几条消息
+// GetLastMessages 获取会话的最后几条消息，按时间顺序返回
 func (r *MessageRepositoryImpl) GetLastMessages(ctx context.Context, chatID uint, limit int) ([]models.Message, error) {
 	var messages []models.Message
 	err := r.db.WithContext(ctx).
 		Where("chat_id = ?", chatID).
-		Order("created_at DESC").
+		Order("created_at DESC, id DESC").
 		Limit(limit).
 		Find(&messages).Error
 
@@ -250,5 +278,55 @@ func (r *MessageRepositoryImpl) GetLastMessages(ctx context.Context, chatID uint
 		return nil, err
 	}
 
+	// 查询时从最新的消息开始取，返回前恢复为时间顺序
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
 	return messages, nil
-}
\ No newline at end of file
+}
+
+// CountMessagesAfter 统计会话中ID大于afterID的消息数
+func (r *MessageRepositoryImpl) CountMessagesAfter(ctx context.Context, chatID, afterID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Message{}).
+		Where("chat_id = ? AND id > ?", chatID, afterID).
+		Count(&count).Error
+	return count, err
+}
+
+// GetMessagesAfter 按时间顺序获取会话中ID大于afterID的消息
+func (r *MessageRepositoryImpl) GetMessagesAfter(ctx context.Context, chatID, afterID uint, limit int) ([]models.Message, error) {
+	var messages []models.Message
+	err := r.db.WithContext(ctx).
+		Where("chat_id = ? AND id > ?", chatID, afterID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&messages).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// CountUserMessages 统计用户发送过的消息数（包含已删除的消息）
+func (r *MessageRepositoryImpl) CountUserMessages(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Unscoped().
+		Model(&models.Message{}).
+		Where("sender_type = ? AND sender_id = ?", models.SenderTypeUser, userID).
+		Count(&count).Error
+	return count, err
+}
+
+// CountGuestMessagesByIP 统计从同一IP创建的游客发送过的消息数（包含已删除的消息）
+func (r *MessageRepositoryImpl) CountGuestMessagesByIP(ctx context.Context, ip string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Unscoped().
+		Model(&models.Message{}).
+		Joins("JOIN users ON users.id = messages.sender_id").
+		Where("messages.sender_type = ? AND users.is_guest = ? AND users.guest_ip = ?", models.SenderTypeUser, true, ip).
+		Count(&count).Error
+	return count, err
+}
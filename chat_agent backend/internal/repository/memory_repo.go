@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"chat_agent/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// MemoryRepository 记忆仓库接口
+type MemoryRepository interface {
+	// 保存记忆
+	Create(ctx context.Context, memory *models.Memory) error
+
+	// 根据ID获取记忆
+	GetByID(ctx context.Context, id uint) (*models.Memory, error)
+
+	// 查找会话中内容完全相同的记忆，不存在时返回nil
+	FindByContent(ctx context.Context, chatID uint, memoryType, content string) (*models.Memory, error)
+
+	// 查找会话中指定键的记忆，不存在时返回nil
+	FindByKey(ctx context.Context, chatID uint, memoryType, key string) (*models.Memory, error)
+
+	// 更新记忆
+	Update(ctx context.Context, memory *models.Memory) error
+
+	// 获取会话最近的记忆（最新的在前）
+	GetRecent(ctx context.Context, chatID uint, memoryType string, limit int) ([]models.Memory, error)
+
+	// 获取会话有效权重最高的记忆（按排序分数）
+	GetTopWeighted(ctx context.Context, chatID uint, memoryType string, limit int) ([]models.Memory, error)
+
+	// 搜索会话中包含关键词的记忆，按排序分数降序
+	Search(ctx context.Context, chatID uint, query string, limit int) ([]models.Memory, error)
+
+	// 更新记忆权重和排序分数
+	UpdateWeight(ctx context.Context, id uint, weight, rankScore float64) error
+
+	// 删除记忆
+	Delete(ctx context.Context, id uint) error
+
+	// 删除排序分数低于below的某一类型记忆，返回删除的数量
+	DeleteBelowRankScore(ctx context.Context, memoryType string, below float64) (int64, error)
+
+	// 删除未置顶且在before之前最后更新的某一类型记忆，返回删除的数量
+	DeleteUpdatedBefore(ctx context.Context, memoryType string, before time.Time) (int64, error)
+
+	// 删除会话中某一类型的记忆，memoryType为空时删除全部，返回删除的数量
+	DeleteByChat(ctx context.Context, chatID uint, memoryType string) (int64, error)
+
+	// 删除会话中内容完全相同且未置顶的记忆，返回删除的数量
+	DeleteByContent(ctx context.Context, chatID uint, content string) (int64, error)
+
+	// 只保留会话中最近（或有效权重最高）的keep条记忆，删除其余的
+	Trim(ctx context.Context, chatID uint, memoryType string, keep int, byWeight bool) error
+}
+
+// MemoryRepositoryImpl 记忆仓库实现
+type MemoryRepositoryImpl struct {
+	db *gorm.DB
+}
+
+// NewMemoryRepository 创建新的记忆仓库
+func NewMemoryRepository(db *gorm.DB) MemoryRepository {
+	return &MemoryRepositoryImpl{db: db}
+}
+
+// Create 保存记忆
+func (r *MemoryRepositoryImpl) Create(ctx context.Context, memory *models.Memory) error {
+	return r.db.WithContext(ctx).Create(memory).Error
+}
+
+// GetByID 根据ID获取记忆
+func (r *MemoryRepositoryImpl) GetByID(ctx context.Context, id uint) (*models.Memory, error) {
+	var memory models.Memory
+	err := r.db.WithContext(ctx).First(&memory, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &memory, nil
+}
+
+// FindByContent 查找会话中内容完全相同的记忆
+func (r *MemoryRepositoryImpl) FindByContent(ctx context.Context, chatID uint, memoryType, content string) (*models.Memory, error) {
+	var memories []models.Memory
+	err := r.db.WithContext(ctx).
+		Where("chat_id = ? AND type = ? AND content = ?", chatID, memoryType, content).
+		Limit(1).
+		Find(&memories).Error
+	if err != nil || len(memories) == 0 {
+		return nil, err
+	}
+	return &memories[0], nil
+}
+
+// FindByKey 查找会话中指定键的记忆
+func (r *MemoryRepositoryImpl) FindByKey(ctx context.Context, chatID uint, memoryType, key string) (*models.Memory, error) {
+	var memories []models.Memory
+	err := r.db.WithContext(ctx).
+		Where("chat_id = ? AND type = ? AND fact_key = ?", chatID, memoryType, key).
+		Limit(1).
+		Find(&memories).Error
+	if err != nil || len(memories) == 0 {
+		return nil, err
+	}
+	return &memories[0], nil
+}
+
+// Update 更新记忆
+func (r *MemoryRepositoryImpl) Update(ctx context.Context, memory *models.Memory) error {
+	return r.db.WithContext(ctx).Save(memory).Error
+}
+
+// GetRecent 获取会话最近的记忆
+func (r *MemoryRepositoryImpl) GetRecent(ctx context.Context, chatID uint, memoryType string, limit int) ([]models.Memory, error) {
+	var memories []models.Memory
+	err := r.db.WithContext(ctx).
+		Where("chat_id = ? AND type = ?", chatID, memoryType).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&memories).Error
+	return memories, err
+}
+
+// GetTopWeighted 获取会话有效权重最高的记忆
+func (r *MemoryRepositoryImpl) GetTopWeighted(ctx context.Context, chatID uint, memoryType string, limit int) ([]models.Memory, error) {
+	var memories []models.Memory
+	err := r.db.WithContext(ctx).
+		Where("chat_id = ? AND type = ?", chatID, memoryType).
+		Order("rank_score DESC, id DESC").
+		Limit(limit).
+		Find(&memories).Error
+	return memories, err
+}
+
+// Search 搜索会话中包含关键词的记忆
+func (r *MemoryRepositoryImpl) Search(ctx context.Context, chatID uint, query string, limit int) ([]models.Memory, error) {
+	// 转义LIKE通配符，按字面匹配
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(query)
+
+	var memories []models.Memory
+	err := r.db.WithContext(ctx).
+		Where("chat_id = ? AND content LIKE ?", chatID, "%"+escaped+"%").
+		Order("rank_score DESC, id DESC").
+		Limit(limit).
+		Find(&memories).Error
+	return memories, err
+}
+
+// UpdateWeight 更新记忆权重和排序分数
+func (r *MemoryRepositoryImpl) UpdateWeight(ctx context.Context, id uint, weight, rankScore float64) error {
+	result := r.db.WithContext(ctx).Model(&models.Memory{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"weight":     weight,
+		"rank_score": rankScore,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Delete 删除记忆
+func (r *MemoryRepositoryImpl) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&models.Memory{}, id).Error
+}
+
+// DeleteBelowRankScore 删除排序分数低于below的某一类型记忆
+func (r *MemoryRepositoryImpl) DeleteBelowRankScore(ctx context.Context, memoryType string, below float64) (int64, error) {
+	result := r.db.WithContext(ctx).Where("type = ? AND rank_score < ?", memoryType, below).Delete(&models.Memory{})
+	return result.RowsAffected, result.Error
+}
+
+// DeleteUpdatedBefore 删除未置顶且在before之前最后更新的某一类型记忆
+func (r *MemoryRepositoryImpl) DeleteUpdatedBefore(ctx context.Context, memoryType string, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("type = ? AND pinned = ? AND updated_at < ?", memoryType, false, before).Delete(&models.Memory{})
+	return result.RowsAffected, result.Error
+}
+
+// DeleteByChat 删除会话中某一类型的记忆
+func (r *MemoryRepositoryImpl) DeleteByChat(ctx context.Context, chatID uint, memoryType string) (int64, error) {
+	query := r.db.WithContext(ctx).Where("chat_id = ?", chatID)
+	if memoryType != "" {
+		query = query.Where("type = ?", memoryType)
+	}
+	result := query.Delete(&models.Memory{})
+	return result.RowsAffected, result.Error
+}
+
+// DeleteByContent 删除会话中内容完全相同且未置顶的记忆
+func (r *MemoryRepositoryImpl) DeleteByContent(ctx context.Context, chatID uint, content string) (int64, error) {
+	result := r.db.WithContext(ctx).Where("chat_id = ? AND content = ? AND pinned = ?", chatID, content, false).Delete(&models.Memory{})
+	return result.RowsAffected, result.Error
+}
+
+// Trim 只保留会话中最近（或权重最高）的keep条记忆
+func (r *MemoryRepositoryImpl) Trim(ctx context.Context, chatID uint, memoryType string, keep int, byWeight bool) error {
+	order := "created_at DESC, id DESC"
+	if byWeight {
+		order = "rank_score DESC, id DESC"
+	}
+
+	var keepIDs []uint
+	if err := r.db.WithContext(ctx).Model(&models.Memory{}).
+		Where("chat_id = ? AND type = ?", chatID, memoryType).
+		Order(order).
+		Limit(keep).
+		Pluck("id", &keepIDs).Error; err != nil {
+		return err
+	}
+
+	query := r.db.WithContext(ctx).Where("chat_id = ? AND type = ?", chatID, memoryType)
+	if len(keepIDs) > 0 {
+		query = query.Where("id NOT IN ?", keepIDs)
+	}
+	return query.Delete(&models.Memory{}).Error
+}
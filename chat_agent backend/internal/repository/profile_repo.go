@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+
+	"chat_agent/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ProfileRepository 用户画像仓库接口
+type ProfileRepository interface {
+	// 写入画像事实，用户已有相同键的事实时替换
+	UpsertFact(ctx context.Context, fact *models.ProfileFact) error
+
+	// 获取用户的画像事实，按权重降序，limit<=0时返回全部
+	ListFacts(ctx context.Context, userID uint, limit int) ([]models.ProfileFact, error)
+
+	// 删除用户的一条画像事实，返回删除的数量
+	DeleteFact(ctx context.Context, userID, factID uint) (int64, error)
+
+	// 删除用户的全部画像事实
+	DeleteFacts(ctx context.Context, userID uint) error
+
+	// 判断用户是否选择不与该明星共享画像
+	IsOptedOut(ctx context.Context, userID, starID uint) (bool, error)
+
+	// 设置用户是否选择不与该明星共享画像
+	SetOptOut(ctx context.Context, userID, starID uint, optOut bool) error
+
+	// 获取用户选择不共享画像的明星ID
+	ListOptOuts(ctx context.Context, userID uint) ([]uint, error)
+}
+
+// ProfileRepositoryImpl 用户画像仓库实现
+type ProfileRepositoryImpl struct {
+	db *gorm.DB
+}
+
+// NewProfileRepository 创建新的用户画像仓库
+func NewProfileRepository(db *gorm.DB) ProfileRepository {
+	return &ProfileRepositoryImpl{db: db}
+}
+
+// UpsertFact 写入画像事实
+func (r *ProfileRepositoryImpl) UpsertFact(ctx context.Context, fact *models.ProfileFact) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "fact_key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"content", "weight", "source_star_id", "updated_at"}),
+	}).Create(fact).Error
+}
+
+// ListFacts 获取用户的画像事实
+func (r *ProfileRepositoryImpl) ListFacts(ctx context.Context, userID uint, limit int) ([]models.ProfileFact, error) {
+	var facts []models.ProfileFact
+	query := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("weight DESC, updated_at DESC, id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	err := query.Find(&facts).Error
+	return facts, err
+}
+
+// DeleteFact 删除用户的一条画像事实
+func (r *ProfileRepositoryImpl) DeleteFact(ctx context.Context, userID, factID uint) (int64, error) {
+	result := r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.ProfileFact{}, factID)
+	return result.RowsAffected, result.Error
+}
+
+// DeleteFacts 删除用户的全部画像事实
+func (r *ProfileRepositoryImpl) DeleteFacts(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.ProfileFact{}).Error
+}
+
+// IsOptedOut 判断用户是否选择不与该明星共享画像
+func (r *ProfileRepositoryImpl) IsOptedOut(ctx context.Context, userID, starID uint) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.ProfileOptOut{}).
+		Where("user_id = ? AND star_id = ?", userID, starID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// SetOptOut 设置用户是否选择不与该明星共享画像
+func (r *ProfileRepositoryImpl) SetOptOut(ctx context.Context, userID, starID uint, optOut bool) error {
+	if !optOut {
+		return r.db.WithContext(ctx).
+			Where("user_id = ? AND star_id = ?", userID, starID).
+			Delete(&models.ProfileOptOut{}).Error
+	}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.ProfileOptOut{UserID: userID, StarID: starID}).Error
+}
+
+// ListOptOuts 获取用户选择不共享画像的明星ID
+func (r *ProfileRepositoryImpl) ListOptOuts(ctx context.Context, userID uint) ([]uint, error) {
+	starIDs := []uint{}
+	err := r.db.WithContext(ctx).Model(&models.ProfileOptOut{}).
+		Where("user_id = ?", userID).
+		Order("star_id").
+		Pluck("star_id", &starIDs).Error
+	return starIDs, err
+}
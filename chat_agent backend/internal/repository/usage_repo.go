@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"chat_agent/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// 用量汇总维度
+const (
+	UsageGroupByModel = "model"
+	UsageGroupByStar  = "star_id"
+	UsageGroupByChat  = "chat_id"
+)
+
+// UsageRepository 用量仓库接口
+type UsageRepository interface {
+	// 记录一次模型调用的用量
+	Create(ctx context.Context, record *models.UsageRecord) error
+
+	// 统计用户自某一时间以来消耗的token总数
+	SumUserTokens(ctx context.Context, userID uint, since time.Time) (int64, error)
+
+	// 按维度汇总用户自某一时间以来的用量
+	Rollup(ctx context.Context, userID uint, since time.Time, groupBy string) ([]models.UsageRollup, error)
+}
+
+// UsageRepositoryImpl 用量仓库实现
+type UsageRepositoryImpl struct {
+	db *gorm.DB
+}
+
+// NewUsageRepository 创建新的用量仓库
+func NewUsageRepository(db *gorm.DB) UsageRepository {
+	return &UsageRepositoryImpl{db: db}
+}
+
+// Create 记录一次模型调用的用量
+func (r *UsageRepositoryImpl) Create(ctx context.Context, record *models.UsageRecord) error {
+	return r.db.WithContext(ctx).Create(record).Error
+}
+
+// SumUserTokens 统计用户自某一时间以来消耗的token总数
+func (r *UsageRepositoryImpl) SumUserTokens(ctx context.Context, userID uint, since time.Time) (int64, error) {
+	var total int64
+	err := r.db.WithContext(ctx).Model(&models.UsageRecord{}).
+		Where("user_id = ? AND created_at >= ?", userID, since).
+		Select("COALESCE(SUM(total_tokens), 0)").
+		Scan(&total).Error
+	if err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// Rollup 按维度汇总用户自某一时间以来的用量
+func (r *UsageRepositoryImpl) Rollup(ctx context.Context, userID uint, since time.Time, groupBy string) ([]models.UsageRollup, error) {
+	switch groupBy {
+	case UsageGroupByModel, UsageGroupByStar, UsageGroupByChat:
+	default:
+		return nil, fmt.Errorf("unsupported usage group: %s", groupBy)
+	}
+
+	var rollups []models.UsageRollup
+	err := r.db.WithContext(ctx).Model(&models.UsageRecord{}).
+		Select(fmt.Sprintf("CAST(%s AS CHAR) AS `key`, SUM(prompt_tokens) AS prompt_tokens, SUM(completion_tokens) AS completion_tokens, SUM(total_tokens) AS total_tokens, COUNT(*) AS requests, SUM(estimated_cost) AS estimated_cost", groupBy)).
+		Where("user_id = ? AND created_at >= ?", userID, since).
+		Group(groupBy).
+		Order("total_tokens DESC").
+		Scan(&rollups).Error
+	if err != nil {
+		return nil, err
+	}
+	return rollups, nil
+}
@@ -0,0 +1,362 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"chat_agent/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// UserRepository 用户仓库接口
+type UserRepository interface {
+	// 创建用户
+	Create(ctx context.Context, user *models.User) error
+
+	// 根据ID获取用户
+	GetByID(ctx context.Context, id uint) (*models.User, error)
+
+	// 根据用户名获取用户
+	GetByUsername(ctx context.Context, username string) (*models.User, error)
+
+	// 根据邮箱获取用户
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+
+	// 更新用户
+	Update(ctx context.Context, user *models.User) error
+
+	// 获取已过期的游客账号
+	ListExpiredGuests(ctx context.Context, before time.Time, limit int) ([]models.User, error)
+
+	// 将游客的会话、消息和用量记录转移给正式用户，并删除游客账号
+	TransferGuestData(ctx context.Context, guestID, userID uint) error
+
+	// 彻底删除游客账号及其全部数据，返回被删除的会话ID
+	DeleteGuest(ctx context.Context, guestID uint) ([]uint, error)
+}
+
+// UserRepositoryImpl 用户仓库实现
+type UserRepositoryImpl struct {
+	db *gorm.DB
+}
+
+// NewUserRepository 创建新的用户仓库
+func NewUserRepository(db *gorm.DB) UserRepository {
+	return &UserRepositoryImpl{db: db}
+}
+
+// Create 创建用户
+func (r *UserRepositoryImpl) Create(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Create(user).Error
+}
+
+// GetByID 根据ID获取用户
+func (r *UserRepositoryImpl) GetByID(ctx context.Context, id uint) (*models.User, error) {
+	var user models.User
+	err := r.db.WithContext(ctx).First(&user, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetByUsername 根据用户名获取用户
+func (r *UserRepositoryImpl) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	var user models.User
+	err := r.db.WithContext(ctx).Where("username = ?", username).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetByEmail 根据邮箱获取用户
+func (r *UserRepositoryImpl) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Update 更新用户
+func (r *UserRepositoryImpl) Update(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Save(user).Error
+}
+
+// ListExpiredGuests 获取已过期的游客账号
+func (r *UserRepositoryImpl) ListExpiredGuests(ctx context.Context, before time.Time, limit int) ([]models.User, error) {
+	var users []models.User
+	err := r.db.WithContext(ctx).
+		Where("is_guest = ? AND guest_expires_at < ?", true, before).
+		Order("guest_expires_at ASC").
+		Limit(limit).
+		Find(&users).Error
+	return users, err
+}
+
+// TransferGuestData 将游客的会话、消息、用量记录和画像转移给正式用户，并删除游客账号
+func (r *UserRepositoryImpl) TransferGuestData(ctx context.Context, guestID, userID uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 转移会话（记忆按会话ID存储，随会话一起转移）
+		if err := tx.Model(&models.Chat{}).Where("user_id = ?", guestID).
+			Update("user_id", userID).Error; err != nil {
+			return err
+		}
+
+		// 转移游客发送的消息
+		if err := tx.Model(&models.Message{}).
+			Where("sender_type = ? AND sender_id = ?", models.SenderTypeUser, guestID).
+			Update("sender_id", userID).Error; err != nil {
+			return err
+		}
+
+		// 转移用量记录，保证额度统计连续
+		if err := tx.Model(&models.UsageRecord{}).Where("user_id = ?", guestID).
+			Update("user_id", userID).Error; err != nil {
+			return err
+		}
+
+		// 转移画像事实和共享设置，正式用户已有的同键事实和设置优先
+		var keys []string
+		if err := tx.Model(&models.ProfileFact{}).Where("user_id = ?", userID).
+			Pluck("fact_key", &keys).Error; err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			if err := tx.Where("user_id = ? AND fact_key IN ?", guestID, keys).
+				Delete(&models.ProfileFact{}).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&models.ProfileFact{}).Where("user_id = ?", guestID).
+			Update("user_id", userID).Error; err != nil {
+			return err
+		}
+
+		var starIDs []uint
+		if err := tx.Model(&models.ProfileOptOut{}).Where("user_id = ?", userID).
+			Pluck("star_id", &starIDs).Error; err != nil {
+			return err
+		}
+		if len(starIDs) > 0 {
+			if err := tx.Where("user_id = ? AND star_id IN ?", guestID, starIDs).
+				Delete(&models.ProfileOptOut{}).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&models.ProfileOptOut{}).Where("user_id = ?", guestID).
+			Update("user_id", userID).Error; err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Delete(&models.User{}, guestID).Error
+	})
+}
+
+// DeleteGuest 彻底删除游客账号及其全部数据，返回被删除的会话ID
+func (r *UserRepositoryImpl) DeleteGuest(ctx context.Context, guestID uint) ([]uint, error) {
+	var chatIDs []uint
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&models.Chat{}).Where("user_id = ?", guestID).
+			Pluck("id", &chatIDs).Error; err != nil {
+			return err
+		}
+
+		if len(chatIDs) > 0 {
+			if err := tx.Unscoped().Where("chat_id IN ?", chatIDs).Delete(&models.Message{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Where("chat_id IN ?", chatIDs).Delete(&models.Memory{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Where("id IN ?", chatIDs).Delete(&models.Chat{}).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("user_id = ?", guestID).Delete(&models.UsageRecord{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", guestID).Delete(&models.ProfileFact{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", guestID).Delete(&models.ProfileOptOut{}).Error; err != nil {
+			return err
+		}
+
+		// 仅删除仍为游客的账号，避免误删清理期间刚升级的用户
+		return tx.Unscoped().Where("is_guest = ?", true).Delete(&models.User{}, guestID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return chatIDs, nil
+}
+
+// RefreshTokenRepository 刷新令牌仓库接口
+type RefreshTokenRepository interface {
+	// 保存刷新令牌
+	Create(ctx context.Context, token *models.RefreshToken) error
+
+	// 根据令牌哈希获取刷新令牌
+	GetByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+
+	// 吊销刷新令牌，令牌已被吊销时返回gorm.ErrRecordNotFound
+	Revoke(ctx context.Context, id uint) error
+
+	// 吊销用户的所有刷新令牌
+	RevokeAllForUser(ctx context.Context, userID uint) error
+}
+
+// RefreshTokenRepositoryImpl 刷新令牌仓库实现
+type RefreshTokenRepositoryImpl struct {
+	db *gorm.DB
+}
+
+// NewRefreshTokenRepository 创建新的刷新令牌仓库
+func NewRefreshTokenRepository(db *gorm.DB) RefreshTokenRepository {
+	return &RefreshTokenRepositoryImpl{db: db}
+}
+
+// Create 保存刷新令牌
+func (r *RefreshTokenRepositoryImpl) Create(ctx context.Context, token *models.RefreshToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+// GetByHash 根据令牌哈希获取刷新令牌
+func (r *RefreshTokenRepositoryImpl) GetByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Revoke 吊销刷新令牌，令牌已被吊销（如被并发的请求抢先使用）时返回gorm.ErrRecordNotFound
+func (r *RefreshTokenRepositoryImpl) Revoke(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Model(&models.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// RevokeAllForUser 吊销用户的所有刷新令牌
+func (r *RefreshTokenRepositoryImpl) RevokeAllForUser(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// PasswordResetTokenRepository 密码重置令牌仓库接口
+type PasswordResetTokenRepository interface {
+	// 保存重置令牌
+	Create(ctx context.Context, token *models.PasswordResetToken) error
+
+	// 根据令牌哈希获取重置令牌
+	GetByHash(ctx context.Context, tokenHash string) (*models.PasswordResetToken, error)
+
+	// 将未使用且未过期的重置令牌标记为已使用，令牌已被使用或已过期时返回gorm.ErrRecordNotFound
+	Consume(ctx context.Context, tokenHash string, now time.Time) error
+
+	// 将用户所有未使用的重置令牌标记为已使用
+	InvalidateAllForUser(ctx context.Context, userID uint) error
+}
+
+// PasswordResetTokenRepositoryImpl 密码重置令牌仓库实现
+type PasswordResetTokenRepositoryImpl struct {
+	db *gorm.DB
+}
+
+// NewPasswordResetTokenRepository 创建新的密码重置令牌仓库
+func NewPasswordResetTokenRepository(db *gorm.DB) PasswordResetTokenRepository {
+	return &PasswordResetTokenRepositoryImpl{db: db}
+}
+
+// Create 保存重置令牌
+func (r *PasswordResetTokenRepositoryImpl) Create(ctx context.Context, token *models.PasswordResetToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+// GetByHash 根据令牌哈希获取重置令牌
+func (r *PasswordResetTokenRepositoryImpl) GetByHash(ctx context.Context, tokenHash string) (*models.PasswordResetToken, error) {
+	var token models.PasswordResetToken
+	err := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Consume 将未使用且未过期的重置令牌标记为已使用，令牌已被使用（如被并发的请求抢先使用）或已过期时返回gorm.ErrRecordNotFound
+func (r *PasswordResetTokenRepositoryImpl) Consume(ctx context.Context, tokenHash string, now time.Time) error {
+	result := r.db.WithContext(ctx).Model(&models.PasswordResetToken{}).
+		Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", tokenHash, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// InvalidateAllForUser 将用户所有未使用的重置令牌标记为已使用
+func (r *PasswordResetTokenRepositoryImpl) InvalidateAllForUser(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now()).Error
+}
+
+// UserIdentityRepository 外部身份仓库接口
+type UserIdentityRepository interface {
+	// 绑定外部身份
+	Create(ctx context.Context, identity *models.UserIdentity) error
+
+	// 根据issuer和subject获取外部身份
+	GetByIssuerSubject(ctx context.Context, issuer, subject string) (*models.UserIdentity, error)
+
+	// 更新外部身份
+	Update(ctx context.Context, identity *models.UserIdentity) error
+}
+
+// UserIdentityRepositoryImpl 外部身份仓库实现
+type UserIdentityRepositoryImpl struct {
+	db *gorm.DB
+}
+
+// NewUserIdentityRepository 创建新的外部身份仓库
+func NewUserIdentityRepository(db *gorm.DB) UserIdentityRepository {
+	return &UserIdentityRepositoryImpl{db: db}
+}
+
+// Create 绑定外部身份
+func (r *UserIdentityRepositoryImpl) Create(ctx context.Context, identity *models.UserIdentity) error {
+	return r.db.WithContext(ctx).Create(identity).Error
+}
+
+// GetByIssuerSubject 根据issuer和subject获取外部身份
+func (r *UserIdentityRepositoryImpl) GetByIssuerSubject(ctx context.Context, issuer, subject string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	err := r.db.WithContext(ctx).Where("issuer = ? AND subject = ?", issuer, subject).First(&identity).Error
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// Update 更新外部身份
+func (r *UserIdentityRepositoryImpl) Update(ctx context.Context, identity *models.UserIdentity) error {
+	return r.db.WithContext(ctx).Save(identity).Error
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"chat_agent/internal/auth"
+	"chat_agent/internal/models"
+	"chat_agent/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// apiKeyPrefix API密钥的固定前缀，便于在日志和代码仓库中识别泄漏的密钥
+const apiKeyPrefix = "ca_"
+
+// lastUsedUpdateInterval 最后使用时间的最小更新间隔，避免每次请求都写数据库
+const lastUsedUpdateInterval = time.Minute
+
+// APIKeyService API密钥服务接口
+type APIKeyService interface {
+	// 创建API密钥，返回的明文密钥只出现这一次
+	CreateKey(ctx context.Context, userID uint, req *models.CreateAPIKeyRequest) (*models.CreateAPIKeyResponse, error)
+
+	// 获取用户的API密钥列表
+	ListKeys(ctx context.Context, userID uint) ([]models.APIKeyResponse, error)
+
+	// 吊销API密钥
+	RevokeKey(ctx context.Context, userID, keyID uint) error
+
+	// 校验API密钥并解析出对应的用户和权限范围
+	AuthenticateAPIKey(ctx context.Context, rawKey string) (*auth.Principal, error)
+}
+
+// APIKeyServiceImpl API密钥服务实现
+type APIKeyServiceImpl struct {
+	apiKeyRepo repository.APIKeyRepository
+	userRepo   repository.UserRepository
+}
+
+// NewAPIKeyService 创建新的API密钥服务
+func NewAPIKeyService(apiKeyRepo repository.APIKeyRepository, userRepo repository.UserRepository) APIKeyService {
+	return &APIKeyServiceImpl{
+		apiKeyRepo: apiKeyRepo,
+		userRepo:   userRepo,
+	}
+}
+
+// CreateKey 创建API密钥
+func (s *APIKeyServiceImpl) CreateKey(ctx context.Context, userID uint, req *models.CreateAPIKeyRequest) (*models.CreateAPIKeyResponse, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("用户不存在")
+		}
+		return nil, err
+	}
+
+	// 校验权限范围，用户只能申请自己角色允许的权限
+	scopes := make([]string, 0, len(req.Scopes))
+	seen := make(map[string]bool)
+	for _, scope := range req.Scopes {
+		scope = strings.TrimSpace(scope)
+		if !models.IsValidScope(scope) {
+			return nil, fmt.Errorf("无效的权限范围: %s", scope)
+		}
+		if !models.RoleAtLeast(user.Role, models.ScopeRequiredRole(scope)) {
+			return nil, fmt.Errorf("当前角色无权申请权限范围: %s", scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+
+	token, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	rawKey := apiKeyPrefix + token
+
+	key := &models.APIKey{
+		UserID:  userID,
+		Name:    req.Name,
+		Prefix:  rawKey[:len(apiKeyPrefix)+8],
+		KeyHash: auth.HashToken(rawKey),
+		Scopes:  strings.Join(scopes, ","),
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		key.ExpiresAt = &expiresAt
+	}
+
+	if err := s.apiKeyRepo.Create(ctx, key); err != nil {
+		return nil, err
+	}
+
+	return &models.CreateAPIKeyResponse{
+		Key:    rawKey,
+		APIKey: key.ToAPIKeyResponse(),
+	}, nil
+}
+
+// ListKeys 获取用户的API密钥列表
+func (s *APIKeyServiceImpl) ListKeys(ctx context.Context, userID uint) ([]models.APIKeyResponse, error) {
+	keys, err := s.apiKeyRepo.GetUserKeys(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]models.APIKeyResponse, len(keys))
+	for i, key := range keys {
+		responses[i] = key.ToAPIKeyResponse()
+	}
+
+	return responses, nil
+}
+
+// RevokeKey 吊销API密钥
+func (s *APIKeyServiceImpl) RevokeKey(ctx context.Context, userID, keyID uint) error {
+	key, err := s.apiKeyRepo.GetByID(ctx, keyID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("API密钥不存在")
+		}
+		return err
+	}
+
+	// 验证是否是用户自己的密钥
+	if key.UserID != userID {
+		return errors.New("无权吊销此API密钥")
+	}
+
+	return s.apiKeyRepo.Revoke(ctx, keyID)
+}
+
+// AuthenticateAPIKey 校验API密钥并解析出对应的用户和权限范围
+func (s *APIKeyServiceImpl) AuthenticateAPIKey(ctx context.Context, rawKey string) (*auth.Principal, error) {
+	if !strings.HasPrefix(rawKey, apiKeyPrefix) {
+		return nil, auth.ErrInvalidToken
+	}
+
+	key, err := s.apiKeyRepo.GetByHash(ctx, auth.HashToken(rawKey))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, auth.ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if !key.IsValid(now) {
+		return nil, auth.ErrInvalidToken
+	}
+
+	// 每次都重新读取用户，确保禁用账号或调整角色后立即生效
+	user, err := s.userRepo.GetByID(ctx, key.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, auth.ErrInvalidToken
+		}
+		return nil, err
+	}
+	if !user.IsActive {
+		return nil, auth.ErrInvalidToken
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedUpdateInterval {
+		if err := s.apiKeyRepo.UpdateLastUsed(ctx, key.ID, now); err != nil {
+			// 更新最后使用时间失败不影响本次请求
+			log.Printf("更新API密钥 %d 最后使用时间失败: %v", key.ID, err)
+		}
+	}
+
+	return &auth.Principal{
+		UserID:   user.ID,
+		Role:     user.Role,
+		Method:   auth.MethodAPIKey,
+		Scopes:   key.ScopeList(),
+		APIKeyID: key.ID,
+	}, nil
+}
@@ -0,0 +1,496 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"chat_agent/internal/auth"
+	"chat_agent/internal/models"
+	"chat_agent/internal/notify"
+	"chat_agent/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// AuthService 认证服务接口
+type AuthService interface {
+	// 注册新用户
+	Register(ctx context.Context, req *models.RegisterRequest) (*models.AuthResponse, error)
+
+	// 用户登录
+	Login(ctx context.Context, req *models.LoginRequest) (*models.AuthResponse, error)
+
+	// 使用刷新令牌换取新的令牌对
+	Refresh(ctx context.Context, refreshToken string) (*models.AuthResponse, error)
+
+	// 退出登录（吊销刷新令牌）
+	Logout(ctx context.Context, refreshToken string) error
+
+	// 获取当前用户信息
+	GetCurrentUser(ctx context.Context, userID uint) (*models.UserResponse, error)
+
+	// 修改密码（需要提供旧密码）
+	ChangePassword(ctx context.Context, userID uint, req *models.ChangePasswordRequest) error
+
+	// 申请重置密码，重置令牌通过通知器发送给用户
+	RequestPasswordReset(ctx context.Context, email string) error
+
+	// 使用重置令牌设置新密码
+	ResetPassword(ctx context.Context, req *models.ResetPasswordRequest) error
+
+	// 为已通过其他方式（如单点登录）验证身份的用户签发令牌
+	CreateSession(ctx context.Context, user *models.User) (*models.AuthResponse, error)
+
+	// 按用户的当前状态校验访问令牌对应的登录会话
+	AuthenticateSession(ctx context.Context, userID uint, issuedAt time.Time) (*auth.Principal, error)
+}
+
+// AuthOptions 认证服务的可配置项
+type AuthOptions struct {
+	RefreshTTL       time.Duration // 刷新令牌有效期
+	PasswordResetTTL time.Duration // 密码重置令牌有效期
+	PasswordResetURL string        // 重置密码页面地址
+	MaxLoginAttempts int           // 连续登录失败多少次后锁定账号，0表示不锁定
+	LockoutDuration  time.Duration // 账号锁定时长
+
+	// Now 返回当前时间，为空时使用time.Now，测试中可以替换以控制锁定和令牌过期
+	Now func() time.Time
+}
+
+// AuthServiceImpl 认证服务实现
+type AuthServiceImpl struct {
+	userRepo         repository.UserRepository
+	refreshTokenRepo repository.RefreshTokenRepository
+	resetTokenRepo   repository.PasswordResetTokenRepository
+	tokenManager     *auth.TokenManager
+	notifier         notify.Notifier
+	options          AuthOptions
+}
+
+// NewAuthService 创建新的认证服务
+func NewAuthService(
+	userRepo repository.UserRepository,
+	refreshTokenRepo repository.RefreshTokenRepository,
+	resetTokenRepo repository.PasswordResetTokenRepository,
+	tokenManager *auth.TokenManager,
+	notifier notify.Notifier,
+	options AuthOptions,
+) AuthService {
+	if notifier == nil {
+		notifier = notify.NewLogNotifier()
+	}
+	if options.Now == nil {
+		options.Now = time.Now
+	}
+	return &AuthServiceImpl{
+		userRepo:         userRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		resetTokenRepo:   resetTokenRepo,
+		tokenManager:     tokenManager,
+		notifier:         notifier,
+		options:          options,
+	}
+}
+
+// Register 注册新用户
+func (s *AuthServiceImpl) Register(ctx context.Context, req *models.RegisterRequest) (*models.AuthResponse, error) {
+	username := strings.TrimSpace(req.Username)
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+
+	// 校验密码策略
+	if err := auth.ValidatePassword(req.Password); err != nil {
+		return nil, err
+	}
+
+	// 检查用户名和邮箱是否已被占用
+	if _, err := s.userRepo.GetByUsername(ctx, username); err == nil {
+		return nil, errors.New("用户名已存在")
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if _, err := s.userRepo.GetByEmail(ctx, email); err == nil {
+		return nil, errors.New("邮箱已被注册")
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	hashedPassword, err := auth.HashPassword(req.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	nickname := req.Nickname
+	if nickname == "" {
+		nickname = username
+	}
+
+	now := s.options.Now()
+	user := &models.User{
+		Username:          username,
+		Email:             email,
+		Password:          hashedPassword,
+		Nickname:          nickname,
+		IsActive:          true,
+		Role:              models.RoleUser,
+		PasswordChangedAt: &now,
+	}
+
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(ctx, user)
+}
+
+// Login 用户登录
+func (s *AuthServiceImpl) Login(ctx context.Context, req *models.LoginRequest) (*models.AuthResponse, error) {
+	user, err := s.findUserByLogin(ctx, req.Username)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			auth.CheckDummyPassword(req.Password)
+			return nil, errors.New("用户名或密码错误")
+		}
+		return nil, err
+	}
+
+	// 游客账号没有密码，不能登录
+	if user.IsGuest {
+		auth.CheckDummyPassword(req.Password)
+		return nil, errors.New("用户名或密码错误")
+	}
+
+	now := s.options.Now()
+	if user.IsLocked(now) {
+		minutes := int(user.LockedUntil.Sub(now).Minutes()) + 1
+		return nil, fmt.Errorf("登录失败次数过多，账号已锁定，请%d分钟后重试", minutes)
+	}
+
+	if !auth.CheckPassword(user.Password, req.Password) {
+		if err := s.recordFailedLogin(ctx, user, now); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("用户名或密码错误")
+	}
+
+	if !user.IsActive {
+		return nil, errors.New("账号已被禁用")
+	}
+
+	// 登录成功，清除失败计数
+	if user.FailedLoginCount > 0 || user.LockedUntil != nil {
+		user.FailedLoginCount = 0
+		user.LockedUntil = nil
+		if err := s.userRepo.Update(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.issueTokens(ctx, user)
+}
+
+// Refresh 使用刷新令牌换取新的令牌对（旧的刷新令牌会被吊销）
+func (s *AuthServiceImpl) Refresh(ctx context.Context, refreshToken string) (*models.AuthResponse, error) {
+	stored, err := s.refreshTokenRepo.GetByHash(ctx, auth.HashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("刷新令牌无效")
+		}
+		return nil, err
+	}
+
+	if !stored.IsValid(s.options.Now()) {
+		return nil, errors.New("刷新令牌已失效")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, stored.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("用户不存在")
+		}
+		return nil, err
+	}
+
+	if !user.IsActive {
+		return nil, errors.New("账号已被禁用")
+	}
+
+	// 刷新令牌只能使用一次，并发的请求中只有成功吊销令牌的一个可以换取新令牌
+	if err := s.refreshTokenRepo.Revoke(ctx, stored.ID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("刷新令牌已失效")
+		}
+		return nil, err
+	}
+
+	return s.issueTokens(ctx, user)
+}
+
+// Logout 退出登录（吊销刷新令牌）
+func (s *AuthServiceImpl) Logout(ctx context.Context, refreshToken string) error {
+	stored, err := s.refreshTokenRepo.GetByHash(ctx, auth.HashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 令牌不存在视为已退出
+			return nil
+		}
+		return err
+	}
+
+	if err := s.refreshTokenRepo.Revoke(ctx, stored.ID); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return nil
+}
+
+// GetCurrentUser 获取当前用户信息
+func (s *AuthServiceImpl) GetCurrentUser(ctx context.Context, userID uint) (*models.UserResponse, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("用户不存在")
+		}
+		return nil, err
+	}
+
+	response := user.ToUserResponse()
+	return &response, nil
+}
+
+// ChangePassword 修改密码（需要提供旧密码）
+func (s *AuthServiceImpl) ChangePassword(ctx context.Context, userID uint, req *models.ChangePasswordRequest) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("用户不存在")
+		}
+		return err
+	}
+
+	if !auth.CheckPassword(user.Password, req.OldPassword) {
+		return errors.New("旧密码错误")
+	}
+
+	if req.OldPassword == req.NewPassword {
+		return errors.New("新密码不能与旧密码相同")
+	}
+
+	return s.setPassword(ctx, user, req.NewPassword)
+}
+
+// RequestPasswordReset 申请重置密码，重置令牌通过通知器发送给用户
+func (s *AuthServiceImpl) RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := s.userRepo.GetByEmail(ctx, strings.ToLower(strings.TrimSpace(email)))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 不暴露邮箱是否已注册
+			return nil
+		}
+		return err
+	}
+
+	if !user.IsActive || user.IsGuest {
+		return nil
+	}
+
+	token, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	// 新令牌生效后，之前申请的令牌全部作废
+	if err := s.resetTokenRepo.InvalidateAllForUser(ctx, user.ID); err != nil {
+		return err
+	}
+
+	expiresAt := s.options.Now().Add(s.options.PasswordResetTTL)
+	resetToken := &models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: auth.HashToken(token),
+		ExpiresAt: expiresAt,
+	}
+	if err := s.resetTokenRepo.Create(ctx, resetToken); err != nil {
+		return err
+	}
+
+	return s.notifier.Send(ctx, notify.Message{
+		To:      user.Email,
+		Subject: "重置密码",
+		Body: fmt.Sprintf("你好，%s：\n请在%s之前使用以下链接重置密码：\n%s\n如果这不是你本人的操作，请忽略此消息。",
+			user.Nickname, expiresAt.Format("2006-01-02 15:04"), s.buildResetLink(token)),
+	})
+}
+
+// ResetPassword 使用重置令牌设置新密码
+func (s *AuthServiceImpl) ResetPassword(ctx context.Context, req *models.ResetPasswordRequest) error {
+	resetToken, err := s.resetTokenRepo.GetByHash(ctx, auth.HashToken(req.Token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("重置令牌无效")
+		}
+		return err
+	}
+
+	now := s.options.Now()
+	if !resetToken.IsValid(now) {
+		return errors.New("重置令牌已失效")
+	}
+
+	// 先校验新密码，不符合策略时令牌仍可使用
+	if err := auth.ValidatePassword(req.NewPassword); err != nil {
+		return err
+	}
+
+	// 重置令牌只能使用一次，并发的请求中只有成功标记令牌的一个可以设置密码
+	if err := s.resetTokenRepo.Consume(ctx, resetToken.TokenHash, now); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("重置令牌已失效")
+		}
+		return err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, resetToken.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("用户不存在")
+		}
+		return err
+	}
+
+	// 重置密码同时解除账号锁定
+	user.FailedLoginCount = 0
+	user.LockedUntil = nil
+	if err := s.setPassword(ctx, user, req.NewPassword); err != nil {
+		return err
+	}
+
+	return s.resetTokenRepo.InvalidateAllForUser(ctx, user.ID)
+}
+
+// setPassword 校验并保存新密码，同时吊销该用户已签发的所有刷新令牌
+func (s *AuthServiceImpl) setPassword(ctx context.Context, user *models.User, password string) error {
+	if err := auth.ValidatePassword(password); err != nil {
+		return err
+	}
+
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	now := s.options.Now()
+	user.Password = hashedPassword
+	user.PasswordChangedAt = &now
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+
+	return s.refreshTokenRepo.RevokeAllForUser(ctx, user.ID)
+}
+
+// recordFailedLogin 记录一次登录失败，达到上限后锁定账号
+func (s *AuthServiceImpl) recordFailedLogin(ctx context.Context, user *models.User, now time.Time) error {
+	if s.options.MaxLoginAttempts <= 0 {
+		return nil
+	}
+
+	user.FailedLoginCount++
+	if user.FailedLoginCount >= s.options.MaxLoginAttempts {
+		lockedUntil := now.Add(s.options.LockoutDuration)
+		user.LockedUntil = &lockedUntil
+		user.FailedLoginCount = 0
+		log.Printf("用户 %d 连续登录失败，账号锁定至 %s", user.ID, lockedUntil.Format(time.RFC3339))
+	}
+
+	return s.userRepo.Update(ctx, user)
+}
+
+// buildResetLink 构建重置密码链接
+func (s *AuthServiceImpl) buildResetLink(token string) string {
+	if s.options.PasswordResetURL == "" {
+		return token
+	}
+
+	separator := "?"
+	if strings.Contains(s.options.PasswordResetURL, "?") {
+		separator = "&"
+	}
+	return s.options.PasswordResetURL + separator + "token=" + url.QueryEscape(token)
+}
+
+// findUserByLogin 根据用户名或邮箱查找用户
+func (s *AuthServiceImpl) findUserByLogin(ctx context.Context, login string) (*models.User, error) {
+	login = strings.TrimSpace(login)
+	if strings.Contains(login, "@") {
+		return s.userRepo.GetByEmail(ctx, strings.ToLower(login))
+	}
+	return s.userRepo.GetByUsername(ctx, login)
+}
+
+// CreateSession 为已通过其他方式（如单点登录）验证身份的用户签发令牌
+func (s *AuthServiceImpl) CreateSession(ctx context.Context, user *models.User) (*models.AuthResponse, error) {
+	if !user.IsActive {
+		return nil, errors.New("账号已被禁用")
+	}
+	return s.issueTokens(ctx, user)
+}
+
+// AuthenticateSession 按用户的当前状态校验访问令牌对应的登录会话，使用数据库中的角色而不是令牌中的角色；
+// 修改或重置密码之前签发的访问令牌不再有效
+func (s *AuthServiceImpl) AuthenticateSession(ctx context.Context, userID uint, issuedAt time.Time) (*auth.Principal, error) {
+	// 每次都重新读取用户，确保禁用账号或调整角色后立即生效
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, auth.ErrInvalidToken
+		}
+		return nil, err
+	}
+	if !user.IsActive || user.IsGuest {
+		return nil, auth.ErrInvalidToken
+	}
+	// 令牌的签发时间只精确到秒，修改密码的同一秒内签发的令牌仍然有效
+	if user.PasswordChangedAt != nil && issuedAt.Before(user.PasswordChangedAt.Truncate(time.Second)) {
+		return nil, auth.ErrInvalidToken
+	}
+
+	return &auth.Principal{
+		UserID: user.ID,
+		Role:   user.Role,
+		Method: auth.MethodSession,
+	}, nil
+}
+
+// issueTokens 为用户签发访问令牌和刷新令牌
+func (s *AuthServiceImpl) issueTokens(ctx context.Context, user *models.User) (*models.AuthResponse, error) {
+	accessToken, expiresAt, err := s.tokenManager.GenerateAccessToken(user.ID, user.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	stored := &models.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: auth.HashToken(refreshToken),
+		ExpiresAt: s.options.Now().Add(s.options.RefreshTTL),
+	}
+	if err := s.refreshTokenRepo.Create(ctx, stored); err != nil {
+		return nil, err
+	}
+
+	return &models.AuthResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(time.Until(expiresAt).Seconds()),
+		User:         user.ToUserResponse(),
+	}, nil
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
+	"sync"
 	"time"
 
 	"chat_agent/internal/ai"
@@ -44,16 +46,51 @@ type ChatService interface {
 
 	// 删除消息
 	DeleteMessage(ctx context.Context, userID, messageID uint) error
+
+	// 获取会话的长期记忆，query不为空时按相关性返回匹配的记忆
+	ListMemories(ctx context.Context, userID, chatID uint, query string) ([]models.MemoryResponse, error)
+
+	// 修改记忆的内容或置顶状态
+	UpdateMemory(ctx context.Context, userID, chatID uint, memoryID string, req *models.UpdateMemoryRequest) (*models.MemoryResponse, error)
+
+	// 删除记忆
+	DeleteMemory(ctx context.Context, userID, chatID uint, memoryID string) error
 }
 
+// ErrUnsupportedModel 请求的模型没有对应的提供方
+var ErrUnsupportedModel = errors.New("不支持的模型")
+
+// factExtractionTimeout 单条消息提取事实的超时时间（包含可选的模型调用）
+const factExtractionTimeout = 30 * time.Second
+
+// recentMessageLimit 摘要时保留的最近消息条数，这些消息总是直接出现在提示词中，更早的消息通过滚动摘要保留
+const recentMessageLimit = 10
+
+// historyCandidateLimit 提示词最多考虑的未摘要历史消息条数，实际装入多少由模型的上下文长度决定
+const historyCandidateLimit = 100
+
+// summaryBatchSize 每次调用模型最多摘要的消息条数，积压较多时分批合并
+const summaryBatchSize = 50
+
+// summaryTimeout 一次摘要更新的超时时间
+const summaryTimeout = 2 * time.Minute
+
 // ChatServiceImpl 聊天服务实现
 type ChatServiceImpl struct {
-	chatRepo     repository.ChatRepository
-	messageRepo  repository.MessageRepository
-	starRepo     repository.StarRepository
-	llmClient    ai.LLMClient
-	memoryManager ai.MemoryManager
-	promptBuilder *ai.PromptTemplate
+	chatRepo       repository.ChatRepository
+	messageRepo    repository.MessageRepository
+	starRepo       repository.StarRepository
+	llmClient      ai.LLMClient
+	memoryManager  ai.MemoryManager
+	promptBuilder  *ai.PromptTemplate
+	usageService   UsageService
+	profileService ProfileService
+	factExtractor  ai.FactExtractor
+	summarizer     *ai.Summarizer
+	summarizing    sync.Map // 正在更新摘要的会话，避免同一会话并发摘要
+	fallbackModels []string // 请求的模型失败时依次尝试的模型，明星未配置回退模型时使用
+
+	generationLimits GenerationLimits // 会话和请求覆盖生成参数时允许的范围
 }
 
 // NewChatService 创建新的聊天服务
@@ -64,14 +101,27 @@ func NewChatService(
 	llmClient ai.LLMClient,
 	memoryManager ai.MemoryManager,
 	promptBuilder *ai.PromptTemplate,
+	usageService UsageService,
+	profileService ProfileService,
+	factExtractor ai.FactExtractor,
+	summarizer *ai.Summarizer,
+	fallbackModels []string,
+	generationLimits GenerationLimits,
 ) ChatService {
 	return &ChatServiceImpl{
-		chatRepo:     chatRepo,
-		messageRepo:  messageRepo,
-		starRepo:     starRepo,
-		llmClient:    llmClient,
-		memoryManager: memoryManager,
-		promptBuilder: promptBuilder,
+		chatRepo:       chatRepo,
+		messageRepo:    messageRepo,
+		starRepo:       starRepo,
+		llmClient:      llmClient,
+		memoryManager:  memoryManager,
+		promptBuilder:  promptBuilder,
+		usageService:   usageService,
+		profileService: profileService,
+		factExtractor:  factExtractor,
+		summarizer:     summarizer,
+		fallbackModels: fallbackModels,
+
+		generationLimits: generationLimits,
 	}
 }
 
@@ -99,13 +149,13 @@ func (s *ChatServiceImpl) CreateChat(ctx context.Context, userID, starID uint) (
 
 	// 创建新的聊天会话
 	chat := &models.Chat{
-		UserID:      userID,
-		StarID:      starID,
-		Title:       fmt.Sprintf("与%s的聊天", star.Name),
+		UserID:       userID,
+		StarID:       starID,
+		Title:        fmt.Sprintf("与%s的聊天", star.Name),
 		MessageCount: 0,
-		LastActive:  time.Now(),
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		LastActive:   time.Now(),
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
 	}
 
 	// 保存到数据库
@@ -187,6 +237,13 @@ func (s *ChatServiceImpl) UpdateChat(ctx context.Context, userID, chatID uint, u
 	if title, ok := updates["title"].(string); ok && title != "" {
 		chat.Title = title
 	}
+	// 会话的生成参数只能在管理员设置的范围内覆盖明星的配置
+	if generation, ok := updates["generation"].(models.GenerationParams); ok {
+		if err := s.generationLimits.Validate(generation); err != nil {
+			return nil, err
+		}
+		chat.GenerationParams = chat.GenerationParams.Merge(generation)
+	}
 
 	chat.UpdatedAt = time.Now()
 
@@ -215,7 +272,15 @@ func (s *ChatServiceImpl) DeleteChat(ctx context.Context, userID, chatID uint) e
 	}
 
 	// 删除会话（包括相关消息）
-	return s.chatRepo.Delete(ctx, chatID)
+	if err := s.chatRepo.Delete(ctx, chatID); err != nil {
+		return err
+	}
+
+	// 同时清除会话在记忆管理器中的全部记忆
+	if _, err := s.memoryManager.DeleteChatMemory(ctx, chatID); err != nil {
+		log.Printf("清除会话%d的记忆失败: %v", chatID, err)
+	}
+	return nil
 }
 
 // SendMessage 发送消息
@@ -234,6 +299,21 @@ func (s *ChatServiceImpl) SendMessage(ctx context.Context, userID uint, req *mod
 		return nil, errors.New("无权在该聊天会话中发送消息")
 	}
 
+	// 调用模型前检查用户额度
+	if err := s.usageService.CheckQuota(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	// 检查请求的模型能否被路由到某个提供方
+	if err := s.validateModel(req.Model); err != nil {
+		return nil, err
+	}
+
+	// 请求覆盖的生成参数必须在管理员设置的范围内
+	if err := s.generationLimits.Validate(req.GenerationParams); err != nil {
+		return nil, err
+	}
+
 	// 获取明星信息
 	star, err := s.starRepo.GetByID(ctx, chat.StarID)
 	if err != nil {
@@ -270,70 +350,49 @@ func (s *ChatServiceImpl) SendMessage(ctx context.Context, userID uint, req *mod
 		return nil, err
 	}
 
-	// 尝试使用爬虫增强明星资料（异步执行，不阻塞主流程）
-	go func() {
-		enhancedStar, err := ai.EnhanceStarProfile(context.Background(), s.starRepo, star.ID)
-		if err == nil {
-			// 如果成功增强了明星资料，使用增强后的资料
-			star = enhancedStar
-		}
-	}()
+	// 尝试使用爬虫增强明星资料（异步执行，不阻塞主流程），增强后的资料从下一条消息开始生效
+	go s.enhanceStar(star.ID)
 
-	// 获取最近的聊天记录作为上下文
-	recentMessages, err := s.messageRepo.GetLastMessages(ctx, req.ChatID, 10) // 增加历史记录数量
+	// 获取尚未合并进摘要的聊天记录作为上下文
+	recentMessages, err := s.historyMessages(ctx, chat, userMessage.ID)
 	if err != nil {
 		return nil, err
 	}
 
-	// 获取长期记忆
-	longTermMemories, err := s.memoryManager.GetLongTermMemory(ctx, req.ChatID, 10) // 获取最近10条长期记忆
-	if err != nil {
-		// 记忆获取失败不影响主流程
-		longTermMemories = []string{}
-	}
+	// 获取用户画像、置顶的记忆和与当前消息相关的长期记忆
+	profile := s.profileFacts(ctx, chat)
+	pinnedMemories := s.pinnedMemories(ctx, req.ChatID)
+	longTermMemories := s.recallMemories(ctx, req.ChatID, req.Content)
 
-	// 构建提示词
-	messages := s.promptBuilder.BuildChatCompletionMessages(star, recentMessages, req.Content, longTermMemories)
+	// 按回退链中上下文最短的模型构建提示词
+	chain := s.modelChain(star, req.Model)
+	messages := s.promptBuilder.BuildChatCompletionMessages(star, profile, chat.Summary, recentMessages, req.Content, pinnedMemories, longTermMemories, s.promptModels(chain)...)
 
 	// 添加到记忆
 	s.memoryManager.AddShortTermMemory(ctx, req.ChatID, req.Content)
 
-	// 尝试调用LLM获取回复
-	response, err := s.llmClient.GenerateResponse(ctx, messages, req.Model)
-	if err != nil {
-		return nil, err
-	}
-
-	// 创建AI回复消息
-	aiMessage := &models.Message{
-		ChatID:     req.ChatID,
-		SenderID:   star.ID,
-		SenderType: models.SenderTypeStar,
-		Content:    response,
-		Status:     models.MessageStatusSent,
-		CreatedAt:  time.Now(),
-	}
+	// 从用户消息中提取事实写入长期记忆（异步执行，不阻塞回复）
+	go s.rememberFacts(chat, req.Content)
 
-	// 保存AI回复消息
-	if err := s.messageRepo.Create(ctx, aiMessage); err != nil {
-		return nil, err
-	}
-
-	// 再次更新聊天会话信息
-	if err := s.chatRepo.UpdateLastActive(ctx, req.ChatID, response); err != nil {
-		return nil, err
+	// 按回退链依次调用模型获取回复，全部失败时使用明星的兜底回复
+	completion, index, err := ai.GenerateWithFallback(ctx, s.llmClient, messages, generateOptions(star, chat, req), chain)
+	fallback := index > 0
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		if completion, err = fallbackReply(star, err); err != nil {
+			return nil, err
+		}
+		fallback = true
 	}
 
-	if err := s.chatRepo.IncrementMessageCount(ctx, req.ChatID); err != nil {
+	// 保存AI回复并记录用量
+	aiMessage, err := s.saveStarReply(ctx, userID, chat, star, completion, fallback, longTermMemories)
+	if err != nil {
 		return nil, err
 	}
 
-	// 添加AI回复到记忆
-	s.memoryManager.AddShortTermMemory(ctx, req.ChatID, response)
-	
-	// 提取对话中的关键信息，更新长期记忆
-	s.memoryManager.AddLongTermMemory(ctx, req.ChatID, response, 1.0) // weight=1.0表示重要性一般
-
 	// 返回AI回复消息
 	aiMessageResponse := aiMessage.ToMessageResponse()
 	return &aiMessageResponse, nil
@@ -355,6 +414,21 @@ func (s *ChatServiceImpl) SendMessageStream(ctx context.Context, userID uint, re
 		return nil, nil, errors.New("无权在该聊天会话中发送消息")
 	}
 
+	// 调用模型前检查用户额度
+	if err := s.usageService.CheckQuota(ctx, userID); err != nil {
+		return nil, nil, err
+	}
+
+	// 检查请求的模型能否被路由到某个提供方
+	if err := s.validateModel(req.Model); err != nil {
+		return nil, nil, err
+	}
+
+	// 请求覆盖的生成参数必须在管理员设置的范围内
+	if err := s.generationLimits.Validate(req.GenerationParams); err != nil {
+		return nil, nil, err
+	}
+
 	// 获取明星信息
 	star, err := s.starRepo.GetByID(ctx, chat.StarID)
 	if err != nil {
@@ -385,127 +459,400 @@ func (s *ChatServiceImpl) SendMessageStream(ctx context.Context, userID uint, re
 		return nil, nil, err
 	}
 
-	// 尝试使用爬虫增强明星资料（异步执行，不阻塞主流程）
-	go func() {
-		enhancedStar, err := ai.EnhanceStarProfile(context.Background(), s.starRepo, star.ID)
-		if err == nil {
-			// 如果成功增强了明星资料，使用增强后的资料
-			star = enhancedStar
-		}
-	}()
+	// 尝试使用爬虫增强明星资料（异步执行，不阻塞主流程），增强后的资料从下一条消息开始生效
+	go s.enhanceStar(star.ID)
 
-	// 获取最近的聊天记录作为上下文
-	recentMessages, err := s.messageRepo.GetLastMessages(ctx, req.ChatID, 10) // 增加历史记录数量
+	// 获取尚未合并进摘要的聊天记录作为上下文
+	recentMessages, err := s.historyMessages(ctx, chat, userMessage.ID)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	// 获取长期记忆
-	longTermMemories, err := s.memoryManager.GetLongTermMemory(ctx, req.ChatID, 10) // 获取最近10条长期记忆
-	if err != nil {
-		// 记忆获取失败不影响主流程
-		longTermMemories = []string{}
-	}
+	// 获取用户画像、置顶的记忆和与当前消息相关的长期记忆
+	profile := s.profileFacts(ctx, chat)
+	pinnedMemories := s.pinnedMemories(ctx, req.ChatID)
+	longTermMemories := s.recallMemories(ctx, req.ChatID, req.Content)
 
-	// 构建提示词
-	messages := s.promptBuilder.BuildChatCompletionMessages(star, recentMessages, req.Content, longTermMemories)
+	// 按回退链中上下文最短的模型构建提示词
+	chain := s.modelChain(star, req.Model)
+	messages := s.promptBuilder.BuildChatCompletionMessages(star, profile, chat.Summary, recentMessages, req.Content, pinnedMemories, longTermMemories, s.promptModels(chain)...)
 
 	// 添加到记忆
 	s.memoryManager.AddShortTermMemory(ctx, req.ChatID, req.Content)
 
+	// 从用户消息中提取事实写入长期记忆（异步执行，不阻塞回复）
+	go s.rememberFacts(chat, req.Content)
+
 	// 创建响应通道
 	streamChan := make(chan string)
-	errChan := make(chan error)
+	errChan := make(chan error, 1)
 
-	// 调用LLM的流式生成功能
+	// 调用LLM的流式生成功能，结束后保存完整回复
 	go func() {
 		defer close(streamChan)
 		defer close(errChan)
 
-		err := s.llmClient.GenerateStreamResponse(ctx, messages, req.Model, func(chunk string) error {
-			streamChan <- chunk
-			return nil
+		// 按回退链依次调用模型，已经输出内容后失败不再回退
+		streamed := false
+		completion, index, err := ai.GenerateStreamWithFallback(ctx, s.llmClient, messages, generateOptions(star, chat, req), chain, func(chunk string) error {
+			select {
+			case streamChan <- chunk:
+				streamed = true
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
 		})
+		fallback := index > 0
 
 		if err != nil {
-			// 如果调用失败，发送默认回复
-			defaultResponse := "你好！很高兴能和你聊天。虽然我的AI功能暂时无法使用，但我依然可以陪伴你。有什么想聊的吗？"
-			streamChan <- defaultResponse
-			// 不发送错误，而是使用默认回复
+			if ctx.Err() != nil {
+				// 客户端已断开连接
+				return
+			}
+			if streamed {
+				errChan <- err
+				return
+			}
+
+			// 所有模型都失败时使用明星的兜底回复
+			if completion, err = fallbackReply(star, err); err != nil {
+				errChan <- err
+				return
+			}
+			fallback = true
+			select {
+			case streamChan <- completion.Content:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		// 流式响应结束，保存AI回复
+		if _, err := s.saveStarReply(context.Background(), userID, chat, star, completion, fallback, longTermMemories); err != nil {
+			errChan <- err
 		}
 	}()
 
-	// 创建最终的响应通道
-	responseChan := make(chan string)
-	finalErrChan := make(chan error)
+	return streamChan, errChan, nil
+}
 
-	// 收集完整回复内容
-	var fullResponse string
+// historyMessages 获取提示词中使用的历史消息：已合并进摘要的消息和当前消息（currentID）除外，按时间顺序返回
+func (s *ChatServiceImpl) historyMessages(ctx context.Context, chat *models.Chat, currentID uint) ([]models.Message, error) {
+	messages, err := s.messageRepo.GetLastMessages(ctx, chat.ID, historyCandidateLimit)
+	if err != nil {
+		return nil, err
+	}
 
-	// 处理流式响应
-	go func() {
-		defer close(responseChan)
-		defer close(finalErrChan)
+	history := make([]models.Message, 0, len(messages))
+	for _, message := range messages {
+		if message.ID > chat.SummaryUntilID && message.ID != currentID {
+			history = append(history, message)
+		}
+	}
+	return history, nil
+}
 
-		for {
-			select {
-			case chunk, ok := <-streamChan:
-				if !ok {
-					// 流式响应结束，保存AI回复
-					aiMessage := &models.Message{
-						ChatID:     req.ChatID,
-						SenderID:   star.ID,
-						SenderType: models.SenderTypeStar,
-						Content:    fullResponse,
-						Status:     models.MessageStatusSent,
-						CreatedAt:  time.Now(),
-					}
-
-					// 保存AI回复消息
-					if err := s.messageRepo.Create(context.Background(), aiMessage); err != nil {
-						finalErrChan <- err
-						return
-					}
-
-					// 更新聊天会话信息
-					if err := s.chatRepo.UpdateLastActive(context.Background(), req.ChatID, fullResponse); err != nil {
-						finalErrChan <- err
-						return
-					}
-
-					if err := s.chatRepo.IncrementMessageCount(context.Background(), req.ChatID); err != nil {
-						finalErrChan <- err
-						return
-					}
-
-					// 添加AI回复到记忆
-			s.memoryManager.AddShortTermMemory(context.Background(), req.ChatID, fullResponse)
-			
-			// 提取对话中的关键信息，更新长期记忆
-			s.memoryManager.AddLongTermMemory(context.Background(), req.ChatID, fullResponse, 1.0) // weight=1.0表示重要性一般
-
-					return
-				}
-
-				// 发送数据块到响应通道
-				responseChan <- chunk
-				fullResponse += chunk
-
-			case err, ok := <-errChan:
-				if !ok {
-					return
-				}
-				finalErrChan <- err
-				return
+// profileFacts 获取提示词中使用的用户画像，获取失败不影响主流程
+func (s *ChatServiceImpl) profileFacts(ctx context.Context, chat *models.Chat) []string {
+	if s.profileService == nil {
+		return nil
+	}
+	profile, err := s.profileService.FactsForStar(ctx, chat.UserID, chat.StarID)
+	if err != nil {
+		log.Printf("获取用户画像失败(user=%d): %v", chat.UserID, err)
+		return nil
+	}
+	return profile
+}
 
-			case <-ctx.Done():
-				finalErrChan <- ctx.Err()
+// pinnedMemories 获取用户置顶的记忆，置顶的记忆总会加入提示词
+func (s *ChatServiceImpl) pinnedMemories(ctx context.Context, chatID uint) []string {
+	items, err := s.memoryManager.ListMemories(ctx, chatID)
+	if err != nil {
+		log.Printf("获取置顶记忆失败(chat=%d): %v", chatID, err)
+		return nil
+	}
+
+	var pinned []string
+	for _, item := range items {
+		if item.Pinned {
+			pinned = append(pinned, item.Content)
+		}
+	}
+	return pinned
+}
+
+// recallMemories 召回提示词中使用的长期记忆：先取与当前消息最相关的记忆，再用权重最高的记忆补足
+func (s *ChatServiceImpl) recallMemories(ctx context.Context, chatID uint, query string) []string {
+	const (
+		relevantLimit = 5
+		totalLimit    = 10
+	)
+
+	seen := make(map[string]bool)
+	var memories []string
+	appendUnique := func(items []string) {
+		for _, item := range items {
+			if len(memories) >= totalLimit {
 				return
 			}
+			if !seen[item] {
+				seen[item] = true
+				memories = append(memories, item)
+			}
 		}
-	}()
+	}
 
-	return streamChan, errChan, nil
+	// 记忆获取失败不影响主流程
+	if relevant, err := s.memoryManager.SearchMemory(ctx, chatID, query, relevantLimit); err == nil {
+		appendUnique(relevant)
+	}
+	if topWeighted, err := s.memoryManager.GetLongTermMemory(ctx, chatID, totalLimit); err == nil {
+		appendUnique(topWeighted)
+	}
+
+	if memories == nil {
+		return []string{}
+	}
+	return memories
+}
+
+// saveStarReply 保存明星回复消息，更新会话信息和记忆，并记录本次调用的用量；
+// fallback表示回复来自回退模型或兜底回复，recalled为生成回复时召回的长期记忆
+func (s *ChatServiceImpl) saveStarReply(ctx context.Context, userID uint, chat *models.Chat, star *models.Star, completion *ai.Completion, fallback bool, recalled []string) (*models.Message, error) {
+	// 创建AI回复消息
+	aiMessage := &models.Message{
+		ChatID:           chat.ID,
+		SenderID:         star.ID,
+		SenderType:       models.SenderTypeStar,
+		Content:          completion.Content,
+		Status:           models.MessageStatusSent,
+		Model:            completion.Model,
+		PromptTokens:     completion.Usage.PromptTokens,
+		CompletionTokens: completion.Usage.CompletionTokens,
+		Fallback:         fallback,
+		CreatedAt:        time.Now(),
+	}
+
+	// 保存AI回复消息
+	if err := s.messageRepo.Create(ctx, aiMessage); err != nil {
+		return nil, err
+	}
+
+	// 记录用量（未实际调用模型的兜底回复不计入）
+	if completion.Model != "" {
+		record := &models.UsageRecord{
+			UserID:           userID,
+			ChatID:           chat.ID,
+			StarID:           chat.StarID,
+			MessageID:        aiMessage.ID,
+			Model:            completion.Model,
+			PromptTokens:     completion.Usage.PromptTokens,
+			CompletionTokens: completion.Usage.CompletionTokens,
+			TotalTokens:      completion.Usage.TotalTokens,
+		}
+		if err := s.usageService.RecordUsage(ctx, record); err != nil {
+			// 用量记录失败不影响回复
+			log.Printf("记录用量失败(chat=%d): %v", chat.ID, err)
+		}
+	}
+
+	// 再次更新聊天会话信息
+	if err := s.chatRepo.UpdateLastActive(ctx, chat.ID, completion.Content); err != nil {
+		return nil, err
+	}
+
+	if err := s.chatRepo.IncrementMessageCount(ctx, chat.ID); err != nil {
+		return nil, err
+	}
+
+	// 添加AI回复到记忆
+	s.memoryManager.AddShortTermMemory(ctx, chat.ID, completion.Content)
+
+	// 强化被回复引用的长期记忆（未实际调用模型的兜底回复不算引用）
+	if completion.Model != "" {
+		s.reinforceMemories(ctx, chat.ID, recalled, completion.Content)
+
+		// 对话足够长时在后台把较早的消息合并进滚动摘要
+		go s.refreshSummary(chat.ID, star)
+	}
+
+	return aiMessage, nil
+}
+
+// enhanceStar 使用爬虫增强明星资料并保存到仓库；不修改当前请求正在使用的明星，避免与请求并发读写
+func (s *ChatServiceImpl) enhanceStar(starID uint) {
+	if _, err := ai.EnhanceStarProfile(context.Background(), s.starRepo, starID); err != nil {
+		log.Printf("增强明星%d的资料失败: %v", starID, err)
+	}
+}
+
+// reinforceMemories 强化召回的记忆中被回复实际引用的记忆
+func (s *ChatServiceImpl) reinforceMemories(ctx context.Context, chatID uint, recalled []string, reply string) {
+	for _, memory := range recalled {
+		if !ai.MemoryReferenced(memory, reply) {
+			continue
+		}
+		if err := s.memoryManager.ReinforceMemory(ctx, chatID, memory); err != nil {
+			log.Printf("强化记忆失败(chat=%d): %v", chatID, err)
+		}
+	}
+}
+
+// rememberFacts 从用户消息中提取事实，按键写入会话的长期记忆和用户画像，相同键的旧事实被替换
+func (s *ChatServiceImpl) rememberFacts(chat *models.Chat, content string) {
+	if s.factExtractor == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), factExtractionTimeout)
+	defer cancel()
+
+	facts, err := s.factExtractor.Extract(ctx, content)
+	if err != nil {
+		// 模型提取失败时仍保存规则提取出的事实
+		log.Printf("提取事实失败(chat=%d): %v", chat.ID, err)
+	}
+	for _, fact := range facts {
+		if err := s.memoryManager.UpsertLongTermMemory(ctx, chat.ID, fact.Key, fact.Content, fact.Weight); err != nil {
+			log.Printf("保存事实失败(chat=%d): %v", chat.ID, err)
+		}
+	}
+
+	// 同时写入用户画像，在与其他明星的会话中共享
+	if s.profileService != nil {
+		if err := s.profileService.RememberFacts(ctx, chat.UserID, chat.StarID, facts); err != nil {
+			log.Printf("保存用户画像失败(user=%d): %v", chat.UserID, err)
+		}
+	}
+}
+
+// modelChain 本次回复的回退链：先是请求的模型，再依次是明星配置的回退模型，明星未配置时使用全局的回退模型
+func (s *ChatServiceImpl) modelChain(star *models.Star, model string) []string {
+	fallbacks := star.FallbackModelList()
+	if len(fallbacks) == 0 {
+		fallbacks = s.fallbackModels
+	}
+	return ai.FallbackChain(model, fallbacks)
+}
+
+// promptModels 回退链中各模型实际调用的名称（展开别名和默认模型），提示词预算按这些名称查找上下文长度
+func (s *ChatServiceImpl) promptModels(chain []string) []string {
+	resolver, ok := s.llmClient.(ai.ModelResolver)
+	if !ok {
+		return chain
+	}
+	names := make([]string, len(chain))
+	for i, model := range chain {
+		names[i] = resolver.ResolveModelName(model)
+	}
+	return names
+}
+
+// generateOptions 本次调用的生成参数：明星的配置依次被会话和请求中设置的参数覆盖
+func generateOptions(star *models.Star, chat *models.Chat, req *models.SendMessageRequest) ai.GenerateOptions {
+	return ai.GenerateOptions{
+		GenerationParams: star.GenerationParams.Merge(chat.GenerationParams).Merge(req.GenerationParams),
+	}
+}
+
+// fallbackReply 回退链中的模型全部失败时使用明星以自己口吻编写的兜底回复，未配置兜底回复时返回原始错误
+func fallbackReply(star *models.Star, err error) (*ai.Completion, error) {
+	if star.FallbackReply == "" {
+		return nil, err
+	}
+	log.Printf("明星%d的模型全部调用失败，使用兜底回复: %v", star.ID, err)
+	return &ai.Completion{Content: star.FallbackReply}, nil
+}
+
+// validateModel 客户端支持预先检查模型时（如按模型路由的客户端），拒绝无法路由的模型
+func (s *ChatServiceImpl) validateModel(model string) error {
+	validator, ok := s.llmClient.(ai.ModelValidator)
+	if !ok {
+		return nil
+	}
+	if err := validator.ValidateModel(model); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnsupportedModel, err)
+	}
+	return nil
+}
+
+// forgetFacts 重新提取已删除消息中的事实，删除会话中与之内容相同的长期记忆（置顶的记忆保留）
+func (s *ChatServiceImpl) forgetFacts(chatID uint, content string) {
+	if s.factExtractor == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), factExtractionTimeout)
+	defer cancel()
+
+	facts, err := s.factExtractor.Extract(ctx, content)
+	if err != nil {
+		log.Printf("提取事实失败(chat=%d): %v", chatID, err)
+	}
+	for _, fact := range facts {
+		if _, err := s.memoryManager.ForgetMemory(ctx, chatID, fact.Content); err != nil {
+			log.Printf("清除事实失败(chat=%d): %v", chatID, err)
+		}
+	}
+}
+
+// refreshSummary 把滑出上下文窗口的消息合并进会话的滚动摘要，未摘要的消息积累到一定数量时才更新
+func (s *ChatServiceImpl) refreshSummary(chatID uint, star *models.Star) {
+	if s.summarizer == nil || s.summarizer.Every() <= 0 {
+		return
+	}
+	if _, running := s.summarizing.LoadOrStore(chatID, true); running {
+		return
+	}
+	defer s.summarizing.Delete(chatID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), summaryTimeout)
+	defer cancel()
+
+	chat, err := s.chatRepo.GetByID(ctx, chatID)
+	if err != nil {
+		log.Printf("获取会话失败(chat=%d): %v", chatID, err)
+		return
+	}
+
+	// 最近的消息仍直接出现在提示词中，只摘要更早的消息
+	pending, err := s.messageRepo.CountMessagesAfter(ctx, chatID, chat.SummaryUntilID)
+	if err != nil {
+		log.Printf("统计未摘要消息失败(chat=%d): %v", chatID, err)
+		return
+	}
+	remaining := int(pending) - recentMessageLimit
+	if remaining < s.summarizer.Every() {
+		return
+	}
+
+	summary, untilID := chat.Summary, chat.SummaryUntilID
+	for remaining > 0 {
+		limit := remaining
+		if limit > summaryBatchSize {
+			limit = summaryBatchSize
+		}
+		messages, err := s.messageRepo.GetMessagesAfter(ctx, chatID, untilID, limit)
+		if err != nil || len(messages) == 0 {
+			break
+		}
+
+		summary, err = s.summarizer.Summarize(ctx, star, summary, messages)
+		if err != nil {
+			log.Printf("生成会话摘要失败(chat=%d): %v", chatID, err)
+			return
+		}
+		untilID = messages[len(messages)-1].ID
+		remaining -= len(messages)
+
+		// 每批完成后立即保存，失败时已合并的部分不会丢失
+		if err := s.chatRepo.UpdateSummary(ctx, chatID, summary, untilID); err != nil {
+			log.Printf("保存会话摘要失败(chat=%d): %v", chatID, err)
+			return
+		}
+	}
 }
 
 // GetChatMessages 获取聊天消息列表
@@ -560,5 +907,113 @@ func (s *ChatServiceImpl) DeleteMessage(ctx context.Context, userID, messageID u
 	}
 
 	// 删除消息
-	return s.messageRepo.Delete(ctx, messageID)
-}
\ No newline at end of file
+	if err := s.messageRepo.Delete(ctx, messageID); err != nil {
+		return err
+	}
+
+	// 同时忘记这条消息的短期记忆，以及从中提取出的长期记忆（提取可能调用模型，异步执行）
+	if _, err := s.memoryManager.ForgetMemory(ctx, chat.ID, message.Content); err != nil {
+		log.Printf("清除消息%d的记忆失败: %v", messageID, err)
+	}
+	go s.forgetFacts(chat.ID, message.Content)
+	return nil
+}
+
+// ListMemories 获取会话的长期记忆
+func (s *ChatServiceImpl) ListMemories(ctx context.Context, userID, chatID uint, query string) ([]models.MemoryResponse, error) {
+	if err := s.checkMemoryAccess(ctx, userID, chatID); err != nil {
+		return nil, err
+	}
+
+	items, err := s.memoryManager.ListMemories(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 有查询时按检索结果的顺序返回匹配的长期记忆
+	if query != "" {
+		matched, err := s.memoryManager.SearchMemory(ctx, chatID, query, len(items))
+		if err != nil {
+			return nil, err
+		}
+		byContent := make(map[string]ai.MemoryItem, len(items))
+		for _, item := range items {
+			byContent[item.Content] = item
+		}
+		items = items[:0]
+		for _, content := range matched {
+			if item, ok := byContent[content]; ok {
+				items = append(items, item)
+				delete(byContent, content)
+			}
+		}
+	}
+
+	responses := make([]models.MemoryResponse, len(items))
+	for i, item := range items {
+		responses[i] = toMemoryResponse(item)
+	}
+	return responses, nil
+}
+
+// UpdateMemory 修改记忆的内容或置顶状态
+func (s *ChatServiceImpl) UpdateMemory(ctx context.Context, userID, chatID uint, memoryID string, req *models.UpdateMemoryRequest) (*models.MemoryResponse, error) {
+	if err := s.checkMemoryAccess(ctx, userID, chatID); err != nil {
+		return nil, err
+	}
+
+	item, err := s.memoryManager.UpdateMemory(ctx, chatID, memoryID, ai.MemoryUpdate{
+		Content: req.Content,
+		Pinned:  req.Pinned,
+	})
+	if errors.Is(err, ai.ErrMemoryNotFound) {
+		return nil, errors.New("记忆不存在")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	response := toMemoryResponse(*item)
+	return &response, nil
+}
+
+// DeleteMemory 删除记忆
+func (s *ChatServiceImpl) DeleteMemory(ctx context.Context, userID, chatID uint, memoryID string) error {
+	if err := s.checkMemoryAccess(ctx, userID, chatID); err != nil {
+		return err
+	}
+
+	err := s.memoryManager.DeleteMemory(ctx, chatID, memoryID)
+	if errors.Is(err, ai.ErrMemoryNotFound) {
+		return errors.New("记忆不存在")
+	}
+	return err
+}
+
+// checkMemoryAccess 验证会话存在且属于该用户
+func (s *ChatServiceImpl) checkMemoryAccess(ctx context.Context, userID, chatID uint) error {
+	chat, err := s.chatRepo.GetByID(ctx, chatID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("聊天会话不存在")
+		}
+		return err
+	}
+	if chat.UserID != userID {
+		return errors.New("无权访问此聊天会话的记忆")
+	}
+	return nil
+}
+
+// toMemoryResponse 转换为记忆响应
+func toMemoryResponse(item ai.MemoryItem) models.MemoryResponse {
+	return models.MemoryResponse{
+		ID:        item.ID,
+		Key:       item.Key,
+		Content:   item.Content,
+		Weight:    item.Weight,
+		Pinned:    item.Pinned,
+		CreatedAt: item.CreatedAt,
+		UpdatedAt: item.UpdatedAt,
+	}
+}
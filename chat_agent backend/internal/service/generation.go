@@ -0,0 +1,75 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"chat_agent/internal/models"
+)
+
+// ErrInvalidGenerationParams 生成参数超出允许的范围
+var ErrInvalidGenerationParams = errors.New("生成参数无效")
+
+// GenerationLimits 生成参数的允许范围
+type GenerationLimits struct {
+	MinTemperature float32
+	MaxTemperature float32
+	MinTopP        float32
+	MaxTopP        float32
+	MaxTokens      int // 0表示不限制
+	MinPenalty     float32
+	MaxPenalty     float32
+	MaxStop        int // 停止序列的最大数量
+}
+
+// ProviderGenerationLimits 模型接口接受的参数范围，管理员配置的明星生成参数按该范围校验
+var ProviderGenerationLimits = GenerationLimits{
+	MinTemperature: 0,
+	MaxTemperature: 2,
+	MinTopP:        0,
+	MaxTopP:        1,
+	MinPenalty:     -2,
+	MaxPenalty:     2,
+	MaxStop:        4,
+}
+
+// Validate 检查已设置的参数是否在允许的范围内
+func (l GenerationLimits) Validate(params models.GenerationParams) error {
+	if err := checkRange("temperature", params.Temperature, l.MinTemperature, l.MaxTemperature); err != nil {
+		return err
+	}
+	if err := checkRange("top_p", params.TopP, l.MinTopP, l.MaxTopP); err != nil {
+		return err
+	}
+	if err := checkRange("presence_penalty", params.PresencePenalty, l.MinPenalty, l.MaxPenalty); err != nil {
+		return err
+	}
+	if err := checkRange("frequency_penalty", params.FrequencyPenalty, l.MinPenalty, l.MaxPenalty); err != nil {
+		return err
+	}
+	if params.MaxTokens != nil {
+		if *params.MaxTokens <= 0 {
+			return fmt.Errorf("%w: max_tokens必须大于0", ErrInvalidGenerationParams)
+		}
+		if l.MaxTokens > 0 && *params.MaxTokens > l.MaxTokens {
+			return fmt.Errorf("%w: max_tokens不能超过%d", ErrInvalidGenerationParams, l.MaxTokens)
+		}
+	}
+	if len(params.Stop) > l.MaxStop {
+		return fmt.Errorf("%w: 停止序列最多%d个", ErrInvalidGenerationParams, l.MaxStop)
+	}
+	for _, stop := range params.Stop {
+		if stop == "" {
+			return fmt.Errorf("%w: 停止序列不能为空", ErrInvalidGenerationParams)
+		}
+	}
+	return nil
+}
+
+// checkRange 检查参数是否在[min, max]之间，未设置的参数不检查
+func checkRange(name string, value *float32, min, max float32) error {
+	if value != nil && (*value < min || *value > max) {
+		return fmt.Errorf("%w: %s必须在%g到%g之间", ErrInvalidGenerationParams, name, min, max)
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"chat_agent/internal/ai"
+	"chat_agent/internal/auth"
+	"chat_agent/internal/models"
+	"chat_agent/internal/repository"
+)
+
+// ErrGuestLimitReached 游客消息数已达上限
+var ErrGuestLimitReached = errors.New("游客消息数已达上限，请注册后继续聊天")
+
+// guestSweepBatchSize 每轮清理最多处理的游客数量
+const guestSweepBatchSize = 100
+
+// GuestService 游客会话服务接口
+type GuestService interface {
+	// 创建游客账号并签发游客令牌，clientIP为发起请求的客户端IP
+	CreateGuest(ctx context.Context, clientIP string) (*models.GuestSessionResponse, error)
+
+	// 检查游客是否还能继续发送消息，同一IP创建的游客共用上限，达到上限时返回ErrGuestLimitReached
+	CheckMessageLimit(ctx context.Context, userID uint) error
+
+	// 游客注册后，将游客令牌对应的数据转移到新账号
+	UpgradeGuest(ctx context.Context, guestToken string, userID uint) error
+
+	// 清理已过期的游客账号，返回清理的数量
+	SweepExpiredGuests(ctx context.Context) (int, error)
+
+	// 后台定期清理过期游客，直到ctx被取消
+	RunSweeper(ctx context.Context, interval time.Duration)
+}
+
+// GuestOptions 游客服务的可配置项
+type GuestOptions struct {
+	TTL          time.Duration // 游客账号有效期
+	MessageLimit int           // 同一IP的游客最多可发送的消息数，0表示不限制
+}
+
+// GuestServiceImpl 游客会话服务实现
+type GuestServiceImpl struct {
+	userRepo      repository.UserRepository
+	messageRepo   repository.MessageRepository
+	tokenManager  *auth.TokenManager
+	memoryManager ai.MemoryManager
+	options       GuestOptions
+}
+
+// NewGuestService 创建新的游客服务
+func NewGuestService(
+	userRepo repository.UserRepository,
+	messageRepo repository.MessageRepository,
+	tokenManager *auth.TokenManager,
+	memoryManager ai.MemoryManager,
+	options GuestOptions,
+) GuestService {
+	if options.TTL <= 0 {
+		options.TTL = 24 * time.Hour
+	}
+	return &GuestServiceImpl{
+		userRepo:      userRepo,
+		messageRepo:   messageRepo,
+		tokenManager:  tokenManager,
+		memoryManager: memoryManager,
+		options:       options,
+	}
+}
+
+// CreateGuest 创建游客账号并签发游客令牌，clientIP为发起请求的客户端IP
+func (s *GuestServiceImpl) CreateGuest(ctx context.Context, clientIP string) (*models.GuestSessionResponse, error) {
+	suffix, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	// 用户名和邮箱有唯一索引，游客使用随机占位值
+	username := "guest_" + suffix[:16]
+	expiresAt := time.Now().Add(s.options.TTL)
+	user := &models.User{
+		Username:       username,
+		Email:          username + "@guest.invalid",
+		Nickname:       "游客",
+		IsActive:       true,
+		Role:           models.RoleUser,
+		IsGuest:        true,
+		GuestExpiresAt: &expiresAt,
+		GuestIP:        clientIP,
+	}
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+
+	token, err := s.tokenManager.GenerateGuestToken(user.ID, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.GuestSessionResponse{
+		User:         user.ToUserResponse(),
+		ExpiresAt:    expiresAt,
+		MessageLimit: s.options.MessageLimit,
+		GuestToken:   token,
+	}, nil
+}
+
+// CheckMessageLimit 检查游客是否还能继续发送消息。同一IP创建的游客共用上限，
+// 避免反复创建游客绕过限制；游客过期被清理后其消息不再计入
+func (s *GuestServiceImpl) CheckMessageLimit(ctx context.Context, userID uint) error {
+	if s.options.MessageLimit <= 0 {
+		return nil
+	}
+
+	guest, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	var used int64
+	if guest.GuestIP != "" {
+		used, err = s.messageRepo.CountGuestMessagesByIP(ctx, guest.GuestIP)
+	} else {
+		used, err = s.messageRepo.CountUserMessages(ctx, userID)
+	}
+	if err != nil {
+		return err
+	}
+	if used >= int64(s.options.MessageLimit) {
+		return fmt.Errorf("%w（%d条）", ErrGuestLimitReached, s.options.MessageLimit)
+	}
+	return nil
+}
+
+// UpgradeGuest 游客注册后，将游客令牌对应的数据转移到新账号
+func (s *GuestServiceImpl) UpgradeGuest(ctx context.Context, guestToken string, userID uint) error {
+	guestID, err := s.tokenManager.ParseGuestToken(guestToken)
+	if err != nil {
+		// 游客会话已过期，没有需要转移的数据
+		return nil
+	}
+
+	guest, err := s.userRepo.GetByID(ctx, guestID)
+	if err != nil || !guest.IsGuest {
+		return nil
+	}
+
+	return s.userRepo.TransferGuestData(ctx, guestID, userID)
+}
+
+// SweepExpiredGuests 清理已过期的游客账号
+func (s *GuestServiceImpl) SweepExpiredGuests(ctx context.Context) (int, error) {
+	guests, err := s.userRepo.ListExpiredGuests(ctx, time.Now(), guestSweepBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, guest := range guests {
+		chatIDs, err := s.userRepo.DeleteGuest(ctx, guest.ID)
+		if err != nil {
+			return removed, err
+		}
+
+		// 同时清理会话的全部记忆
+		for _, chatID := range chatIDs {
+			if _, err := s.memoryManager.DeleteChatMemory(ctx, chatID); err != nil {
+				log.Printf("清理游客会话%d的记忆失败: %v", chatID, err)
+			}
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// RunSweeper 后台定期清理过期游客，直到ctx被取消
+func (s *GuestServiceImpl) RunSweeper(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			removed, err := s.SweepExpiredGuests(ctx)
+			if err != nil {
+				log.Printf("清理过期游客失败: %v", err)
+				continue
+			}
+			if removed > 0 {
+				log.Printf("已清理%d个过期游客", removed)
+			}
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"chat_agent/internal/ai"
+	"chat_agent/internal/models"
+	"chat_agent/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// exportChatPageSize 导出用户记忆时分页读取会话的每页条数
+const exportChatPageSize = 100
+
+// MemoryService 记忆导出导入服务接口：会话或用户的记忆导出为带版本的JSON快照，
+// 可以导入到其他会话或其他部署中，以替换方式导入即为从快照恢复
+type MemoryService interface {
+	// 导出会话的记忆和摘要
+	ExportChat(ctx context.Context, userID, chatID uint) (*ai.MemorySnapshot, error)
+
+	// 导出用户全部会话的记忆和用户画像
+	ExportUser(ctx context.Context, userID uint) (*ai.MemorySnapshot, error)
+
+	// 把快照导入会话，快照包含多个会话时使用与该会话同一明星的记忆；replace为true时替换已有记忆
+	ImportChat(ctx context.Context, userID, chatID uint, snapshot *ai.MemorySnapshot, replace bool) error
+
+	// 把快照导入用户，按明星导入到用户与该明星的会话中（不存在时创建），返回导入的会话数
+	ImportUser(ctx context.Context, userID uint, snapshot *ai.MemorySnapshot, replace bool) (int, error)
+}
+
+// MemoryServiceImpl 记忆导出导入服务实现
+type MemoryServiceImpl struct {
+	chatRepo      repository.ChatRepository
+	starRepo      repository.StarRepository
+	profileRepo   repository.ProfileRepository
+	memoryManager ai.MemoryManager
+}
+
+// NewMemoryService 创建新的记忆导出导入服务
+func NewMemoryService(
+	chatRepo repository.ChatRepository,
+	starRepo repository.StarRepository,
+	profileRepo repository.ProfileRepository,
+	memoryManager ai.MemoryManager,
+) MemoryService {
+	return &MemoryServiceImpl{
+		chatRepo:      chatRepo,
+		starRepo:      starRepo,
+		profileRepo:   profileRepo,
+		memoryManager: memoryManager,
+	}
+}
+
+// ExportChat 导出会话的记忆和摘要
+func (s *MemoryServiceImpl) ExportChat(ctx context.Context, userID, chatID uint) (*ai.MemorySnapshot, error) {
+	chat, err := s.ownedChat(ctx, userID, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	chatSnapshot, err := s.exportChat(ctx, chat)
+	if err != nil {
+		return nil, err
+	}
+	return &ai.MemorySnapshot{
+		ExportedAt: time.Now(),
+		UserID:     userID,
+		Chats:      []ai.ChatMemorySnapshot{*chatSnapshot},
+	}, nil
+}
+
+// ExportUser 导出用户全部会话的记忆和用户画像
+func (s *MemoryServiceImpl) ExportUser(ctx context.Context, userID uint) (*ai.MemorySnapshot, error) {
+	snapshot := &ai.MemorySnapshot{
+		ExportedAt: time.Now(),
+		UserID:     userID,
+		Chats:      []ai.ChatMemorySnapshot{},
+	}
+
+	for page := 1; ; page++ {
+		chats, total, err := s.chatRepo.GetUserChats(ctx, userID, page, exportChatPageSize)
+		if err != nil {
+			return nil, err
+		}
+		for i := range chats {
+			chatSnapshot, err := s.exportChat(ctx, &chats[i])
+			if err != nil {
+				return nil, err
+			}
+			snapshot.Chats = append(snapshot.Chats, *chatSnapshot)
+		}
+		if len(chats) == 0 || int64(page*exportChatPageSize) >= total {
+			break
+		}
+	}
+
+	profile, err := s.profileRepo.ListFacts(ctx, userID, 0)
+	if err != nil {
+		return nil, err
+	}
+	snapshot.Profile = profile
+	return snapshot, nil
+}
+
+// ImportChat 把快照导入会话
+func (s *MemoryServiceImpl) ImportChat(ctx context.Context, userID, chatID uint, snapshot *ai.MemorySnapshot, replace bool) error {
+	chat, err := s.ownedChat(ctx, userID, chatID)
+	if err != nil {
+		return err
+	}
+
+	// 只有一个会话时直接导入，否则使用与目标会话同一明星的记忆
+	var source *ai.ChatMemorySnapshot
+	if len(snapshot.Chats) == 1 {
+		source = &snapshot.Chats[0]
+	} else {
+		for i := range snapshot.Chats {
+			if snapshot.Chats[i].StarID == chat.StarID {
+				source = &snapshot.Chats[i]
+				break
+			}
+		}
+	}
+	if source == nil {
+		return errors.New("快照中没有该明星的会话记忆")
+	}
+
+	return s.importChat(ctx, chat, source, replace)
+}
+
+// ImportUser 把快照导入用户
+func (s *MemoryServiceImpl) ImportUser(ctx context.Context, userID uint, snapshot *ai.MemorySnapshot, replace bool) (int, error) {
+	for _, source := range snapshot.Chats {
+		if source.StarID == 0 {
+			return 0, errors.New("快照中的会话缺少明星信息，请导入到指定会话")
+		}
+	}
+
+	imported := 0
+	for i := range snapshot.Chats {
+		chat, err := s.userStarChat(ctx, userID, snapshot.Chats[i].StarID)
+		if err != nil {
+			return imported, err
+		}
+		if err := s.importChat(ctx, chat, &snapshot.Chats[i], replace); err != nil {
+			return imported, err
+		}
+		imported++
+	}
+
+	// 导入用户画像
+	if replace {
+		if err := s.profileRepo.DeleteFacts(ctx, userID); err != nil {
+			return imported, err
+		}
+	}
+	for _, fact := range snapshot.Profile {
+		if fact.Key == "" || fact.Content == "" {
+			continue
+		}
+		if err := s.profileRepo.UpsertFact(ctx, &models.ProfileFact{
+			UserID:       userID,
+			Key:          fact.Key,
+			Content:      fact.Content,
+			Weight:       fact.Weight,
+			SourceStarID: fact.SourceStarID,
+		}); err != nil {
+			return imported, err
+		}
+	}
+	return imported, nil
+}
+
+// exportChat 导出单个会话的记忆和摘要
+func (s *MemoryServiceImpl) exportChat(ctx context.Context, chat *models.Chat) (*ai.ChatMemorySnapshot, error) {
+	snapshot, err := ai.ExportChatMemory(ctx, s.memoryManager, chat.ID)
+	if err != nil {
+		return nil, err
+	}
+	snapshot.StarID = chat.StarID
+	snapshot.Summary = chat.Summary
+	snapshot.SummaryUntilID = chat.SummaryUntilID
+	return snapshot, nil
+}
+
+// importChat 导入单个会话的记忆和摘要
+func (s *MemoryServiceImpl) importChat(ctx context.Context, chat *models.Chat, source *ai.ChatMemorySnapshot, replace bool) error {
+	if err := ai.ImportChatMemory(ctx, s.memoryManager, chat.ID, source, replace); err != nil {
+		return err
+	}
+
+	// 合并导入时保留会话已有的摘要
+	if !replace && (chat.Summary != "" || source.Summary == "") {
+		return nil
+	}
+	// 摘要覆盖到的消息ID只在恢复到原会话时有效，导入其他会话后重新摘要该会话的消息
+	untilID := uint(0)
+	if source.ChatID == chat.ID {
+		untilID = source.SummaryUntilID
+	}
+	return s.chatRepo.UpdateSummary(ctx, chat.ID, source.Summary, untilID)
+}
+
+// ownedChat 获取属于该用户的会话
+func (s *MemoryServiceImpl) ownedChat(ctx context.Context, userID, chatID uint) (*models.Chat, error) {
+	chat, err := s.chatRepo.GetByID(ctx, chatID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("聊天会话不存在")
+		}
+		return nil, err
+	}
+	if chat.UserID != userID {
+		return nil, errors.New("无权访问此聊天会话的记忆")
+	}
+	return chat, nil
+}
+
+// userStarChat 获取用户与该明星的会话，不存在时创建
+func (s *MemoryServiceImpl) userStarChat(ctx context.Context, userID, starID uint) (*models.Chat, error) {
+	if chat, err := s.chatRepo.GetUserStarChat(ctx, userID, starID); err == nil && chat != nil {
+		return chat, nil
+	}
+
+	star, err := s.starRepo.GetByID(ctx, starID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("明星%d不存在", starID)
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	chat := &models.Chat{
+		UserID:     userID,
+		StarID:     starID,
+		Title:      fmt.Sprintf("与%s的聊天", star.Name),
+		LastActive: now,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := s.chatRepo.Create(ctx, chat); err != nil {
+		return nil, err
+	}
+	return chat, nil
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"chat_agent/internal/ai"
+	"chat_agent/internal/models"
+	"chat_agent/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// profilePromptLimit 提示词中最多使用的画像事实条数
+const profilePromptLimit = 20
+
+// ProfileService 用户画像服务接口：画像按用户保存，由各会话中提取的事实汇总而成，在与所有明星的会话中共享
+type ProfileService interface {
+	// 获取用户的画像和不共享画像的明星
+	GetProfile(ctx context.Context, userID uint) (*models.ProfileResponse, error)
+
+	// 删除一条画像事实
+	DeleteFact(ctx context.Context, userID, factID uint) error
+
+	// 设置是否与某位明星共享画像
+	SetSharing(ctx context.Context, userID, starID uint, enabled bool) error
+
+	// 获取提示词中使用的画像事实，用户选择不与该明星共享时返回空
+	FactsForStar(ctx context.Context, userID, starID uint) ([]string, error)
+
+	// 把在与某位明星的会话中提取的事实写入画像，用户选择不与该明星共享时忽略
+	RememberFacts(ctx context.Context, userID, starID uint, facts []ai.Fact) error
+}
+
+// ProfileServiceImpl 用户画像服务实现
+type ProfileServiceImpl struct {
+	profileRepo repository.ProfileRepository
+	starRepo    repository.StarRepository
+}
+
+// NewProfileService 创建新的用户画像服务
+func NewProfileService(profileRepo repository.ProfileRepository, starRepo repository.StarRepository) ProfileService {
+	return &ProfileServiceImpl{
+		profileRepo: profileRepo,
+		starRepo:    starRepo,
+	}
+}
+
+// GetProfile 获取用户的画像和不共享画像的明星
+func (s *ProfileServiceImpl) GetProfile(ctx context.Context, userID uint) (*models.ProfileResponse, error) {
+	facts, err := s.profileRepo.ListFacts(ctx, userID, 0)
+	if err != nil {
+		return nil, err
+	}
+	optOuts, err := s.profileRepo.ListOptOuts(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if facts == nil {
+		facts = []models.ProfileFact{}
+	}
+	return &models.ProfileResponse{Facts: facts, OptedOutStarIDs: optOuts}, nil
+}
+
+// DeleteFact 删除一条画像事实
+func (s *ProfileServiceImpl) DeleteFact(ctx context.Context, userID, factID uint) error {
+	deleted, err := s.profileRepo.DeleteFact(ctx, userID, factID)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return errors.New("画像事实不存在")
+	}
+	return nil
+}
+
+// SetSharing 设置是否与某位明星共享画像
+func (s *ProfileServiceImpl) SetSharing(ctx context.Context, userID, starID uint, enabled bool) error {
+	if _, err := s.starRepo.GetByID(ctx, starID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("明星不存在")
+		}
+		return err
+	}
+	return s.profileRepo.SetOptOut(ctx, userID, starID, !enabled)
+}
+
+// FactsForStar 获取提示词中使用的画像事实
+func (s *ProfileServiceImpl) FactsForStar(ctx context.Context, userID, starID uint) ([]string, error) {
+	optedOut, err := s.profileRepo.IsOptedOut(ctx, userID, starID)
+	if err != nil || optedOut {
+		return nil, err
+	}
+
+	facts, err := s.profileRepo.ListFacts(ctx, userID, profilePromptLimit)
+	if err != nil {
+		return nil, err
+	}
+	contents := make([]string, len(facts))
+	for i, fact := range facts {
+		contents[i] = fact.Content
+	}
+	return contents, nil
+}
+
+// RememberFacts 把在与某位明星的会话中提取的事实写入画像
+func (s *ProfileServiceImpl) RememberFacts(ctx context.Context, userID, starID uint, facts []ai.Fact) error {
+	if len(facts) == 0 {
+		return nil
+	}
+	optedOut, err := s.profileRepo.IsOptedOut(ctx, userID, starID)
+	if err != nil || optedOut {
+		return err
+	}
+
+	for _, fact := range facts {
+		if err := s.profileRepo.UpsertFact(ctx, &models.ProfileFact{
+			UserID:       userID,
+			Key:          fact.Key,
+			Content:      fact.Content,
+			Weight:       fact.Weight,
+			SourceStarID: starID,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
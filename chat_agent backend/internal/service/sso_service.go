@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"chat_agent/internal/auth"
+	"chat_agent/internal/models"
+	"chat_agent/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// ssoStateTTL 单点登录跳转状态的有效期
+const ssoStateTTL = 10 * time.Minute
+
+// usernameInvalidChars 用户名中不允许出现的字符
+var usernameInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// SSOService 单点登录服务接口
+type SSOService interface {
+	// 开始单点登录，返回身份提供方的授权地址和需要写入Cookie的状态令牌
+	BeginLogin(ctx context.Context) (authURL string, stateToken string, err error)
+
+	// 完成单点登录：校验状态、用授权码换取身份，创建或关联本地用户并签发令牌
+	CompleteLogin(ctx context.Context, stateToken, state, code string) (*models.AuthResponse, error)
+}
+
+// SSOServiceImpl 单点登录服务实现
+type SSOServiceImpl struct {
+	provider     *auth.OIDCProvider
+	tokenManager *auth.TokenManager
+	userRepo     repository.UserRepository
+	identityRepo repository.UserIdentityRepository
+	authService  AuthService
+}
+
+// NewSSOService 创建新的单点登录服务
+func NewSSOService(
+	provider *auth.OIDCProvider,
+	tokenManager *auth.TokenManager,
+	userRepo repository.UserRepository,
+	identityRepo repository.UserIdentityRepository,
+	authService AuthService,
+) SSOService {
+	return &SSOServiceImpl{
+		provider:     provider,
+		tokenManager: tokenManager,
+		userRepo:     userRepo,
+		identityRepo: identityRepo,
+		authService:  authService,
+	}
+}
+
+// BeginLogin 开始单点登录
+func (s *SSOServiceImpl) BeginLogin(ctx context.Context) (string, string, error) {
+	state, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, challenge, err := auth.GeneratePKCE()
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err := s.provider.AuthCodeURL(ctx, state, nonce, challenge)
+	if err != nil {
+		return "", "", err
+	}
+
+	stateToken, err := s.tokenManager.GenerateOIDCStateToken(auth.OIDCState{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+	}, ssoStateTTL)
+	if err != nil {
+		return "", "", err
+	}
+
+	return authURL, stateToken, nil
+}
+
+// CompleteLogin 完成单点登录
+func (s *SSOServiceImpl) CompleteLogin(ctx context.Context, stateToken, state, code string) (*models.AuthResponse, error) {
+	saved, err := s.tokenManager.ParseOIDCStateToken(stateToken)
+	if err != nil || saved.State != state {
+		return nil, errors.New("登录状态无效或已过期，请重新登录")
+	}
+
+	identity, err := s.provider.Exchange(ctx, code, saved.CodeVerifier, saved.Nonce)
+	if err != nil {
+		if errors.Is(err, auth.ErrOIDCLogin) {
+			return nil, errors.New("单点登录失败，请重新登录")
+		}
+		return nil, err
+	}
+
+	user, err := s.findOrCreateUser(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.authService.CreateSession(ctx, user)
+}
+
+// findOrCreateUser 按issuer+subject查找已绑定的用户；未绑定时按已验证的邮箱关联已有用户，否则创建新用户
+func (s *SSOServiceImpl) findOrCreateUser(ctx context.Context, identity *auth.OIDCIdentity) (*models.User, error) {
+	now := time.Now()
+
+	// 已绑定的外部身份
+	existing, err := s.identityRepo.GetByIssuerSubject(ctx, identity.Issuer, identity.Subject)
+	if err == nil {
+		existing.Email = identity.Email
+		existing.LastLoginAt = &now
+		if err := s.identityRepo.Update(ctx, existing); err != nil {
+			return nil, err
+		}
+		return s.userRepo.GetByID(ctx, existing.UserID)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	email := strings.ToLower(strings.TrimSpace(identity.Email))
+
+	// 只有身份提供方确认过的邮箱才能关联已有账号
+	var user *models.User
+	if email != "" && identity.EmailVerified {
+		found, err := s.userRepo.GetByEmail(ctx, email)
+		if err == nil && !found.IsGuest {
+			user = found
+		} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+
+	if user == nil {
+		user, err = s.createUser(ctx, identity, email)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	link := &models.UserIdentity{
+		UserID:      user.ID,
+		Issuer:      identity.Issuer,
+		Subject:     identity.Subject,
+		Email:       identity.Email,
+		LastLoginAt: &now,
+	}
+	if err := s.identityRepo.Create(ctx, link); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// createUser 为首次单点登录的用户创建本地账号（没有密码，只能通过单点登录或重置密码后登录）
+func (s *SSOServiceImpl) createUser(ctx context.Context, identity *auth.OIDCIdentity, email string) (*models.User, error) {
+	// 邮箱未验证或已被占用时使用占位邮箱，避免冒用他人账号
+	if email == "" || !identity.EmailVerified {
+		email = ""
+	} else if _, err := s.userRepo.GetByEmail(ctx, email); err == nil {
+		email = ""
+	}
+	if email == "" {
+		email = auth.HashToken(identity.Issuer + "|" + identity.Subject)[:24] + "@sso.invalid"
+	}
+
+	username, err := s.uniqueUsername(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	nickname := identity.Name
+	if nickname == "" {
+		nickname = username
+	}
+	if len([]rune(nickname)) > 50 {
+		nickname = string([]rune(nickname)[:50])
+	}
+
+	user := &models.User{
+		Username: username,
+		Email:    email,
+		Nickname: nickname,
+		Avatar:   identity.Picture,
+		IsActive: true,
+		Role:     models.RoleUser,
+	}
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// uniqueUsername 根据外部身份生成未被占用的用户名
+func (s *SSOServiceImpl) uniqueUsername(ctx context.Context, identity *auth.OIDCIdentity) (string, error) {
+	base := identity.Username
+	if base == "" {
+		base, _, _ = strings.Cut(identity.Email, "@")
+	}
+	base = usernameInvalidChars.ReplaceAllString(base, "")
+	if len(base) < 3 {
+		base = "sso_user"
+	}
+	if len(base) > 40 {
+		base = base[:40]
+	}
+
+	candidate := base
+	for attempt := 0; attempt < 5; attempt++ {
+		if _, err := s.userRepo.GetByUsername(ctx, candidate); errors.Is(err, gorm.ErrRecordNotFound) {
+			return candidate, nil
+		} else if err != nil {
+			return "", err
+		}
+
+		suffix, err := auth.GenerateOpaqueToken()
+		if err != nil {
+			return "", err
+		}
+		candidate = fmt.Sprintf("%s_%s", base, suffix[:6])
+	}
+	return "", errors.New("无法生成可用的用户名")
+}
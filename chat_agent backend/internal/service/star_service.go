@@ -26,10 +26,10 @@ type StarService interface {
 	// 搜索明星
 	SearchStars(ctx context.Context, keyword string) ([]models.StarResponse, error)
 
-	// 创建明星（管理员功能）
+	// 创建明星（编辑功能）
 	CreateStar(ctx context.Context, req *models.CreateStarRequest) (*models.StarResponse, error)
 
-	// 更新明星信息（管理员功能）
+	// 更新明星信息（编辑功能）
 	UpdateStar(ctx context.Context, starID uint, req *models.UpdateStarRequest) (*models.StarResponse, error)
 
 	// 删除明星（管理员功能）
@@ -130,8 +130,12 @@ func (s *StarServiceImpl) SearchStars(ctx context.Context, keyword string) ([]mo
 	return responses, nil
 }
 
-// CreateStar 创建明星（管理员功能）
+// CreateStar 创建明星（编辑功能）
 func (s *StarServiceImpl) CreateStar(ctx context.Context, req *models.CreateStarRequest) (*models.StarResponse, error) {
+	if err := ProviderGenerationLimits.Validate(req.GenerationParams); err != nil {
+		return nil, err
+	}
+
 	// 创建明星对象
 	star := &models.Star{
 		Name:          req.Name,
@@ -145,6 +149,11 @@ func (s *StarServiceImpl) CreateStar(ctx context.Context, req *models.CreateStar
 		Introduction:  req.Introduction,
 		StyleFeatures: req.StyleFeatures,
 		IsActive:      true, // 默认激活
+
+		FallbackModels: req.FallbackModels,
+		FallbackReply:  req.FallbackReply,
+
+		GenerationParams: req.GenerationParams,
 	}
 
 	// 保存到数据库
@@ -156,8 +165,12 @@ func (s *StarServiceImpl) CreateStar(ctx context.Context, req *models.CreateStar
 	return &response, nil
 }
 
-// UpdateStar 更新明星信息（管理员功能）
+// UpdateStar 更新明星信息（编辑功能）
 func (s *StarServiceImpl) UpdateStar(ctx context.Context, starID uint, req *models.UpdateStarRequest) (*models.StarResponse, error) {
+	if err := ProviderGenerationLimits.Validate(req.GenerationParams); err != nil {
+		return nil, err
+	}
+
 	// 获取现有明星
 	star, err := s.starRepo.GetByID(ctx, starID)
 	if err != nil {
@@ -201,6 +214,14 @@ func (s *StarServiceImpl) UpdateStar(ctx context.Context, starID uint, req *mode
 	if req.IsActive != nil {
 		star.IsActive = *req.IsActive
 	}
+	// 回退配置可以设置为空，恢复使用全局配置
+	if req.FallbackModels != nil {
+		star.FallbackModels = *req.FallbackModels
+	}
+	if req.FallbackReply != nil {
+		star.FallbackReply = *req.FallbackReply
+	}
+	star.GenerationParams = star.GenerationParams.Merge(req.GenerationParams)
 
 	// 保存更新
 	if err := s.starRepo.Update(ctx, star); err != nil {
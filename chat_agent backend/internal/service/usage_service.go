@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"chat_agent/internal/models"
+	"chat_agent/internal/repository"
+)
+
+// ErrQuotaExceeded token额度已用完
+var ErrQuotaExceeded = errors.New("token额度已用完")
+
+// 用量统计周期
+const (
+	UsagePeriodDay   = "day"
+	UsagePeriodMonth = "month"
+)
+
+// UsageService 用量与额度服务接口
+type UsageService interface {
+	// 检查用户是否还有可用额度，额度用完时返回ErrQuotaExceeded
+	CheckQuota(ctx context.Context, userID uint) error
+
+	// 记录一次模型调用的用量（按价格表估算费用）
+	RecordUsage(ctx context.Context, record *models.UsageRecord) error
+
+	// 获取用户在某一周期内的用量报告
+	GetUsageReport(ctx context.Context, userID uint, period string) (*models.UsageReport, error)
+}
+
+// UsageOptions 用量服务的可配置项
+type UsageOptions struct {
+	DailyTokenQuota   int64                        // 每日token额度，0表示不限制
+	MonthlyTokenQuota int64                        // 每月token额度，0表示不限制
+	Prices            map[string]models.ModelPrice // 模型单价表（每千token），支持"前缀*"，"*"为默认价格
+	Currency          string
+}
+
+// UsageServiceImpl 用量与额度服务实现
+type UsageServiceImpl struct {
+	usageRepo repository.UsageRepository
+	options   UsageOptions
+}
+
+// NewUsageService 创建新的用量服务
+func NewUsageService(usageRepo repository.UsageRepository, options UsageOptions) UsageService {
+	return &UsageServiceImpl{
+		usageRepo: usageRepo,
+		options:   options,
+	}
+}
+
+// CheckQuota 检查用户是否还有可用额度
+func (s *UsageServiceImpl) CheckQuota(ctx context.Context, userID uint) error {
+	now := time.Now()
+
+	if s.options.DailyTokenQuota > 0 {
+		used, err := s.usageRepo.SumUserTokens(ctx, userID, startOfDay(now))
+		if err != nil {
+			return err
+		}
+		if used >= s.options.DailyTokenQuota {
+			return fmt.Errorf("%w: 今日已使用%d个token，额度为%d", ErrQuotaExceeded, used, s.options.DailyTokenQuota)
+		}
+	}
+
+	if s.options.MonthlyTokenQuota > 0 {
+		used, err := s.usageRepo.SumUserTokens(ctx, userID, startOfMonth(now))
+		if err != nil {
+			return err
+		}
+		if used >= s.options.MonthlyTokenQuota {
+			return fmt.Errorf("%w: 本月已使用%d个token，额度为%d", ErrQuotaExceeded, used, s.options.MonthlyTokenQuota)
+		}
+	}
+
+	return nil
+}
+
+// RecordUsage 记录一次模型调用的用量
+func (s *UsageServiceImpl) RecordUsage(ctx context.Context, record *models.UsageRecord) error {
+	if record.TotalTokens == 0 {
+		record.TotalTokens = record.PromptTokens + record.CompletionTokens
+	}
+	record.EstimatedCost = s.estimateCost(record.Model, record.PromptTokens, record.CompletionTokens)
+	return s.usageRepo.Create(ctx, record)
+}
+
+// GetUsageReport 获取用户在某一周期内的用量报告
+func (s *UsageServiceImpl) GetUsageReport(ctx context.Context, userID uint, period string) (*models.UsageReport, error) {
+	now := time.Now()
+
+	var since time.Time
+	switch period {
+	case UsagePeriodDay:
+		since = startOfDay(now)
+	case UsagePeriodMonth, "":
+		period = UsagePeriodMonth
+		since = startOfMonth(now)
+	default:
+		return nil, errors.New("无效的统计周期，只支持day或month")
+	}
+
+	report := &models.UsageReport{
+		Period:   period,
+		Since:    since,
+		Currency: s.options.Currency,
+	}
+
+	var err error
+	if report.ByModel, err = s.usageRepo.Rollup(ctx, userID, since, repository.UsageGroupByModel); err != nil {
+		return nil, err
+	}
+	if report.ByStar, err = s.usageRepo.Rollup(ctx, userID, since, repository.UsageGroupByStar); err != nil {
+		return nil, err
+	}
+	if report.ByChat, err = s.usageRepo.Rollup(ctx, userID, since, repository.UsageGroupByChat); err != nil {
+		return nil, err
+	}
+
+	// 汇总总量
+	for _, rollup := range report.ByModel {
+		report.PromptTokens += rollup.PromptTokens
+		report.CompletionTokens += rollup.CompletionTokens
+		report.TotalTokens += rollup.TotalTokens
+		report.Requests += rollup.Requests
+		report.EstimatedCost += rollup.EstimatedCost
+	}
+
+	// 额度使用情况
+	if report.DailyQuota, err = s.quotaStatus(ctx, userID, startOfDay(now), s.options.DailyTokenQuota); err != nil {
+		return nil, err
+	}
+	if report.MonthlyQuota, err = s.quotaStatus(ctx, userID, startOfMonth(now), s.options.MonthlyTokenQuota); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// quotaStatus 计算额度使用情况
+func (s *UsageServiceImpl) quotaStatus(ctx context.Context, userID uint, since time.Time, limit int64) (models.QuotaStatus, error) {
+	used, err := s.usageRepo.SumUserTokens(ctx, userID, since)
+	if err != nil {
+		return models.QuotaStatus{}, err
+	}
+
+	status := models.QuotaStatus{Limit: limit, Used: used}
+	if limit > 0 {
+		status.Remaining = max(limit-used, 0)
+	}
+	return status, nil
+}
+
+// estimateCost 按价格表估算费用，模型依次按完整名称、"前缀*"中最长的前缀匹配，
+// "提供方/模型"格式的名称再按去掉提供方的名称匹配，都未配置时使用"*"默认价格
+func (s *UsageServiceImpl) estimateCost(model string, promptTokens, completionTokens int) float64 {
+	price, ok := s.priceFor(model)
+	if !ok {
+		if _, upstream, found := strings.Cut(model, "/"); found {
+			price, ok = s.priceFor(upstream)
+		}
+	}
+	if !ok {
+		price, ok = s.options.Prices["*"]
+		if !ok {
+			return 0
+		}
+	}
+	return float64(promptTokens)/1000*price.Prompt + float64(completionTokens)/1000*price.Completion
+}
+
+// priceFor 按完整名称或最长的前缀查找单价，不使用"*"默认价格
+func (s *UsageServiceImpl) priceFor(model string) (models.ModelPrice, bool) {
+	if price, ok := s.options.Prices[model]; ok {
+		return price, true
+	}
+	var best models.ModelPrice
+	bestLen := 0
+	for pattern, price := range s.options.Prices {
+		prefix, isPrefix := strings.CutSuffix(pattern, "*")
+		if isPrefix && prefix != "" && strings.HasPrefix(model, prefix) && len(prefix) > bestLen {
+			best, bestLen = price, len(prefix)
+		}
+	}
+	return best, bestLen > 0
+}
+
+// startOfDay 获取当天零点
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+// startOfMonth 获取当月第一天零点
+func startOfMonth(t time.Time) time.Time {
+	year, month, _ := t.Date()
+	return time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+}
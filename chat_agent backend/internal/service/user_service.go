@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"chat_agent/internal/models"
+	"chat_agent/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// UserService 用户管理服务接口
+type UserService interface {
+	// 修改用户角色（管理员功能）
+	UpdateUserRole(ctx context.Context, operatorID, userID uint, role string) (*models.UserResponse, error)
+}
+
+// UserServiceImpl 用户管理服务实现
+type UserServiceImpl struct {
+	userRepo         repository.UserRepository
+	refreshTokenRepo repository.RefreshTokenRepository
+}
+
+// NewUserService 创建新的用户管理服务
+func NewUserService(userRepo repository.UserRepository, refreshTokenRepo repository.RefreshTokenRepository) UserService {
+	return &UserServiceImpl{
+		userRepo:         userRepo,
+		refreshTokenRepo: refreshTokenRepo,
+	}
+}
+
+// UpdateUserRole 修改用户角色（管理员功能）
+func (s *UserServiceImpl) UpdateUserRole(ctx context.Context, operatorID, userID uint, role string) (*models.UserResponse, error) {
+	if !models.IsValidRole(role) {
+		return nil, errors.New("无效的角色")
+	}
+
+	// 避免管理员误操作把自己降级导致系统没有管理员
+	if operatorID == userID && role != models.RoleAdmin {
+		return nil, errors.New("不能降低自己的角色")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("用户不存在")
+		}
+		return nil, err
+	}
+
+	if user.Role != role {
+		user.Role = role
+		if err := s.userRepo.Update(ctx, user); err != nil {
+			return nil, err
+		}
+
+		// 角色变更后吊销已签发的刷新令牌，用户重新登录后按新角色签发令牌
+		if err := s.refreshTokenRepo.RevokeAllForUser(ctx, user.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	response := user.ToUserResponse()
+	return &response, nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"chat_agent/internal/auth"
+	"chat_agent/internal/models"
+	"chat_agent/internal/service"
+	"chat_agent/test/internal/memrepo"
+	"chat_agent/test/internal/testutil"
+)
+
+// 验证API密钥的创建、哈希存储、权限范围、吊销和认证，不需要数据库：
+//
+//	go run ./test/api_key
+func main() {
+	// 创建上下文
+	ctx := context.Background()
+	userRepo := memrepo.NewUserRepository()
+	apiKeyRepo := memrepo.NewAPIKeyRepository()
+	apiKeyService := service.NewAPIKeyService(apiKeyRepo, userRepo)
+
+	user := &models.User{Username: "xiaoming", Email: "xiaoming@example.com", Role: models.RoleUser, IsActive: true}
+	editor := &models.User{Username: "editor", Email: "editor@example.com", Role: models.RoleEditor, IsActive: true}
+	userRepo.Create(ctx, user)
+	userRepo.Create(ctx, editor)
+
+	// 明文密钥只在创建时返回，仓库中只保存哈希
+	created, err := apiKeyService.CreateKey(ctx, user.ID, &models.CreateAPIKeyRequest{Name: "批处理", Scopes: []string{" chat:write ", models.ScopeChatWrite}, ExpiresInDays: 30})
+	testutil.Check("创建API密钥", err == nil && strings.HasPrefix(created.Key, "ca_"), err)
+	if err != nil {
+		testutil.Finish()
+	}
+	stored, _ := apiKeyRepo.GetByID(ctx, created.APIKey.ID)
+	testutil.Check("只保存密钥的哈希", stored.KeyHash == auth.HashToken(created.Key) && !strings.Contains(stored.KeyHash, created.Key), stored.KeyHash)
+	testutil.Check("保存便于识别的前缀", stored.Prefix == created.Key[:len(stored.Prefix)] && len(stored.Prefix) < len(created.Key), stored.Prefix)
+	testutil.Check("权限范围去重并去掉空白", stored.Scopes == models.ScopeChatWrite, stored.Scopes)
+	testutil.Check("设置过期时间", stored.ExpiresAt != nil && time.Until(*stored.ExpiresAt) > 29*24*time.Hour, stored.ExpiresAt)
+	again, _ := apiKeyService.CreateKey(ctx, user.ID, &models.CreateAPIKeyRequest{Name: "另一个", Scopes: []string{models.ScopeChatRead}})
+	testutil.Check("每次生成不同的密钥", again.Key != created.Key && again.APIKey.ExpiresAt == nil)
+
+	// 权限范围按角色限制
+	_, err = apiKeyService.CreateKey(ctx, user.ID, &models.CreateAPIKeyRequest{Name: "无效", Scopes: []string{"chat:delete"}})
+	testutil.Check("拒绝无效的权限范围", err != nil && strings.Contains(err.Error(), "chat:delete"), err)
+	_, err = apiKeyService.CreateKey(ctx, user.ID, &models.CreateAPIKeyRequest{Name: "越权", Scopes: []string{models.ScopeStarsWrite}})
+	testutil.Check("普通用户不能申请stars:write", err != nil, err)
+	_, err = apiKeyService.CreateKey(ctx, editor.ID, &models.CreateAPIKeyRequest{Name: "编辑", Scopes: []string{models.ScopeStarsWrite}})
+	testutil.Check("编辑可以申请stars:write", err == nil, err)
+	_, err = apiKeyService.CreateKey(ctx, editor.ID, &models.CreateAPIKeyRequest{Name: "越权", Scopes: []string{models.ScopeStarsAdmin}})
+	testutil.Check("编辑不能申请stars:admin", err != nil, err)
+
+	// 高级权限包含低级权限
+	testutil.Check("chat:write包含chat:read", models.HasScope([]string{models.ScopeChatWrite}, models.ScopeChatRead))
+	testutil.Check("stars:admin包含stars:write", models.HasScope([]string{models.ScopeStarsAdmin}, models.ScopeStarsWrite))
+	testutil.Check("chat:read不包含chat:write", !models.HasScope([]string{models.ScopeChatRead}, models.ScopeChatWrite))
+	testutil.Check("权限范围之间互不包含", !models.HasScope([]string{models.ScopeStarsAdmin}, models.ScopeChatRead))
+
+	// 认证解析出用户、角色和权限范围，并记录最后使用时间
+	principal, err := apiKeyService.AuthenticateAPIKey(ctx, created.Key)
+	testutil.Check("认证API密钥", err == nil && principal.UserID == user.ID && principal.Role == models.RoleUser &&
+		principal.Method == auth.MethodAPIKey && principal.APIKeyID == created.APIKey.ID && len(principal.Scopes) == 1, principal, err)
+	stored, _ = apiKeyRepo.GetByID(ctx, created.APIKey.ID)
+	testutil.Check("记录最后使用时间", stored.LastUsedAt != nil)
+	_, err = apiKeyService.AuthenticateAPIKey(ctx, "ca_unknown")
+	testutil.Check("拒绝不存在的密钥", errors.Is(err, auth.ErrInvalidToken), err)
+	_, err = apiKeyService.AuthenticateAPIKey(ctx, strings.TrimPrefix(created.Key, "ca_"))
+	testutil.Check("拒绝没有前缀的密钥", errors.Is(err, auth.ErrInvalidToken), err)
+
+	// 调整角色后密钥使用新的角色，禁用账号后密钥失效
+	user.Role = models.RoleEditor
+	userRepo.Update(ctx, user)
+	principal, _ = apiKeyService.AuthenticateAPIKey(ctx, created.Key)
+	testutil.Check("使用用户当前的角色", principal != nil && principal.Role == models.RoleEditor, principal)
+	user.IsActive = false
+	userRepo.Update(ctx, user)
+	_, err = apiKeyService.AuthenticateAPIKey(ctx, created.Key)
+	testutil.Check("禁用账号后密钥失效", errors.Is(err, auth.ErrInvalidToken), err)
+	user.IsActive = true
+	userRepo.Update(ctx, user)
+
+	// 过期和吊销的密钥不可用
+	past := time.Now().Add(-time.Minute)
+	testutil.Check("过期的密钥无效", !(&models.APIKey{ExpiresAt: &past}).IsValid(time.Now()))
+	keys, _ := apiKeyService.ListKeys(ctx, user.ID)
+	testutil.Check("列出用户的密钥", len(keys) == 2 && keys[0].ID == again.APIKey.ID, keys)
+	err = apiKeyService.RevokeKey(ctx, editor.ID, created.APIKey.ID)
+	testutil.Check("不能吊销其他用户的密钥", err != nil, err)
+	err = apiKeyService.RevokeKey(ctx, user.ID, created.APIKey.ID)
+	testutil.Check("吊销密钥", err == nil, err)
+	_, err = apiKeyService.AuthenticateAPIKey(ctx, created.Key)
+	testutil.Check("吊销后密钥失效", errors.Is(err, auth.ErrInvalidToken), err)
+	keys, _ = apiKeyService.ListKeys(ctx, user.ID)
+	testutil.Check("列表中显示吊销时间", len(keys) == 2 && keys[1].RevokedAt != nil, keys)
+
+	testutil.Finish()
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"chat_agent/internal/auth"
+	"chat_agent/internal/models"
+	"chat_agent/internal/notify"
+	"chat_agent/internal/service"
+	"chat_agent/test/internal/memrepo"
+	"chat_agent/test/internal/testutil"
+)
+
+// captureNotifier 记录发送的通知，用于取出重置链接中的令牌
+type captureNotifier struct {
+	messages []notify.Message
+}
+
+// Send 实现Notifier接口
+func (n *captureNotifier) Send(ctx context.Context, msg notify.Message) error {
+	n.messages = append(n.messages, msg)
+	return nil
+}
+
+// resetToken 从最后一条通知的重置链接中取出令牌
+func (n *captureNotifier) resetToken() string {
+	if len(n.messages) == 0 {
+		return ""
+	}
+	for _, line := range strings.Split(n.messages[len(n.messages)-1].Body, "\n") {
+		if link, err := url.Parse(line); err == nil && link.Query().Get("token") != "" {
+			return link.Query().Get("token")
+		}
+	}
+	return ""
+}
+
+// 验证注册、登录、刷新、退出和重置密码的流程，不需要数据库：
+//
+//	go run ./test/auth
+func main() {
+	// 创建上下文
+	ctx := context.Background()
+	userRepo := memrepo.NewUserRepository()
+	refreshRepo := memrepo.NewRefreshTokenRepository()
+	notifier := &captureNotifier{}
+	tokenManager := auth.NewTokenManager("test-secret", 15*time.Minute)
+	authService := service.NewAuthService(userRepo, refreshRepo, memrepo.NewPasswordResetTokenRepository(), tokenManager, notifier, service.AuthOptions{
+		RefreshTTL:       time.Hour,
+		PasswordResetTTL: time.Hour,
+		PasswordResetURL: "https://example.com/reset",
+		MaxLoginAttempts: 3,
+		LockoutDuration:  time.Minute,
+	})
+
+	// 注册后直接签发令牌对
+	registered, err := authService.Register(ctx, &models.RegisterRequest{Username: "xiaoming", Email: "XiaoMing@Example.com", Password: "password123"})
+	testutil.Check("注册并签发令牌", err == nil && registered.AccessToken != "" && registered.RefreshToken != "", err)
+	if err != nil {
+		testutil.Finish()
+	}
+	claims, err := tokenManager.ParseAccessToken(registered.AccessToken)
+	testutil.Check("访问令牌包含用户和角色", err == nil && claims.UserID == registered.User.ID && claims.Role == models.RoleUser, claims, err)
+	_, err = authService.Register(ctx, &models.RegisterRequest{Username: "xiaoming", Email: "other@example.com", Password: "password123"})
+	testutil.Check("拒绝重复的用户名", err != nil && strings.Contains(err.Error(), "用户名已存在"), err)
+	_, err = authService.Register(ctx, &models.RegisterRequest{Username: "other", Email: "xiaoming@example.com", Password: "password123"})
+	testutil.Check("邮箱不区分大小写查重", err != nil && strings.Contains(err.Error(), "邮箱已被注册"), err)
+
+	// 用户名或邮箱都可以登录
+	_, err = authService.Login(ctx, &models.LoginRequest{Username: "xiaoming", Password: "password123"})
+	testutil.Check("用户名登录", err == nil, err)
+	loggedIn, err := authService.Login(ctx, &models.LoginRequest{Username: "XiaoMing@example.com", Password: "password123"})
+	testutil.Check("邮箱登录", err == nil, err)
+	_, err = authService.Login(ctx, &models.LoginRequest{Username: "xiaoming", Password: "wrong1234"})
+	testutil.Check("拒绝错误的密码", err != nil && err.Error() == "用户名或密码错误", err)
+
+	// 用户不存在时同样执行一次密码校验，登录耗时不泄露用户名是否存在
+	start := time.Now()
+	authService.Login(ctx, &models.LoginRequest{Username: "xiaoming", Password: "wrong5678"})
+	wrongPassword := time.Since(start)
+	start = time.Now()
+	_, err = authService.Login(ctx, &models.LoginRequest{Username: "nobody", Password: "wrong5678"})
+	unknownUser := time.Since(start)
+	testutil.Check("不存在的用户登录耗时与密码错误相当", err != nil && err.Error() == "用户名或密码错误" && unknownUser > wrongPassword/2, unknownUser, wrongPassword)
+
+	// 刷新令牌换取新的令牌对，旧令牌只能使用一次
+	refreshed, err := authService.Refresh(ctx, loggedIn.RefreshToken)
+	testutil.Check("刷新令牌换取新的令牌对", err == nil && refreshed.RefreshToken != loggedIn.RefreshToken, err)
+	_, err = authService.Refresh(ctx, loggedIn.RefreshToken)
+	testutil.Check("拒绝重复使用刷新令牌", err != nil, err)
+	_, err = authService.Refresh(ctx, "unknown-token")
+	testutil.Check("拒绝不存在的刷新令牌", err != nil, err)
+
+	// 并发使用同一个刷新令牌时只有一个请求成功
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := authService.Refresh(ctx, refreshed.RefreshToken); err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	testutil.Check("并发刷新只有一个请求成功", succeeded == 1, succeeded)
+
+	// 退出登录吊销刷新令牌，重复退出不报错
+	session, _ := authService.Login(ctx, &models.LoginRequest{Username: "xiaoming", Password: "password123"})
+	err = authService.Logout(ctx, session.RefreshToken)
+	testutil.Check("退出登录", err == nil, err)
+	_, err = authService.Refresh(ctx, session.RefreshToken)
+	testutil.Check("退出后刷新令牌失效", err != nil)
+	testutil.Check("重复退出不报错", authService.Logout(ctx, session.RefreshToken) == nil && authService.Logout(ctx, "unknown-token") == nil)
+
+	// 重置密码：令牌通过通知器发送，使用后新密码生效、旧会话全部失效
+	session, _ = authService.Login(ctx, &models.LoginRequest{Username: "xiaoming", Password: "password123"})
+	testutil.Check("未注册的邮箱不发送通知", authService.RequestPasswordReset(ctx, "nobody@example.com") == nil && len(notifier.messages) == 0)
+	authService.RequestPasswordReset(ctx, "xiaoming@example.com")
+	first := notifier.resetToken()
+	err = authService.RequestPasswordReset(ctx, "xiaoming@example.com")
+	token := notifier.resetToken()
+	testutil.Check("重置链接通过通知器发送", err == nil && token != "" && notifier.messages[1].To == "xiaoming@example.com", notifier.messages)
+	err = authService.ResetPassword(ctx, &models.ResetPasswordRequest{Token: first, NewPassword: "newpassword456"})
+	testutil.Check("新的申请使之前的令牌作废", err != nil, err)
+	err = authService.ResetPassword(ctx, &models.ResetPasswordRequest{Token: token, NewPassword: "short"})
+	testutil.Check("新密码同样校验密码策略", err != nil, err)
+	err = authService.ResetPassword(ctx, &models.ResetPasswordRequest{Token: token, NewPassword: "newpassword456"})
+	testutil.Check("使用重置令牌设置新密码", err == nil, err)
+	err = authService.ResetPassword(ctx, &models.ResetPasswordRequest{Token: token, NewPassword: "another789"})
+	testutil.Check("重置令牌只能使用一次", err != nil, err)
+	_, err = authService.Refresh(ctx, session.RefreshToken)
+	testutil.Check("重置密码吊销已签发的刷新令牌", err != nil && refreshRepo.Active(registered.User.ID) == 0, err)
+	_, err = authService.Login(ctx, &models.LoginRequest{Username: "xiaoming", Password: "password123"})
+	testutil.Check("旧密码不能再登录", err != nil)
+	relogged, err := authService.Login(ctx, &models.LoginRequest{Username: "xiaoming", Password: "newpassword456"})
+	testutil.Check("新密码可以登录", err == nil, err)
+	_, err = authService.AuthenticateSession(ctx, registered.User.ID, time.Now().Add(-time.Minute))
+	testutil.Check("重置密码前签发的访问令牌失效", errors.Is(err, auth.ErrInvalidToken), err)
+	if relogged != nil {
+		claims, _ = tokenManager.ParseAccessToken(relogged.AccessToken)
+		_, err = authService.AuthenticateSession(ctx, registered.User.ID, claims.IssuedAt.Time)
+		testutil.Check("重置密码后签发的访问令牌有效", err == nil, err)
+	}
+
+	// 并发使用同一个重置令牌时只有一个请求成功
+	authService.RequestPasswordReset(ctx, "xiaoming@example.com")
+	token = notifier.resetToken()
+	succeeded = 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := authService.ResetPassword(ctx, &models.ResetPasswordRequest{Token: token, NewPassword: fmt.Sprintf("concurrent%d", i)})
+			if err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	testutil.Check("并发重置密码只有一个请求成功", succeeded == 1, succeeded)
+
+	testutil.Finish()
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"chat_agent/internal/api"
+	"chat_agent/internal/auth"
+	"chat_agent/internal/middleware"
+	"chat_agent/internal/models"
+	"chat_agent/internal/service"
+	"chat_agent/test/internal/memrepo"
+	"chat_agent/test/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// caller 发起请求的调用方，三种凭证最多设置一种
+type caller struct {
+	name        string
+	accessToken string
+	apiKey      string
+	guestToken  string
+}
+
+// request 以调用方的身份请求路由，返回响应中的业务状态码
+func request(engine *gin.Engine, path string, who caller) int {
+	return send(engine, http.MethodGet, path, "", who)
+}
+
+// send 以调用方的身份发送带JSON请求体的请求，返回响应中的业务状态码
+func send(engine *gin.Engine, method, path, body string, who caller) int {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if who.accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+who.accessToken)
+	}
+	if who.apiKey != "" {
+		req.Header.Set(middleware.APIKeyHeader, who.apiKey)
+	}
+	if who.guestToken != "" {
+		req.AddCookie(&http.Cookie{Name: middleware.GuestCookieName, Value: who.guestToken})
+	}
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, req)
+
+	var response api.Response
+	json.Unmarshal(recorder.Body.Bytes(), &response)
+	return response.Code
+}
+
+// 验证认证中间件和Authorize(Policy)对每种角色、权限范围、SessionOnly和AllowGuest组合的授权结果，不需要数据库：
+//
+//	go run ./test/authorization
+func main() {
+	// 创建上下文
+	ctx := context.Background()
+	userRepo := memrepo.NewUserRepository()
+	refreshRepo := memrepo.NewRefreshTokenRepository()
+	tokenManager := auth.NewTokenManager("test-secret", 15*time.Minute)
+	authService := service.NewAuthService(userRepo, refreshRepo, memrepo.NewPasswordResetTokenRepository(), tokenManager, nil, service.AuthOptions{RefreshTTL: time.Hour})
+	apiKeyService := service.NewAPIKeyService(memrepo.NewAPIKeyRepository(), userRepo)
+	userService := service.NewUserService(userRepo, refreshRepo)
+
+	// 每种角色各一个用户，外加一个游客
+	passwordHash, _ := auth.HashPassword("password123")
+	users := map[string]*models.User{}
+	for _, role := range []string{models.RoleUser, models.RoleEditor, models.RoleAdmin} {
+		user := &models.User{Username: role, Email: role + "@example.com", Password: passwordHash, Role: role, IsActive: true}
+		userRepo.Create(ctx, user)
+		users[role] = user
+	}
+	guestExpiresAt := time.Now().Add(time.Hour)
+	guest := &models.User{Username: "guest", Role: models.RoleUser, IsActive: true, IsGuest: true, GuestExpiresAt: &guestExpiresAt}
+	userRepo.Create(ctx, guest)
+
+	session := func(role string) caller {
+		token, _, _ := tokenManager.GenerateAccessToken(users[role].ID, role)
+		return caller{name: role + "会话", accessToken: token}
+	}
+	apiKey := func(role string, scopes ...string) caller {
+		created, err := apiKeyService.CreateKey(ctx, users[role].ID, &models.CreateAPIKeyRequest{Name: "test", Scopes: scopes})
+		if err != nil {
+			panic(err)
+		}
+		return caller{name: role + "密钥(" + strings.Join(scopes, ",") + ")", apiKey: created.Key}
+	}
+	guestToken, _ := tokenManager.GenerateGuestToken(guest.ID, guestExpiresAt)
+
+	gin.SetMode(gin.ReleaseMode)
+	engine := gin.New()
+	authMiddleware := middleware.AuthMiddleware(tokenManager, apiKeyService, authService)
+	ok := func(c *gin.Context) { api.Success(c, nil) }
+	routes := map[string]api.Policy{
+		"/chat/read":     api.PolicyChatRead,
+		"/chat/write":    api.PolicyChatWrite,
+		"/editor":        api.PolicyEditor,
+		"/admin":         api.PolicyAdmin,
+		"/admin/session": api.PolicyAdminSession,
+		"/session":       api.PolicySession,
+	}
+	for path, policy := range routes {
+		engine.GET(path, authMiddleware, api.Authorize(policy), ok)
+	}
+
+	// 每个调用方可以访问的路由，其他路由返回403
+	matrix := []struct {
+		who     caller
+		allowed []string
+	}{
+		{session(models.RoleUser), []string{"/chat/read", "/chat/write", "/session"}},
+		{session(models.RoleEditor), []string{"/chat/read", "/chat/write", "/editor", "/session"}},
+		{session(models.RoleAdmin), []string{"/chat/read", "/chat/write", "/editor", "/admin", "/admin/session", "/session"}},
+		{apiKey(models.RoleUser, models.ScopeChatRead), []string{"/chat/read"}},
+		{apiKey(models.RoleUser, models.ScopeChatWrite), []string{"/chat/read", "/chat/write"}},
+		{apiKey(models.RoleEditor, models.ScopeStarsWrite), []string{"/editor"}},
+		{apiKey(models.RoleAdmin, models.ScopeStarsAdmin), []string{"/editor", "/admin"}},
+		{apiKey(models.RoleAdmin, models.ScopeChatWrite, models.ScopeStarsAdmin), []string{"/chat/read", "/chat/write", "/editor", "/admin"}},
+		{caller{name: "游客", guestToken: guestToken}, []string{"/chat/read", "/chat/write"}},
+	}
+	for _, entry := range matrix {
+		allowed := map[string]bool{}
+		for _, path := range entry.allowed {
+			allowed[path] = true
+		}
+		for path := range routes {
+			expected := 403
+			if allowed[path] {
+				expected = 200
+			}
+			code := request(engine, path, entry.who)
+			testutil.Check(entry.who.name+" "+path, code == expected, code, expected)
+		}
+	}
+
+	// 没有凭证或凭证无效时返回401
+	testutil.Check("缺少凭证", request(engine, "/chat/read", caller{}) == 401)
+	testutil.Check("无效的访问令牌", request(engine, "/chat/read", caller{accessToken: "invalid"}) == 401)
+	testutil.Check("无效的API密钥", request(engine, "/chat/read", caller{apiKey: "sk-invalid"}) == 401)
+	testutil.Check("无效的游客Cookie", request(engine, "/chat/read", caller{guestToken: "invalid"}) == 401)
+	guestAsSession, _, _ := tokenManager.GenerateAccessToken(guest.ID, models.RoleUser)
+	testutil.Check("游客不能使用访问令牌", request(engine, "/chat/read", caller{accessToken: guestAsSession}) == 401)
+
+	// 编辑可以修改明星资料，但上下线只允许管理员操作
+	starRepo := memrepo.NewStarRepository()
+	starRepo.Create(ctx, &models.Star{Name: "测试明星", IsActive: true})
+	api.NewStarHandler(service.NewStarService(starRepo)).RegisterRoutes(engine.Group("/api"), authMiddleware, api.RateLimits{})
+	testutil.Check("编辑可以修改明星资料", send(engine, http.MethodPut, "/api/stars/1", `{"introduction":"新的简介"}`, session(models.RoleEditor)) == 200)
+	testutil.Check("编辑不能通过更新接口下线明星", send(engine, http.MethodPut, "/api/stars/1", `{"is_active":false}`, session(models.RoleEditor)) == 403)
+	testutil.Check("编辑密钥不能通过更新接口下线明星", send(engine, http.MethodPut, "/api/stars/1", `{"is_active":false}`, apiKey(models.RoleAdmin, models.ScopeStarsWrite)) == 403)
+	star, _ := starRepo.GetByID(ctx, 1)
+	testutil.Check("明星仍然在线", star.IsActive && star.Introduction == "新的简介", star)
+	testutil.Check("管理员可以通过更新接口下线明星", send(engine, http.MethodPut, "/api/stars/1", `{"is_active":false}`, session(models.RoleAdmin)) == 200)
+	star, _ = starRepo.GetByID(ctx, 1)
+	testutil.Check("明星已下线", !star.IsActive, star)
+
+	// 角色调整对已签发的访问令牌立即生效，并吊销刷新令牌
+	editor := session(models.RoleEditor)
+	login, _ := authService.Login(ctx, &models.LoginRequest{Username: models.RoleEditor, Password: "password123"})
+	_, err := userService.UpdateUserRole(ctx, users[models.RoleAdmin].ID, users[models.RoleEditor].ID, models.RoleUser)
+	testutil.Check("降级编辑", err == nil, err)
+	testutil.Check("降级后旧的访问令牌失去编辑权限", request(engine, "/editor", editor) == 403)
+	testutil.Check("降级后仍可访问普通路由", request(engine, "/chat/read", editor) == 200)
+	_, err = authService.Refresh(ctx, login.RefreshToken)
+	testutil.Check("角色调整吊销刷新令牌", err != nil && refreshRepo.Active(users[models.RoleEditor].ID) == 0, err)
+
+	user := session(models.RoleUser)
+	userService.UpdateUserRole(ctx, users[models.RoleAdmin].ID, users[models.RoleUser].ID, models.RoleEditor)
+	testutil.Check("升级后旧的访问令牌获得编辑权限", request(engine, "/editor", user) == 200)
+
+	// 禁用账号后访问令牌立即失效
+	disabled, _ := userRepo.GetByID(ctx, users[models.RoleUser].ID)
+	disabled.IsActive = false
+	userRepo.Update(ctx, disabled)
+	testutil.Check("禁用账号后访问令牌失效", request(engine, "/chat/read", user) == 401)
+
+	testutil.Finish()
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"chat_agent/internal/ai"
+	"chat_agent/internal/models"
+	"chat_agent/test/internal/testutil"
+)
+
+// 验证滚动摘要的生成和注入，不需要网络：
+//
+//	go run ./test/chat_summary
+func main() {
+	// 创建上下文
+	ctx := context.Background()
+	star := &models.Star{Name: "测试明星"}
+
+	// 已有摘要和新的对话记录一起交给模型合并
+	llmClient := testutil.FakeLLM("  用户叫小明，和测试明星约好下周一起听新歌。  ")
+	summarizer := ai.NewSummarizer(llmClient, "", 20)
+	messages := []models.Message{
+		{SenderType: models.SenderTypeUser, Content: "下周的新歌我一定第一个听"},
+		{SenderType: models.SenderTypeStar, Content: "好呀，到时候告诉我你的感受"},
+	}
+	summary, err := summarizer.Summarize(ctx, star, "用户叫小明。", messages)
+	testutil.Check("返回去掉空白的摘要", err == nil && summary == "用户叫小明，和测试明星约好下周一起听新歌。", summary, err)
+
+	request := ""
+	if calls := llmClient.Calls(); len(calls) == 1 && len(calls[0].Messages) == 2 {
+		request = calls[0].Messages[1]["content"]
+	}
+	testutil.Check("请求包含已有摘要", strings.Contains(request, "已有摘要：\n用户叫小明。"), request)
+	testutil.Check("请求按发送者标注对话", strings.Contains(request, "用户: 下周的新歌我一定第一个听") &&
+		strings.Contains(request, "测试明星: 好呀，到时候告诉我你的感受"), request)
+
+	// 没有新消息时不调用模型
+	summary, err = summarizer.Summarize(ctx, star, "用户叫小明。", nil)
+	testutil.Check("没有新消息时保持原摘要", err == nil && summary == "用户叫小明。" && len(llmClient.Calls()) == 1, summary, err)
+
+	// 模型返回空内容或调用失败时返回错误，调用方保留原摘要
+	_, err = ai.NewSummarizer(testutil.FakeLLM(" "), "", 20).Summarize(ctx, star, "", messages)
+	testutil.Check("空摘要返回错误", err != nil)
+	_, err = ai.NewSummarizer(testutil.FakeLLM("", ai.FakeRule{StatusCode: 504}), "", 20).Summarize(ctx, star, "", messages)
+	testutil.Check("模型失败返回错误", err != nil)
+
+	// 摘要作为系统消息注入在角色设定之后、对话历史之前
+	promptBuilder := ai.NewPromptTemplate()
+	completion := promptBuilder.BuildChatCompletionMessages(star, nil, "用户叫小明，和测试明星约好下周一起听新歌。", messages, "还记得我们的约定吗", nil, nil)
+	testutil.Check("摘要作为第二条系统消息", len(completion) == 3 && completion[1]["role"] == "system" &&
+		strings.Contains(completion[1]["content"], "约好下周一起听新歌"), completion)
+
+	completion = promptBuilder.BuildChatCompletionMessages(star, nil, "", messages, "你好", nil, nil)
+	testutil.Check("没有摘要时不注入", len(completion) == 2, completion)
+
+	testutil.Finish()
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"chat_agent/internal/ai"
+	"chat_agent/test/internal/testutil"
+)
+
+// 验证事实提取和冲突合并，不需要网络：
+//
+//	go run ./test/fact_extraction
+func main() {
+	// 创建上下文
+	ctx := context.Background()
+	promptBuilder := ai.NewPromptTemplate()
+
+	// 关键词规则把自我介绍规范化为事实
+	extractor := ai.NewKeywordFactExtractor(promptBuilder, nil, "")
+	facts, err := extractor.Extract(ctx, "你好呀！我叫小明，我的生日是5月3日。我今年20岁，我住在杭州，我不喜欢香菜")
+	expected := map[string]string{
+		"名字":    "用户的名字是小明",
+		"生日":    "用户的生日是5月3日",
+		"年龄":    "用户今年20岁",
+		"城市":    "用户住在杭州",
+		"喜好:香菜": "用户不喜欢香菜",
+	}
+	testutil.Check("规则提取事实数量", err == nil && len(facts) == len(expected), facts, err)
+	for _, fact := range facts {
+		testutil.Check("规则提取 "+fact.Key, expected[fact.Key] == fact.Content, fact.Content)
+	}
+
+	// 与用户无关的句子不产生事实
+	facts, _ = extractor.Extract(ctx, "今天天气真好。我爱你")
+	testutil.Check("忽略无关句子", len(facts) == 0, facts)
+
+	// 同一条消息中相同键的事实只保留最后一条
+	facts, _ = extractor.Extract(ctx, "我的生日是5月3日，不对，我的生日是5月4日")
+	testutil.Check("同一消息内合并", len(facts) == 1 && facts[0].Content == "用户的生日是5月4日", facts)
+
+	// 规则无法处理的关键信息交给模型提取
+	llmClient := testutil.FakeLLM("```json\n[{\"key\": \"家人\", \"fact\": \"用户有一个正在读大学的妹妹\", \"importance\": 0.6}]\n```")
+	llmExtractor := ai.NewKeywordFactExtractor(promptBuilder, llmClient, "")
+	facts, err = llmExtractor.Extract(ctx, "我叫小明。我家人里有个妹妹在读大学")
+	testutil.Check("模型补充提取", err == nil && len(llmClient.Calls()) == 1 && len(facts) == 2 &&
+		facts[1].Key == "家人" && facts[1].Weight > 1.5, facts, err)
+
+	// 规则已全部覆盖或没有关键信息时不调用模型
+	facts, _ = llmExtractor.Extract(ctx, "我住在杭州。哈哈哈")
+	testutil.Check("无需调用模型", len(llmClient.Calls()) == 1 && len(facts) == 1, facts)
+
+	// 冲突的事实写入记忆时替换旧事实，而不是并存
+	memory := ai.NewInMemoryManager()
+	for _, message := range []string{"我喜欢香菜", "我住在杭州", "我不喜欢香菜", "我现在住在上海"} {
+		facts, _ := extractor.Extract(ctx, message)
+		for _, fact := range facts {
+			memory.UpsertLongTermMemory(ctx, 1, fact.Key, fact.Content, fact.Weight)
+		}
+	}
+	memories, _ := memory.GetLongTermMemory(ctx, 1, 10)
+	joined := strings.Join(memories, "|")
+	testutil.Check("冲突事实被替换", len(memories) == 2 && strings.Contains(joined, "用户不喜欢香菜") &&
+		strings.Contains(joined, "用户住在上海"), memories)
+
+	// 语义检索在事实被替换后不再返回旧内容
+	semantic := ai.NewSemanticMemoryManager(ai.NewInMemoryManager(), ai.NewHashEmbedder(256), ai.NewVectorIndex(50), 0.1)
+	semantic.UpsertLongTermMemory(ctx, 1, "城市", "用户住在杭州", 1.3)
+	semantic.SearchMemory(ctx, 1, "住在哪里", 5)
+	semantic.UpsertLongTermMemory(ctx, 1, "城市", "用户住在上海", 1.3)
+	results, _ := semantic.SearchMemory(ctx, 1, "用户住在杭州", 5)
+	testutil.Check("语义检索不返回旧事实", len(results) == 1 && results[0] == "用户住在上海", results)
+
+	testutil.Finish()
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"chat_agent/internal/ai"
+	"chat_agent/test/internal/testutil"
+)
+
+// 验证模拟模型客户端和模拟的OpenAI兼容服务，不需要网络：
+//
+//	go run ./test/fake_llm
+func main() {
+	// 创建上下文
+	ctx := context.Background()
+	fast := ai.ResilienceOptions{MaxAttempts: 3, BaseDelay: 10 * time.Millisecond, MaxDelay: 2 * time.Second}
+	prompt := func(message string) []map[string]string {
+		return []map[string]string{
+			{"role": "system", "content": "你是测试明星"},
+			{"role": "user", "content": "以下是之前的对话:\n用户: 你好\n测试明星: 你好呀\n\n用户: " + message},
+		}
+	}
+
+	// 按最后一条用户消息匹配规则
+	script, err := ai.NewFakeScript([]ai.FakeRule{
+		{Contains: "天气", Reply: "今天是晴天。"},
+		{Pattern: `^\d+$`, Reply: "你发的数字是{{message}}"},
+		{Contains: "[flaky]", StatusCode: http.StatusServiceUnavailable, Times: 2},
+		{Contains: "[flaky]", Reply: "终于好了"},
+	}, "")
+	testutil.Check("创建脚本", err == nil, err)
+	_, err = ai.NewFakeScript([]ai.FakeRule{{Pattern: "("}}, "")
+	testutil.Check("拒绝错误的正则表达式", err != nil)
+
+	fake := ai.NewFakeLLMClient(script)
+	completion, err := fake.GenerateResponse(ctx, prompt("今天天气怎么样"), ai.GenerateOptions{})
+	testutil.Check("按包含的文本匹配", err == nil && completion.Content == "今天是晴天。" && completion.Model == "fake-model", completion, err)
+	completion, _ = fake.GenerateResponse(ctx, prompt("42"), ai.GenerateOptions{Model: "gpt-4o"})
+	testutil.Check("按正则匹配并替换消息", completion.Content == "你发的数字是42" && completion.Model == "gpt-4o", completion)
+	completion, _ = fake.GenerateResponse(ctx, prompt("随便聊聊"), ai.GenerateOptions{})
+	testutil.Check("没有规则匹配时使用默认回复", strings.Contains(completion.Content, "随便聊聊") && completion.Usage.TotalTokens > 0, completion)
+	again, _ := fake.GenerateResponse(ctx, prompt("随便聊聊"), ai.GenerateOptions{})
+	testutil.Check("相同的输入得到相同的输出", again.Content == completion.Content && again.Usage == completion.Usage)
+
+	var chunks []string
+	completion, err = fake.GenerateStreamResponse(ctx, prompt("今天天气怎么样"), ai.GenerateOptions{}, func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	testutil.Check("流式回复按字数分块", err == nil && strings.Join(chunks, "") == "今天是晴天。" && len(chunks) == 2, chunks, err)
+
+	// 注入的错误先失败两次再成功，重试后恢复
+	resilient := ai.NewResilientClient("fake", fake, fast)
+	completion, err = resilient.GenerateResponse(ctx, prompt("[flaky]"), ai.GenerateOptions{})
+	testutil.Check("注入的错误在重试后恢复", err == nil && completion.Content == "终于好了", completion, err)
+
+	// 模拟服务通过真实的OpenAI客户端调用
+	server := ai.NewFakeOpenAIServer(nil, ai.FakeServerOptions{})
+	httpServer := httptest.NewServer(server)
+	client := ai.NewOpenAIClient("fake", httpServer.URL+"/v1", "fake-model")
+	completion, err = client.GenerateResponse(ctx, prompt("你好"), ai.GenerateOptions{})
+	testutil.Check("模拟服务返回脚本回复和用量", err == nil && strings.Contains(completion.Content, "很高兴见到你") &&
+		completion.Model == "fake-model" && completion.Usage.PromptTokens > 0 && completion.Usage.CompletionTokens > 0, completion, err)
+
+	chunks = nil
+	completion, err = client.GenerateStreamResponse(ctx, prompt("[long]"), ai.GenerateOptions{Model: "gpt-4o"}, func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	testutil.Check("模拟服务以SSE流式返回", err == nil && len(chunks) > 10 && strings.Join(chunks, "") == completion.Content &&
+		completion.Model == "gpt-4o" && completion.Usage.CompletionTokens > 0, len(chunks), completion, err)
+
+	_, err = client.GenerateResponse(ctx, prompt("[429]"), ai.GenerateOptions{})
+	var apiErr *ai.APIError
+	testutil.Check("注入限流错误和Retry-After", errors.As(err, &apiErr) && apiErr.StatusCode == 429 && apiErr.RetryAfter == time.Second, err)
+	_, err = client.GenerateResponse(ctx, prompt("[500]"), ai.GenerateOptions{})
+	testutil.Check("注入服务端错误", errors.As(err, &apiErr) && apiErr.StatusCode == 500, err)
+	httpServer.Close()
+
+	// 服务端延迟和每N个请求一次的错误
+	server = ai.NewFakeOpenAIServer(nil, ai.FakeServerOptions{Latency: 200 * time.Millisecond, FailEvery: 2})
+	httpServer = httptest.NewServer(server)
+	client = ai.NewOpenAIClient("fake", httpServer.URL, "fake-model")
+	start := time.Now()
+	_, err = client.GenerateResponse(ctx, prompt("在吗"), ai.GenerateOptions{})
+	testutil.Check("模拟服务延迟", err == nil && time.Since(start) >= 200*time.Millisecond, err, time.Since(start))
+	_, err = client.GenerateResponse(ctx, prompt("在吗"), ai.GenerateOptions{})
+	testutil.Check("每N个请求注入一次错误", errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusServiceUnavailable, err)
+	completion, err = ai.NewResilientClient("fake", client, fast).GenerateResponse(ctx, prompt("在吗"), ai.GenerateOptions{})
+	testutil.Check("重试绕过注入的错误", err == nil && strings.Contains(completion.Content, "在的"), completion, err)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	_, err = client.GenerateResponse(timeoutCtx, prompt("在吗"), ai.GenerateOptions{})
+	cancel()
+	testutil.Check("调用方取消时不等待延迟", err != nil, err)
+	httpServer.Close()
+
+	testutil.Finish()
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+
+	"chat_agent/internal/ai"
+	"chat_agent/internal/models"
+	"chat_agent/internal/service"
+	"chat_agent/test/internal/testutil"
+)
+
+// float32Ptr 返回float32指针
+func float32Ptr(value float32) *float32 {
+	return &value
+}
+
+// intPtr 返回int指针
+func intPtr(value int) *int {
+	return &value
+}
+
+// recorder 记录收到的请求体的模拟接口
+type recorder struct {
+	body     map[string]interface{}
+	response string
+}
+
+// ServeHTTP 实现http.Handler接口
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	data, _ := io.ReadAll(req.Body)
+	r.body = nil
+	json.Unmarshal(data, &r.body)
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, r.response)
+}
+
+// 验证生成参数的合并、范围校验和发送给模型接口的参数，不需要网络：
+//
+//	go run ./test/generation_params
+func main() {
+	// 创建上下文
+	ctx := context.Background()
+	messages := []map[string]string{{"role": "system", "content": "你是测试明星"}, {"role": "user", "content": "你好"}}
+
+	// 明星的配置依次被会话和请求覆盖
+	star := models.GenerationParams{Temperature: float32Ptr(0.9), TopP: float32Ptr(0.8), Stop: []string{"用户:"}, Seed: intPtr(7)}
+	chat := models.GenerationParams{Temperature: float32Ptr(0.5), MaxTokens: intPtr(300)}
+	request := models.GenerationParams{Temperature: float32Ptr(0.2), Stop: []string{}}
+	merged := star.Merge(chat).Merge(request)
+	testutil.Check("请求覆盖会话，会话覆盖明星", *merged.Temperature == 0.2 && *merged.MaxTokens == 300 && *merged.TopP == 0.8 && *merged.Seed == 7, merged)
+	testutil.Check("空的停止序列清除上一级的配置", merged.Stop != nil && len(merged.Stop) == 0, merged.Stop)
+	testutil.Check("未设置的参数不覆盖", star.Merge(models.GenerationParams{}).Stop[0] == "用户:" && models.GenerationParams{}.IsEmpty() && !request.IsEmpty())
+
+	// 用户覆盖的参数不能超出管理员设置的范围
+	limits := service.GenerationLimits{MaxTemperature: 1.2, MaxTopP: 1, MaxTokens: 1000, MinPenalty: -1, MaxPenalty: 1, MaxStop: 2}
+	testutil.Check("范围内的参数通过校验", limits.Validate(merged) == nil, limits.Validate(merged))
+	err := limits.Validate(models.GenerationParams{Temperature: float32Ptr(1.5)})
+	testutil.Check("拒绝超出范围的temperature", errors.Is(err, service.ErrInvalidGenerationParams), err)
+	err = limits.Validate(models.GenerationParams{MaxTokens: intPtr(4000)})
+	testutil.Check("拒绝超出上限的max_tokens", errors.Is(err, service.ErrInvalidGenerationParams), err)
+	err = limits.Validate(models.GenerationParams{PresencePenalty: float32Ptr(-1.5)})
+	testutil.Check("拒绝超出范围的penalty", errors.Is(err, service.ErrInvalidGenerationParams), err)
+	err = limits.Validate(models.GenerationParams{Stop: []string{"a", "b", "c"}})
+	testutil.Check("拒绝过多的停止序列", errors.Is(err, service.ErrInvalidGenerationParams), err)
+	err = service.ProviderGenerationLimits.Validate(models.GenerationParams{Temperature: float32Ptr(1.5), MaxTokens: intPtr(8000)})
+	testutil.Check("明星的参数按模型接口的范围校验", err == nil, err)
+
+	// OpenAI兼容接口收到设置了的参数
+	openai := &recorder{response: `{"id":"1","model":"test-model","choices":[{"index":0,"message":{"role":"assistant","content":"你好"}}]}`}
+	server := httptest.NewServer(openai)
+	client := ai.NewOpenAIClient("test-key", server.URL, "test-model")
+	_, err = client.GenerateResponse(ctx, messages, ai.GenerateOptions{GenerationParams: models.GenerationParams{
+		Temperature:      float32Ptr(0),
+		TopP:             float32Ptr(0.9),
+		MaxTokens:        intPtr(256),
+		PresencePenalty:  float32Ptr(0.5),
+		FrequencyPenalty: float32Ptr(-0.5),
+		Stop:             []string{"用户:"},
+		Seed:             intPtr(42),
+	}})
+	temperature, hasTemperature := openai.body["temperature"].(float64)
+	testutil.Check("发送生成参数", err == nil && openai.body["top_p"] != nil && openai.body["max_tokens"] == float64(256) &&
+		openai.body["presence_penalty"] == 0.5 && openai.body["frequency_penalty"] == -0.5 && openai.body["seed"] == float64(42) &&
+		fmt.Sprint(openai.body["stop"]) == "[用户:]", openai.body, err)
+	testutil.Check("temperature为0时仍然发送", hasTemperature && temperature < 1e-6, openai.body["temperature"])
+	client.GenerateResponse(ctx, messages, ai.GenerateOptions{})
+	_, hasTemperature = openai.body["temperature"]
+	_, hasSeed := openai.body["seed"]
+	testutil.Check("未设置的参数使用提供方的默认值", !hasTemperature && !hasSeed && openai.body["max_tokens"] == nil, openai.body)
+	server.Close()
+
+	// Anthropic接口收到max_tokens、temperature和停止序列
+	anthropic := &recorder{response: `{"model":"claude-test","content":[{"type":"text","text":"你好"}],"usage":{"input_tokens":3,"output_tokens":1}}`}
+	server = httptest.NewServer(anthropic)
+	claude := ai.NewAnthropicClient("test-key", server.URL, "claude-test", nil)
+	_, err = claude.GenerateResponse(ctx, messages, ai.GenerateOptions{GenerationParams: models.GenerationParams{
+		Temperature: float32Ptr(0), MaxTokens: intPtr(64), Stop: []string{"用户:"},
+	}})
+	testutil.Check("Anthropic接口收到生成参数", err == nil && anthropic.body["max_tokens"] == float64(64) && anthropic.body["temperature"] == float64(0) &&
+		fmt.Sprint(anthropic.body["stop_sequences"]) == "[用户:]", anthropic.body, err)
+	claude.GenerateResponse(ctx, messages, ai.GenerateOptions{})
+	testutil.Check("Anthropic未配置max_tokens时使用默认值", anthropic.body["max_tokens"] == float64(1024) && anthropic.body["temperature"] == nil, anthropic.body)
+	server.Close()
+
+	// 路由和回退时每个模型都使用相同的生成参数
+	mock := testutil.FakeLLM("你好", ai.FakeRule{StatusCode: 503, Times: 1})
+	router := ai.NewLLMRouter("model-a")
+	router.Register(ai.LLMProvider{Name: "mock", Client: mock, Models: []string{"*"}})
+	options := ai.GenerateOptions{GenerationParams: models.GenerationParams{Temperature: float32Ptr(0.3)}}
+	completion, index, err := ai.GenerateWithFallback(ctx, router, messages, options, []string{"model-a", "mock/model-b"})
+	calls := mock.Calls()
+	testutil.Check("回退模型使用相同的生成参数", err == nil && index == 1 && completion.Model == "model-b" && len(calls) == 2 &&
+		*calls[1].Options.Temperature == 0.3 && calls[0].Options.Model == "model-a", calls, err)
+
+	testutil.Finish()
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"chat_agent/internal/ai"
+	"chat_agent/internal/config"
+	"chat_agent/internal/models"
+	"chat_agent/internal/repository"
+	"chat_agent/test/internal/memorycheck"
+	"chat_agent/test/internal/testutil"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// 对数据库记忆管理器执行与其他实现相同的行为检查，需要可用的MySQL（DB_HOST等变量），不可用时跳过：
+//
+//	go run ./test/gorm_memory
+func main() {
+	// 创建上下文
+	ctx := context.Background()
+	db, err := config.InitDatabase(config.LoadConfig())
+	if err != nil {
+		testutil.Skip("MySQL不可用", err)
+	}
+	db = db.Session(&gorm.Session{Logger: logger.Default.LogMode(logger.Silent)})
+	if err := db.AutoMigrate(&models.Memory{}); err != nil {
+		testutil.Skip("迁移memories表失败", err)
+	}
+
+	// 使用按时间生成的会话ID，避免与已有的会话和之前的运行冲突
+	chatID := uint(time.Now().Unix())*10 + 1
+	memoryRepo := repository.NewMemoryRepository(db)
+	memorycheck.Run(ctx, ai.NewGormMemoryManager(memoryRepo, memorycheck.ShortTermCap, memorycheck.LongTermCap, memorycheck.Decay, ai.MemoryTTL{}), chatID)
+
+	// 记忆持久化在数据库中，新建的管理器可以读到之前写入的记忆
+	ai.NewGormMemoryManager(memoryRepo, 0, 0, memorycheck.Decay, ai.MemoryTTL{}).AddLongTermMemory(ctx, chatID+5, "用户喜欢猫", 1.0)
+	restarted := ai.NewGormMemoryManager(repository.NewMemoryRepository(db), 0, 0, memorycheck.Decay, ai.MemoryTTL{})
+	longTerm, err := restarted.GetLongTermMemory(ctx, chatID+5, 10)
+	testutil.Check("重新创建管理器后记忆仍然存在", err == nil && len(longTerm) == 1 && longTerm[0] == "用户喜欢猫", longTerm, err)
+	restarted.DeleteChatMemory(ctx, chatID+5)
+
+	testutil.Finish()
+}
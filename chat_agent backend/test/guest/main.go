@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"chat_agent/internal/ai"
+	"chat_agent/internal/api"
+	"chat_agent/internal/auth"
+	"chat_agent/internal/middleware"
+	"chat_agent/internal/models"
+	"chat_agent/internal/service"
+	"chat_agent/test/internal/memrepo"
+	"chat_agent/test/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// post 从指定IP发送POST请求，guestToken不为空时携带游客Cookie
+func post(engine *gin.Engine, path, ip, guestToken string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"chat_id":1,"content":"你好"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = ip + ":12345"
+	if guestToken != "" {
+		req.AddCookie(&http.Cookie{Name: middleware.GuestCookieName, Value: guestToken})
+	}
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, req)
+	return recorder
+}
+
+// 验证游客会话的创建限流、消息数上限、注册后的数据转移和过期清理，不需要数据库：
+//
+//	go run ./test/guest
+func main() {
+	// 创建上下文
+	ctx := context.Background()
+	userRepo := memrepo.NewUserRepository()
+	messageRepo := memrepo.NewMessageRepository(userRepo)
+	memoryManager := ai.NewInMemoryManager()
+	tokenManager := auth.NewTokenManager("test-secret", 15*time.Minute)
+	guestService := service.NewGuestService(userRepo, messageRepo, tokenManager, memoryManager, service.GuestOptions{TTL: time.Hour, MessageLimit: 3})
+	send := func(userID, chatID uint) {
+		messageRepo.Create(ctx, &models.Message{ChatID: chatID, SenderID: userID, SenderType: models.SenderTypeUser, Content: "你好"})
+	}
+
+	// 创建游客会话按IP限流，游客记录创建时的IP
+	gin.SetMode(gin.ReleaseMode)
+	engine := gin.New()
+	limiter := middleware.NewMemoryRateLimiter()
+	authHandler := api.NewAuthHandler(nil, guestService)
+	authHandler.RegisterRoutes(engine.Group(""), nil, api.RateLimits{
+		CreateGuest: middleware.RateLimitMiddleware(limiter, "create_guest", middleware.RateLimit{PerMinute: 1, Burst: 2}),
+	})
+	first := post(engine, "/auth/guest", "198.51.100.1", "")
+	second := post(engine, "/auth/guest", "198.51.100.1", "")
+	third := post(engine, "/auth/guest", "198.51.100.1", "")
+	testutil.Check("同一IP在突发范围内可以创建游客", first.Code == 200 && second.Code == 200, first.Code, second.Code)
+	testutil.Check("同一IP超出限流返回429", third.Code == http.StatusTooManyRequests && third.Header().Get("Retry-After") != "", third.Code)
+	testutil.Check("其他IP不受影响", post(engine, "/auth/guest", "198.51.100.2", "").Code == 200)
+
+	guestToken := ""
+	for _, cookie := range first.Result().Cookies() {
+		if cookie.Name == middleware.GuestCookieName {
+			guestToken = cookie.Value
+		}
+	}
+	guestID, err := tokenManager.ParseGuestToken(guestToken)
+	guest, _ := userRepo.GetByID(ctx, guestID)
+	testutil.Check("游客令牌写入Cookie", err == nil && guest != nil && guest.IsGuest, err)
+	testutil.Check("游客记录创建时的IP", guest != nil && guest.GuestIP == "198.51.100.1", guest)
+
+	// 同一IP创建的游客共用消息数上限
+	session, _ := guestService.CreateGuest(ctx, "203.0.113.7")
+	other, _ := guestService.CreateGuest(ctx, "203.0.113.7")
+	elsewhere, _ := guestService.CreateGuest(ctx, "203.0.113.8")
+	send(session.User.ID, 10)
+	send(session.User.ID, 10)
+	testutil.Check("未达到上限时可以发送", guestService.CheckMessageLimit(ctx, session.User.ID) == nil)
+	send(other.User.ID, 11)
+	err = guestService.CheckMessageLimit(ctx, session.User.ID)
+	testutil.Check("达到上限时返回ErrGuestLimitReached", errors.Is(err, service.ErrGuestLimitReached), err)
+	err = guestService.CheckMessageLimit(ctx, other.User.ID)
+	testutil.Check("同一IP的新游客不能绕过上限", errors.Is(err, service.ErrGuestLimitReached), err)
+	testutil.Check("其他IP的游客不受影响", guestService.CheckMessageLimit(ctx, elsewhere.User.ID) == nil)
+
+	// 达到上限后发送消息的接口返回HTTP 429，请求不会到达聊天服务
+	chatEngine := gin.New()
+	protected := chatEngine.Group("")
+	protected.Use(middleware.AuthMiddleware(tokenManager, nil, nil))
+	api.NewChatHandler(nil, guestService).RegisterRoutes(protected, api.RateLimits{})
+	limited := post(chatEngine, "/chats/messages", "203.0.113.7", session.GuestToken)
+	testutil.Check("消息接口返回429", limited.Code == http.StatusTooManyRequests && strings.Contains(limited.Body.String(), "注册"), limited.Code, limited.Body.String())
+	limited = post(chatEngine, "/chats/messages/stream", "203.0.113.7", other.GuestToken)
+	testutil.Check("流式消息接口返回429", limited.Code == http.StatusTooManyRequests, limited.Code)
+
+	// 注册后游客的消息转移到新账号，不再计入该IP的游客上限
+	registered := &models.User{Username: "xiaoming", Email: "xiaoming@example.com", Role: models.RoleUser, IsActive: true}
+	userRepo.Create(ctx, registered)
+	err = guestService.UpgradeGuest(ctx, session.GuestToken, registered.ID)
+	transferred, _ := messageRepo.CountUserMessages(ctx, registered.ID)
+	testutil.Check("注册后转移游客的消息", err == nil && transferred == 2, err, transferred)
+	_, err = userRepo.GetByID(ctx, session.User.ID)
+	testutil.Check("转移后删除游客账号", err != nil)
+	testutil.Check("转移的消息不再计入游客上限", guestService.CheckMessageLimit(ctx, other.User.ID) == nil)
+	testutil.Check("游客令牌无效或已转移时忽略", guestService.UpgradeGuest(ctx, "invalid", registered.ID) == nil &&
+		guestService.UpgradeGuest(ctx, session.GuestToken, registered.ID) == nil)
+
+	// 过期的游客被清理，会话的记忆一并删除
+	expiring := service.NewGuestService(userRepo, messageRepo, tokenManager, memoryManager, service.GuestOptions{TTL: time.Millisecond})
+	expired, _ := expiring.CreateGuest(ctx, "203.0.113.9")
+	send(expired.User.ID, 20)
+	memoryManager.AddShortTermMemory(ctx, 20, "用户: 你好")
+	time.Sleep(5 * time.Millisecond)
+	removed, err := guestService.SweepExpiredGuests(ctx)
+	memories, _ := memoryManager.GetShortTermMemory(ctx, 20, 10)
+	_, lookupErr := userRepo.GetByID(ctx, expired.User.ID)
+	testutil.Check("清理过期的游客", err == nil && removed == 1 && lookupErr != nil, removed, err)
+	testutil.Check("清理游客会话的记忆", len(memories) == 0, memories)
+	_, err = userRepo.GetByID(ctx, other.User.ID)
+	testutil.Check("未过期的游客保留", err == nil, err)
+
+	// 后台清理任务按间隔运行，ctx取消后退出
+	expired, _ = expiring.CreateGuest(ctx, "203.0.113.9")
+	sweeperCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		guestService.RunSweeper(sweeperCtx, 10*time.Millisecond)
+		close(done)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+		testutil.Check("取消后清理任务退出", true)
+	case <-time.After(time.Second):
+		testutil.Check("取消后清理任务退出", false)
+	}
+	_, err = userRepo.GetByID(ctx, expired.User.ID)
+	testutil.Check("后台任务清理过期的游客", err != nil)
+
+	testutil.Finish()
+}
@@ -0,0 +1,178 @@
+// Package memorycheck 各种记忆管理器共用的行为检查，保证内存、Redis和数据库实现的行为一致
+package memorycheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"chat_agent/internal/ai"
+	"chat_agent/test/internal/testutil"
+)
+
+// 被检查的记忆管理器需要使用以下配置创建
+const (
+	ShortTermCap = 3
+	LongTermCap  = 30
+)
+
+// Decay 被检查的记忆管理器使用的衰减策略：不随时间衰减，每次强化增加1
+var Decay = ai.MemoryDecay{ReinforceBoost: 1}
+
+// Run 检查记忆管理器的读写、排序、淘汰、去重、修改、删除和并发写入，
+// chatID和chatID+1应当是没有记忆的会话，检查结束后删除这两个会话的记忆
+func Run(ctx context.Context, manager ai.MemoryManager, chatID uint) {
+	otherChatID := chatID + 1
+	defer manager.DeleteChatMemory(ctx, chatID)
+	defer manager.DeleteChatMemory(ctx, otherChatID)
+
+	// 短期记忆按时间从早到晚返回，只保留最近的若干条
+	for i := 1; i <= 5; i++ {
+		if err := manager.AddShortTermMemory(ctx, chatID, fmt.Sprintf("消息%d", i)); err != nil {
+			testutil.Check("写入短期记忆", false, err)
+			return
+		}
+	}
+	shortTerm, err := manager.GetShortTermMemory(ctx, chatID, 10)
+	testutil.Check("只保留最近的短期记忆", err == nil && equal(shortTerm, "消息3", "消息4", "消息5"), shortTerm, err)
+	shortTerm, _ = manager.GetShortTermMemory(ctx, chatID, 2)
+	testutil.Check("按条数返回最近的短期记忆", equal(shortTerm, "消息4", "消息5"), shortTerm)
+
+	// 长期记忆去重，按权重从高到低返回
+	manager.AddLongTermMemory(ctx, chatID, "用户喜欢猫", 1.0)
+	manager.AddLongTermMemory(ctx, chatID, "用户喜欢猫", 1.0)
+	manager.AddLongTermMemory(ctx, chatID, "用户喜欢狗", 0.5)
+	manager.AddLongTermMemory(ctx, chatID, "用户住在杭州", 2.0)
+	longTerm, err := manager.GetLongTermMemory(ctx, chatID, 10)
+	testutil.Check("内容相同的长期记忆只保留一条", err == nil && len(longTerm) == 3, longTerm, err)
+	testutil.Check("按权重排序长期记忆", equal(longTerm, "用户住在杭州", "用户喜欢猫", "用户喜欢狗"), longTerm)
+	longTerm, _ = manager.GetLongTermMemory(ctx, chatID, 1)
+	testutil.Check("按条数返回权重最高的长期记忆", equal(longTerm, "用户住在杭州"), longTerm)
+
+	// 相同键的事实相互替换
+	manager.UpsertLongTermMemory(ctx, chatID, "生日", "用户的生日是3月1日", 1.5)
+	manager.UpsertLongTermMemory(ctx, chatID, "生日", "用户的生日是5月1日", 1.0)
+	items, _ := manager.ListMemories(ctx, chatID)
+	birthday := find(items, "用户的生日是5月1日")
+	testutil.Check("相同键的事实替换旧内容", len(items) == 4 && birthday != nil && birthday.Key == "生日" && find(items, "用户的生日是3月1日") == nil, items)
+	testutil.Check("替换时保留较高的权重", birthday != nil && birthday.Weight == 1.5, birthday)
+	manager.UpsertLongTermMemory(ctx, chatID, "宠物", "用户喜欢猫", 0.5)
+	items, _ = manager.ListMemories(ctx, chatID)
+	pet := find(items, "用户喜欢猫")
+	testutil.Check("按键写入已有的内容时补上键", len(items) == 4 && pet != nil && pet.Key == "宠物" && pet.Weight == 1.0, items)
+	manager.UpsertLongTermMemory(ctx, chatID, "宠物", "用户喜欢猫", 0.5)
+	items, _ = manager.ListMemories(ctx, chatID)
+	testutil.Check("补上键后按键写入不重复添加", len(items) == 4, items)
+
+	// 被引用的记忆得到强化
+	manager.ReinforceMemory(ctx, chatID, "用户喜欢狗")
+	items, _ = manager.ListMemories(ctx, chatID)
+	dog := find(items, "用户喜欢狗")
+	testutil.Check("强化被引用的记忆", dog != nil && dog.Weight == 1.5, dog)
+	manager.ReinforceMemory(ctx, chatID, "不存在的记忆")
+
+	// 修改权重、内容和置顶状态
+	cat := find(items, "用户喜欢猫")
+	if cat == nil {
+		testutil.Check("列出长期记忆", false, items)
+		return
+	}
+	err = manager.UpdateMemoryWeight(ctx, cat.ID, 3.0)
+	longTerm, _ = manager.GetLongTermMemory(ctx, chatID, 10)
+	testutil.Check("修改记忆权重", err == nil && len(longTerm) == 4 && longTerm[0] == "用户喜欢猫", longTerm, err)
+	pin := true
+	content := "用户喜欢小狗"
+	updated, err := manager.UpdateMemory(ctx, chatID, dog.ID, ai.MemoryUpdate{Content: &content, Pinned: &pin})
+	longTerm, _ = manager.GetLongTermMemory(ctx, chatID, 10)
+	testutil.Check("修改记忆内容并置顶", err == nil && updated.Content == content && updated.Pinned && len(longTerm) == 4 && longTerm[0] == content, updated, longTerm, err)
+	_, err = manager.UpdateMemory(ctx, otherChatID, dog.ID, ai.MemoryUpdate{Pinned: &pin})
+	testutil.Check("不能修改其他会话的记忆", errors.Is(err, ai.ErrMemoryNotFound), err)
+
+	// 超出上限时淘汰权重最低的记忆，置顶的记忆保留
+	for i := 0; i < LongTermCap; i++ {
+		manager.AddLongTermMemory(ctx, chatID, fmt.Sprintf("填充记忆%d", i), 0.01*float64(i+1))
+	}
+	items, _ = manager.ListMemories(ctx, chatID)
+	testutil.Check("长期记忆不超过上限", len(items) == LongTermCap, len(items))
+	testutil.Check("淘汰权重最低的记忆", find(items, "填充记忆0") == nil && find(items, "填充记忆3") == nil && find(items, "填充记忆4") != nil)
+	testutil.Check("置顶和高权重的记忆保留", find(items, content) != nil && find(items, "用户喜欢猫") != nil && find(items, "用户住在杭州") != nil)
+
+	// 关键词检索同时匹配短期和长期记忆
+	found, err := manager.SearchMemory(ctx, chatID, "杭州", 5)
+	testutil.Check("按关键词检索记忆", err == nil && equal(found, "用户住在杭州"), found, err)
+	manager.AddShortTermMemory(ctx, chatID, "我下周去杭州出差")
+	found, _ = manager.SearchMemory(ctx, chatID, "杭州", 5)
+	testutil.Check("检索结果包含短期记忆", len(found) == 2, found)
+	found, _ = manager.SearchMemory(ctx, chatID, "杭州", 1)
+	testutil.Check("按条数返回检索结果", len(found) == 1, found)
+
+	// 删除单条记忆，忘记与消息内容相同的记忆，置顶的记忆不会被忘记
+	city := find(items, "用户住在杭州")
+	if city == nil {
+		return
+	}
+	testutil.Check("不能删除其他会话的记忆", errors.Is(manager.DeleteMemory(ctx, otherChatID, city.ID), ai.ErrMemoryNotFound))
+	err = manager.DeleteMemory(ctx, chatID, city.ID)
+	testutil.Check("删除长期记忆", err == nil, err)
+	testutil.Check("删除不存在的记忆返回ErrMemoryNotFound", errors.Is(manager.DeleteMemory(ctx, chatID, city.ID), ai.ErrMemoryNotFound))
+	forgotten, err := manager.ForgetMemory(ctx, chatID, "我下周去杭州出差")
+	testutil.Check("忘记与消息内容相同的短期记忆", err == nil && forgotten == 1, forgotten, err)
+	forgotten, _ = manager.ForgetMemory(ctx, chatID, content)
+	testutil.Check("置顶的记忆不会被忘记", forgotten == 0, forgotten)
+
+	// 并发写入的记忆ID不重复，会话之间互不影响
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			manager.AddLongTermMemory(ctx, otherChatID, fmt.Sprintf("并发写入的记忆%d", i), 1.0)
+		}(i)
+	}
+	wg.Wait()
+	items, _ = manager.ListMemories(ctx, otherChatID)
+	ids := make(map[string]bool)
+	for _, item := range items {
+		ids[item.ID] = true
+	}
+	testutil.Check("并发写入的记忆全部保存", len(items) == 20, len(items))
+	testutil.Check("并发写入的记忆ID不重复", len(ids) == len(items), len(ids))
+	shortTerm, _ = manager.GetShortTermMemory(ctx, otherChatID, 10)
+	testutil.Check("会话之间的记忆互不影响", len(shortTerm) == 0 && find(items, "用户喜欢猫") == nil, shortTerm)
+
+	// 清除短期记忆和删除会话的全部记忆
+	err = manager.ClearShortTermMemory(ctx, chatID)
+	shortTerm, _ = manager.GetShortTermMemory(ctx, chatID, 10)
+	testutil.Check("清除短期记忆", err == nil && len(shortTerm) == 0, shortTerm, err)
+	manager.AddShortTermMemory(ctx, chatID, "再见")
+	items, _ = manager.ListMemories(ctx, chatID)
+	deleted, err := manager.DeleteChatMemory(ctx, chatID)
+	longTerm, _ = manager.GetLongTermMemory(ctx, chatID, 10)
+	testutil.Check("删除会话的全部记忆", err == nil && deleted == len(items)+1 && len(longTerm) == 0, deleted, longTerm, err)
+	items, _ = manager.ListMemories(ctx, otherChatID)
+	testutil.Check("删除会话不影响其他会话", len(items) == 20, len(items))
+}
+
+// find 按内容查找记忆
+func find(items []ai.MemoryItem, content string) *ai.MemoryItem {
+	for i := range items {
+		if items[i].Content == content {
+			return &items[i]
+		}
+	}
+	return nil
+}
+
+// equal 判断记忆内容是否依次相同
+func equal(contents []string, expected ...string) bool {
+	if len(contents) != len(expected) {
+		return false
+	}
+	for i := range contents {
+		if contents[i] != expected[i] {
+			return false
+		}
+	}
+	return true
+}
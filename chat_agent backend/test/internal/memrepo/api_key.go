@@ -0,0 +1,93 @@
+package memrepo
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"chat_agent/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// APIKeyRepository 保存在内存中的API密钥仓库
+type APIKeyRepository struct {
+	mu   sync.Mutex
+	keys []models.APIKey
+}
+
+// NewAPIKeyRepository 创建内存API密钥仓库
+func NewAPIKeyRepository() *APIKeyRepository {
+	return &APIKeyRepository{}
+}
+
+// Create 实现APIKeyRepository接口
+func (r *APIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key.ID = uint(len(r.keys) + 1)
+	key.CreatedAt = time.Now()
+	r.keys = append(r.keys, *key)
+	return nil
+}
+
+// GetByID 实现APIKeyRepository接口
+func (r *APIKeyRepository) GetByID(ctx context.Context, id uint) (*models.APIKey, error) {
+	return r.find(func(key *models.APIKey) bool { return key.ID == id })
+}
+
+// GetByHash 实现APIKeyRepository接口
+func (r *APIKeyRepository) GetByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	return r.find(func(key *models.APIKey) bool { return key.KeyHash == keyHash })
+}
+
+// GetUserKeys 实现APIKeyRepository接口
+func (r *APIKeyRepository) GetUserKeys(ctx context.Context, userID uint) ([]models.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var keys []models.APIKey
+	for i := len(r.keys) - 1; i >= 0; i-- {
+		if r.keys[i].UserID == userID {
+			keys = append(keys, r.keys[i])
+		}
+	}
+	return keys, nil
+}
+
+// Revoke 实现APIKeyRepository接口
+func (r *APIKeyRepository) Revoke(ctx context.Context, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.keys {
+		if r.keys[i].ID == id && r.keys[i].RevokedAt == nil {
+			now := time.Now()
+			r.keys[i].RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+// UpdateLastUsed 实现APIKeyRepository接口
+func (r *APIKeyRepository) UpdateLastUsed(ctx context.Context, id uint, usedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.keys {
+		if r.keys[i].ID == id {
+			r.keys[i].LastUsedAt = &usedAt
+		}
+	}
+	return nil
+}
+
+// find 返回第一个满足条件的API密钥的副本
+func (r *APIKeyRepository) find(match func(key *models.APIKey) bool) (*models.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.keys {
+		if match(&r.keys[i]) {
+			key := r.keys[i]
+			return &key, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
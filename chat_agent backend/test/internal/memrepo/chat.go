@@ -0,0 +1,47 @@
+package memrepo
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"chat_agent/internal/models"
+	"chat_agent/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// ChatRepository 保存在内存中的聊天会话仓库，只实现创建和查询会话
+type ChatRepository struct {
+	repository.ChatRepository
+	mu    sync.Mutex
+	chats []models.Chat
+}
+
+// NewChatRepository 创建内存聊天会话仓库
+func NewChatRepository() *ChatRepository {
+	return &ChatRepository{}
+}
+
+// Create 实现ChatRepository接口
+func (r *ChatRepository) Create(ctx context.Context, chat *models.Chat) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	chat.ID = uint(len(r.chats) + 1)
+	chat.CreatedAt = time.Now()
+	r.chats = append(r.chats, *chat)
+	return nil
+}
+
+// GetByID 实现ChatRepository接口
+func (r *ChatRepository) GetByID(ctx context.Context, id uint) (*models.Chat, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.chats {
+		if r.chats[i].ID == id {
+			chat := r.chats[i]
+			return &chat, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
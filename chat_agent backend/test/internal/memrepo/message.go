@@ -0,0 +1,97 @@
+package memrepo
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"chat_agent/internal/models"
+	"chat_agent/internal/repository"
+)
+
+// MessageRepository 保存在内存中的消息仓库，只实现创建和统计消息
+type MessageRepository struct {
+	repository.MessageRepository
+	mu       sync.Mutex
+	users    *UserRepository
+	messages []models.Message
+}
+
+// NewMessageRepository 创建内存消息仓库，users转移和删除游客时一并处理这里的消息
+func NewMessageRepository(users *UserRepository) *MessageRepository {
+	r := &MessageRepository{users: users}
+	users.messages = r
+	return r
+}
+
+// Create 实现MessageRepository接口
+func (r *MessageRepository) Create(ctx context.Context, message *models.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	message.ID = uint(len(r.messages) + 1)
+	message.CreatedAt = time.Now()
+	r.messages = append(r.messages, *message)
+	return nil
+}
+
+// CountUserMessages 实现MessageRepository接口
+func (r *MessageRepository) CountUserMessages(ctx context.Context, userID uint) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var count int64
+	for _, message := range r.messages {
+		if message.SenderType == models.SenderTypeUser && message.SenderID == userID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// CountGuestMessagesByIP 实现MessageRepository接口
+func (r *MessageRepository) CountGuestMessagesByIP(ctx context.Context, ip string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var count int64
+	for _, message := range r.messages {
+		if message.SenderType != models.SenderTypeUser {
+			continue
+		}
+		if sender, err := r.users.GetByID(ctx, message.SenderID); err == nil && sender.IsGuest && sender.GuestIP == ip {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// transfer 把发送者为from的消息转移给to
+func (r *MessageRepository) transfer(from, to uint) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.messages {
+		if r.messages[i].SenderType == models.SenderTypeUser && r.messages[i].SenderID == from {
+			r.messages[i].SenderID = to
+		}
+	}
+}
+
+// deleteSender 删除发送者为senderID的消息所在会话的全部消息，返回这些会话的ID
+func (r *MessageRepository) deleteSender(senderID uint) []uint {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	chats := map[uint]bool{}
+	var chatIDs []uint
+	for _, message := range r.messages {
+		if message.SenderType == models.SenderTypeUser && message.SenderID == senderID && !chats[message.ChatID] {
+			chats[message.ChatID] = true
+			chatIDs = append(chatIDs, message.ChatID)
+		}
+	}
+	kept := r.messages[:0]
+	for _, message := range r.messages {
+		if !chats[message.ChatID] {
+			kept = append(kept, message)
+		}
+	}
+	r.messages = kept
+	return chatIDs
+}
@@ -0,0 +1,60 @@
+package memrepo
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"chat_agent/internal/models"
+	"chat_agent/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// StarRepository 保存在内存中的明星仓库，只实现创建、查询和更新明星
+type StarRepository struct {
+	repository.StarRepository
+	mu    sync.Mutex
+	stars []models.Star
+}
+
+// NewStarRepository 创建内存明星仓库
+func NewStarRepository() *StarRepository {
+	return &StarRepository{}
+}
+
+// Create 实现StarRepository接口
+func (r *StarRepository) Create(ctx context.Context, star *models.Star) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	star.ID = uint(len(r.stars) + 1)
+	star.CreatedAt = time.Now()
+	r.stars = append(r.stars, *star)
+	return nil
+}
+
+// GetByID 实现StarRepository接口
+func (r *StarRepository) GetByID(ctx context.Context, id uint) (*models.Star, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.stars {
+		if r.stars[i].ID == id {
+			star := r.stars[i]
+			return &star, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// Update 实现StarRepository接口
+func (r *StarRepository) Update(ctx context.Context, star *models.Star) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.stars {
+		if r.stars[i].ID == star.ID {
+			r.stars[i] = *star
+			return nil
+		}
+	}
+	return gorm.ErrRecordNotFound
+}
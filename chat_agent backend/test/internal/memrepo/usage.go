@@ -0,0 +1,89 @@
+package memrepo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"chat_agent/internal/models"
+	"chat_agent/internal/repository"
+)
+
+// UsageRepository 保存在内存中的用量仓库
+type UsageRepository struct {
+	mu      sync.Mutex
+	records []models.UsageRecord
+}
+
+// NewUsageRepository 创建内存用量仓库
+func NewUsageRepository() *UsageRepository {
+	return &UsageRepository{}
+}
+
+// Create 实现UsageRepository接口，已设置CreatedAt的记录保留原时间，便于构造历史用量
+func (r *UsageRepository) Create(ctx context.Context, record *models.UsageRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record.ID = uint(len(r.records) + 1)
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now()
+	}
+	r.records = append(r.records, *record)
+	return nil
+}
+
+// SumUserTokens 实现UsageRepository接口
+func (r *UsageRepository) SumUserTokens(ctx context.Context, userID uint, since time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var total int64
+	for _, record := range r.records {
+		if record.UserID == userID && !record.CreatedAt.Before(since) {
+			total += int64(record.TotalTokens)
+		}
+	}
+	return total, nil
+}
+
+// Rollup 实现UsageRepository接口
+func (r *UsageRepository) Rollup(ctx context.Context, userID uint, since time.Time, groupBy string) ([]models.UsageRollup, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	index := make(map[string]int)
+	var rollups []models.UsageRollup
+	for _, record := range r.records {
+		if record.UserID != userID || record.CreatedAt.Before(since) {
+			continue
+		}
+
+		var key string
+		switch groupBy {
+		case repository.UsageGroupByModel:
+			key = record.Model
+		case repository.UsageGroupByStar:
+			key = fmt.Sprint(record.StarID)
+		case repository.UsageGroupByChat:
+			key = fmt.Sprint(record.ChatID)
+		default:
+			return nil, fmt.Errorf("unsupported usage group: %s", groupBy)
+		}
+
+		i, ok := index[key]
+		if !ok {
+			i = len(rollups)
+			index[key] = i
+			rollups = append(rollups, models.UsageRollup{Key: key})
+		}
+		rollups[i].PromptTokens += int64(record.PromptTokens)
+		rollups[i].CompletionTokens += int64(record.CompletionTokens)
+		rollups[i].TotalTokens += int64(record.TotalTokens)
+		rollups[i].Requests++
+		rollups[i].EstimatedCost += record.EstimatedCost
+	}
+
+	sort.SliceStable(rollups, func(i, j int) bool { return rollups[i].TotalTokens > rollups[j].TotalTokens })
+	return rollups, nil
+}
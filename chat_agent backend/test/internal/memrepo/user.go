@@ -0,0 +1,261 @@
+// Package memrepo test目录下各个验证程序共用的内存仓库，不需要数据库
+package memrepo
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"chat_agent/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// UserRepository 保存在内存中的用户仓库
+type UserRepository struct {
+	mu       sync.Mutex
+	users    []models.User
+	messages *MessageRepository // 转移和删除游客时一并处理的消息，为nil时只处理用户
+}
+
+// NewUserRepository 创建内存用户仓库
+func NewUserRepository() *UserRepository {
+	return &UserRepository{}
+}
+
+// Create 实现UserRepository接口
+func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.users {
+		if (user.Username != "" && existing.Username == user.Username) || (user.Email != "" && existing.Email == user.Email) {
+			return gorm.ErrDuplicatedKey
+		}
+	}
+	user.ID = uint(len(r.users) + 1)
+	user.CreatedAt = time.Now()
+	r.users = append(r.users, *user)
+	return nil
+}
+
+// GetByID 实现UserRepository接口
+func (r *UserRepository) GetByID(ctx context.Context, id uint) (*models.User, error) {
+	return r.find(func(user *models.User) bool { return user.ID == id })
+}
+
+// GetByUsername 实现UserRepository接口
+func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	return r.find(func(user *models.User) bool { return user.Username == username })
+}
+
+// GetByEmail 实现UserRepository接口
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.find(func(user *models.User) bool { return email != "" && user.Email == email })
+}
+
+// Update 实现UserRepository接口
+func (r *UserRepository) Update(ctx context.Context, user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.users {
+		if r.users[i].ID == user.ID && r.users[i].DeletedAt.Time.IsZero() {
+			r.users[i] = *user
+			return nil
+		}
+	}
+	return gorm.ErrRecordNotFound
+}
+
+// ListExpiredGuests 实现UserRepository接口
+func (r *UserRepository) ListExpiredGuests(ctx context.Context, before time.Time, limit int) ([]models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var guests []models.User
+	for _, user := range r.users {
+		if user.IsGuest && user.GuestExpiresAt != nil && user.GuestExpiresAt.Before(before) && user.DeletedAt.Time.IsZero() {
+			guests = append(guests, user)
+		}
+		if limit > 0 && len(guests) >= limit {
+			break
+		}
+	}
+	return guests, nil
+}
+
+// TransferGuestData 实现UserRepository接口，把游客发送的消息转移给正式用户并删除游客账号
+func (r *UserRepository) TransferGuestData(ctx context.Context, guestID, userID uint) error {
+	if err := r.deleteGuest(guestID); err != nil {
+		return err
+	}
+	if r.messages != nil {
+		r.messages.transfer(guestID, userID)
+	}
+	return nil
+}
+
+// DeleteGuest 实现UserRepository接口，返回游客发过消息的会话ID
+func (r *UserRepository) DeleteGuest(ctx context.Context, guestID uint) ([]uint, error) {
+	if err := r.deleteGuest(guestID); err != nil {
+		return nil, err
+	}
+	if r.messages != nil {
+		return r.messages.deleteSender(guestID), nil
+	}
+	return nil, nil
+}
+
+// deleteGuest 删除游客账号
+func (r *UserRepository) deleteGuest(guestID uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.users {
+		if r.users[i].ID == guestID && r.users[i].IsGuest && r.users[i].DeletedAt.Time.IsZero() {
+			r.users[i].DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+			return nil
+		}
+	}
+	return gorm.ErrRecordNotFound
+}
+
+// find 返回第一个满足条件且未删除的用户的副本
+func (r *UserRepository) find(match func(user *models.User) bool) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.users {
+		if r.users[i].DeletedAt.Time.IsZero() && match(&r.users[i]) {
+			user := r.users[i]
+			return &user, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// RefreshTokenRepository 保存在内存中的刷新令牌仓库
+type RefreshTokenRepository struct {
+	mu     sync.Mutex
+	tokens []models.RefreshToken
+}
+
+// NewRefreshTokenRepository 创建内存刷新令牌仓库
+func NewRefreshTokenRepository() *RefreshTokenRepository {
+	return &RefreshTokenRepository{}
+}
+
+// Create 实现RefreshTokenRepository接口
+func (r *RefreshTokenRepository) Create(ctx context.Context, token *models.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token.ID = uint(len(r.tokens) + 1)
+	r.tokens = append(r.tokens, *token)
+	return nil
+}
+
+// GetByHash 实现RefreshTokenRepository接口
+func (r *RefreshTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.tokens {
+		if token.TokenHash == tokenHash {
+			return &token, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// Revoke 实现RefreshTokenRepository接口，与数据库实现一样只有第一次吊销成功
+func (r *RefreshTokenRepository) Revoke(ctx context.Context, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.tokens {
+		if r.tokens[i].ID == id && r.tokens[i].RevokedAt == nil {
+			now := time.Now()
+			r.tokens[i].RevokedAt = &now
+			return nil
+		}
+	}
+	return gorm.ErrRecordNotFound
+}
+
+// RevokeAllForUser 实现RefreshTokenRepository接口
+func (r *RefreshTokenRepository) RevokeAllForUser(ctx context.Context, userID uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for i := range r.tokens {
+		if r.tokens[i].UserID == userID && r.tokens[i].RevokedAt == nil {
+			r.tokens[i].RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+// Active 返回用户未吊销的刷新令牌数量
+func (r *RefreshTokenRepository) Active(userID uint) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	count := 0
+	for _, token := range r.tokens {
+		if token.UserID == userID && token.RevokedAt == nil {
+			count++
+		}
+	}
+	return count
+}
+
+// PasswordResetTokenRepository 保存在内存中的密码重置令牌仓库
+type PasswordResetTokenRepository struct {
+	mu     sync.Mutex
+	tokens []models.PasswordResetToken
+}
+
+// NewPasswordResetTokenRepository 创建内存密码重置令牌仓库
+func NewPasswordResetTokenRepository() *PasswordResetTokenRepository {
+	return &PasswordResetTokenRepository{}
+}
+
+// Create 实现PasswordResetTokenRepository接口
+func (r *PasswordResetTokenRepository) Create(ctx context.Context, token *models.PasswordResetToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token.ID = uint(len(r.tokens) + 1)
+	r.tokens = append(r.tokens, *token)
+	return nil
+}
+
+// GetByHash 实现PasswordResetTokenRepository接口
+func (r *PasswordResetTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*models.PasswordResetToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.tokens {
+		if token.TokenHash == tokenHash {
+			return &token, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// Consume 实现PasswordResetTokenRepository接口
+func (r *PasswordResetTokenRepository) Consume(ctx context.Context, tokenHash string, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.tokens {
+		if r.tokens[i].TokenHash == tokenHash && r.tokens[i].UsedAt == nil && r.tokens[i].ExpiresAt.After(now) {
+			r.tokens[i].UsedAt = &now
+			return nil
+		}
+	}
+	return gorm.ErrRecordNotFound
+}
+
+// InvalidateAllForUser 实现PasswordResetTokenRepository接口
+func (r *PasswordResetTokenRepository) InvalidateAllForUser(ctx context.Context, userID uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for i := range r.tokens {
+		if r.tokens[i].UserID == userID && r.tokens[i].UsedAt == nil {
+			r.tokens[i].UsedAt = &now
+		}
+	}
+	return nil
+}
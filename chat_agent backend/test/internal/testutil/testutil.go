@@ -0,0 +1,46 @@
+// Package testutil test目录下各个验证程序共用的检查工具
+package testutil
+
+import (
+	"fmt"
+	"os"
+
+	"chat_agent/internal/ai"
+)
+
+// failures 失败的检查数量
+var failures int
+
+// Check 打印检查结果，ok为false时记录一次失败并打印detail
+func Check(name string, ok bool, detail ...interface{}) {
+	if ok {
+		fmt.Printf("PASS %s\n", name)
+		return
+	}
+	failures++
+	fmt.Printf("FAIL %s %v\n", name, detail)
+}
+
+// Skip 依赖的服务不可用时跳过整个验证程序
+func Skip(reason string, err error) {
+	fmt.Printf("SKIP %s: %v\n", reason, err)
+	os.Exit(0)
+}
+
+// FakeLLM 创建按rules回复的模拟模型客户端，没有规则匹配时回复reply
+func FakeLLM(reply string, rules ...ai.FakeRule) *ai.FakeLLMClient {
+	script, err := ai.NewFakeScript(rules, reply)
+	if err != nil {
+		panic(err)
+	}
+	return ai.NewFakeLLMClient(script)
+}
+
+// Finish 打印汇总，有检查失败时以状态码1退出
+func Finish() {
+	if failures > 0 {
+		fmt.Printf("\n%d项检查失败\n", failures)
+		os.Exit(1)
+	}
+	fmt.Println("\n测试完成！")
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	"chat_agent/internal/ai"
+	"chat_agent/internal/api"
+	"chat_agent/test/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// upstream 模拟的OpenAI兼容接口，按顺序返回预设的状态码，用完后返回成功
+type upstream struct {
+	statuses   []int
+	retryAfter string
+	delay      time.Duration
+	chunkDelay time.Duration // 流式输出时每个数据块之间的间隔
+	calls      atomic.Int32
+}
+
+// ServeHTTP 实现http.Handler接口
+func (u *upstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	call := int(u.calls.Add(1))
+	time.Sleep(u.delay)
+	if call <= len(u.statuses) {
+		if u.retryAfter != "" {
+			w.Header().Set("Retry-After", u.retryAfter)
+		}
+		w.WriteHeader(u.statuses[call-1])
+		fmt.Fprint(w, `{"error":{"message":"upstream error","type":"server_error"}}`)
+		return
+	}
+
+	var req struct {
+		Stream bool `json:"stream"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	if req.Stream {
+		w.Header().Set("Content-Type", "text/event-stream")
+		chunks := 1
+		if u.chunkDelay > 0 {
+			chunks = 3
+		}
+		for i := 0; i < chunks; i++ {
+			if i > 0 {
+				time.Sleep(u.chunkDelay)
+			}
+			fmt.Fprint(w, "data: {\"id\":\"1\",\"model\":\"test-model\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"你好\"}}]}\n\n")
+			w.(http.Flusher).Flush()
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, `{"id":"1","model":"test-model","choices":[{"index":0,"message":{"role":"assistant","content":"你好"}}],"usage":{"prompt_tokens":5,"completion_tokens":2,"total_tokens":7}}`)
+}
+
+// newClient 创建连接到模拟接口的弹性客户端
+func newClient(u *upstream, options ai.ResilienceOptions) (*ai.ResilientClient, func()) {
+	server := httptest.NewServer(u)
+	client := ai.NewResilientClient("test", ai.NewOpenAIClient("test-key", server.URL, "test-model"), options)
+	return client, server.Close
+}
+
+// 验证模型调用的重试、Retry-After、单次超时和熔断，不需要网络：
+//
+//	go run ./test/llm_resilience
+func main() {
+	// 创建上下文
+	ctx := context.Background()
+	messages := []map[string]string{{"role": "user", "content": "在吗"}}
+	fast := ai.ResilienceOptions{MaxAttempts: 3, BaseDelay: 10 * time.Millisecond, MaxDelay: 2 * time.Second}
+
+	// 服务端错误按指数退避重试
+	u := &upstream{statuses: []int{http.StatusServiceUnavailable, http.StatusBadGateway}}
+	client, closeServer := newClient(u, fast)
+	completion, err := client.GenerateResponse(ctx, messages, ai.GenerateOptions{})
+	testutil.Check("服务端错误重试后成功", err == nil && completion.Content == "你好" && u.calls.Load() == 3, completion, err, u.calls.Load())
+	closeServer()
+
+	// 限流时遵循Retry-After
+	u = &upstream{statuses: []int{http.StatusTooManyRequests}, retryAfter: "1"}
+	client, closeServer = newClient(u, fast)
+	start := time.Now()
+	_, err = client.GenerateResponse(ctx, messages, ai.GenerateOptions{})
+	testutil.Check("遵循Retry-After", err == nil && time.Since(start) >= time.Second && u.calls.Load() == 2, err, time.Since(start))
+	closeServer()
+
+	// 要求等待太久时不再重试
+	u = &upstream{statuses: []int{http.StatusTooManyRequests}, retryAfter: "120"}
+	client, closeServer = newClient(u, fast)
+	_, err = client.GenerateResponse(ctx, messages, ai.GenerateOptions{})
+	var apiErr *ai.APIError
+	testutil.Check("等待太久时直接返回错误", errors.As(err, &apiErr) && apiErr.StatusCode == 429 && apiErr.RetryAfter == 120*time.Second && u.calls.Load() == 1, err, u.calls.Load())
+	closeServer()
+
+	// 请求本身有误时不重试
+	u = &upstream{statuses: []int{http.StatusBadRequest}}
+	client, closeServer = newClient(u, fast)
+	_, err = client.GenerateResponse(ctx, messages, ai.GenerateOptions{})
+	testutil.Check("客户端错误不重试", err != nil && u.calls.Load() == 1, err, u.calls.Load())
+	closeServer()
+
+	// 流式调用在返回内容之前失败时重试
+	u = &upstream{statuses: []int{http.StatusInternalServerError}}
+	client, closeServer = newClient(u, fast)
+	var chunks []string
+	completion, err = client.GenerateStreamResponse(ctx, messages, ai.GenerateOptions{}, func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	testutil.Check("流式调用重试", err == nil && completion.Content == "你好" && len(chunks) == 1 && u.calls.Load() == 2, completion, err, chunks)
+	closeServer()
+
+	// 单次调用超时后重试，调用方的上下文不受影响
+	u = &upstream{delay: 300 * time.Millisecond}
+	timeout := fast
+	timeout.Timeout = 100 * time.Millisecond
+	timeout.MaxAttempts = 2
+	client, closeServer = newClient(u, timeout)
+	_, err = client.GenerateResponse(ctx, messages, ai.GenerateOptions{})
+	testutil.Check("单次调用超时", err != nil && u.calls.Load() == 2, err, u.calls.Load())
+	closeServer()
+
+	// 流式调用只限制等待第一个数据块的时间
+	u = &upstream{delay: 300 * time.Millisecond}
+	client, closeServer = newClient(u, timeout)
+	_, err = client.GenerateStreamResponse(ctx, messages, ai.GenerateOptions{}, func(string) error { return nil })
+	testutil.Check("流式调用等待第一个数据块超时后重试", errors.Is(err, context.DeadlineExceeded) && u.calls.Load() == 2, err, u.calls.Load())
+	closeServer()
+
+	u = &upstream{chunkDelay: 80 * time.Millisecond}
+	client, closeServer = newClient(u, timeout)
+	chunks = nil
+	completion, err = client.GenerateStreamResponse(ctx, messages, ai.GenerateOptions{}, func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	testutil.Check("开始输出后总时长超过超时时间也不中断", err == nil && len(chunks) == 3 && completion.Content == "你好你好你好" && u.calls.Load() == 1, completion, err, chunks)
+	closeServer()
+
+	// 连续失败后熔断，冷却后放行一个探测请求，成功后恢复
+	u = &upstream{statuses: []int{500, 500, 500}}
+	breaker := ai.ResilienceOptions{MaxAttempts: 1, BreakerThreshold: 2, BreakerCooldown: 200 * time.Millisecond}
+	client, closeServer = newClient(u, breaker)
+	client.GenerateResponse(ctx, messages, ai.GenerateOptions{})
+	client.GenerateResponse(ctx, messages, ai.GenerateOptions{})
+	_, err = client.GenerateResponse(ctx, messages, ai.GenerateOptions{})
+	testutil.Check("连续失败后熔断", errors.Is(err, ai.ErrCircuitOpen) && u.calls.Load() == 2 && client.BreakerStatus().State == ai.BreakerOpen, err, client.BreakerStatus())
+
+	// 健康检查报告熔断状态
+	router := ai.NewLLMRouter("test-model")
+	router.Register(ai.LLMProvider{Name: "test", Client: client, Models: []string{"*"}})
+	gin.SetMode(gin.ReleaseMode)
+	engine := gin.New()
+	engine.GET("/health", api.NewHealthHandler(router).HealthCheck)
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/health", nil))
+	var health struct {
+		Status    string             `json:"status"`
+		Providers []ai.BreakerStatus `json:"llm_providers"`
+	}
+	json.Unmarshal(recorder.Body.Bytes(), &health)
+	testutil.Check("健康检查报告熔断状态", recorder.Code == 200 && health.Status == "degraded" && len(health.Providers) == 1 && health.Providers[0].State == ai.BreakerOpen, recorder.Body.String())
+
+	time.Sleep(250 * time.Millisecond)
+	testutil.Check("冷却后进入半开状态", client.BreakerStatus().State == ai.BreakerHalfOpen, client.BreakerStatus())
+	_, err = client.GenerateResponse(ctx, messages, ai.GenerateOptions{})
+	testutil.Check("探测失败后重新熔断", err != nil && client.BreakerStatus().State == ai.BreakerOpen && u.calls.Load() == 3, err, client.BreakerStatus())
+	time.Sleep(250 * time.Millisecond)
+	_, err = client.GenerateResponse(ctx, messages, ai.GenerateOptions{})
+	testutil.Check("探测成功后恢复", err == nil && client.BreakerStatus().State == ai.BreakerClosed, err, client.BreakerStatus())
+	closeServer()
+
+	testutil.Finish()
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"chat_agent/internal/ai"
+	"chat_agent/test/internal/testutil"
+)
+
+// lastModel 返回模拟模型客户端最后一次调用使用的模型名称
+func lastModel(client *ai.FakeLLMClient) string {
+	calls := client.Calls()
+	if len(calls) == 0 {
+		return ""
+	}
+	return calls[len(calls)-1].Options.Model
+}
+
+// 验证按模型名称路由到不同提供方和Anthropic客户端，不需要网络：
+//
+//	go run ./test/llm_router
+func main() {
+	// 创建上下文
+	ctx := context.Background()
+	openai := testutil.FakeLLM("openai")
+	ark := testutil.FakeLLM("ark")
+	ollama := testutil.FakeLLM("ollama")
+
+	router := ai.NewLLMRouter("doubao-1.5-pro-32k-250115")
+	router.Register(ai.LLMProvider{Name: "openai", Client: openai, Models: []string{"gpt-*", "o1"}})
+	router.Register(ai.LLMProvider{Name: "ark", Client: ark, Models: []string{"doubao-*", "doubao-1.5-pro-32k-250115"}})
+	router.Register(ai.LLMProvider{Name: "ollama", Client: ollama, Models: []string{"gpt-oss*", "qwen*"}})
+	router.Alias("fast", "qwen2.5:7b")
+	router.Alias("local", "ollama/llama3")
+
+	// 按模型名称选择提供方，前缀越长越优先
+	completion, err := router.GenerateResponse(ctx, nil, ai.GenerateOptions{Model: "gpt-4o"})
+	testutil.Check("按前缀路由", err == nil && completion.Content == "openai" && lastModel(openai) == "gpt-4o", completion, err)
+	completion, _ = router.GenerateResponse(ctx, nil, ai.GenerateOptions{Model: "gpt-oss-20b"})
+	testutil.Check("更长的前缀优先", completion.Content == "ollama", completion)
+	completion, _ = router.GenerateResponse(ctx, nil, ai.GenerateOptions{Model: "o1"})
+	testutil.Check("按完整名称路由", completion.Content == "openai", completion)
+
+	// 未指定模型时使用默认模型
+	completion, _ = router.GenerateResponse(ctx, nil, ai.GenerateOptions{})
+	testutil.Check("使用默认模型", completion.Content == "ark" && lastModel(ark) == "doubao-1.5-pro-32k-250115", completion)
+
+	// 别名和"提供方/模型"
+	completion, _ = router.GenerateResponse(ctx, nil, ai.GenerateOptions{Model: "fast"})
+	testutil.Check("别名展开为模型名称", completion.Content == "ollama" && lastModel(ollama) == "qwen2.5:7b", completion, lastModel(ollama))
+	completion, _ = router.GenerateResponse(ctx, nil, ai.GenerateOptions{Model: "local"})
+	testutil.Check("提供方前缀直接指定提供方", completion.Content == "ollama" && lastModel(ollama) == "llama3", completion, lastModel(ollama))
+
+	// 按解析后的名称查找上下文长度
+	windows := ai.NewContextWindows(map[string]int{"qwen2.5*": 32768}, 4096)
+	testutil.Check("解析别名后的模型名称", router.ResolveModelName("fast") == "ollama/qwen2.5:7b" && router.ResolveModelName("") == "ark/doubao-1.5-pro-32k-250115", router.ResolveModelName("fast"))
+	testutil.Check("按别名指向的模型查找上下文长度", windows.Lookup(router.ResolveModelName("fast")) == 32768 && windows.Lookup("fast") == 4096)
+	testutil.Check("未指定模型时按默认模型查找上下文长度", windows.Lookup(router.ResolveModelName("")) == 32768, windows.Lookup(router.ResolveModelName("")))
+	testutil.Check("无法路由的模型名称原样返回", router.ResolveModelName("claude-3-5-sonnet") == "claude-3-5-sonnet")
+
+	// 未知模型在调用前被拒绝
+	_, err = router.GenerateResponse(ctx, nil, ai.GenerateOptions{Model: "claude-3-5-sonnet"})
+	testutil.Check("拒绝未知模型", errors.Is(err, ai.ErrUnknownModel) && strings.Contains(err.Error(), "claude-3-5-sonnet"), err)
+	testutil.Check("检查模型", router.ValidateModel("gpt-4o") == nil && router.ValidateModel("llama3") != nil)
+
+	// Anthropic客户端：系统消息合并为system参数，解析非流式和流式响应
+	var request map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" || r.Header.Get("x-api-key") != "test-key" || r.Header.Get("anthropic-version") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		if request["stream"] == true {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"model\":\"claude-test\",\"usage\":{\"input_tokens\":12}}}\n\n")
+			fmt.Fprint(w, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"你好\"}}\n\n")
+			fmt.Fprint(w, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"呀\"}}\n\n")
+			fmt.Fprint(w, "event: message_delta\ndata: {\"type\":\"message_delta\",\"usage\":{\"output_tokens\":3}}\n\n")
+			fmt.Fprint(w, "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
+			return
+		}
+		fmt.Fprint(w, `{"model":"claude-test","content":[{"type":"text","text":"你好"}],"usage":{"input_tokens":12,"output_tokens":2}}`)
+	}))
+	defer server.Close()
+
+	client := ai.NewAnthropicClient("test-key", server.URL, "claude-test", nil)
+	messages := []map[string]string{
+		{"role": "system", "content": "你是明星"},
+		{"role": "system", "content": "## 记忆"},
+		{"role": "user", "content": "在吗"},
+		{"role": "user", "content": "你好"},
+	}
+	completion, err = client.GenerateResponse(ctx, messages, ai.GenerateOptions{})
+	sent, _ := request["messages"].([]interface{})
+	testutil.Check("Anthropic非流式响应", err == nil && completion.Content == "你好" && completion.Usage.TotalTokens == 14, completion, err)
+	testutil.Check("系统消息合并为system参数", request["system"] == "你是明星\n\n## 记忆" && len(sent) == 1, request)
+
+	var chunks []string
+	completion, err = client.GenerateStreamResponse(ctx, messages, ai.GenerateOptions{}, func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	testutil.Check("Anthropic流式响应", err == nil && completion.Content == "你好呀" && len(chunks) == 2 && completion.Usage.CompletionTokens == 3, completion, chunks, err)
+
+	_, err = ai.NewAnthropicClient("wrong-key", server.URL, "claude-test", nil).GenerateResponse(ctx, messages, ai.GenerateOptions{})
+	testutil.Check("Anthropic错误响应", err != nil && strings.Contains(err.Error(), "401"), err)
+
+	testutil.Finish()
+}
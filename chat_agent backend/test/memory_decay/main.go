@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+
+	"chat_agent/internal/ai"
+	"chat_agent/internal/models"
+	"chat_agent/test/internal/testutil"
+)
+
+// 验证记忆的衰减、强化、清理和置顶，使用很短的半衰期，不需要网络：
+//
+//	go run ./test/memory_decay
+func main() {
+	// 创建上下文
+	ctx := context.Background()
+
+	// 衰减计算：经过一个半衰期权重减半
+	decay := ai.MemoryDecay{HalfLife: time.Hour, ReinforceBoost: 0.5, MaxWeight: 2, PruneBelow: 0.1}
+	start := time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC)
+	testutil.Check("半衰期后权重减半", math.Abs(decay.EffectiveWeight(1, start, start.Add(time.Hour))-0.5) < 1e-9)
+	testutil.Check("强化不超过上限", decay.Reinforce(1.8, start, start) == 2)
+
+	// 排序分数与时间无关：新的低权重记忆和旧的高权重记忆按有效权重比较
+	older := decay.RankScore(1, start)
+	newer := decay.RankScore(0.4, start.Add(2*time.Hour))
+	testutil.Check("按有效权重排序", newer > older, older, newer)
+	testutil.Check("由分数反推有效权重", math.Abs(decay.WeightFromScore(older, start.Add(time.Hour))-0.5) < 1e-9)
+
+	// 回复引用判断
+	testutil.Check("识别被引用的记忆", ai.MemoryReferenced("用户的生日是5月3日", "当然记得，你的生日是5月3日呀！"))
+	testutil.Check("忽略未引用的记忆", !ai.MemoryReferenced("用户养了一只叫豆豆的猫", "今天的演唱会很精彩"))
+
+	// 记忆管理器按有效权重排序、强化和清理
+	manager := ai.NewInMemoryManagerWithOptions(ai.InMemoryOptions{
+		Decay: ai.MemoryDecay{HalfLife: 50 * time.Millisecond, ReinforceBoost: 1, MaxWeight: 5, PruneBelow: 0.1},
+	})
+	manager.AddLongTermMemory(ctx, 1, "旧的重要记忆", 1.0)
+	time.Sleep(100 * time.Millisecond) // 有效权重衰减到约0.25
+	manager.AddLongTermMemory(ctx, 1, "新的普通记忆", 0.5)
+	memories, _ := manager.GetLongTermMemory(ctx, 1, 10)
+	testutil.Check("新记忆排在衰减后的旧记忆之前", len(memories) == 2 && memories[0] == "新的普通记忆", memories)
+
+	manager.ReinforceMemory(ctx, 1, "旧的重要记忆") // 约0.25 + 1
+	memories, _ = manager.GetLongTermMemory(ctx, 1, 10)
+	testutil.Check("强化后排到前面", len(memories) == 2 && memories[0] == "旧的重要记忆", memories)
+
+	time.Sleep(150 * time.Millisecond) // 旧记忆约0.16，新记忆约0.06
+	stats, err := manager.CollectGarbage(ctx)
+	memories, _ = manager.GetLongTermMemory(ctx, 1, 10)
+	testutil.Check("清理有效权重过低的记忆", err == nil && stats.Pruned == 1 && len(memories) == 1 && memories[0] == "旧的重要记忆", stats, memories, err)
+
+	// 置顶的记忆不会被清理，并且总是出现在提示词中
+	manager.AddLongTermMemory(ctx, 2, "用户的名字是小明", 1.0)
+	items, _ := manager.ListMemories(ctx, 2)
+	pinned := true
+	manager.UpdateMemory(ctx, 2, items[0].ID, ai.MemoryUpdate{Pinned: &pinned})
+	time.Sleep(300 * time.Millisecond)
+	manager.CollectGarbage(ctx)
+	items, _ = manager.ListMemories(ctx, 2)
+	testutil.Check("置顶的记忆不会被清理", len(items) == 1 && items[0].Pinned, items)
+
+	prompt := ai.NewPromptTemplate().BuildMemoryPrompt(&models.Star{Name: "测试明星"}, []string{"用户的名字是小明"}, []string{"用户住在杭州", "用户的名字是小明"})
+	testutil.Check("置顶的记忆排在提示词最前", strings.Index(prompt, "用户的名字是小明") < strings.Index(prompt, "用户住在杭州") &&
+		strings.Count(prompt, "用户的名字是小明") == 1 && strings.Contains(prompt, "前1条"), prompt)
+
+	testutil.Finish()
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"chat_agent/internal/ai"
+	"chat_agent/test/internal/testutil"
+)
+
+// 验证记忆的过期清理和删除会话、消息时的记忆清除，不需要网络：
+//
+//	go run ./test/memory_gc
+func main() {
+	// 创建上下文
+	ctx := context.Background()
+	manager := ai.NewInMemoryManagerWithOptions(ai.InMemoryOptions{
+		TTL: ai.MemoryTTL{ShortTerm: 100 * time.Millisecond, LongTerm: 200 * time.Millisecond},
+	})
+
+	// 过期的短期记忆被清理，未过期的保留
+	manager.AddShortTermMemory(ctx, 1, "很早的消息")
+	manager.AddLongTermMemory(ctx, 1, "用户喜欢猫", 1.0)
+	manager.AddLongTermMemory(ctx, 1, "用户的名字是小明", 1.0)
+	items, _ := manager.ListMemories(ctx, 1)
+	pin := true
+	for _, item := range items {
+		if item.Content == "用户的名字是小明" {
+			manager.UpdateMemory(ctx, 1, item.ID, ai.MemoryUpdate{Pinned: &pin})
+		}
+	}
+	time.Sleep(150 * time.Millisecond)
+	manager.AddShortTermMemory(ctx, 1, "刚刚的消息")
+	stats, err := manager.CollectGarbage(ctx)
+	shortTerm, _ := manager.GetShortTermMemory(ctx, 1, 10)
+	testutil.Check("清理过期的短期记忆", err == nil && stats.ExpiredShortTerm == 1 && len(shortTerm) == 1 && shortTerm[0] == "刚刚的消息", stats, shortTerm, err)
+
+	// 长期记忆从最后一次更新开始计时，强化后重新计时，置顶的记忆不过期
+	manager.AddLongTermMemory(ctx, 1, "用户住在杭州", 1.0)
+	time.Sleep(100 * time.Millisecond)
+	manager.ReinforceMemory(ctx, 1, "用户住在杭州")
+	time.Sleep(150 * time.Millisecond)
+	stats, _ = manager.CollectGarbage(ctx)
+	longTerm, _ := manager.GetLongTermMemory(ctx, 1, 10)
+	testutil.Check("清理过期的长期记忆", stats.ExpiredLongTerm == 1 && len(longTerm) == 2, stats, longTerm)
+	testutil.Check("置顶的记忆不过期", len(longTerm) == 2 && longTerm[0] == "用户的名字是小明", longTerm)
+	testutil.Check("强化后重新计时", len(longTerm) == 2 && longTerm[1] == "用户住在杭州", longTerm)
+	testutil.Check("统计清理总数", stats.Total() == stats.ExpiredShortTerm+stats.ExpiredLongTerm+stats.Pruned, stats)
+
+	// 删除消息时忘记内容相同的短期和长期记忆，置顶的记忆保留
+	manager.AddShortTermMemory(ctx, 2, "我住在杭州")
+	manager.AddShortTermMemory(ctx, 2, "你好")
+	manager.AddLongTermMemory(ctx, 2, "用户住在杭州", 1.0)
+	forgotten, err := manager.ForgetMemory(ctx, 2, "我住在杭州")
+	forgotten2, _ := manager.ForgetMemory(ctx, 2, "用户住在杭州")
+	shortTerm, _ = manager.GetShortTermMemory(ctx, 2, 10)
+	longTerm, _ = manager.GetLongTermMemory(ctx, 2, 10)
+	testutil.Check("删除消息忘记相关记忆", err == nil && forgotten == 1 && forgotten2 == 1 && len(shortTerm) == 1 && len(longTerm) == 0, forgotten, forgotten2, shortTerm, longTerm)
+	forgotten, _ = manager.ForgetMemory(ctx, 1, "用户的名字是小明")
+	testutil.Check("忘记记忆时保留置顶的记忆", forgotten == 0, forgotten)
+
+	// 删除会话时清除全部记忆并释放内存
+	deleted, err := manager.DeleteChatMemory(ctx, 1)
+	deleted2, _ := manager.DeleteChatMemory(ctx, 2)
+	testutil.Check("删除会话清除全部记忆", err == nil && deleted == 2 && deleted2 == 1 && manager.ChatCount() == 0, deleted, deleted2, manager.ChatCount())
+	testutil.Check("删除会话后释放内存", manager.UsedBytes() == 0, manager.UsedBytes())
+
+	// 语义检索的向量索引同步忘记
+	semantic := ai.NewSemanticMemoryManager(ai.NewInMemoryManager(), ai.NewHashEmbedder(256), ai.NewVectorIndex(50), 0.1)
+	semantic.AddLongTermMemory(ctx, 3, "用户喜欢猫", 1.0)
+	found, _ := semantic.SearchMemory(ctx, 3, "用户喜欢猫", 5)
+	semantic.ForgetMemory(ctx, 3, "用户喜欢猫")
+	after, _ := semantic.SearchMemory(ctx, 3, "用户喜欢猫", 5)
+	testutil.Check("语义检索不再返回已忘记的记忆", len(found) == 1 && len(after) == 0, found, after)
+
+	testutil.Finish()
+}
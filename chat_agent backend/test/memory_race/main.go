@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"chat_agent/internal/ai"
+	"chat_agent/test/internal/memorycheck"
+	"chat_agent/test/internal/testutil"
+)
+
+// 并发压测内存记忆管理器，需要配合竞态检测运行：
+//
+//	go run -race ./test/memory_race
+func main() {
+	// 创建上下文
+	ctx := context.Background()
+
+	// 预算很小，保证压测过程中会频繁触发淘汰
+	const (
+		maxBytes     = 64 << 10
+		shortTermCap = 10
+		longTermCap  = 20
+		updatedTo    = 10.0 // 压测写入的权重为0到9，修改后的权重与之区分
+	)
+	manager := ai.NewInMemoryManagerWithOptions(ai.InMemoryOptions{
+		Shards:       4,
+		MaxBytes:     maxBytes,
+		ShortTermCap: shortTermCap,
+		LongTermCap:  longTermCap,
+	})
+
+	// 模拟多个HTTP请求和流式回复协程同时读写同一批会话，按ListMemories返回的真实ID修改权重
+	var wg sync.WaitGroup
+	var updated atomic.Int64
+	for worker := 0; worker < 32; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				chatID := uint((worker*7+i)%64 + 1)
+				content := fmt.Sprintf("worker%d-message%d %s", worker, i, strings.Repeat("x", i%200))
+
+				manager.AddShortTermMemory(ctx, chatID, content)
+				manager.AddLongTermMemory(ctx, chatID, content, float64(i%10))
+				manager.GetShortTermMemory(ctx, chatID, 5)
+				manager.GetLongTermMemory(ctx, chatID, 5)
+				manager.SearchMemory(ctx, chatID, "message", 3)
+				items, _ := manager.ListMemories(ctx, chatID)
+				for _, item := range items {
+					if item.Content == content {
+						if manager.UpdateMemoryWeight(ctx, item.ID, updatedTo) == nil {
+							updated.Add(1)
+						}
+						break
+					}
+				}
+				if i%50 == 0 {
+					manager.ClearShortTermMemory(ctx, chatID)
+				}
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	// 检查内存预算
+	fmt.Printf("会话数量: %d，内存占用: %d字节，修改权重: %d次\n", manager.ChatCount(), manager.UsedBytes(), updated.Load())
+	testutil.Check("内存占用不超出预算", manager.UsedBytes() <= maxBytes, manager.UsedBytes())
+	testutil.Check("按真实ID修改了记忆权重", updated.Load() > 0, updated.Load())
+
+	// 压测后每个会话的记忆条数不超过上限，ID不重复，权重只会是写入或修改后的值
+	for chatID := uint(1); chatID <= 64; chatID++ {
+		items, _ := manager.ListMemories(ctx, chatID)
+		shortTerm, _ := manager.GetShortTermMemory(ctx, chatID, 100)
+		ids := make(map[string]bool)
+		weightsValid := true
+		for _, item := range items {
+			ids[item.ID] = true
+			if item.Weight != updatedTo && (item.Weight < 0 || item.Weight > 9 || item.Weight != float64(int(item.Weight))) {
+				weightsValid = false
+			}
+		}
+		name := fmt.Sprintf("会话%d", chatID)
+		testutil.Check(name+"的长期记忆不超过上限", len(items) <= longTermCap, len(items))
+		testutil.Check(name+"的短期记忆不超过上限", len(shortTerm) <= shortTermCap, len(shortTerm))
+		testutil.Check(name+"的记忆ID不重复", len(ids) == len(items), len(ids), len(items))
+		testutil.Check(name+"的记忆权重有效", weightsValid, items)
+	}
+
+	// 多个协程并发写入同一会话并修改各自记忆的权重，结束后条数和权重都准确
+	const concurrentChat = 2000
+	for worker := 0; worker < longTermCap; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			content := fmt.Sprintf("并发写入的记忆%d", worker)
+			manager.AddLongTermMemory(ctx, concurrentChat, content, 1)
+			items, _ := manager.ListMemories(ctx, concurrentChat)
+			for _, item := range items {
+				if item.Content == content {
+					manager.UpdateMemoryWeight(ctx, item.ID, float64(worker+2))
+				}
+			}
+		}(worker)
+	}
+	wg.Wait()
+	items, _ := manager.ListMemories(ctx, concurrentChat)
+	testutil.Check("并发写入的记忆全部保存", len(items) == longTermCap, len(items))
+	weightsMatch := len(items) > 0
+	for _, item := range items {
+		var worker int
+		if _, err := fmt.Sscanf(item.Content, "并发写入的记忆%d", &worker); err != nil || item.Weight != float64(worker+2) {
+			weightsMatch = false
+		}
+	}
+	testutil.Check("并发修改的权重全部生效", weightsMatch, items)
+	testutil.Check("修改权重后按权重排序", len(items) > 0 && items[0].Weight == float64(longTermCap+1), items)
+
+	// 并发按同一个键写入不同的内容，最终只保留一条带键的记忆
+	const upsertChat = 2001
+	manager.AddLongTermMemory(ctx, upsertChat, "用户的生日是1月1日", 1)
+	for worker := 0; worker < 20; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			manager.UpsertLongTermMemory(ctx, upsertChat, "生日", fmt.Sprintf("用户的生日是1月%d日", worker%5+1), 1)
+		}(worker)
+	}
+	wg.Wait()
+	items, _ = manager.ListMemories(ctx, upsertChat)
+	keyed := 0
+	for _, item := range items {
+		if item.Key == "生日" {
+			keyed++
+		}
+	}
+	testutil.Check("并发按键写入只保留一条记忆", keyed == 1, items)
+
+	// 与Redis、数据库实现的行为一致
+	memorycheck.Run(ctx, ai.NewInMemoryManagerWithOptions(ai.InMemoryOptions{
+		ShortTermCap: memorycheck.ShortTermCap,
+		LongTermCap:  memorycheck.LongTermCap,
+		Decay:        memorycheck.Decay,
+	}), 3000)
+
+	// 检查短期记忆上限和顺序
+	for i := 0; i < 15; i++ {
+		manager.AddShortTermMemory(ctx, 1000, fmt.Sprintf("m%d", i))
+	}
+	shortTerm, _ := manager.GetShortTermMemory(ctx, 1000, 20)
+	testutil.Check("短期记忆保留最近的10条", len(shortTerm) == 10 && shortTerm[0] == "m5" && shortTerm[9] == "m14", shortTerm)
+
+	// 检查长期记忆按权重排序并去重
+	manager.AddLongTermMemory(ctx, 1001, "低", 0.5)
+	manager.AddLongTermMemory(ctx, 1001, "高", 2)
+	manager.AddLongTermMemory(ctx, 1001, "高", 3)
+	longTerm, _ := manager.GetLongTermMemory(ctx, 1001, 10)
+	testutil.Check("长期记忆按权重排序并去重", len(longTerm) == 2 && longTerm[0] == "高", longTerm)
+
+	testutil.Finish()
+}
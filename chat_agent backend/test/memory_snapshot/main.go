@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"chat_agent/internal/ai"
+	"chat_agent/test/internal/testutil"
+)
+
+// 验证记忆快照的导出、导入和恢复，不需要网络：
+//
+//	go run ./test/memory_snapshot
+func main() {
+	// 创建上下文
+	ctx := context.Background()
+
+	// 准备源会话的记忆
+	source := ai.NewInMemoryManager()
+	source.AddShortTermMemory(ctx, 1, "你好")
+	source.AddShortTermMemory(ctx, 1, "今天过得怎么样")
+	source.UpsertLongTermMemory(ctx, 1, "名字", "用户的名字是小明", 2.0)
+	source.UpsertLongTermMemory(ctx, 1, "城市", "用户住在杭州", 1.3)
+	items, _ := source.ListMemories(ctx, 1)
+	pin := true
+	for _, item := range items {
+		if item.Key == "城市" {
+			source.UpdateMemory(ctx, 1, item.ID, ai.MemoryUpdate{Pinned: &pin})
+		}
+	}
+
+	// 导出并序列化为带版本的JSON
+	chatSnapshot, err := ai.ExportChatMemory(ctx, source, 1)
+	testutil.Check("导出会话记忆", err == nil && len(chatSnapshot.ShortTerm) == 2 && len(chatSnapshot.LongTerm) == 2, chatSnapshot, err)
+	data, err := ai.SerializeMemory(&ai.MemorySnapshot{Chats: []ai.ChatMemorySnapshot{*chatSnapshot}})
+	testutil.Check("快照包含版本号", err == nil && strings.Contains(data, fmt.Sprintf(`"version": %d`, ai.MemorySnapshotVersion)), data)
+
+	// 导入到另一个部署的会话
+	snapshot, err := ai.DeserializeMemory(data)
+	testutil.Check("反序列化快照", err == nil && len(snapshot.Chats) == 1, err)
+	target := ai.NewInMemoryManager()
+	target.UpsertLongTermMemory(ctx, 7, "城市", "用户住在上海", 1.0)
+	target.AddLongTermMemory(ctx, 7, "用户喜欢猫", 1.0)
+	err = ai.ImportChatMemory(ctx, target, 7, &snapshot.Chats[0], false)
+	imported, _ := target.ListMemories(ctx, 7)
+	byKey := make(map[string]ai.MemoryItem)
+	for _, item := range imported {
+		byKey[item.Key] = item
+	}
+	testutil.Check("合并导入按键替换并保留已有记忆", err == nil && len(imported) == 3 && byKey["城市"].Content == "用户住在杭州", imported, err)
+	testutil.Check("导入恢复置顶状态", byKey["城市"].Pinned, byKey["城市"])
+	testutil.Check("导入保留权重", math.Abs(byKey["名字"].Weight-2.0) < 0.01, byKey["名字"].Weight)
+	shortTerm, _ := target.GetShortTermMemory(ctx, 7, 10)
+	testutil.Check("导入短期记忆保持顺序", len(shortTerm) == 2 && shortTerm[0] == "你好", shortTerm)
+
+	// 以替换方式导入即为从快照恢复
+	target.AddLongTermMemory(ctx, 7, "快照之后的新记忆", 1.0)
+	err = ai.ImportChatMemory(ctx, target, 7, &snapshot.Chats[0], true)
+	restored, _ := target.ListMemories(ctx, 7)
+	shortTerm, _ = target.GetShortTermMemory(ctx, 7, 10)
+	testutil.Check("从快照恢复", err == nil && len(restored) == 2 && len(shortTerm) == 2, restored, shortTerm, err)
+
+	// 兼容旧版的长期记忆数组，拒绝未知版本
+	legacy, err := ai.DeserializeMemory(`[{"id":"1_1","chat_id":3,"type":"long_term","content":"用户喜欢猫","weight":1}]`)
+	testutil.Check("兼容旧版数组", err == nil && len(legacy.Chats) == 1 && legacy.Chats[0].ChatID == 3 && len(legacy.Chats[0].LongTerm) == 1, legacy, err)
+	_, err = ai.DeserializeMemory(`{"version": 99, "chats": []}`)
+	testutil.Check("拒绝未知版本", err != nil)
+
+	testutil.Finish()
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"chat_agent/internal/ai"
+	"chat_agent/internal/models"
+	"chat_agent/test/internal/testutil"
+)
+
+// newClient 创建模拟模型客户端，failing中的模型调用失败，partial中的模型流式输出一段内容后失败，其他模型回复"来自<模型>的回复"
+func newClient(failing, partial []string) *ai.FakeLLMClient {
+	var rules []ai.FakeRule
+	for _, model := range failing {
+		rules = append(rules, ai.FakeRule{Model: model, StatusCode: 503})
+	}
+	for _, model := range partial {
+		rules = append(rules, ai.FakeRule{Model: model, Reply: "说到一半", StatusCode: 503})
+	}
+	return testutil.FakeLLM("来自模型的回复", rules...)
+}
+
+// 验证模型回退链，不需要网络：
+//
+//	go run ./test/model_fallback
+func main() {
+	// 创建上下文
+	ctx := context.Background()
+
+	// 明星的回退模型和回退链
+	star := &models.Star{FallbackModels: " gpt-4o-mini, ,claude-3-5-haiku "}
+	fallbacks := star.FallbackModelList()
+	testutil.Check("解析明星的回退模型", len(fallbacks) == 2 && fallbacks[0] == "gpt-4o-mini" && fallbacks[1] == "claude-3-5-haiku", fallbacks)
+	chain := ai.FallbackChain("doubao-pro", append([]string{"doubao-pro"}, fallbacks...))
+	testutil.Check("回退链去掉重复的模型", len(chain) == 3 && chain[0] == "doubao-pro", chain)
+
+	// 请求的模型可用时不回退
+	client := newClient(nil, nil)
+	completion, index, err := ai.GenerateWithFallback(ctx, client, nil, ai.GenerateOptions{}, chain)
+	testutil.Check("请求的模型直接回复", err == nil && index == 0 && completion.Model == "doubao-pro" && len(client.Calls()) == 1, completion, err)
+
+	// 请求的模型失败时依次尝试回退模型，记录实际回复的模型
+	client = newClient([]string{"doubao-pro", "gpt-4o-mini"}, nil)
+	completion, index, err = ai.GenerateWithFallback(ctx, client, nil, ai.GenerateOptions{}, chain)
+	testutil.Check("依次尝试回退模型", err == nil && index == 2 && completion.Model == "claude-3-5-haiku" && len(client.Calls()) == 3, completion, index, client.Calls())
+
+	// 全部失败时返回包含每个模型错误的错误
+	client = newClient(chain, nil)
+	_, _, err = ai.GenerateWithFallback(ctx, client, nil, ai.GenerateOptions{}, chain)
+	var apiErr *ai.APIError
+	testutil.Check("全部失败时返回错误", errors.Is(err, ai.ErrAllModelsFailed) && errors.As(err, &apiErr) &&
+		strings.Contains(err.Error(), "gpt-4o-mini: ") && strings.Contains(err.Error(), "claude-3-5-haiku: "), err)
+
+	// 流式调用在输出内容之前失败时回退
+	client = newClient([]string{"doubao-pro"}, nil)
+	var chunks []string
+	completion, index, err = ai.GenerateStreamWithFallback(ctx, client, nil, ai.GenerateOptions{}, chain, func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	testutil.Check("流式调用回退", err == nil && index == 1 && completion.Model == "gpt-4o-mini" && strings.Join(chunks, "") == "来自模型的回复", completion, err, chunks)
+
+	// 已经输出内容后失败不再回退，避免拼接两个模型的回复
+	client = newClient(nil, []string{"doubao-pro"})
+	chunks = nil
+	_, _, err = ai.GenerateStreamWithFallback(ctx, client, nil, ai.GenerateOptions{}, chain, func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	testutil.Check("输出内容后不再回退", err != nil && len(client.Calls()) == 1 && strings.Join(chunks, "") == "说到一半", err, chunks)
+
+	// 调用方取消后不再尝试其他模型
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	client = newClient([]string{"doubao-pro"}, nil)
+	_, _, err = ai.GenerateWithFallback(canceled, client, nil, ai.GenerateOptions{}, chain)
+	testutil.Check("取消后不再回退", err != nil && len(client.Calls()) == 1, err, client.Calls())
+
+	testutil.Finish()
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"chat_agent/internal/auth"
+	"chat_agent/internal/auth/oidctest"
+	"chat_agent/internal/models"
+	"chat_agent/internal/repository"
+	"chat_agent/internal/service"
+	"chat_agent/test/internal/testutil"
+
+	"gorm.io/gorm"
+)
+
+// MockUserRepository 模拟的用户仓库，只实现单点登录用到的方法
+type MockUserRepository struct {
+	repository.UserRepository
+	users []*models.User
+}
+
+// Create 实现仓库接口
+func (r *MockUserRepository) Create(ctx context.Context, user *models.User) error {
+	user.ID = uint(len(r.users) + 1)
+	r.users = append(r.users, user)
+	return nil
+}
+
+// GetByID 实现仓库接口
+func (r *MockUserRepository) GetByID(ctx context.Context, id uint) (*models.User, error) {
+	for _, user := range r.users {
+		if user.ID == id {
+			return user, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// GetByUsername 实现仓库接口
+func (r *MockUserRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	for _, user := range r.users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// GetByEmail 实现仓库接口
+func (r *MockUserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	for _, user := range r.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// MockUserIdentityRepository 模拟的外部身份仓库
+type MockUserIdentityRepository struct {
+	identities []*models.UserIdentity
+}
+
+// Create 实现仓库接口
+func (r *MockUserIdentityRepository) Create(ctx context.Context, identity *models.UserIdentity) error {
+	identity.ID = uint(len(r.identities) + 1)
+	r.identities = append(r.identities, identity)
+	return nil
+}
+
+// GetByIssuerSubject 实现仓库接口
+func (r *MockUserIdentityRepository) GetByIssuerSubject(ctx context.Context, issuer, subject string) (*models.UserIdentity, error) {
+	for _, identity := range r.identities {
+		if identity.Issuer == issuer && identity.Subject == subject {
+			return identity, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// Update 实现仓库接口
+func (r *MockUserIdentityRepository) Update(ctx context.Context, identity *models.UserIdentity) error {
+	return nil
+}
+
+// MockAuthService 模拟的认证服务，只实现签发令牌
+type MockAuthService struct {
+	service.AuthService
+}
+
+// CreateSession 实现服务接口
+func (s *MockAuthService) CreateSession(ctx context.Context, user *models.User) (*models.AuthResponse, error) {
+	return &models.AuthResponse{AccessToken: "access", User: user.ToUserResponse()}, nil
+}
+
+// authorize 访问授权地址，返回身份提供方跳回时携带的授权码和state
+func authorize(client *http.Client, authURL string) (string, string, error) {
+	noRedirect := *client
+	noRedirect.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	resp, err := noRedirect.Get(authURL)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return "", "", err
+	}
+	return location.Query().Get("code"), location.Query().Get("state"), nil
+}
+
+func main() {
+	// 创建上下文
+	ctx := context.Background()
+
+	// 启动进程内的身份提供方，整个流程不需要访问网络
+	idp := oidctest.NewServer("chat-agent", "secret")
+	defer idp.Close()
+
+	provider := auth.NewOIDCProvider(auth.OIDCConfig{
+		IssuerURL:    idp.Issuer(),
+		ClientID:     "chat-agent",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost:8000/api/v1/auth/oidc/callback",
+		Scopes:       []string{"profile", "email"},
+	}, idp.Client())
+	tokenManager := auth.NewTokenManager("test-secret", 0)
+	userRepo := &MockUserRepository{}
+	identityRepo := &MockUserIdentityRepository{}
+	ssoService := service.NewSSOService(provider, tokenManager, userRepo, identityRepo, &MockAuthService{})
+
+	// login 完整走一遍单点登录流程
+	login := func() (*models.AuthResponse, error) {
+		authURL, stateToken, err := ssoService.BeginLogin(ctx)
+		if err != nil {
+			return nil, err
+		}
+		code, state, err := authorize(idp.Client(), authURL)
+		if err != nil {
+			return nil, err
+		}
+		return ssoService.CompleteLogin(ctx, stateToken, state, code)
+	}
+
+	// 首次登录创建用户
+	first, err := login()
+	testutil.Check("首次登录创建用户", err == nil && first.User.Username == "testuser" && first.User.Email == "user1@example.com", err)
+
+	// 同一issuer+subject再次登录关联到同一用户
+	second, err := login()
+	testutil.Check("再次登录关联同一用户", err == nil && first != nil && second.User.ID == first.User.ID, err)
+
+	// 新的subject但邮箱已验证且已存在，关联到已有用户
+	idp.SetUser(oidctest.User{Subject: "user-2", Email: "user1@example.com", EmailVerified: true, PreferredUsername: "other"})
+	linked, err := login()
+	testutil.Check("已验证邮箱关联已有用户", err == nil && first != nil && linked.User.ID == first.User.ID, err)
+
+	// 邮箱未验证时不能关联已有用户，使用占位邮箱创建新用户
+	idp.SetUser(oidctest.User{Subject: "user-3", Email: "user1@example.com", PreferredUsername: "testuser"})
+	unverified, err := login()
+	testutil.Check("未验证邮箱创建新用户", err == nil && first != nil && unverified.User.ID != first.User.ID &&
+		unverified.User.Email != "user1@example.com" && unverified.User.Username != "testuser", err)
+
+	// state不匹配时拒绝登录
+	authURL, stateToken, _ := ssoService.BeginLogin(ctx)
+	code, _, _ := authorize(idp.Client(), authURL)
+	_, err = ssoService.CompleteLogin(ctx, stateToken, "forged-state", code)
+	testutil.Check("拒绝伪造的state", err != nil)
+
+	// 授权码只能使用一次
+	authURL, stateToken, _ = ssoService.BeginLogin(ctx)
+	code, state, _ := authorize(idp.Client(), authURL)
+	_, err = ssoService.CompleteLogin(ctx, stateToken, state, code)
+	testutil.Check("授权码首次使用成功", err == nil, err)
+	_, err = ssoService.CompleteLogin(ctx, stateToken, state, code)
+	testutil.Check("拒绝重复使用的授权码", err != nil)
+
+	// PKCE校验：code_verifier不匹配时身份提供方拒绝签发令牌
+	_, challenge, _ := auth.GeneratePKCE()
+	authURL, _ = provider.AuthCodeURL(ctx, "state", "nonce", challenge)
+	code, _, _ = authorize(idp.Client(), authURL)
+	_, err = provider.Exchange(ctx, code, "wrong-verifier", "nonce")
+	testutil.Check("拒绝错误的code_verifier", err != nil)
+
+	// nonce不匹配时拒绝ID令牌
+	verifier, challenge, _ := auth.GeneratePKCE()
+	authURL, _ = provider.AuthCodeURL(ctx, "state", "nonce", challenge)
+	code, _, _ = authorize(idp.Client(), authURL)
+	_, err = provider.Exchange(ctx, code, verifier, "other-nonce")
+	testutil.Check("拒绝nonce不匹配的ID令牌", err != nil)
+
+	testutil.Finish()
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"chat_agent/internal/auth"
+	"chat_agent/internal/models"
+	"chat_agent/internal/service"
+	"chat_agent/test/internal/memrepo"
+	"chat_agent/test/internal/testutil"
+)
+
+// 验证密码策略、密码哈希、修改密码和连续登录失败后的锁定，不需要数据库：
+//
+//	go run ./test/password
+func main() {
+	// 创建上下文
+	ctx := context.Background()
+
+	// 密码策略
+	testutil.Check("接受字母加数字的密码", auth.ValidatePassword("password123") == nil)
+	testutil.Check("拒绝过短的密码", errors.Is(auth.ValidatePassword("abc123"), auth.ErrPasswordTooShort))
+	testutil.Check("拒绝超过72字节的密码", errors.Is(auth.ValidatePassword(strings.Repeat("a1", 37)), auth.ErrPasswordTooLong))
+	testutil.Check("拒绝纯字母的密码", errors.Is(auth.ValidatePassword("passwordonly"), auth.ErrPasswordTooWeak))
+	testutil.Check("拒绝纯数字的密码", errors.Is(auth.ValidatePassword("1234567890"), auth.ErrPasswordTooWeak))
+	testutil.Check("按字符而不是字节计算最小长度", auth.ValidatePassword("密码密码密码12") == nil && errors.Is(auth.ValidatePassword("密码12"), auth.ErrPasswordTooShort))
+
+	// 密码哈希
+	hashed, err := auth.HashPassword("password123")
+	testutil.Check("生成bcrypt哈希", err == nil && auth.IsPasswordHash(hashed) && hashed != "password123", err)
+	testutil.Check("校验正确的密码", auth.CheckPassword(hashed, "password123"))
+	testutil.Check("拒绝错误的密码", !auth.CheckPassword(hashed, "password124"))
+	testutil.Check("识别明文密码", !auth.IsPasswordHash("password"))
+	other, _ := auth.HashPassword("password123")
+	testutil.Check("相同密码的哈希加盐后不同", other != hashed)
+
+	random, err := auth.GenerateRandomPassword(4)
+	testutil.Check("随机密码符合密码策略", err == nil && len(random) == auth.MinPasswordLength && auth.ValidatePassword(random) == nil, random, err)
+
+	// 连续登录失败后锁定账号
+	userRepo := memrepo.NewUserRepository()
+	refreshRepo := memrepo.NewRefreshTokenRepository()
+	tokenManager := auth.NewTokenManager("test-secret", 15*time.Minute)
+
+	// 可控制的时钟，锁定到期不依赖真实的等待
+	clock := time.Now()
+	authService := service.NewAuthService(userRepo, refreshRepo, memrepo.NewPasswordResetTokenRepository(), tokenManager, nil, service.AuthOptions{
+		RefreshTTL:       time.Hour,
+		MaxLoginAttempts: 3,
+		LockoutDuration:  15 * time.Minute,
+		Now:              func() time.Time { return clock },
+	})
+	registered, err := authService.Register(ctx, &models.RegisterRequest{Username: "xiaoming", Email: "xiaoming@example.com", Password: "password123"})
+	testutil.Check("注册", err == nil, err)
+	if err != nil {
+		testutil.Finish()
+	}
+	_, err = authService.Register(ctx, &models.RegisterRequest{Username: "weak", Email: "weak@example.com", Password: "password"})
+	testutil.Check("注册时校验密码策略", errors.Is(err, auth.ErrPasswordTooWeak), err)
+	stored, _ := userRepo.GetByID(ctx, registered.User.ID)
+	testutil.Check("只保存密码的哈希", auth.IsPasswordHash(stored.Password) && stored.PasswordChangedAt != nil)
+
+	login := func(password string) error {
+		_, err := authService.Login(ctx, &models.LoginRequest{Username: "xiaoming", Password: password})
+		return err
+	}
+	login("wrong1234")
+	login("wrong1234")
+	stored, _ = userRepo.GetByID(ctx, registered.User.ID)
+	testutil.Check("记录连续失败次数", stored.FailedLoginCount == 2 && stored.LockedUntil == nil, stored.FailedLoginCount)
+	testutil.Check("登录成功清除失败次数", login("password123") == nil)
+	stored, _ = userRepo.GetByID(ctx, registered.User.ID)
+	testutil.Check("失败次数已清零", stored.FailedLoginCount == 0, stored.FailedLoginCount)
+
+	login("wrong1234")
+	login("wrong1234")
+	login("wrong1234")
+	stored, _ = userRepo.GetByID(ctx, registered.User.ID)
+	testutil.Check("达到上限后锁定账号", stored.IsLocked(clock), stored.LockedUntil)
+	err = login("password123")
+	testutil.Check("锁定期间正确的密码也不能登录", err != nil && strings.Contains(err.Error(), "锁定"), err)
+	clock = clock.Add(16 * time.Minute)
+	testutil.Check("锁定到期后可以登录", login("password123") == nil)
+
+	// 修改密码后旧密码和已签发的刷新令牌失效
+	session, _ := authService.Login(ctx, &models.LoginRequest{Username: "xiaoming", Password: "password123"})
+	err = authService.ChangePassword(ctx, registered.User.ID, &models.ChangePasswordRequest{OldPassword: "wrong1234", NewPassword: "newpassword456"})
+	testutil.Check("修改密码需要正确的旧密码", err != nil, err)
+	err = authService.ChangePassword(ctx, registered.User.ID, &models.ChangePasswordRequest{OldPassword: "password123", NewPassword: "password123"})
+	testutil.Check("新密码不能与旧密码相同", err != nil, err)
+	err = authService.ChangePassword(ctx, registered.User.ID, &models.ChangePasswordRequest{OldPassword: "password123", NewPassword: "newpassword"})
+	testutil.Check("新密码校验密码策略", errors.Is(err, auth.ErrPasswordTooWeak), err)
+	err = authService.ChangePassword(ctx, registered.User.ID, &models.ChangePasswordRequest{OldPassword: "password123", NewPassword: "newpassword456"})
+	testutil.Check("修改密码", err == nil, err)
+	testutil.Check("旧密码不能再登录", login("password123") != nil && login("newpassword456") == nil)
+	_, err = authService.Refresh(ctx, session.RefreshToken)
+	testutil.Check("修改密码吊销已签发的刷新令牌", err != nil, err)
+
+	testutil.Finish()
+}
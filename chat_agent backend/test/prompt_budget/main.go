@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"chat_agent/internal/ai"
+	"chat_agent/internal/models"
+	"chat_agent/test/internal/testutil"
+)
+
+// bpeRanks 构造一个小的tiktoken格式词表：256个单字节加上几个合并结果
+func bpeRanks() string {
+	var builder strings.Builder
+	for b := 0; b < 256; b++ {
+		fmt.Fprintf(&builder, "%s %d\n", base64.StdEncoding.EncodeToString([]byte{byte(b)}), b)
+	}
+	for rank, token := range []string{"he", "ll", "hell", "hello", " world"} {
+		fmt.Fprintf(&builder, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(token)), 256+rank)
+	}
+	return builder.String()
+}
+
+// 验证token计数、模型上下文长度和提示词的token预算，不需要网络：
+//
+//	go run ./test/prompt_budget
+func main() {
+	// 估算器：中文每字1个token，其他字符每4个1个token
+	estimate := ai.EstimateCounter{}
+	testutil.Check("估算中文token数", estimate.CountTokens("你好世界") == 4, estimate.CountTokens("你好世界"))
+	testutil.Check("估算英文token数", estimate.CountTokens("hello world!") == 3, estimate.CountTokens("hello world!"))
+
+	// tiktoken格式词表的BPE分词
+	bpe, err := ai.NewBPECounter(strings.NewReader(bpeRanks()))
+	testutil.Check("加载BPE词表", err == nil, err)
+	tokens := bpe.Encode("hello  world")
+	testutil.Check("预分词把最后一个空白留给下一个词", fmt.Sprint(tokens) == "[259 32 260]", tokens)
+	tokens = bpe.Encode("hellx")
+	testutil.Check("按rank合并字节对", fmt.Sprint(tokens) == "[258 120]", tokens)
+	testutil.Check("按字节计算中文", bpe.CountTokens("你好") == 6, bpe.CountTokens("你好"))
+	_, err = ai.NewBPECounter(strings.NewReader("not-base64!\n"))
+	testutil.Check("拒绝格式错误的词表", err != nil)
+
+	// 模型的上下文长度
+	windows := ai.NewContextWindows(map[string]int{"qwen*": 32768, "tiny": 2000}, 8192)
+	testutil.Check("按前缀查找上下文长度", windows.Lookup("gpt-4o-mini") == 128000 && windows.Lookup("claude-3-5-haiku") == 200000)
+	testutil.Check("按名称中的长度标注查找", windows.Lookup("doubao-1.5-pro-32k-250115") == 32768, windows.Lookup("doubao-1.5-pro-32k-250115"))
+	testutil.Check("配置覆盖默认值", windows.Lookup("qwen2.5:7b") == 32768 && windows.Lookup("ollama/qwen2.5:7b") == 32768)
+	testutil.Check("未知模型使用默认值", windows.Lookup("llama3") == 8192)
+
+	// 上下文很短时只装入最新的历史消息，置顶记忆总会装入
+	builder := ai.NewPromptTemplateWithOptions(ai.PromptOptions{ContextWindows: windows, CompletionReserve: 500})
+	star := &models.Star{Name: "测试明星"}
+	var history []models.Message
+	for i := 0; i < 60; i++ {
+		history = append(history, models.Message{
+			ID:         uint(i + 1),
+			SenderType: models.SenderTypeUser,
+			Content:    fmt.Sprintf("第%d条消息：今天去看了演唱会，现场的气氛特别好，大家一起合唱", i),
+		})
+	}
+	var recalled []string
+	for i := 0; i < 60; i++ {
+		recalled = append(recalled, fmt.Sprintf("用户提到的第%d件事情，需要在之后的对话中自然地提起", i))
+	}
+	messages := builder.BuildChatCompletionMessages(star, nil, "", history, "你好", []string{"用户的名字是小明"}, recalled, "gpt-4o", "tiny")
+	prompt := messages[len(messages)-1]["content"]
+	memoryPrompt := messages[len(messages)-2]["content"]
+	budget := builder.PromptBudget("gpt-4o", "tiny")
+	testutil.Check("按回退链中最短的上下文计算预算", budget == 1500, budget)
+	testutil.Check("提示词不超过预算", builder.CountMessageTokens(messages) <= budget, builder.CountMessageTokens(messages))
+	testutil.Check("装入最新的历史消息", strings.Contains(prompt, "第58条消息") && strings.Contains(prompt, "第59条消息") && !strings.Contains(prompt, "第0条消息") &&
+		strings.Index(prompt, "第58条消息") < strings.Index(prompt, "第59条消息"), prompt)
+	testutil.Check("当前消息在最后", strings.HasSuffix(prompt, "用户: 你好"))
+	testutil.Check("置顶记忆总会装入，召回的记忆最多使用一半预算", strings.Contains(memoryPrompt, "用户的名字是小明") &&
+		strings.Contains(memoryPrompt, "第0件事情") && !strings.Contains(memoryPrompt, "第59件事情"), memoryPrompt)
+
+	// 上下文足够长时装入全部历史消息和记忆
+	messages = builder.BuildChatCompletionMessages(star, nil, "", history, "你好", nil, recalled, "gpt-4o")
+	prompt = messages[len(messages)-1]["content"]
+	testutil.Check("上下文足够时装入全部历史", strings.Contains(prompt, "第0条消息") && strings.Contains(messages[len(messages)-2]["content"], "第59件事情"))
+
+	testutil.Finish()
+}
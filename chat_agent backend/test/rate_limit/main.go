@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	"chat_agent/internal/config"
+	"chat_agent/internal/middleware"
+	"chat_agent/test/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// failingLimiter 总是返回错误的限流器，模拟限流后端不可用
+type failingLimiter struct{}
+
+// Allow 实现RateLimiter接口
+func (failingLimiter) Allow(ctx context.Context, key string, limit middleware.RateLimit) (middleware.RateLimitResult, error) {
+	return middleware.RateLimitResult{}, errors.New("redis: connection refused")
+}
+
+// checkBucket 验证令牌桶的容量、补充速度和等待时间，key在每次运行时唯一
+func checkBucket(name string, limiter middleware.RateLimiter) {
+	ctx := context.Background()
+	key := fmt.Sprintf("bucket:%d", time.Now().UnixNano())
+	limit := middleware.RateLimit{PerMinute: 600, Burst: 3} // 每100毫秒补充一个令牌
+
+	var results []middleware.RateLimitResult
+	for i := 0; i < 4; i++ {
+		result, err := limiter.Allow(ctx, key, limit)
+		if err != nil {
+			testutil.Check(name+" 取令牌", false, err)
+			return
+		}
+		results = append(results, result)
+	}
+	testutil.Check(name+" 突发请求不超过桶容量", results[0].Allowed && results[1].Allowed && results[2].Allowed && !results[3].Allowed, results)
+	testutil.Check(name+" 返回剩余令牌数", results[0].Remaining == 2 && results[2].Remaining == 0, results)
+	testutil.Check(name+" 被拒绝时返回等待时间", results[3].RetryAfter > 0 && results[3].RetryAfter <= 100*time.Millisecond, results[3].RetryAfter)
+
+	time.Sleep(120 * time.Millisecond)
+	result, _ := limiter.Allow(ctx, key, limit)
+	testutil.Check(name+" 按速率补充令牌", result.Allowed, result)
+	result, _ = limiter.Allow(ctx, key, limit)
+	testutil.Check(name+" 只补充流逝时间对应的令牌", !result.Allowed, result)
+
+	time.Sleep(500 * time.Millisecond)
+	allowed := 0
+	for i := 0; i < 5; i++ {
+		if result, _ := limiter.Allow(ctx, key, limit); result.Allowed {
+			allowed++
+		}
+	}
+	testutil.Check(name+" 长时间空闲后最多补满桶容量", allowed == 3, allowed)
+
+	other, _ := limiter.Allow(ctx, key+":other", limit)
+	testutil.Check(name+" 不同的键互不影响", other.Allowed)
+	single, _ := limiter.Allow(ctx, key+":single", middleware.RateLimit{PerMinute: 60})
+	testutil.Check(name+" 未设置突发数时桶容量为1", single.Allowed && single.Remaining == 0, single)
+}
+
+// request 从指定IP请求路由，调用方的用户和API密钥通过查询参数user、key传入
+func request(engine *gin.Engine, path, ip string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = ip + ":12345"
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, req)
+	return recorder
+}
+
+// 验证令牌桶限流器和限流中间件，设置了REDIS_HOST等变量且Redis可用时同时验证Redis限流器：
+//
+//	go run ./test/rate_limit
+func main() {
+	checkBucket("进程内", middleware.NewMemoryRateLimiter())
+
+	// 中间件按API密钥、用户或IP区分调用方，不同接口的配额互不影响
+	gin.SetMode(gin.ReleaseMode)
+	engine := gin.New()
+	limiter := middleware.NewMemoryRateLimiter()
+	limit := middleware.RateLimit{PerMinute: 1, Burst: 2}
+	identify := func(c *gin.Context) {
+		if userID, err := strconv.Atoi(c.Query("user")); err == nil {
+			c.Set(middleware.ContextUserIDKey, uint(userID))
+		}
+		if keyID, err := strconv.Atoi(c.Query("key")); err == nil {
+			c.Set(middleware.ContextAPIKeyIDKey, uint(keyID))
+		}
+	}
+	ok := func(c *gin.Context) { c.String(http.StatusOK, "ok") }
+	engine.GET("/send", identify, middleware.RateLimitMiddleware(limiter, "send", limit), ok)
+	engine.GET("/search", identify, middleware.RateLimitMiddleware(limiter, "search", limit), ok)
+	engine.GET("/broken", middleware.RateLimitMiddleware(failingLimiter{}, "broken", limit), ok)
+	engine.GET("/unlimited", middleware.RateLimitMiddleware(limiter, "unlimited", middleware.RateLimit{}), ok)
+
+	first := request(engine, "/send?user=1", "192.0.2.1")
+	request(engine, "/send?user=1", "192.0.2.1")
+	limited := request(engine, "/send?user=1", "192.0.2.1")
+	testutil.Check("返回限流响应头", first.Code == 200 && first.Header().Get("X-RateLimit-Limit") == "1" && first.Header().Get("X-RateLimit-Remaining") == "1", first.Header())
+	retryAfter, _ := strconv.Atoi(limited.Header().Get("Retry-After"))
+	testutil.Check("超出限制返回429和Retry-After", limited.Code == http.StatusTooManyRequests && retryAfter >= 1 && retryAfter <= 60, limited.Code, limited.Header())
+	testutil.Check("同一IP的其他用户不受影响", request(engine, "/send?user=2", "192.0.2.1").Code == 200)
+	testutil.Check("同一用户的其他接口不受影响", request(engine, "/search?user=1", "192.0.2.1").Code == 200)
+	testutil.Check("API密钥优先于用户", request(engine, "/send?user=1&key=7", "192.0.2.1").Code == 200)
+
+	request(engine, "/send", "192.0.2.9")
+	request(engine, "/send", "192.0.2.9")
+	testutil.Check("未登录时按IP限流", request(engine, "/send", "192.0.2.9").Code == http.StatusTooManyRequests &&
+		request(engine, "/send", "192.0.2.10").Code == 200)
+
+	testutil.Check("限流后端不可用时放行", request(engine, "/broken", "192.0.2.1").Code == 200)
+	unlimited := 0
+	for i := 0; i < 10; i++ {
+		if request(engine, "/unlimited", "192.0.2.1").Code == 200 {
+			unlimited++
+		}
+	}
+	testutil.Check("未配置速率时不限流", unlimited == 10, unlimited)
+
+	// Redis限流器使用相同的令牌桶算法
+	if client, err := config.InitRedis(config.LoadConfig()); err != nil {
+		fmt.Printf("SKIP Redis限流器: %v\n", err)
+	} else {
+		checkBucket("Redis", middleware.NewRedisRateLimiter(client))
+		client.Close()
+	}
+
+	testutil.Finish()
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"chat_agent/internal/ai"
+	"chat_agent/internal/config"
+	"chat_agent/test/internal/memorycheck"
+	"chat_agent/test/internal/testutil"
+)
+
+// 对Redis记忆管理器执行与其他实现相同的行为检查，需要可用的Redis（REDIS_HOST等变量），不可用时跳过：
+//
+//	go run ./test/redis_memory
+func main() {
+	// 创建上下文
+	ctx := context.Background()
+	client, err := config.InitRedis(config.LoadConfig())
+	if err != nil {
+		testutil.Skip("Redis不可用", err)
+	}
+	defer client.Close()
+
+	// 每次运行使用独立的键前缀，遗留的键在一小时后过期
+	options := ai.RedisMemoryOptions{
+		KeyPrefix:    fmt.Sprintf("test:memory:%d", time.Now().UnixNano()),
+		ShortTermCap: memorycheck.ShortTermCap,
+		LongTermCap:  memorycheck.LongTermCap,
+		TTL:          time.Hour,
+		Decay:        memorycheck.Decay,
+	}
+	memorycheck.Run(ctx, ai.NewRedisMemoryManager(client, options), 1)
+
+	// 多个实例共享同一份记忆，各自写入的记忆ID不重复（重复时后写入的记忆会覆盖先写入的）
+	first := ai.NewRedisMemoryManager(client, options)
+	second := ai.NewRedisMemoryManager(client, options)
+	for i := 0; i < 10; i++ {
+		first.AddLongTermMemory(ctx, 10, fmt.Sprintf("实例一写入的记忆%d", i), 1.0)
+		second.AddLongTermMemory(ctx, 10, fmt.Sprintf("实例二写入的记忆%d", i), 1.0)
+	}
+	longTerm, err := second.GetLongTermMemory(ctx, 10, 100)
+	testutil.Check("多个实例共享记忆且ID不重复", err == nil && len(longTerm) == 20, len(longTerm), err)
+	first.DeleteChatMemory(ctx, 10)
+
+	testutil.Finish()
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"chat_agent/internal/ai"
+)
+
+// 使用本地哈希向量验证语义记忆检索，不需要网络：
+//
+//	go run ./test/semantic_memory
+func main() {
+	// 创建上下文
+	ctx := context.Background()
+
+	base := ai.NewInMemoryManager()
+	manager := ai.NewSemanticMemoryManager(base, ai.NewHashEmbedder(256), ai.NewVectorIndex(50), 0.1)
+
+	// 写入几条不同主题的长期记忆
+	memories := []string{
+		"用户的生日是5月3日",
+		"用户喜欢吃火锅和麻辣烫",
+		"用户养了一只叫豆豆的猫",
+		"用户在上海做程序员",
+		"The user plays guitar on weekends",
+	}
+	for _, content := range memories {
+		manager.AddLongTermMemory(ctx, 1, content, 1.0)
+	}
+
+	cases := []struct {
+		query    string
+		expected string
+	}{
+		{"你还记得我的生日吗", "用户的生日是5月3日"},
+		{"今天想吃火锅", "用户喜欢吃火锅和麻辣烫"},
+		{"我的猫最近不爱吃饭", "用户养了一只叫豆豆的猫"},
+		{"do you still play guitar", "The user plays guitar on weekends"},
+	}
+
+	failures := 0
+	for _, c := range cases {
+		result, err := manager.SearchMemory(ctx, 1, c.query, 1)
+		if err != nil || len(result) == 0 || result[0] != c.expected {
+			fmt.Printf("FAIL %s -> %v %v\n", c.query, result, err)
+			failures++
+			continue
+		}
+		fmt.Printf("PASS %s -> %s\n", c.query, result[0])
+	}
+
+	// 首次检索前写入的记忆也能被检索到（从底层记忆加载）
+	fresh := ai.NewSemanticMemoryManager(base, ai.NewHashEmbedder(256), ai.NewVectorIndex(50), 0.1)
+	result, _ := fresh.SearchMemory(ctx, 1, "猫叫什么", 1)
+	if len(result) == 0 || result[0] != "用户养了一只叫豆豆的猫" {
+		fmt.Printf("FAIL 从底层记忆加载索引: %v\n", result)
+		failures++
+	}
+
+	// 多个实例共享底层记忆时，其他实例写入、修改和删除的记忆立即反映到检索结果中
+	replica := ai.NewSemanticMemoryManager(base, ai.NewHashEmbedder(256), ai.NewVectorIndex(50), 0.1)
+	replica.AddLongTermMemory(ctx, 1, "用户在学习弹钢琴", 1.0)
+	result, _ = fresh.SearchMemory(ctx, 1, "钢琴练得怎么样", 1)
+	if len(result) == 0 || result[0] != "用户在学习弹钢琴" {
+		fmt.Printf("FAIL 检索其他实例写入的记忆: %v\n", result)
+		failures++
+	}
+
+	items, _ := replica.ListMemories(ctx, 1)
+	for _, item := range items {
+		switch item.Content {
+		case "用户养了一只叫豆豆的猫":
+			content := "用户养了一只叫豆豆的狗"
+			replica.UpdateMemory(ctx, 1, item.ID, ai.MemoryUpdate{Content: &content})
+		case "用户在学习弹钢琴":
+			replica.DeleteMemory(ctx, 1, item.ID)
+		}
+	}
+	result, _ = fresh.SearchMemory(ctx, 1, "我的狗叫什么", 1)
+	if len(result) == 0 || result[0] != "用户养了一只叫豆豆的狗" {
+		fmt.Printf("FAIL 检索其他实例修改的记忆: %v\n", result)
+		failures++
+	}
+	result, _ = fresh.SearchMemory(ctx, 1, "钢琴练得怎么样", 5)
+	for _, content := range result {
+		if content == "用户在学习弹钢琴" || content == "用户养了一只叫豆豆的猫" {
+			fmt.Printf("FAIL 不再检索到其他实例删除或修改前的记忆: %v\n", result)
+			failures++
+		}
+	}
+
+	// 同一文本的向量是确定的
+	a, _ := ai.NewHashEmbedder(64).Embed(ctx, []string{"你好世界"})
+	b, _ := ai.NewHashEmbedder(64).Embed(ctx, []string{"你好世界"})
+	if ai.CosineSimilarity(a[0], b[0]) < 0.9999 {
+		fmt.Println("FAIL 哈希向量不确定")
+		failures++
+	}
+
+	if failures > 0 {
+		os.Exit(1)
+	}
+	fmt.Println("\n测试完成！")
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"chat_agent/internal/ai"
+	"chat_agent/internal/api"
+	"chat_agent/internal/auth"
+	"chat_agent/internal/middleware"
+	"chat_agent/internal/models"
+	"chat_agent/internal/service"
+	"chat_agent/test/internal/memrepo"
+	"chat_agent/test/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// usageServer 返回固定用量的OpenAI兼容接口，用于验证客户端解析服务端返回的用量而不是估算
+func usageServer(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Stream bool `json:"stream"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+
+	if !req.Stream {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"u1","object":"chat.completion","model":"priced-model","choices":[{"index":0,"message":{"role":"assistant","content":"你好"},"finish_reason":"stop"}],"usage":{"prompt_tokens":11,"completion_tokens":7,"total_tokens":18}}`)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	fmt.Fprint(w, "data: {\"id\":\"u2\",\"object\":\"chat.completion.chunk\",\"model\":\"priced-model\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"你好\"}}]}\n\n")
+	fmt.Fprint(w, "data: {\"id\":\"u2\",\"object\":\"chat.completion.chunk\",\"model\":\"priced-model\",\"choices\":[],\"usage\":{\"prompt_tokens\":13,\"completion_tokens\":5,\"total_tokens\":18}}\n\n")
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+// send 以用户身份发送消息，返回HTTP状态码和响应码
+func send(engine *gin.Engine, path, accessToken string, chatID uint) (int, int) {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(fmt.Sprintf(`{"chat_id":%d,"content":"你好"}`, chatID)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, req)
+	var response api.Response
+	json.Unmarshal(recorder.Body.Bytes(), &response)
+	return recorder.Code, response.Code
+}
+
+// 验证用量的解析、记录、汇总、费用估算和额度检查，不需要数据库和模型密钥：
+//
+//	go run ./test/usage
+func main() {
+	// 创建上下文
+	ctx := context.Background()
+	now := time.Now()
+
+	// 客户端使用服务端返回的用量，流式调用也请求用量
+	server := httptest.NewServer(http.HandlerFunc(usageServer))
+	defer server.Close()
+	client := ai.NewOpenAIClient("fake", server.URL, "priced-model")
+	messages := []map[string]string{{"role": "user", "content": "你好"}}
+	completion, err := client.GenerateResponse(ctx, messages, ai.GenerateOptions{})
+	testutil.Check("解析服务端返回的用量", err == nil && completion.Usage == ai.TokenUsage{PromptTokens: 11, CompletionTokens: 7, TotalTokens: 18}, completion, err)
+	completion, err = client.GenerateStreamResponse(ctx, messages, ai.GenerateOptions{}, func(string) error { return nil })
+	testutil.Check("解析流式响应最后的用量", err == nil && completion.Usage == ai.TokenUsage{PromptTokens: 13, CompletionTokens: 5, TotalTokens: 18}, completion, err)
+	completion, _ = testutil.FakeLLM("你好呀").GenerateResponse(ctx, messages, ai.GenerateOptions{})
+	testutil.Check("服务端未返回用量时按文本估算", completion.Usage.PromptTokens > 0 && completion.Usage.CompletionTokens > 0 &&
+		completion.Usage.TotalTokens == completion.Usage.PromptTokens+completion.Usage.CompletionTokens, completion.Usage)
+
+	// 记录用量时按价格表估算费用，未配置的模型使用默认价格
+	usageRepo := memrepo.NewUsageRepository()
+	usageService := service.NewUsageService(usageRepo, service.UsageOptions{
+		DailyTokenQuota:   1000,
+		MonthlyTokenQuota: 5000,
+		Prices: map[string]models.ModelPrice{
+			"priced-model": {Prompt: 0.5, Completion: 2},
+			"gpt-4o*":      {Prompt: 1, Completion: 1},
+			"gpt-4o-mini*": {Prompt: 0.2, Completion: 0.2},
+			"*":            {Prompt: 0.1, Completion: 0.1},
+		},
+		Currency: "CNY",
+	})
+	record := &models.UsageRecord{UserID: 1, ChatID: 10, StarID: 100, Model: "priced-model", PromptTokens: 400, CompletionTokens: 100}
+	err = usageService.RecordUsage(ctx, record)
+	testutil.Check("补全总token数", err == nil && record.TotalTokens == 500, record.TotalTokens, err)
+	testutil.Check("按模型单价估算费用", math.Abs(record.EstimatedCost-0.4) < 1e-9, record.EstimatedCost)
+	record = &models.UsageRecord{UserID: 1, ChatID: 11, StarID: 101, Model: "other-model", PromptTokens: 100, CompletionTokens: 100, TotalTokens: 200}
+	usageService.RecordUsage(ctx, record)
+	testutil.Check("未配置的模型使用默认价格", math.Abs(record.EstimatedCost-0.02) < 1e-9, record.EstimatedCost)
+	for model, expected := range map[string]float64{"gpt-4o-2024-08-06": 2, "gpt-4o-mini-2024-07-18": 0.4, "openai/gpt-4o-mini": 0.4} {
+		prefixed := &models.UsageRecord{UserID: 4, Model: model, PromptTokens: 1000, CompletionTokens: 1000}
+		usageService.RecordUsage(ctx, prefixed)
+		testutil.Check("按最长的前缀匹配模型单价: "+model, math.Abs(prefixed.EstimatedCost-expected) < 1e-9, prefixed.EstimatedCost)
+	}
+	unpriced := &models.UsageRecord{UserID: 2, Model: "other-model", PromptTokens: 100}
+	service.NewUsageService(usageRepo, service.UsageOptions{}).RecordUsage(ctx, unpriced)
+	testutil.Check("没有价格表时费用为0", unpriced.EstimatedCost == 0, unpriced.EstimatedCost)
+
+	// 上个月的用量不计入额度
+	usageRepo.Create(ctx, &models.UsageRecord{UserID: 1, ChatID: 10, StarID: 100, Model: "priced-model", TotalTokens: 9000,
+		CreatedAt: time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).Add(-time.Hour)})
+	testutil.Check("未超出额度时可以调用模型", usageService.CheckQuota(ctx, 1) == nil)
+
+	// 达到每日额度后拒绝调用
+	usageService.RecordUsage(ctx, &models.UsageRecord{UserID: 1, ChatID: 10, StarID: 100, Model: "priced-model", PromptTokens: 200, CompletionTokens: 100})
+	err = usageService.CheckQuota(ctx, 1)
+	testutil.Check("达到每日额度返回ErrQuotaExceeded", errors.Is(err, service.ErrQuotaExceeded) && strings.Contains(err.Error(), "今日"), err)
+	testutil.Check("额度按用户计算", usageService.CheckQuota(ctx, 2) == nil)
+
+	// 今天之前、本月之内的用量只计入每月额度
+	monthly := service.NewUsageService(usageRepo, service.UsageOptions{MonthlyTokenQuota: 1000})
+	err = monthly.CheckQuota(ctx, 1)
+	testutil.Check("达到每月额度返回ErrQuotaExceeded", errors.Is(err, service.ErrQuotaExceeded) && strings.Contains(err.Error(), "本月"), err)
+	testutil.Check("额度为0时不限制", service.NewUsageService(usageRepo, service.UsageOptions{}).CheckQuota(ctx, 1) == nil)
+	if now.Day() > 1 {
+		usageRepo.Create(ctx, &models.UsageRecord{UserID: 3, TotalTokens: 800, CreatedAt: now.Add(-24 * time.Hour)})
+		daily := service.NewUsageService(usageRepo, service.UsageOptions{DailyTokenQuota: 500, MonthlyTokenQuota: 1000})
+		testutil.Check("昨天的用量不计入每日额度", daily.CheckQuota(ctx, 3) == nil)
+		usageRepo.Create(ctx, &models.UsageRecord{UserID: 3, TotalTokens: 200})
+		testutil.Check("昨天的用量计入每月额度", errors.Is(daily.CheckQuota(ctx, 3), service.ErrQuotaExceeded))
+	}
+
+	// 用量报告按模型、明星、会话汇总
+	report, err := usageService.GetUsageReport(ctx, 1, service.UsagePeriodMonth)
+	testutil.Check("生成本月的用量报告", err == nil && report.Period == service.UsagePeriodMonth && report.Currency == "CNY", err)
+	if err != nil {
+		testutil.Finish()
+	}
+	testutil.Check("汇总总量", report.TotalTokens == 1000 && report.Requests == 3 && report.PromptTokens == 700 && report.CompletionTokens == 300, report)
+	testutil.Check("汇总估算费用", math.Abs(report.EstimatedCost-0.72) < 1e-9, report.EstimatedCost)
+	testutil.Check("按模型汇总", len(report.ByModel) == 2 && report.ByModel[0].Key == "priced-model" && report.ByModel[0].Requests == 2, report.ByModel)
+	testutil.Check("按明星汇总", len(report.ByStar) == 2 && report.ByStar[0].Key == "100" && report.ByStar[0].TotalTokens == 800, report.ByStar)
+	testutil.Check("按会话汇总", len(report.ByChat) == 2 && report.ByChat[1].Key == "11", report.ByChat)
+	testutil.Check("每日额度使用情况", report.DailyQuota == models.QuotaStatus{Limit: 1000, Used: 1000, Remaining: 0}, report.DailyQuota)
+	testutil.Check("每月额度使用情况", report.MonthlyQuota == models.QuotaStatus{Limit: 5000, Used: 1000, Remaining: 4000}, report.MonthlyQuota)
+	_, err = usageService.GetUsageReport(ctx, 1, "week")
+	testutil.Check("拒绝无效的统计周期", err != nil, err)
+
+	// 额度用完时聊天服务在调用模型前返回ErrQuotaExceeded，接口返回HTTP 429
+	chatRepo := memrepo.NewChatRepository()
+	chat := &models.Chat{UserID: 1, StarID: 100}
+	chatRepo.Create(ctx, chat)
+	llmClient := testutil.FakeLLM("你好")
+	chatService := service.NewChatService(chatRepo, nil, nil, llmClient, ai.NewInMemoryManager(), ai.NewPromptTemplate(), usageService, nil, nil, nil, nil, service.GenerationLimits{})
+	_, err = chatService.SendMessage(ctx, 1, &models.SendMessageRequest{ChatID: chat.ID, Content: "你好"})
+	testutil.Check("发送消息前检查额度", errors.Is(err, service.ErrQuotaExceeded), err)
+	_, _, err = chatService.SendMessageStream(ctx, 1, &models.SendMessageRequest{ChatID: chat.ID, Content: "你好"})
+	testutil.Check("流式发送消息前检查额度", errors.Is(err, service.ErrQuotaExceeded), err)
+	testutil.Check("额度用完时不调用模型", len(llmClient.Calls()) == 0, llmClient.Calls())
+
+	gin.SetMode(gin.ReleaseMode)
+	engine := gin.New()
+	tokenManager := auth.NewTokenManager("test-secret", 15*time.Minute)
+	protected := engine.Group("")
+	protected.Use(middleware.AuthMiddleware(tokenManager, nil, nil))
+	api.NewChatHandler(chatService, nil).RegisterRoutes(protected, api.RateLimits{})
+	api.NewUsageHandler(usageService).RegisterRoutes(protected)
+	accessToken, _, _ := tokenManager.GenerateAccessToken(1, models.RoleUser)
+	status, code := send(engine, "/chats/messages", accessToken, chat.ID)
+	testutil.Check("消息接口返回429", status == http.StatusTooManyRequests && code == 429, status, code)
+	status, code = send(engine, "/chats/messages/stream", accessToken, chat.ID)
+	testutil.Check("流式消息接口返回429", status == http.StatusTooManyRequests && code == 429, status, code)
+
+	req := httptest.NewRequest(http.MethodGet, "/usage?period=day", nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, req)
+	var response struct {
+		Code int                `json:"code"`
+		Data models.UsageReport `json:"data"`
+	}
+	json.Unmarshal(recorder.Body.Bytes(), &response)
+	testutil.Check("用量接口返回当日报告", response.Code == 200 && response.Data.Period == service.UsagePeriodDay && response.Data.TotalTokens == 1000, recorder.Body.String())
+
+	testutil.Finish()
+}
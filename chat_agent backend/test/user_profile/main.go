@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"chat_agent/internal/ai"
+	"chat_agent/internal/models"
+	"chat_agent/internal/service"
+	"chat_agent/test/internal/testutil"
+)
+
+// MockProfileRepository 保存在内存中的用户画像仓库
+type MockProfileRepository struct {
+	facts   []models.ProfileFact
+	optOuts map[[2]uint]bool
+}
+
+// UpsertFact 实现ProfileRepository接口
+func (r *MockProfileRepository) UpsertFact(ctx context.Context, fact *models.ProfileFact) error {
+	for i := range r.facts {
+		if r.facts[i].UserID == fact.UserID && r.facts[i].Key == fact.Key {
+			r.facts[i] = *fact
+			return nil
+		}
+	}
+	fact.ID = uint(len(r.facts) + 1)
+	r.facts = append(r.facts, *fact)
+	return nil
+}
+
+// ListFacts 实现ProfileRepository接口
+func (r *MockProfileRepository) ListFacts(ctx context.Context, userID uint, limit int) ([]models.ProfileFact, error) {
+	var facts []models.ProfileFact
+	for _, fact := range r.facts {
+		if fact.UserID == userID {
+			facts = append(facts, fact)
+		}
+	}
+	return facts, nil
+}
+
+// DeleteFact 实现ProfileRepository接口
+func (r *MockProfileRepository) DeleteFact(ctx context.Context, userID, factID uint) (int64, error) {
+	for i, fact := range r.facts {
+		if fact.ID == factID && fact.UserID == userID {
+			r.facts = append(r.facts[:i], r.facts[i+1:]...)
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// DeleteFacts 实现ProfileRepository接口
+func (r *MockProfileRepository) DeleteFacts(ctx context.Context, userID uint) error {
+	var kept []models.ProfileFact
+	for _, fact := range r.facts {
+		if fact.UserID != userID {
+			kept = append(kept, fact)
+		}
+	}
+	r.facts = kept
+	return nil
+}
+
+// IsOptedOut 实现ProfileRepository接口
+func (r *MockProfileRepository) IsOptedOut(ctx context.Context, userID, starID uint) (bool, error) {
+	return r.optOuts[[2]uint{userID, starID}], nil
+}
+
+// SetOptOut 实现ProfileRepository接口
+func (r *MockProfileRepository) SetOptOut(ctx context.Context, userID, starID uint, optOut bool) error {
+	r.optOuts[[2]uint{userID, starID}] = optOut
+	return nil
+}
+
+// ListOptOuts 实现ProfileRepository接口
+func (r *MockProfileRepository) ListOptOuts(ctx context.Context, userID uint) ([]uint, error) {
+	var starIDs []uint
+	for key, optOut := range r.optOuts {
+		if optOut && key[0] == userID {
+			starIDs = append(starIDs, key[1])
+		}
+	}
+	return starIDs, nil
+}
+
+// 验证跨明星共享的用户画像和按明星关闭共享，不需要数据库：
+//
+//	go run ./test/user_profile
+func main() {
+	// 创建上下文
+	ctx := context.Background()
+	repo := &MockProfileRepository{optOuts: make(map[[2]uint]bool)}
+	profileService := service.NewProfileService(repo, nil)
+	extractor := ai.NewKeywordFactExtractor(ai.NewPromptTemplate(), nil, "")
+
+	// 在与明星1的会话中提取的事实，明星2也能看到
+	facts, _ := extractor.Extract(ctx, "我叫小明，我住在杭州")
+	profileService.RememberFacts(ctx, 1, 1, facts)
+	profile, err := profileService.FactsForStar(ctx, 1, 2)
+	testutil.Check("其他明星共享画像", err == nil && len(profile) == 2, profile, err)
+
+	// 相同键的事实替换旧事实
+	facts, _ = extractor.Extract(ctx, "我现在住在上海")
+	profileService.RememberFacts(ctx, 1, 2, facts)
+	profile, _ = profileService.FactsForStar(ctx, 1, 1)
+	joined := strings.Join(profile, "|")
+	testutil.Check("画像事实按键替换", len(profile) == 2 && strings.Contains(joined, "用户住在上海") && !strings.Contains(joined, "杭州"), profile)
+
+	// 画像不会泄露给其他用户
+	profile, _ = profileService.FactsForStar(ctx, 2, 1)
+	testutil.Check("画像按用户隔离", len(profile) == 0, profile)
+
+	// 关闭与明星3的共享后，明星3看不到画像，也不会向画像添加事实
+	repo.SetOptOut(ctx, 1, 3, true)
+	profile, _ = profileService.FactsForStar(ctx, 1, 3)
+	testutil.Check("关闭共享的明星看不到画像", len(profile) == 0, profile)
+	facts, _ = extractor.Extract(ctx, "我的生日是5月3日")
+	profileService.RememberFacts(ctx, 1, 3, facts)
+	profile, _ = profileService.FactsForStar(ctx, 1, 1)
+	testutil.Check("关闭共享的明星不添加事实", len(profile) == 2, profile)
+
+	response, _ := profileService.GetProfile(ctx, 1)
+	testutil.Check("画像列出关闭共享的明星", len(response.OptedOutStarIDs) == 1 && response.OptedOutStarIDs[0] == 3, response.OptedOutStarIDs)
+	testutil.Check("删除不存在的事实返回错误", profileService.DeleteFact(ctx, 2, response.Facts[0].ID) != nil)
+
+	// 画像作为单独的段落注入提示词，会话记忆中重复的内容不再列出
+	star := &models.Star{Name: "测试明星"}
+	messages := ai.NewPromptTemplate().BuildChatCompletionMessages(star, []string{"用户的名字是小明"}, "", nil, "你好",
+		nil, []string{"用户的名字是小明", "用户养了一只猫"})
+	testutil.Check("画像作为单独的系统消息", len(messages) == 4 && strings.Contains(messages[1]["content"], "你对这位粉丝的了解") &&
+		strings.Contains(messages[1]["content"], "用户的名字是小明"), messages)
+	testutil.Check("会话记忆不重复画像内容", len(messages) == 4 && !strings.Contains(messages[2]["content"], "小明") &&
+		strings.Contains(messages[2]["content"], "用户养了一只猫"), messages)
+
+	testutil.Finish()
+}